@@ -0,0 +1,37 @@
+package mrpcproxy
+
+import "encoding/json"
+
+// Encoding serializes the Request and Response envelopes sent over MRPC.
+type Encoding interface {
+	MarshalRequest(req *Request) ([]byte, error)
+	UnmarshalRequest(data []byte, req *Request) error
+	MarshalResponse(res *Response) ([]byte, error)
+	UnmarshalResponse(data []byte, res *Response) error
+}
+
+var (
+	// JSONEncoding encodes the envelopes as JSON. It is the default.
+	JSONEncoding Encoding = jsonEncoding{}
+	// ProtoEncoding encodes the envelopes in the Protocol Buffers wire format
+	// described by mrpcproxy.proto.
+	ProtoEncoding Encoding = protoEncoding{}
+)
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) MarshalRequest(req *Request) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+func (jsonEncoding) UnmarshalRequest(data []byte, req *Request) error {
+	return json.Unmarshal(data, req)
+}
+
+func (jsonEncoding) MarshalResponse(res *Response) ([]byte, error) {
+	return json.Marshal(res)
+}
+
+func (jsonEncoding) UnmarshalResponse(data []byte, res *Response) error {
+	return json.Unmarshal(data, res)
+}
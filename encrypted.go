@@ -0,0 +1,85 @@
+package mrpcproxy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrDecrypt is returned when an encrypted message can't be decrypted
+	// with its key.
+	ErrDecrypt = errors.New("message decryption failed")
+)
+
+// EncryptedMessage is the envelope of payloads encrypted with AES-GCM. The
+// key ID is authenticated as additional data.
+type EncryptedMessage struct {
+	KeyID      string `json:",omitempty"`
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// EncryptionKey is an AES key of 16, 24 or 32 bytes. Its ID is sent with the
+// messages so the key can be rotated.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+func (k EncryptionKey) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt returns the EncryptedMessage of payload.
+func Encrypt(payload []byte, key EncryptionKey) ([]byte, error) {
+	aead, err := key.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := EncryptedMessage{KeyID: key.ID, Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(msg.Nonce); err != nil {
+		return nil, err
+	}
+	msg.Ciphertext = aead.Seal(nil, msg.Nonce, payload, []byte(key.ID))
+
+	return json.Marshal(msg)
+}
+
+// Decrypt returns the payload of the EncryptedMessage data, decrypted with
+// the key of its ID among keys.
+func Decrypt(data []byte, keys ...EncryptionKey) ([]byte, error) {
+	msg := EncryptedMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.ID != msg.KeyID {
+			continue
+		}
+
+		aead, err := key.aead()
+		if err != nil {
+			return nil, err
+		}
+		if len(msg.Nonce) != aead.NonceSize() {
+			return nil, ErrDecrypt
+		}
+
+		payload, err := aead.Open(nil, msg.Nonce, msg.Ciphertext, []byte(key.ID))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		return payload, nil
+	}
+
+	return nil, ErrUnknownKey
+}
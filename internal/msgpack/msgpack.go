@@ -0,0 +1,329 @@
+// Package msgpack implements the subset of MessagePack used by the proxy
+// payload encoder and the envelope encoding.
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrMalformed is returned when reading invalid or truncated MessagePack.
+	ErrMalformed = errors.New("malformed msgpack")
+	// ErrType is returned when the value read has another type than expected.
+	ErrType = errors.New("unexpected msgpack type")
+)
+
+// Append appends the MessagePack encoding of a decoded JSON value to b.
+// Integral json.Number values are encoded as integers and the other numbers
+// as float64.
+func Append(b []byte, v interface{}) ([]byte, error) {
+	var err error
+	switch v := v.(type) {
+	case nil:
+		b = append(b, 0xc0)
+	case bool:
+		b = AppendBool(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return AppendInt(b, i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = AppendFloat(b, f)
+	case float64:
+		b = AppendFloat(b, v)
+	case string:
+		b = AppendString(b, v)
+	case []interface{}:
+		b = AppendArrayLen(b, len(v))
+		for _, item := range v {
+			if b, err = Append(b, item); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = AppendMapLen(b, len(v))
+		for _, k := range keys {
+			b = AppendString(b, k)
+			if b, err = Append(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return b, nil
+}
+
+// AppendBool appends a boolean.
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// AppendFloat appends a float64.
+func AppendFloat(b []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+// AppendString appends a string.
+func AppendString(b []byte, s string) []byte {
+	b = appendLen(b, len(s), 0xa0, 0x1f, 0xd9, 0xda, 0xdb)
+	return append(b, s...)
+}
+
+// AppendBin appends a byte array.
+func AppendBin(b []byte, p []byte) []byte {
+	b = appendLen(b, len(p), 0, -1, 0xc4, 0xc5, 0xc6)
+	return append(b, p...)
+}
+
+// AppendArrayLen appends the header of an array of n items.
+func AppendArrayLen(b []byte, n int) []byte {
+	return appendLen(b, n, 0x90, 0x0f, 0, 0xdc, 0xdd)
+}
+
+// AppendMapLen appends the header of a map of n pairs.
+func AppendMapLen(b []byte, n int) []byte {
+	return appendLen(b, n, 0x80, 0x0f, 0, 0xde, 0xdf)
+}
+
+// appendLen appends the header of a string, array or map of length n
+// using the fix format up to fixMax and the 8, 16 or 32 bit formats above.
+// Arrays and maps have no 8 bit format, passed as 0.
+func appendLen(b []byte, n int, fix byte, fixMax int, f8, f16, f32 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(b, fix|byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		return append(b, f8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, f16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, f32), uint32(n))
+	}
+}
+
+// AppendInt appends i in the smallest MessagePack integer format.
+func AppendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(b, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+// Reader reads MessagePack values from a buffer.
+type Reader struct {
+	b []byte
+}
+
+// NewReader returns a Reader of b.
+func NewReader(b []byte) *Reader {
+	return &Reader{b}
+}
+
+// Len returns the number of unread bytes.
+func (r *Reader) Len() int {
+	return len(r.b)
+}
+
+func (r *Reader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.b) < n {
+		return nil, ErrMalformed
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p, nil
+}
+
+// uint reads a big endian unsigned integer of size bytes.
+func (r *Reader) uint(size int) (uint64, error) {
+	p, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for _, c := range p {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// header reads the type byte of the next value.
+func (r *Reader) header() (byte, error) {
+	p, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// length reads the length of a string, bin, array or map with the fix format
+// mask fix and the 8, 16 and 32 bit formats f8, f16 and f32.
+func (r *Reader) length(fix, fixMask, f8, f16, f32 byte) (int, error) {
+	h, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	switch {
+	case fixMask != 0 && h&^fixMask == fix:
+		return int(h & fixMask), nil
+	case f8 != 0 && h == f8:
+		n, err = r.uint(1)
+	case h == f16:
+		n, err = r.uint(2)
+	case h == f32:
+		n, err = r.uint(4)
+	default:
+		return 0, ErrType
+	}
+	return int(n), err
+}
+
+// ArrayLen reads the header of an array.
+func (r *Reader) ArrayLen() (int, error) {
+	return r.length(0x90, 0x0f, 0, 0xdc, 0xdd)
+}
+
+// MapLen reads the header of a map.
+func (r *Reader) MapLen() (int, error) {
+	return r.length(0x80, 0x0f, 0, 0xde, 0xdf)
+}
+
+// String reads a string.
+func (r *Reader) String() (string, error) {
+	n, err := r.length(0xa0, 0x1f, 0xd9, 0xda, 0xdb)
+	if err != nil {
+		return "", err
+	}
+	p, err := r.next(n)
+	return string(p), err
+}
+
+// Bin reads a byte array. Strings are accepted as well.
+func (r *Reader) Bin() ([]byte, error) {
+	if len(r.b) > 0 && (r.b[0]&0xe0 == 0xa0 || r.b[0] >= 0xd9 && r.b[0] <= 0xdb) {
+		s, err := r.String()
+		return []byte(s), err
+	}
+
+	n, err := r.length(0, 0, 0xc4, 0xc5, 0xc6)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.next(n)
+	return append([]byte(nil), p...), err
+}
+
+// Bool reads a boolean.
+func (r *Reader) Bool() (bool, error) {
+	h, err := r.header()
+	switch {
+	case err != nil:
+		return false, err
+	case h == 0xc3:
+		return true, nil
+	case h == 0xc2:
+		return false, nil
+	default:
+		return false, ErrType
+	}
+}
+
+// Int reads an integer of any format.
+func (r *Reader) Int() (int64, error) {
+	h, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case h <= 0x7f:
+		return int64(h), nil
+	case h >= 0xe0:
+		return int64(int8(h)), nil
+	case h >= 0xcc && h <= 0xcf:
+		v, err := r.uint(1 << (h - 0xcc))
+		return int64(v), err
+	case h >= 0xd0 && h <= 0xd3:
+		size := 1 << (h - 0xd0)
+		v, err := r.uint(size)
+		shift := uint(64 - 8*size)
+		return int64(v<<shift) >> shift, err
+	default:
+		return 0, ErrType
+	}
+}
+
+// Skip reads and discards the next value.
+func (r *Reader) Skip() error {
+	if len(r.b) == 0 {
+		return ErrMalformed
+	}
+
+	h := r.b[0]
+	switch {
+	case h <= 0x7f || h >= 0xe0 || h >= 0xcc && h <= 0xd3:
+		_, err := r.Int()
+		return err
+	case h == 0xc0 || h == 0xc2 || h == 0xc3:
+		_, err := r.next(1)
+		return err
+	case h == 0xca || h == 0xcb:
+		_, err := r.next(1 + 4<<(h-0xca))
+		return err
+	case h&0xe0 == 0xa0 || h >= 0xd9 && h <= 0xdb || h >= 0xc4 && h <= 0xc6:
+		_, err := r.Bin()
+		return err
+	case h&0xf0 == 0x90 || h == 0xdc || h == 0xdd:
+		n, err := r.ArrayLen()
+		for i := 0; err == nil && i < n; i++ {
+			err = r.Skip()
+		}
+		return err
+	case h&0xf0 == 0x80 || h == 0xde || h == 0xdf:
+		n, err := r.MapLen()
+		for i := 0; err == nil && i < 2*n; i++ {
+			err = r.Skip()
+		}
+		return err
+	default:
+		return ErrType
+	}
+}
@@ -0,0 +1,82 @@
+package msgpack
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	ints := []int64{0, 1, 0x7f, 0x80, 0xff, 0x100, math.MaxUint16 + 1, math.MaxUint32 + 1, -1, -32, -33, -129, -32769, math.MinInt32 - 1, math.MinInt64, math.MaxInt64}
+	for i, v := range ints {
+		t.Run(fmt.Sprintf("Int%v", i), func(t *testing.T) {
+			got, err := NewReader(AppendInt(nil, v)).Int()
+			if err != nil || got != v {
+				t.Errorf("Unexpected int: got %v %v want %v", got, err, v)
+			}
+		})
+	}
+
+	lengths := []int{0, 15, 16, 31, 32, 255, 256, math.MaxUint16 + 1}
+	for i, n := range lengths {
+		t.Run(fmt.Sprintf("Len%v", i), func(t *testing.T) {
+			s := strings.Repeat("a", n)
+			if got, err := NewReader(AppendString(nil, s)).String(); err != nil || got != s {
+				t.Errorf("Unexpected string of %v: %v", n, err)
+			}
+			if got, err := NewReader(AppendBin(nil, []byte(s))).Bin(); err != nil || string(got) != s {
+				t.Errorf("Unexpected bin of %v: %v", n, err)
+			}
+			if got, err := NewReader(AppendArrayLen(nil, n)).ArrayLen(); err != nil || got != n {
+				t.Errorf("Unexpected array length %v: %v", got, err)
+			}
+			if got, err := NewReader(AppendMapLen(nil, n)).MapLen(); err != nil || got != n {
+				t.Errorf("Unexpected map length %v: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	v, _ := Append(nil, map[string]interface{}{
+		"a": []interface{}{nil, true, false, 1.5, "s", map[string]interface{}{"b": -40000.0}},
+	})
+	b := append(AppendBin(v, []byte("bin")), 0x01)
+
+	r := NewReader(b)
+	if err := r.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := r.Int(); err != nil || i != 1 || r.Len() != 0 {
+		t.Errorf("Unexpected value after skipping: %v %v", i, err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	cases := []struct {
+		b    []byte
+		read func(r *Reader) error
+		err  error
+	}{
+		{nil, func(r *Reader) error { _, err := r.Int(); return err }, ErrMalformed},
+		{[]byte{0xa1}, func(r *Reader) error { _, err := r.String(); return err }, ErrMalformed},
+		{[]byte{0xcd, 0x01}, func(r *Reader) error { _, err := r.Int(); return err }, ErrMalformed},
+		{[]byte{0xa1, 'a'}, func(r *Reader) error { _, err := r.Int(); return err }, ErrType},
+		{[]byte{0x01}, func(r *Reader) error { _, err := r.String(); return err }, ErrType},
+		{[]byte{0x01}, func(r *Reader) error { _, err := r.Bool(); return err }, ErrType},
+		{[]byte{0x91}, func(r *Reader) error { return r.Skip() }, ErrMalformed},
+		{[]byte{0xd4, 0x00, 0x00}, func(r *Reader) error { return r.Skip() }, ErrType},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if err := tc.read(NewReader(tc.b)); err != tc.err {
+				t.Errorf("Unexpected error: got %v want %v", err, tc.err)
+			}
+		})
+	}
+}
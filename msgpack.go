@@ -0,0 +1,446 @@
+package mrpcproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/miracl/mrpcproxy/internal/msgpack"
+)
+
+// MessagePackEncoding encodes the envelopes as MessagePack maps keyed by the
+// field names of the JSON envelopes. Empty fields are left out, Msg is a
+// byte array and Claims hold the JSON encoded claims. Cookies are strings in
+// name=value form for requests and in Set-Cookie form for responses.
+var MessagePackEncoding Encoding = msgpackEncoding{}
+
+var (
+	// ErrMalformedMessagePack is returned when decoding an invalid
+	// MessagePack envelope.
+	ErrMalformedMessagePack = errors.New("malformed msgpack message")
+)
+
+type msgpackEncoding struct{}
+
+// msgpackMap builds a map of the non empty fields.
+type msgpackMap struct {
+	b []byte
+	n int
+}
+
+func (m *msgpackMap) key(k string) {
+	m.b = msgpack.AppendString(m.b, k)
+	m.n++
+}
+
+func (m *msgpackMap) string(k, v string) {
+	if v != "" {
+		m.key(k)
+		m.b = msgpack.AppendString(m.b, v)
+	}
+}
+
+func (m *msgpackMap) strings(k string, vs []string) {
+	if len(vs) > 0 {
+		m.key(k)
+		m.b = appendMsgpackStrings(m.b, vs)
+	}
+}
+
+func (m *msgpackMap) bin(k string, v []byte) {
+	if len(v) > 0 {
+		m.key(k)
+		m.b = msgpack.AppendBin(m.b, v)
+	}
+}
+
+func (m *msgpackMap) int(k string, v int64) {
+	if v != 0 {
+		m.key(k)
+		m.b = msgpack.AppendInt(m.b, v)
+	}
+}
+
+func (m *msgpackMap) values(k string, values map[string][]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m.key(k)
+	m.b = msgpack.AppendMapLen(m.b, len(values))
+	for _, k := range keys {
+		m.b = msgpack.AppendString(m.b, k)
+		m.b = appendMsgpackStrings(m.b, values[k])
+	}
+}
+
+func (m *msgpackMap) stringMap(k string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m.key(k)
+	m.b = msgpack.AppendMapLen(m.b, len(values))
+	for _, k := range keys {
+		m.b = msgpack.AppendString(m.b, k)
+		m.b = msgpack.AppendString(m.b, values[k])
+	}
+}
+
+func (m *msgpackMap) sub(k string, v *msgpackMap) {
+	m.key(k)
+	m.b = append(m.b, v.bytes()...)
+}
+
+func (m *msgpackMap) bytes() []byte {
+	return append(msgpack.AppendMapLen(nil, m.n), m.b...)
+}
+
+func appendMsgpackStrings(b []byte, vs []string) []byte {
+	b = msgpack.AppendArrayLen(b, len(vs))
+	for _, v := range vs {
+		b = msgpack.AppendString(b, v)
+	}
+	return b
+}
+
+func (msgpackEncoding) MarshalRequest(req *Request) ([]byte, error) {
+	m := &msgpackMap{}
+	m.string("RequestID", req.RequestID)
+	m.int("Timestamp", req.Timestamp)
+	m.int("Hops", int64(req.Hops))
+	m.string("Topic", req.Topic)
+	m.string("Action", req.Action)
+	m.string("IPAddress", req.IPAddress)
+	m.values("Params", req.Params)
+	m.bin("Msg", req.Msg)
+	m.values("Headers", req.Headers)
+	if req.Head {
+		m.key("Head")
+		m.b = msgpack.AppendBool(m.b, true)
+	}
+	m.string("SchemaVersion", req.SchemaVersion)
+
+	if req.Claims != nil {
+		claims, err := json.Marshal(req.Claims)
+		if err != nil {
+			return nil, err
+		}
+		m.bin("Claims", claims)
+	}
+
+	if c := req.ClientCert; c != nil {
+		cert := &msgpackMap{}
+		cert.string("Subject", c.Subject)
+		cert.string("Issuer", c.Issuer)
+		cert.string("SerialNumber", c.SerialNumber)
+		cert.strings("DNSNames", c.DNSNames)
+		cert.strings("EmailAddresses", c.EmailAddresses)
+		cert.strings("URIs", c.URIs)
+		cert.string("Fingerprint", c.Fingerprint)
+		m.sub("ClientCert", cert)
+	}
+
+	if len(req.Cookies) > 0 {
+		cookies := make([]string, len(req.Cookies))
+		for i, c := range req.Cookies {
+			cookies[i] = c.Name + "=" + c.Value
+		}
+		m.strings("Cookies", cookies)
+	}
+
+	if len(req.Files) > 0 {
+		m.key("Files")
+		m.b = msgpack.AppendArrayLen(m.b, len(req.Files))
+		for _, f := range req.Files {
+			file := &msgpackMap{}
+			file.string("Field", f.Field)
+			file.string("Filename", f.Filename)
+			file.string("ContentType", f.ContentType)
+			file.int("Size", f.Size)
+			file.string("Key", f.Key)
+			m.b = append(m.b, file.bytes()...)
+		}
+	}
+	m.string("Tenant", req.Tenant)
+	m.int("Deadline", req.Deadline)
+	m.stringMap("Meta", req.Meta)
+	m.stringMap("PathParams", req.PathParams)
+	m.values("QueryParams", req.QueryParams)
+
+	return m.bytes(), nil
+}
+
+func (msgpackEncoding) UnmarshalRequest(data []byte, req *Request) error {
+	r := msgpack.NewReader(data)
+	err := readMsgpackMap(r, func(key string) error {
+		var err error
+		switch key {
+		case "RequestID":
+			req.RequestID, err = r.String()
+		case "Timestamp":
+			req.Timestamp, err = r.Int()
+		case "Hops":
+			var hops int64
+			hops, err = r.Int()
+			req.Hops = int(hops)
+		case "Topic":
+			req.Topic, err = r.String()
+		case "Action":
+			req.Action, err = r.String()
+		case "IPAddress":
+			req.IPAddress, err = r.String()
+		case "Params":
+			req.Params, err = readMsgpackValues(r)
+		case "Msg":
+			req.Msg, err = r.Bin()
+		case "Headers":
+			req.Headers, err = readMsgpackValues(r)
+		case "Head":
+			req.Head, err = r.Bool()
+		case "SchemaVersion":
+			req.SchemaVersion, err = r.String()
+		case "Claims":
+			var claims []byte
+			if claims, err = r.Bin(); err == nil {
+				err = json.Unmarshal(claims, &req.Claims)
+			}
+		case "ClientCert":
+			req.ClientCert = &ClientCert{}
+			err = readMsgpackClientCert(r, req.ClientCert)
+		case "Cookies":
+			var cookies []string
+			cookies, err = readMsgpackStrings(r)
+			for _, c := range cookies {
+				name, value, _ := strings.Cut(c, "=")
+				req.Cookies = append(req.Cookies, &http.Cookie{Name: name, Value: value})
+			}
+		case "Files":
+			var n int
+			n, err = r.ArrayLen()
+			for i := 0; err == nil && i < n; i++ {
+				f := File{}
+				err = readMsgpackFile(r, &f)
+				req.Files = append(req.Files, f)
+			}
+		case "Tenant":
+			req.Tenant, err = r.String()
+		case "Deadline":
+			req.Deadline, err = r.Int()
+		case "Meta":
+			req.Meta, err = readMsgpackStringMap(r)
+		case "PathParams":
+			req.PathParams, err = readMsgpackStringMap(r)
+		case "QueryParams":
+			req.QueryParams, err = readMsgpackValues(r)
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+	return msgpackError(r, err)
+}
+
+func (msgpackEncoding) MarshalResponse(res *Response) ([]byte, error) {
+	m := &msgpackMap{}
+	m.string("RequestID", res.RequestID)
+	m.int("Code", int64(res.Code))
+	m.bin("Msg", res.Msg)
+	m.values("Headers", res.Headers)
+	if len(res.Cookies) > 0 {
+		cookies := make([]string, len(res.Cookies))
+		for i, c := range res.Cookies {
+			cookies[i] = c.String()
+		}
+		m.strings("Cookies", cookies)
+	}
+	m.string("Location", res.Location)
+	m.string("BodyRef", res.BodyRef)
+	m.string("Next", res.Next)
+	if e := res.Error; e != nil {
+		msg := &msgpackMap{}
+		msg.string("Code", e.Code)
+		msg.string("Message", e.Message)
+		msg.bin("Details", e.Details)
+		m.sub("Error", msg)
+	}
+	m.int("LastModified", res.LastModified)
+	return m.bytes(), nil
+}
+
+func (msgpackEncoding) UnmarshalResponse(data []byte, res *Response) error {
+	r := msgpack.NewReader(data)
+	err := readMsgpackMap(r, func(key string) error {
+		var err error
+		switch key {
+		case "RequestID":
+			res.RequestID, err = r.String()
+		case "Code":
+			var code int64
+			code, err = r.Int()
+			res.Code = int(code)
+		case "Msg":
+			res.Msg, err = r.Bin()
+		case "Headers":
+			res.Headers, err = readMsgpackValues(r)
+		case "Cookies":
+			var cookies []string
+			cookies, err = readMsgpackStrings(r)
+			for i := 0; err == nil && i < len(cookies); i++ {
+				var c *http.Cookie
+				if c, err = http.ParseSetCookie(cookies[i]); err == nil {
+					res.Cookies = append(res.Cookies, c)
+				}
+			}
+		case "Location":
+			res.Location, err = r.String()
+		case "BodyRef":
+			res.BodyRef, err = r.String()
+		case "Next":
+			res.Next, err = r.String()
+		case "Error":
+			res.Error = &Error{}
+			err = readMsgpackError(r, res.Error)
+		case "LastModified":
+			res.LastModified, err = r.Int()
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+	return msgpackError(r, err)
+}
+
+func readMsgpackClientCert(r *msgpack.Reader, c *ClientCert) error {
+	return readMsgpackMap(r, func(key string) error {
+		var err error
+		switch key {
+		case "Subject":
+			c.Subject, err = r.String()
+		case "Issuer":
+			c.Issuer, err = r.String()
+		case "SerialNumber":
+			c.SerialNumber, err = r.String()
+		case "DNSNames":
+			c.DNSNames, err = readMsgpackStrings(r)
+		case "EmailAddresses":
+			c.EmailAddresses, err = readMsgpackStrings(r)
+		case "URIs":
+			c.URIs, err = readMsgpackStrings(r)
+		case "Fingerprint":
+			c.Fingerprint, err = r.String()
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+}
+
+func readMsgpackError(r *msgpack.Reader, e *Error) error {
+	return readMsgpackMap(r, func(key string) error {
+		var err error
+		switch key {
+		case "Code":
+			e.Code, err = r.String()
+		case "Message":
+			e.Message, err = r.String()
+		case "Details":
+			e.Details, err = r.Bin()
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+}
+
+func readMsgpackFile(r *msgpack.Reader, f *File) error {
+	return readMsgpackMap(r, func(key string) error {
+		var err error
+		switch key {
+		case "Field":
+			f.Field, err = r.String()
+		case "Filename":
+			f.Filename, err = r.String()
+		case "ContentType":
+			f.ContentType, err = r.String()
+		case "Size":
+			f.Size, err = r.Int()
+		case "Key":
+			f.Key, err = r.String()
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+}
+
+// readMsgpackMap reads a map with string keys, calling fn to read the value
+// of each key.
+func readMsgpackMap(r *msgpack.Reader, fn func(key string) error) error {
+	n, err := r.MapLen()
+	for i := 0; err == nil && i < n; i++ {
+		var key string
+		if key, err = r.String(); err == nil {
+			err = fn(key)
+		}
+	}
+	return err
+}
+
+func readMsgpackStrings(r *msgpack.Reader) ([]string, error) {
+	n, err := r.ArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	vs := make([]string, n)
+	for i := range vs {
+		if vs[i], err = r.String(); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+func readMsgpackValues(r *msgpack.Reader) (map[string][]string, error) {
+	values := map[string][]string{}
+	err := readMsgpackMap(r, func(key string) error {
+		vs, err := readMsgpackStrings(r)
+		values[key] = vs
+		return err
+	})
+	return values, err
+}
+
+func readMsgpackStringMap(r *msgpack.Reader) (map[string]string, error) {
+	values := map[string]string{}
+	err := readMsgpackMap(r, func(key string) error {
+		v, err := r.String()
+		values[key] = v
+		return err
+	})
+	return values, err
+}
+
+// msgpackError returns ErrMalformedMessagePack for invalid MessagePack or
+// data left after the envelope.
+func msgpackError(r *msgpack.Reader, err error) error {
+	if err == msgpack.ErrMalformed || err == msgpack.ErrType || err == nil && r.Len() > 0 {
+		return ErrMalformedMessagePack
+	}
+	return err
+}
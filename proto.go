@@ -0,0 +1,438 @@
+package mrpcproxy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Protocol Buffers wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var (
+	// ErrMalformedProto is returned when decoding an invalid Protocol Buffers
+	// message.
+	ErrMalformedProto = errors.New("malformed protobuf message")
+)
+
+// protoEncoding encodes the envelopes as the messages of mrpcproxy.proto.
+type protoEncoding struct{}
+
+func (protoEncoding) MarshalRequest(req *Request) ([]byte, error) {
+	var b []byte
+	b = appendProtoString(b, 1, req.RequestID)
+	b = appendProtoVarint(b, 2, uint64(req.Timestamp))
+	b = appendProtoVarint(b, 3, uint64(req.Hops))
+	b = appendProtoString(b, 4, req.Topic)
+	b = appendProtoString(b, 5, req.Action)
+	b = appendProtoString(b, 6, req.IPAddress)
+	b = appendProtoValues(b, 7, req.Params)
+	b = appendProtoBytes(b, 8, req.Msg)
+	b = appendProtoValues(b, 9, req.Headers)
+	if req.Head {
+		b = appendProtoVarint(b, 10, 1)
+	}
+	b = appendProtoString(b, 11, req.SchemaVersion)
+
+	if req.Claims != nil {
+		claims, err := json.Marshal(req.Claims)
+		if err != nil {
+			return nil, err
+		}
+		b = appendProtoBytes(b, 12, claims)
+	}
+
+	if c := req.ClientCert; c != nil {
+		var cert []byte
+		cert = appendProtoString(cert, 1, c.Subject)
+		cert = appendProtoString(cert, 2, c.Issuer)
+		cert = appendProtoString(cert, 3, c.SerialNumber)
+		cert = appendProtoStrings(cert, 4, c.DNSNames)
+		cert = appendProtoStrings(cert, 5, c.EmailAddresses)
+		cert = appendProtoStrings(cert, 6, c.URIs)
+		cert = appendProtoString(cert, 7, c.Fingerprint)
+		b = appendProtoMessage(b, 13, cert)
+	}
+
+	for _, c := range req.Cookies {
+		b = appendProtoMessage(b, 14, []byte(c.Name+"="+c.Value))
+	}
+
+	for _, f := range req.Files {
+		var file []byte
+		file = appendProtoString(file, 1, f.Field)
+		file = appendProtoString(file, 2, f.Filename)
+		file = appendProtoString(file, 3, f.ContentType)
+		file = appendProtoVarint(file, 4, uint64(f.Size))
+		file = appendProtoString(file, 5, f.Key)
+		b = appendProtoMessage(b, 15, file)
+	}
+	b = appendProtoString(b, 16, req.Tenant)
+	b = appendProtoVarint(b, 17, uint64(req.Deadline))
+	b = appendProtoMap(b, 18, req.Meta)
+	b = appendProtoMap(b, 19, req.PathParams)
+	b = appendProtoValues(b, 20, req.QueryParams)
+
+	return b, nil
+}
+
+func (protoEncoding) UnmarshalRequest(data []byte, req *Request) error {
+	return walkProto(data, func(field int, v uint64, b []byte) error {
+		var err error
+		switch field {
+		case 1:
+			req.RequestID = string(b)
+		case 2:
+			req.Timestamp = int64(v)
+		case 3:
+			req.Hops = int(v)
+		case 4:
+			req.Topic = string(b)
+		case 5:
+			req.Action = string(b)
+		case 6:
+			req.IPAddress = string(b)
+		case 7:
+			if req.Params == nil {
+				req.Params = map[string][]string{}
+			}
+			err = readProtoValues(b, req.Params)
+		case 8:
+			req.Msg = append([]byte(nil), b...)
+		case 9:
+			if req.Headers == nil {
+				req.Headers = http.Header{}
+			}
+			err = readProtoValues(b, req.Headers)
+		case 10:
+			req.Head = v != 0
+		case 11:
+			req.SchemaVersion = string(b)
+		case 12:
+			err = json.Unmarshal(b, &req.Claims)
+		case 13:
+			req.ClientCert = &ClientCert{}
+			err = readProtoClientCert(b, req.ClientCert)
+		case 14:
+			name, value, _ := strings.Cut(string(b), "=")
+			req.Cookies = append(req.Cookies, &http.Cookie{Name: name, Value: value})
+		case 15:
+			f := File{}
+			err = readProtoFile(b, &f)
+			req.Files = append(req.Files, f)
+		case 16:
+			req.Tenant = string(b)
+		case 17:
+			req.Deadline = int64(v)
+		case 18:
+			if req.Meta == nil {
+				req.Meta = map[string]string{}
+			}
+			err = readProtoMap(b, req.Meta)
+		case 19:
+			if req.PathParams == nil {
+				req.PathParams = map[string]string{}
+			}
+			err = readProtoMap(b, req.PathParams)
+		case 20:
+			if req.QueryParams == nil {
+				req.QueryParams = map[string][]string{}
+			}
+			err = readProtoValues(b, req.QueryParams)
+		}
+		return err
+	})
+}
+
+func (protoEncoding) MarshalResponse(res *Response) ([]byte, error) {
+	var b []byte
+	b = appendProtoString(b, 1, res.RequestID)
+	b = appendProtoVarint(b, 2, uint64(res.Code))
+	b = appendProtoBytes(b, 3, res.Msg)
+	b = appendProtoValues(b, 4, res.Headers)
+	for _, c := range res.Cookies {
+		b = appendProtoMessage(b, 5, []byte(c.String()))
+	}
+	b = appendProtoString(b, 6, res.Location)
+	b = appendProtoString(b, 7, res.BodyRef)
+	b = appendProtoString(b, 8, res.Next)
+	if e := res.Error; e != nil {
+		var msg []byte
+		msg = appendProtoString(msg, 1, e.Code)
+		msg = appendProtoString(msg, 2, e.Message)
+		msg = appendProtoBytes(msg, 3, e.Details)
+		b = appendProtoMessage(b, 9, msg)
+	}
+	b = appendProtoVarint(b, 10, uint64(res.LastModified))
+	return b, nil
+}
+
+func (protoEncoding) UnmarshalResponse(data []byte, res *Response) error {
+	return walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			res.RequestID = string(b)
+		case 2:
+			res.Code = int(v)
+		case 3:
+			res.Msg = append([]byte(nil), b...)
+		case 4:
+			if res.Headers == nil {
+				res.Headers = http.Header{}
+			}
+			return readProtoValues(b, res.Headers)
+		case 5:
+			c, err := http.ParseSetCookie(string(b))
+			if err != nil {
+				return err
+			}
+			res.Cookies = append(res.Cookies, c)
+		case 6:
+			res.Location = string(b)
+		case 7:
+			res.BodyRef = string(b)
+		case 8:
+			res.Next = string(b)
+		case 9:
+			res.Error = &Error{}
+			return readProtoError(b, res.Error)
+		case 10:
+			res.LastModified = int64(v)
+		}
+		return nil
+	})
+}
+
+func readProtoError(data []byte, e *Error) error {
+	return walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			e.Code = string(b)
+		case 2:
+			e.Message = string(b)
+		case 3:
+			e.Details = append(json.RawMessage(nil), b...)
+		}
+		return nil
+	})
+}
+
+func readProtoClientCert(data []byte, c *ClientCert) error {
+	return walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			c.Subject = string(b)
+		case 2:
+			c.Issuer = string(b)
+		case 3:
+			c.SerialNumber = string(b)
+		case 4:
+			c.DNSNames = append(c.DNSNames, string(b))
+		case 5:
+			c.EmailAddresses = append(c.EmailAddresses, string(b))
+		case 6:
+			c.URIs = append(c.URIs, string(b))
+		case 7:
+			c.Fingerprint = string(b)
+		}
+		return nil
+	})
+}
+
+func readProtoFile(data []byte, f *File) error {
+	return walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			f.Field = string(b)
+		case 2:
+			f.Filename = string(b)
+		case 3:
+			f.ContentType = string(b)
+		case 4:
+			f.Size = int64(v)
+		case 5:
+			f.Key = string(b)
+		}
+		return nil
+	})
+}
+
+// readProtoValues adds the map entry in data, a string key and a Values
+// message, to values.
+func readProtoValues(data []byte, values map[string][]string) error {
+	var key string
+	var vs []string
+	err := walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			key = string(b)
+		case 2:
+			return walkProto(b, func(field int, v uint64, b []byte) error {
+				if field == 1 {
+					vs = append(vs, string(b))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	values[key] = append(values[key], vs...)
+	if values[key] == nil {
+		values[key] = []string{}
+	}
+	return nil
+}
+
+// readProtoMap adds the map entry in data, a string key and value, to m.
+func readProtoMap(data []byte, m map[string]string) error {
+	var key, value string
+	err := walkProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			key = string(b)
+		case 2:
+			value = string(b)
+		}
+		return nil
+	})
+	m[key] = value
+	return err
+}
+
+// walkProto calls fn with the number and value of each field of the message
+// in data: v for varint and fixed fields, b for length-delimited fields.
+// Unknown fields are skipped by fn.
+func walkProto(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 || tag>>3 == 0 {
+			return ErrMalformedProto
+		}
+		data = data[n:]
+
+		var v uint64
+		var b []byte
+		switch tag & 7 {
+		case protoVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return ErrMalformedProto
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return ErrMalformedProto
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return ErrMalformedProto
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case protoBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return ErrMalformedProto
+			}
+			b, data = data[n:n+int(l)], data[n+int(l):]
+		default:
+			return ErrMalformedProto
+		}
+
+		if err := fn(int(tag>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendProtoTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+// appendProtoVarint appends a varint field, omitted when zero.
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendProtoTag(b, field, protoVarint), v)
+}
+
+// appendProtoString appends a string field, omitted when empty.
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(appendProtoTag(b, field, protoBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendProtoBytes appends a bytes field, omitted when empty.
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendProtoMessage(b, field, v)
+}
+
+// appendProtoMessage appends an element of a repeated or message field.
+func appendProtoMessage(b []byte, field int, m []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, field, protoBytes), uint64(len(m)))
+	return append(b, m...)
+}
+
+func appendProtoStrings(b []byte, field int, ss []string) []byte {
+	for _, s := range ss {
+		b = appendProtoMessage(b, field, []byte(s))
+	}
+	return b
+}
+
+// appendProtoValues appends values as a map of Values messages, sorted by
+// key.
+func appendProtoValues(b []byte, field int, values map[string][]string) []byte {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var vs []byte
+		vs = appendProtoStrings(vs, 1, values[k])
+
+		var entry []byte
+		entry = appendProtoString(entry, 1, k)
+		entry = appendProtoMessage(entry, 2, vs)
+		b = appendProtoMessage(b, field, entry)
+	}
+	return b
+}
+
+// appendProtoMap appends m as a map of strings, sorted by key.
+func appendProtoMap(b []byte, field int, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = appendProtoString(entry, 1, k)
+		entry = appendProtoString(entry, 2, m[k])
+		b = appendProtoMessage(b, field, entry)
+	}
+	return b
+}
@@ -1,8 +1,10 @@
 package mrpcproxy
 
 import (
+	"context"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Request is the the format of a mrpcproxy request.
@@ -16,4 +18,70 @@ type Request struct {
 	Params    url.Values
 	Msg       []byte
 	Headers   http.Header
+
+	// Head is set for HEAD requests served by GET endpoints. The response
+	// body is discarded, so the service may leave it out.
+	Head bool `json:",omitempty"`
+
+	// SchemaVersion is the payload schema version negotiated for versioned
+	// endpoints.
+	SchemaVersion string `json:",omitempty"`
+
+	// Claims of the verified bearer token when JWT authentication is enabled.
+	Claims map[string]interface{} `json:",omitempty"`
+
+	// ClientCert is the verified TLS client certificate identity.
+	ClientCert *ClientCert `json:",omitempty"`
+
+	// Cookies sent by the client.
+	Cookies []*http.Cookie `json:",omitempty"`
+
+	// Files uploaded to multipart endpoints.
+	Files []File `json:",omitempty"`
+
+	// Tenant resolved by the proxy in multi-tenant mode.
+	Tenant string `json:",omitempty"`
+
+	// Deadline in Unix nanoseconds after which the proxy no longer waits for
+	// the response.
+	Deadline int64 `json:",omitempty"`
+
+	// Meta holds the request context resolved by the proxy, e.g. tenant,
+	// locale or device type, so that services don't parse the raw headers.
+	Meta map[string]string `json:",omitempty"`
+
+	// PathParams and QueryParams are the params of Params by origin.
+	PathParams  map[string]string `json:",omitempty"`
+	QueryParams url.Values        `json:",omitempty"`
+}
+
+// Context returns a copy of parent cancelled at the deadline of req, so that
+// services stop working on requests the proxy has given up on.
+func (req *Request) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	if req.Deadline == 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, time.Unix(0, req.Deadline))
+}
+
+// File describes an uploaded file saved by the proxy storage.
+type File struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int64
+	// Key locates the file in the storage backend.
+	Key string
+}
+
+// ClientCert describes a verified TLS client certificate.
+type ClientCert struct {
+	Subject        string
+	Issuer         string
+	SerialNumber   string
+	DNSNames       []string `json:",omitempty"`
+	EmailAddresses []string `json:",omitempty"`
+	URIs           []string `json:",omitempty"`
+	// Fingerprint is the hex encoded SHA-256 of the DER certificate.
+	Fingerprint string
 }
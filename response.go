@@ -1,6 +1,9 @@
 package mrpcproxy
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+)
 
 // Response is the the format of a mrpcproxy response.
 type Response struct {
@@ -8,4 +11,50 @@ type Response struct {
 	Code      int
 	Msg       []byte
 	Headers   http.Header
+
+	// Cookies are sent to the client as Set-Cookie headers.
+	Cookies []*http.Cookie `json:",omitempty"`
+
+	// Location is the redirect target of 3xx responses. Relative locations are
+	// resolved against the proxy public base URL.
+	Location string `json:",omitempty"`
+
+	// BodyRef references a body, e.g. a URL or an object key, streamed to
+	// the client by the proxy body fetcher instead of Msg.
+	BodyRef string `json:",omitempty"`
+
+	// Next is the topic of the next part of a body split in parts. The proxy
+	// flushes Msg to the client and requests Next until a part without Next.
+	Next string `json:",omitempty"`
+
+	// Error describes the failure of error responses. The proxy renders it
+	// as an RFC 7807 problem details document instead of Msg.
+	Error *Error `json:",omitempty"`
+
+	// LastModified in Unix nanoseconds is sent as the Last-Modified header
+	// of GET responses, which the proxy answers with 304 Not Modified when
+	// the client copy is current.
+	LastModified int64 `json:",omitempty"`
+
+	// Timeout is set by the proxy on the responses of requests the service
+	// didn't answer in time. It is never sent over MRPC.
+	Timeout bool `json:"-"`
+}
+
+// Error is a structured error of a service response.
+type Error struct {
+	// Code identifies the error for clients, e.g. "user_not_found".
+	Code string
+	// Message describes the error for humans.
+	Message string
+	// Details holds JSON encoded details, e.g. the invalid fields.
+	Details json.RawMessage `json:",omitempty"`
+}
+
+// Error returns the code and message of e.
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return e.Code + ": " + e.Message
 }
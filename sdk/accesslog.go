@@ -0,0 +1,190 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogEntry describes a handled request.
+type AccessLogEntry struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	URI       string
+	Proto     string
+	Status    int
+	Bytes     int64
+	Latency   time.Duration
+	RemoteIP  string
+	UserAgent string
+	Referer   string
+	RequestID string
+	Topic     string
+}
+
+// AccessLogFormatter formats an access log line.
+type AccessLogFormatter func(e *AccessLogEntry) string
+
+// CommonLogFormat formats entries in the Apache Common Log Format.
+func CommonLogFormat(e *AccessLogEntry) string {
+	return fmt.Sprintf(`%v - - [%v] "%v %v %v" %v %v`,
+		dash(e.RemoteIP), e.Time.Format(clfTimeFormat), e.Method, e.URI, e.Proto, e.Status, clfBytes(e.Bytes))
+}
+
+// CombinedLogFormat formats entries in the Apache Combined Log Format.
+func CombinedLogFormat(e *AccessLogEntry) string {
+	return fmt.Sprintf(`%v "%v" "%v"`, CommonLogFormat(e), dash(e.Referer), dash(e.UserAgent))
+}
+
+// JSONLogFormat formats entries as JSON objects, one per line.
+func JSONLogFormat(e *AccessLogEntry) string {
+	line, _ := json.Marshal(struct {
+		Time      string  `json:"time"`
+		Method    string  `json:"method"`
+		URI       string  `json:"uri"`
+		Proto     string  `json:"proto"`
+		Status    int     `json:"status"`
+		Bytes     int64   `json:"bytes"`
+		LatencyMS float64 `json:"latency_ms"`
+		RemoteIP  string  `json:"remote_ip"`
+		UserAgent string  `json:"user_agent,omitempty"`
+		Referer   string  `json:"referer,omitempty"`
+		RequestID string  `json:"request_id,omitempty"`
+		Topic     string  `json:"topic,omitempty"`
+	}{
+		e.Time.UTC().Format(time.RFC3339Nano), e.Method, e.URI, e.Proto, e.Status, e.Bytes,
+		float64(e.Latency) / float64(time.Millisecond), e.RemoteIP, e.UserAgent, e.Referer, e.RequestID, e.Topic,
+	})
+	return string(line)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// WithAccessLog replaces the default request log lines with one line per
+// request in format, written to Requests.
+func WithAccessLog(format AccessLogFormatter) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.accessLogFn = format
+		return nil
+	}
+}
+
+// logRequest writes a default request log line unless an access log format is
+// configured.
+func (pxy *Proxy) logRequest(r *http.Request, format string, v ...interface{}) {
+	if pxy.accessLogFn != nil {
+		return
+	}
+	printfCtx(r.Context(), pxy.Requests, format, v...)
+}
+
+// accessLog writes the access log line for endpoint requests.
+func (pxy *Proxy) accessLog(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	if pxy.accessLogFn == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		pxy.logAccess(w, r, ep.Topic, func(w http.ResponseWriter) { h(w, r, p) })
+	}
+}
+
+// accessLogHandler writes the access log line for requests served by h.
+func (pxy *Proxy) accessLogHandler(h http.Handler, topic string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pxy.accessLogFn == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		pxy.logAccess(w, r, topic, func(w http.ResponseWriter) { h.ServeHTTP(w, r) })
+	})
+}
+
+func (pxy *Proxy) logAccess(w http.ResponseWriter, r *http.Request, topic string, serve func(http.ResponseWriter)) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	serve(sw)
+	if !pxy.sampled(sw.status) {
+		return
+	}
+
+	printlnCtx(r.Context(), pxy.Requests, pxy.accessLogFn(&AccessLogEntry{
+		Time:      start,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		URI:       r.URL.RequestURI(),
+		Proto:     r.Proto,
+		Status:    sw.status,
+		Bytes:     sw.bytes,
+		Latency:   time.Since(start),
+		RemoteIP:  pxy.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+		RequestID: RequestIDFromContext(r.Context()),
+		Topic:     topic,
+	}))
+}
+
+// statusWriter records the status code and body size written to the
+// underlying writer.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setStatus sets the status of a response that is never written.
+func (w *statusWriter) setStatus(code int) {
+	w.status = code
+}
+
+// recordStatus sets the status logged and sampled for a response that is
+// never written, e.g. of requests whose client went away.
+func recordStatus(w http.ResponseWriter, code int) {
+	for {
+		if sw, ok := w.(interface{ setStatus(int) }); ok {
+			sw.setStatus(code)
+		}
+		rw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = rw.Unwrap()
+	}
+}
@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	e := &AccessLogEntry{
+		Time:      time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC),
+		Method:    "GET",
+		Path:      "/a",
+		URI:       "/a?b=1",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Bytes:     42,
+		Latency:   1500 * time.Microsecond,
+		RemoteIP:  "1.1.1.1",
+		UserAgent: "curl/7.0",
+		RequestID: "uuid",
+		Topic:     "service.a",
+	}
+
+	cases := []struct {
+		format AccessLogFormatter
+		line   string
+	}{
+		{CommonLogFormat, `1.1.1.1 - - [04/Mar/2019:05:06:07 +0000] "GET /a?b=1 HTTP/1.1" 200 42`},
+		{CombinedLogFormat, `1.1.1.1 - - [04/Mar/2019:05:06:07 +0000] "GET /a?b=1 HTTP/1.1" 200 42 "-" "curl/7.0"`},
+		{JSONLogFormat, `{"time":"2019-03-04T05:06:07Z","method":"GET","uri":"/a?b=1","proto":"HTTP/1.1","status":200,"bytes":42,"latency_ms":1.5,"remote_ip":"1.1.1.1","user_agent":"curl/7.0","request_id":"uuid","topic":"service.a"}`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if line := tc.format(e); line != tc.line {
+				t.Errorf("Unexpected line:\ngot  %v\nwant %v", line, tc.line)
+			}
+		})
+	}
+}
+
+func TestWithAccessLog(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 201, Msg: []byte("created")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	l := &MockLogger{}
+	pxy, _ := New(":80", service, WithAccessLog(JSONLogFormat))
+	pxy.GetID = func() string { return "uuid" }
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = l
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "POST", Path: "/a"})
+	pxy.notFound = pxy.notFoundHandler()
+
+	for _, u := range []string{"/a", "/missing"} {
+		r, _ := http.NewRequest("POST", u, nil)
+		r.RemoteAddr = "1.1.1.1:1234"
+		pxy.router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if len(l.storage) != 2 {
+		t.Fatalf("Unexpected log lines: %v", l.storage)
+	}
+
+	entries := []map[string]interface{}{}
+	for _, line := range l.storage {
+		e := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &e); err != nil {
+			t.Fatalf("Log line is not JSON: %v", line)
+		}
+		entries = append(entries, e)
+	}
+
+	if entries[0]["status"] != 201.0 || entries[0]["bytes"] != 7.0 || entries[0]["request_id"] != "uuid" ||
+		entries[0]["topic"] != "service.a" || entries[0]["remote_ip"] != "1.1.1.1" {
+		t.Errorf("Unexpected endpoint entry: %v", entries[0])
+	}
+
+	if entries[1]["status"] != 404.0 || entries[1]["uri"] != "/missing" {
+		t.Errorf("Unexpected not found entry: %v", entries[1])
+	}
+}
@@ -0,0 +1,321 @@
+package sdk
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrNoAdminToken is returned when the admin API is configured without
+	// a token.
+	ErrNoAdminToken = errors.New("admin API requires a token")
+	// ErrEndpointExists is returned when adding an endpoint whose method, host
+	// and path are already registered.
+	ErrEndpointExists = errors.New("endpoint already exists")
+	// ErrEndpointNotFound is returned when updating or removing an unknown
+	// endpoint.
+	ErrEndpointNotFound = errors.New("endpoint not found")
+)
+
+// AdminConfig configures the admin API.
+type AdminConfig struct {
+	// Addr of the admin listener, e.g. 127.0.0.1:9000.
+	Addr string
+	// Token authenticates the admin requests sent with the header
+	// "Authorization: Bearer <Token>".
+	Token string
+}
+
+// AdminSettings are the proxy settings changed at runtime with
+// /admin/config. Timeouts are in milliseconds and nil fields are kept when
+// updating.
+type AdminSettings struct {
+	Timeout        *int64             `json:"timeout,omitempty"`
+	MaxTimeout     *int64             `json:"maxTimeout,omitempty"`
+	TimeoutHeader  *string            `json:"timeoutHeader,omitempty"`
+	Headers        *map[string]string `json:"headers,omitempty"`
+	ForwardHeaders *[]string          `json:"forwardHeaders,omitempty"`
+}
+
+type adminAPI struct {
+	http  *http.Server
+	token string
+
+	// Serializes the endpoint changes.
+	mu sync.Mutex
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// WithAdminAPI serves the admin API on a separate listener started by Serve.
+// GET, POST, PUT and DELETE on /admin/endpoints list, add, update and remove
+// endpoints, identified by method, host and path given in the body or, for
+// DELETE, in the query. GET and PUT on /admin/config read and change the
+// timeouts and headers, on /admin/maintenance the maintenance mode, and on
+// /admin/dumps the request dumps, see WithRequestDumps. GET /admin/quotas
+// reads the quota usage of a client, see WithQuotas.
+func WithAdminAPI(cfg AdminConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Token == "" {
+			return ErrNoAdminToken
+		}
+
+		a := &adminAPI{token: cfg.Token}
+		router := httprouter.New()
+		router.GET("/admin/endpoints", pxy.adminEndpoints)
+		router.POST("/admin/endpoints", pxy.adminAddEndpoint)
+		router.PUT("/admin/endpoints", pxy.adminUpdateEndpoint)
+		router.DELETE("/admin/endpoints", pxy.adminRemoveEndpoint)
+		router.GET("/admin/config", pxy.adminSettings)
+		router.PUT("/admin/config", pxy.adminUpdateSettings)
+		router.GET("/admin/maintenance", pxy.adminMaintenance)
+		router.PUT("/admin/maintenance", pxy.adminUpdateMaintenance)
+		router.GET("/admin/quotas", pxy.adminQuota)
+		router.GET("/admin/dumps", pxy.adminDumps)
+		router.PUT("/admin/dumps", pxy.adminUpdateDumps)
+		a.http = &http.Server{Addr: cfg.Addr, Handler: a.authenticate(router)}
+
+		pxy.admin = a
+		return WithServer("admin API", a.http)(pxy)
+	}
+}
+
+func (a *adminAPI) authenticate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAdminJSON(w, http.StatusUnauthorized, adminError{http.StatusText(http.StatusUnauthorized)})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (pxy *Proxy) adminEndpoints(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	pxy.admin.mu.Lock()
+	eps := append([]Endpoint{}, pxy.Eps...)
+	pxy.admin.mu.Unlock()
+
+	writeAdminJSON(w, http.StatusOK, eps)
+}
+
+func (pxy *Proxy) adminAddEndpoint(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	pxy.changeEndpoint(w, r, func(i int, ep Endpoint, h httprouter.Handle) ([]Endpoint, []route, error) {
+		if i >= 0 {
+			return nil, nil, ErrEndpointExists
+		}
+		return append(append([]Endpoint{}, pxy.Eps...), ep),
+			append(append([]route{}, pxy.routes...), route{ep.Host, ep.Method, ep.Path, h, true}), nil
+	})
+}
+
+func (pxy *Proxy) adminUpdateEndpoint(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	pxy.changeEndpoint(w, r, func(i int, ep Endpoint, h httprouter.Handle) ([]Endpoint, []route, error) {
+		if i < 0 {
+			return nil, nil, ErrEndpointNotFound
+		}
+
+		eps := append([]Endpoint{}, pxy.Eps...)
+		eps[i] = ep
+
+		routes := make([]route, len(pxy.routes))
+		for j, rt := range pxy.routes {
+			if rt.endpoint && rt.host == ep.Host && rt.method == ep.Method && rt.path == ep.Path {
+				rt.h = h
+			}
+			routes[j] = rt
+		}
+		return eps, routes, nil
+	})
+}
+
+func (pxy *Proxy) adminRemoveEndpoint(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	q := r.URL.Query()
+	ep := Endpoint{Method: q.Get("method"), Host: q.Get("host"), Path: q.Get("path")}
+
+	pxy.admin.mu.Lock()
+	defer pxy.admin.mu.Unlock()
+
+	i := pxy.findEndpoint(ep)
+	if i < 0 {
+		writeAdminJSON(w, http.StatusNotFound, adminError{ErrEndpointNotFound.Error()})
+		return
+	}
+
+	eps := append(append([]Endpoint{}, pxy.Eps[:i]...), pxy.Eps[i+1:]...)
+	var routes []route
+	for _, rt := range pxy.routes {
+		if !rt.endpoint || rt.host != ep.Host || rt.method != ep.Method || rt.path != ep.Path {
+			routes = append(routes, rt)
+		}
+	}
+
+	if err := pxy.rebuildRouters(eps, routes); err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{err.Error()})
+		return
+	}
+
+	pxy.Logger.Printf("admin: removed endpoint %v:%v%v", ep.Method, ep.Host, ep.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changeEndpoint adds or updates the endpoint in the request body. change
+// returns the new endpoints and routes given the index of the existing
+// endpoint, or -1.
+func (pxy *Proxy) changeEndpoint(w http.ResponseWriter, r *http.Request, change func(i int, ep Endpoint, h httprouter.Handle) ([]Endpoint, []route, error)) {
+	var ep Endpoint
+	if err := json.NewDecoder(r.Body).Decode(&ep); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	pxy.admin.mu.Lock()
+	defer pxy.admin.mu.Unlock()
+
+	i := pxy.findEndpoint(ep)
+	if i >= 0 {
+		ep.Middleware = pxy.Eps[i].Middleware
+	}
+
+	if err := pxy.validateEndpoint(ep); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	h, err := pxy.endpointHandler(ep)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	eps, routes, err := change(i, ep, h)
+	if err != nil {
+		code := http.StatusConflict
+		if err == ErrEndpointNotFound {
+			code = http.StatusNotFound
+		}
+		writeAdminJSON(w, code, adminError{err.Error()})
+		return
+	}
+
+	if err := pxy.rebuildRouters(eps, routes); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	code, action := http.StatusCreated, "added"
+	if i >= 0 {
+		code, action = http.StatusOK, "updated"
+	}
+	pxy.Logger.Printf("admin: %v endpoint %v:%v%v, topic: %v", action, ep.Method, ep.Host, ep.Path, ep.Topic)
+	writeAdminJSON(w, code, ep)
+}
+
+func (pxy *Proxy) findEndpoint(ep Endpoint) int {
+	for i, e := range pxy.Eps {
+		if e.Method == ep.Method && strings.EqualFold(e.Host, ep.Host) && e.Path == ep.Path {
+			return i
+		}
+	}
+	return -1
+}
+
+// rebuildRouters replaces the routers with ones serving routes. The proxy is
+// left unchanged when the routes conflict.
+func (pxy *Proxy) rebuildRouters(eps []Endpoint, routes []route) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	router := pxy.makeRouter()
+	hosts := map[string]Router{}
+	hostRouter := func(host string) Router {
+		if host == "" {
+			return router
+		}
+
+		host = strings.ToLower(host)
+		r, ok := hosts[host]
+		if !ok {
+			r = pxy.makeRouter()
+			hosts[host] = r
+		}
+		return r
+	}
+
+	// The routers panic on conflicting routes.
+	for _, rt := range routes {
+		pxy.handleRoute(hostRouter(rt.host), rt)
+	}
+	pxy.finishRouters(router, hosts, hostRouter, eps)
+
+	pxy.routesMu.Lock()
+	pxy.router, pxy.hosts = router, hosts
+	pxy.routesMu.Unlock()
+
+	pxy.Eps, pxy.routes = eps, routes
+	return nil
+}
+
+func (pxy *Proxy) adminSettings(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	pxy.configMu.RLock()
+	timeout := int64(pxy.Timeout / time.Millisecond)
+	maxTimeout := int64(pxy.MaxTimeout / time.Millisecond)
+	settings := AdminSettings{&timeout, &maxTimeout, &pxy.TimeoutHeader, &pxy.Headers, &pxy.ForwardHeaders}
+	body, err := json.Marshal(settings)
+	pxy.configMu.RUnlock()
+
+	if err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, json.RawMessage(body))
+}
+
+func (pxy *Proxy) adminUpdateSettings(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var s AdminSettings
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	pxy.configMu.Lock()
+	if s.Timeout != nil {
+		pxy.Timeout = time.Duration(*s.Timeout) * time.Millisecond
+	}
+	if s.MaxTimeout != nil {
+		pxy.MaxTimeout = time.Duration(*s.MaxTimeout) * time.Millisecond
+	}
+	if s.TimeoutHeader != nil {
+		pxy.TimeoutHeader = *s.TimeoutHeader
+	}
+	if s.Headers != nil {
+		pxy.Headers = *s.Headers
+	}
+	if s.ForwardHeaders != nil {
+		pxy.ForwardHeaders = *s.ForwardHeaders
+	}
+	pxy.configMu.Unlock()
+
+	pxy.Logger.Println("admin: updated config")
+	pxy.adminSettings(w, r, p)
+}
@@ -0,0 +1,101 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestWithAdminAPINoToken(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	if _, err := New(":80", service, WithAdminAPI(AdminConfig{Addr: ":0"})); err == nil {
+		t.Error("Expected error without token")
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"a", "b"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithAdminAPI(AdminConfig{Addr: ":0", Token: "secret"}))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+	pxy.notFound = pxy.notFoundHandler()
+
+	proxyGet := func(path string) (int, string) {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	steps := []struct {
+		method, path, token, body string
+
+		code   int
+		resp   string
+		check  string
+		status int
+		result string
+	}{
+		{"GET", "/admin/endpoints", "wrong", "", 401, `{"error":"Unauthorized"}`, "/a", 200, "a"},
+		{"POST", "/admin/endpoints", "secret", `{"Path":"/b","method":"GET","topic":"service.b"}`, 201, "", "/b", 200, "b"},
+		{"POST", "/admin/endpoints", "secret", `{"Path":"/b","method":"GET","topic":"service.a"}`, 409, `{"error":"endpoint already exists"}`, "/b", 200, "b"},
+		{"POST", "/admin/endpoints", "secret", `{"Path":"/:id","method":"GET","topic":"service.a"}`, 400, "", "/b", 200, "b"},
+		{"PUT", "/admin/endpoints", "secret", `{"Path":"/a","method":"GET","topic":"service.b"}`, 200, "", "/a", 200, "b"},
+		{"PUT", "/admin/endpoints", "secret", `{"Path":"/c","method":"GET","topic":"service.b"}`, 404, `{"error":"endpoint not found"}`, "/c", 404, ""},
+		{"DELETE", "/admin/endpoints?method=GET&path=/a", "secret", "", 204, "", "/a", 404, ""},
+		{"DELETE", "/admin/endpoints?method=GET&path=/a", "secret", "", 404, `{"error":"endpoint not found"}`, "/b", 200, "b"},
+		{"PUT", "/admin/config", "secret", `{"timeout":250,"headers":{"X-Env":"prod"}}`, 200,
+			`{"timeout":250,"maxTimeout":0,"timeoutHeader":"","headers":{"X-Env":"prod"},"forwardHeaders":null}`, "/b", 200, "b"},
+		{"PUT", "/admin/maintenance", "secret", `{"enabled":true,"message":"upgrading"}`, 200, `{"enabled":true,"message":"upgrading"}`, "/b", 503, "upgrading"},
+		{"GET", "/admin/maintenance", "secret", "", 200, `{"enabled":true,"message":"upgrading"}`, "/b", 503, "upgrading"},
+		{"PUT", "/admin/maintenance", "secret", `{"enabled":false}`, 200, `{"enabled":false}`, "/b", 200, "b"},
+		{"PUT", "/admin/dumps", "secret", `{"enabled":true}`, 400, `{"error":"request dumps not configured"}`, "/b", 200, "b"},
+	}
+
+	for i, s := range steps {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(s.method, s.path, bytes.NewBufferString(s.body))
+			r.Header.Set("Authorization", "Bearer "+s.token)
+			w := httptest.NewRecorder()
+			pxy.admin.http.Handler.ServeHTTP(w, r)
+
+			if w.Code != s.code {
+				t.Errorf("Unexpected admin code %v; expected %v: %v", w.Code, s.code, w.Body.String())
+			}
+			if s.resp != "" && w.Body.String() != s.resp+"\n" {
+				t.Errorf("Unexpected admin response %q; expected %q", w.Body.String(), s.resp)
+			}
+			if code, body := proxyGet(s.check); code != s.status || body != s.result {
+				t.Errorf("Unexpected proxy response %v %q; expected %v %q", code, body, s.status, s.result)
+			}
+		})
+	}
+
+	if pxy.Timeout != 250*time.Millisecond || pxy.Headers["X-Env"] != "prod" {
+		t.Errorf("Config not updated: %v %v", pxy.Timeout, pxy.Headers)
+	}
+	if len(pxy.Eps) != 1 || pxy.Eps[0].Path != "/b" {
+		t.Errorf("Unexpected endpoints %+v", pxy.Eps)
+	}
+}
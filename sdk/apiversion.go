@@ -0,0 +1,102 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// APIVersionHeader selects the API version of versioned endpoints served
+// without version prefix.
+const APIVersionHeader = "X-API-Version"
+
+var (
+	// ErrUnknownAPIVersion is returned for requests asking for an API version
+	// the endpoint doesn't have.
+	ErrUnknownAPIVersion = errors.New("unknown API version")
+)
+
+// APIVersion routes a version of an endpoint to a topic.
+type APIVersion struct {
+	// Version is the path prefix of the version without slashes, e.g. v1.
+	Version string `json:"version"`
+	Topic   string `json:"topic"`
+	// Deprecated versions are served with Deprecation and Warning headers.
+	Deprecated bool `json:"deprecated"`
+}
+
+// expandAPIVersions adds an endpoint with the version prefix for each API
+// version of the versioned endpoints. The endpoint without prefix picks the
+// version from the X-API-Version header, the last one by default.
+func expandAPIVersions(eps []Endpoint) []Endpoint {
+	var expanded []Endpoint
+	for _, ep := range eps {
+		expanded = append(expanded, ep)
+		if len(ep.APIVersions) == 0 {
+			continue
+		}
+
+		for _, v := range ep.APIVersions {
+			vep := ep
+			vep.Path = "/" + v.Version + ep.Path
+			vep.Topic = v.Topic
+			vep.APIVersions = []APIVersion{v}
+			expanded = append(expanded, vep)
+		}
+	}
+	return expanded
+}
+
+type apiVersions struct {
+	versions []APIVersion
+	topics   []*template.Template
+}
+
+// parseAPIVersions parses the version topics. It returns nil when the
+// endpoint isn't versioned.
+func parseAPIVersions(ep Endpoint) (*apiVersions, error) {
+	if len(ep.APIVersions) == 0 {
+		return nil, nil
+	}
+
+	topics := make([]string, len(ep.APIVersions))
+	for i, v := range ep.APIVersions {
+		topics[i] = v.Topic
+	}
+	tmpls, err := parseTopics(topics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiVersions{ep.APIVersions, tmpls}, nil
+}
+
+// topic returns the topic template of the requested version and sets the
+// deprecation headers of deprecated versions. Endpoints with a single version
+// serve it regardless of the header.
+func (av *apiVersions) topic(w http.ResponseWriter, r *http.Request) (*template.Template, error) {
+	i := len(av.versions) - 1
+	if version := r.Header.Get(APIVersionHeader); version != "" && len(av.versions) > 1 {
+		i = -1
+		for j, v := range av.versions {
+			if strings.EqualFold(v.Version, version) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, ErrUnknownAPIVersion
+		}
+	}
+
+	if len(av.versions) > 1 {
+		w.Header().Add("Vary", APIVersionHeader)
+	}
+	if v := av.versions[i]; v.Deprecated {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "API version %v is deprecated"`, v.Version))
+	}
+	return av.topics[i], nil
+}
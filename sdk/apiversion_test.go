@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestAPIVersions(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"users.v1", "users.v2"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	err := pxy.Handle(Endpoint{Method: "GET", Path: "/users", APIVersions: []APIVersion{
+		{Version: "v1", Topic: "users.v1", Deprecated: true},
+		{Version: "v2", Topic: "users.v2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path       string
+		header     string
+		code       int
+		topic      string
+		deprecated bool
+	}{
+		{"/users", "", 200, "users.v2", false},
+		{"/users", "v1", 200, "users.v1", true},
+		{"/users", "V2", 200, "users.v2", false},
+		{"/users", "v3", 400, "", false},
+		{"/v1/users", "", 200, "users.v1", true},
+		{"/v2/users", "", 200, "users.v2", false},
+		{"/v2/users", "v1", 200, "users.v2", false},
+		{"/v3/users", "", 404, "", false},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.header != "" {
+				r.Header.Set(APIVersionHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if tc.code == 200 && w.Body.String() != tc.topic {
+				t.Errorf("Unexpected topic %q", w.Body.String())
+			}
+			if deprecated := w.Header().Get("Deprecation") == "true"; deprecated != tc.deprecated {
+				t.Errorf("Unexpected deprecation %v", deprecated)
+			}
+			if tc.deprecated && w.Header().Get("Warning") != `299 - "API version v1 is deprecated"` {
+				t.Errorf("Unexpected warning %q", w.Header().Get("Warning"))
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrForbidden is returned for requests denied by the Authorizer.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Authorizer decides whether a request may be sent to the endpoint. It is
+// asked before the MRPC call, with the endpoint topic resolved.
+type Authorizer interface {
+	Allow(ctx context.Context, r *http.Request, ep Endpoint) (bool, error)
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc func(ctx context.Context, r *http.Request, ep Endpoint) (bool, error)
+
+// Allow calls f.
+func (f AuthorizerFunc) Allow(ctx context.Context, r *http.Request, ep Endpoint) (bool, error) {
+	return f(ctx, r, ep)
+}
+
+// WithAuthorizer authorizes the endpoint requests with a. Denied requests get
+// 403 and failing authorizations 500.
+func WithAuthorizer(a Authorizer) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.authorizer = a
+		return nil
+	}
+}
+
+// authorize returns the status and error of requests the authorizer doesn't
+// allow, or 0.
+func (pxy *Proxy) authorize(r *http.Request, ep Endpoint) (int, error) {
+	if pxy.authorizer == nil {
+		return 0, nil
+	}
+
+	ok, err := pxy.authorizer.Allow(r.Context(), r, ep)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !ok {
+		return http.StatusForbidden, ErrForbidden
+	}
+	return 0, nil
+}
+
+// OPAInput is the input document of OPAAuthorizer queries.
+type OPAInput struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Host   string              `json:"host"`
+	Query  map[string][]string `json:"query"`
+	// Headers of the request without Authorization and Cookie.
+	Headers  map[string][]string    `json:"headers"`
+	ClientIP string                 `json:"clientIp"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+	Endpoint OPAEndpoint            `json:"endpoint"`
+}
+
+// OPAEndpoint describes the endpoint in OPAInput.
+type OPAEndpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Topic  string `json:"topic"`
+}
+
+// OPAAuthorizer queries an Open Policy Agent decision with the OPAInput of
+// the request. The decision must be a boolean, undefined decisions deny.
+type OPAAuthorizer struct {
+	// URL of the decision, e.g. http://localhost:8181/v1/data/httpapi/allow.
+	URL string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// ClientIP returns the client IP of the input. Defaults to the request
+	// remote address.
+	ClientIP func(r *http.Request) string
+}
+
+// NewOPAAuthorizer creates an OPAAuthorizer for the decision at url using the
+// client IP resolution of the proxy.
+func (pxy *Proxy) NewOPAAuthorizer(url string) *OPAAuthorizer {
+	return &OPAAuthorizer{URL: url, ClientIP: pxy.clientIP}
+}
+
+// Allow queries the decision.
+func (a *OPAAuthorizer) Allow(ctx context.Context, r *http.Request, ep Endpoint) (bool, error) {
+	headers := r.Header.Clone()
+	headers.Del("Authorization")
+	headers.Del("Cookie")
+
+	input := OPAInput{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Host:     r.Host,
+		Query:    r.URL.Query(),
+		Headers:  headers,
+		ClientIP: r.RemoteAddr,
+		Claims:   claimsFromContext(ctx),
+		Endpoint: OPAEndpoint{ep.Method, ep.Path, ep.Topic},
+	}
+	if a.ClientIP != nil {
+		input.ClientIP = a.ClientIP(r)
+	}
+
+	body, err := json.Marshal(struct {
+		Input OPAInput `json:"input"`
+	}{input})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa: %v", res.Status)
+	}
+
+	var decision struct {
+		Result *bool `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&decision); err != nil {
+		return false, err
+	}
+	return decision.Result != nil && *decision.Result, nil
+}
@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestAuthorizer(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		allow bool
+		err   error
+		code  int
+	}{
+		{true, nil, 200},
+		{false, nil, 403},
+		{true, errors.New("policy failed"), 500},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			var topic string
+			authz := AuthorizerFunc(func(ctx context.Context, r *http.Request, ep Endpoint) (bool, error) {
+				topic = ep.Topic
+				return tc.allow, tc.err
+			})
+
+			pxy, _ := New(":80", service, WithAuthorizer(authz))
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: "{{.name}}", Method: "GET", Path: "/:name"})
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if topic != "a" {
+				t.Errorf("Unexpected topic %q", topic)
+			}
+		})
+	}
+}
+
+func TestOPAAuthorizer(t *testing.T) {
+	var input OPAInput
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q struct {
+			Input OPAInput `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+		input = q.Input
+
+		switch r.URL.Path {
+		case "/v1/data/allow":
+			fmt.Fprint(w, `{"result": true}`)
+		case "/v1/data/deny":
+			fmt.Fprint(w, `{"result": false}`)
+		case "/v1/data/undefined":
+			fmt.Fprint(w, `{}`)
+		default:
+			http.Error(w, "error", http.StatusInternalServerError)
+		}
+	}))
+	defer opa.Close()
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+
+	cases := []struct {
+		path    string
+		allowed bool
+		err     bool
+	}{
+		{"/v1/data/allow", true, false},
+		{"/v1/data/deny", false, false},
+		{"/v1/data/undefined", false, false},
+		{"/error", false, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("DELETE", "/items/1?force=true", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("Authorization", "Bearer token")
+			r.Header.Set("X-Tenant", "t1")
+			ep := Endpoint{Method: "DELETE", Path: "/items/:id", Topic: "items.delete"}
+
+			allowed, err := pxy.NewOPAAuthorizer(opa.URL+tc.path).Allow(r.Context(), r, ep)
+			if (err != nil) != tc.err || allowed != tc.allowed {
+				t.Fatalf("Unexpected decision %v %v", allowed, err)
+			}
+
+			if input.Method != "DELETE" || input.Path != "/items/1" || input.ClientIP != "192.0.2.1" ||
+				input.Query["force"][0] != "true" || input.Headers["X-Tenant"][0] != "t1" ||
+				input.Endpoint != (OPAEndpoint{"DELETE", "/items/:id", "items.delete"}) {
+				t.Errorf("Unexpected input %+v", input)
+			}
+			if _, ok := input.Headers["Authorization"]; ok {
+				t.Error("Authorization header sent to OPA")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultBatchPath        = "/batch"
+	defaultBatchMaxRequests = 20
+	defaultBatchMaxBodySize = 1 << 20
+)
+
+var (
+	// ErrBatchTooLarge is returned when a batch has more sub-requests than
+	// BatchConfig.MaxRequests.
+	ErrBatchTooLarge = errors.New("too many batch requests")
+	// ErrNestedBatch is answered to sub-requests of the batch path.
+	ErrNestedBatch = errors.New("nested batch request")
+)
+
+// BatchConfig configures the batch endpoint.
+type BatchConfig struct {
+	// Path of the batch endpoint. Defaults to /batch.
+	Path string
+	// MaxRequests is the largest number of sub-requests of a batch. Defaults
+	// to 20.
+	MaxRequests int
+	// MaxBodySize is the largest batch body in bytes. Defaults to 1 MiB.
+	MaxBodySize int64
+}
+
+// BatchRequest is a sub-request of a batch.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse is the response to a sub-request. JSON bodies are embedded
+// as they are and other bodies as strings.
+type BatchResponse struct {
+	Status  int             `json:"status"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// WithBatch serves POST requests to cfg.Path with a JSON array of
+// BatchRequest. The sub-requests are served concurrently by the proxy
+// routes with the headers of the batch request, overridden by their own,
+// and answered with an array of BatchResponse in the same order.
+func WithBatch(cfg BatchConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Path == "" {
+			cfg.Path = defaultBatchPath
+		}
+		if cfg.MaxRequests <= 0 {
+			cfg.MaxRequests = defaultBatchMaxRequests
+		}
+		if cfg.MaxBodySize <= 0 {
+			cfg.MaxBodySize = defaultBatchMaxBodySize
+		}
+
+		h := func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			pxy.serveBatch(w, r, cfg)
+		}
+		pxy.addRoute(route{"", "POST", cfg.Path, pxy.requestIDs(pxy.track(h)), false})
+		return nil
+	}
+}
+
+func (pxy *Proxy) serveBatch(w http.ResponseWriter, r *http.Request, cfg BatchConfig) {
+	status, res, err := pxy.batch(r, cfg)
+	pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, status, RequestIDFromContext(r.Context()))
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		pxy.writeError(w, r, status, err)
+		return
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		pxy.writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	pxy.setHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// batch serves the sub-requests of r.
+func (pxy *Proxy) batch(r *http.Request, cfg BatchConfig) (int, []BatchResponse, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, cfg.MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, nil, err
+		}
+		return http.StatusBadRequest, nil, err
+	}
+
+	var reqs []BatchRequest
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	if len(reqs) > cfg.MaxRequests {
+		return http.StatusRequestEntityTooLarge, nil, ErrBatchTooLarge
+	}
+
+	res := make([]BatchResponse, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req BatchRequest) {
+			defer wg.Done()
+			res[i] = pxy.batchRequest(r, req, cfg)
+		}(i, req)
+	}
+	wg.Wait()
+
+	return http.StatusOK, res, nil
+}
+
+// batchRequest serves a sub-request of the batch request r.
+func (pxy *Proxy) batchRequest(r *http.Request, req BatchRequest, cfg BatchConfig) BatchResponse {
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+
+	sub, err := http.NewRequestWithContext(r.Context(), req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return BatchResponse{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	if sub.URL.Path == cfg.Path {
+		return BatchResponse{Status: http.StatusBadRequest, Error: ErrNestedBatch.Error()}
+	}
+
+	sub.Host, sub.RemoteAddr, sub.TLS = r.Host, r.RemoteAddr, r.TLS
+	sub.Header = r.Header.Clone()
+	sub.Header.Del("Accept-Encoding")
+	sub.Header.Del("Content-Length")
+	for k, v := range req.Headers {
+		sub.Header.Set(k, v)
+	}
+
+	bw := newBufferedWriter()
+	pxy.ServeHTTP(bw, sub)
+
+	res := BatchResponse{Status: bw.code, Headers: bw.header}
+	if b := bw.body.Bytes(); len(b) > 0 {
+		if json.Valid(b) {
+			res.Body = b
+		} else {
+			res.Body, _ = json.Marshal(string(b))
+		}
+	}
+	return res
+}
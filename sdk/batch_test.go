@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestBatch(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("users", func(w mrpc.TopicWriter, data []byte) {
+		req := mrpcproxy.Request{}
+		json.Unmarshal(data, &req)
+		body := fmt.Sprintf(`{"id":%q,"auth":%q,"msg":%q}`, req.Params.Get("id"), req.Headers.Get("Authorization"), req.Msg)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(body)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithBatch(BatchConfig{MaxRequests: 3}))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/users/:id", Topic: "users"},
+		Endpoint{Method: "POST", Path: "/users", Topic: "users"},
+	)
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/text"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	})
+	pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+	cases := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{
+			`[{"path":"/users/1"},{"method":"POST","path":"/users","body":{"a":1}},{"path":"/text"}]`,
+			http.StatusOK,
+			`[{"status":200,"body":{"id":"1","auth":"token","msg":""}},{"status":200,"body":{"id":"","auth":"token","msg":"{\"a\":1}"}},{"status":200,"body":"plain"}]`,
+		},
+		{
+			`[{"path":"/missing"},{"method":"POST","path":"/batch"},{"path":"/users/2","headers":{"Authorization":"other"}}]`,
+			http.StatusOK,
+			`[{"status":404},{"status":400,"error":"nested batch request"},{"status":200,"body":{"id":"2","auth":"other","msg":""}}]`,
+		},
+		{`[{},{},{},{}]`, http.StatusRequestEntityTooLarge, ""},
+		{`{}`, http.StatusBadRequest, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/batch", strings.NewReader(tc.body))
+			r.Header.Set("Authorization", "token")
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.want == "" {
+				return
+			}
+
+			var res []BatchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			for i := range res {
+				res[i].Headers = nil
+			}
+			if got, _ := json.Marshal(res); string(got) != tc.want {
+				t.Errorf("Unexpected responses:\ngot  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
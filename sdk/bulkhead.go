@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrConcurrencyLimit is returned for requests rejected because the
+	// endpoint topic has no free call slot.
+	ErrConcurrencyLimit = errors.New("concurrency limit reached")
+	// ErrInvalidConcurrency is returned for concurrency limits without a
+	// positive Max.
+	ErrInvalidConcurrency = errors.New("concurrency limit must be positive")
+)
+
+// ConcurrencyLimit bounds the MRPC calls of an endpoint topic running at
+// once. Endpoints with the same topic share the limit of the first one
+// registered.
+type ConcurrencyLimit struct {
+	// Max concurrent calls.
+	Max int `json:"max"`
+	// Queue is the number of requests waiting for a free slot. Requests
+	// beyond it are rejected immediately.
+	Queue int `json:"queue"`
+	// QueueTimeout in milliseconds bounds the wait of queued requests.
+	// Defaults to the request timeout.
+	QueueTimeout int `json:"queueTimeout"`
+	// Status of rejected requests, 503 by default. Use 429 to tell clients
+	// to back off.
+	Status int `json:"status"`
+}
+
+type bulkhead struct {
+	limit   ConcurrencyLimit
+	slots   chan struct{}
+	waiting int64
+}
+
+type bulkheadRegistry struct {
+	mu        sync.Mutex
+	bulkheads map[string]*bulkhead
+}
+
+// bulkhead returns the shared bulkhead of the ep topic, creating it on first
+// use.
+func (pxy *Proxy) bulkhead(ep Endpoint) (*bulkhead, error) {
+	limit := *ep.Concurrency
+	if limit.Max <= 0 {
+		return nil, ErrInvalidConcurrency
+	}
+	if limit.Status == 0 {
+		limit.Status = http.StatusServiceUnavailable
+	}
+
+	pxy.bulkheads.mu.Lock()
+	defer pxy.bulkheads.mu.Unlock()
+
+	if pxy.bulkheads.bulkheads == nil {
+		pxy.bulkheads.bulkheads = map[string]*bulkhead{}
+	}
+
+	key := ep.Topic
+	if len(ep.Topics) > 0 {
+		key = strings.Join(ep.Topics, ",")
+	}
+	b, ok := pxy.bulkheads.bulkheads[key]
+	if !ok {
+		b = &bulkhead{limit: limit, slots: make(chan struct{}, limit.Max)}
+		pxy.bulkheads.bulkheads[key] = b
+	}
+	return b, nil
+}
+
+// limitConcurrency runs h when the endpoint topic has a free slot.
+func (pxy *Proxy) limitConcurrency(ep Endpoint, h httprouter.Handle) (httprouter.Handle, error) {
+	b, err := pxy.bulkhead(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		wait := time.Duration(b.limit.QueueTimeout) * time.Millisecond
+		if wait == 0 {
+			wait = pxy.timeout(r, ep)
+		}
+
+		if !b.acquire(r.Context(), wait) {
+			pxy.logEndpointRequest(r, b.limit.Status, ep.Topic, RequestIDFromContext(r.Context()))
+			w.Header().Set("Retry-After", "1")
+			pxy.writeError(w, r, b.limit.Status, ErrConcurrencyLimit)
+			return
+		}
+		defer b.release()
+
+		h(w, r, p)
+	}, nil
+}
+
+// acquire takes a slot, waiting up to wait in the queue when it has room.
+func (b *bulkhead) acquire(ctx context.Context, wait time.Duration) bool {
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if atomic.AddInt64(&b.waiting, 1) > int64(b.limit.Queue) {
+		atomic.AddInt64(&b.waiting, -1)
+		return false
+	}
+	defer atomic.AddInt64(&b.waiting, -1)
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
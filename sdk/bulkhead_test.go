@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("slow", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(50 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		limit ConcurrencyLimit
+		codes map[int]int
+	}{
+		{ConcurrencyLimit{Max: 1}, map[int]int{200: 1, 503: 2}},
+		{ConcurrencyLimit{Max: 1, Queue: 1}, map[int]int{200: 2, 503: 1}},
+		{ConcurrencyLimit{Max: 1, Queue: 2, QueueTimeout: 10, Status: 429}, map[int]int{200: 1, 429: 2}},
+		{ConcurrencyLimit{Max: 3}, map[int]int{200: 3}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			if err := pxy.Handle(Endpoint{Topic: "service.slow", Method: "GET", Path: "/slow", Concurrency: &tc.limit}); err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			codes := map[int]int{}
+			for j := 0; j < 3; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, _ := http.NewRequest("GET", "/slow", nil)
+					w := httptest.NewRecorder()
+					pxy.ServeHTTP(w, r)
+
+					if w.Code != 200 && w.Header().Get("Retry-After") == "" {
+						t.Error("Retry-After missing")
+					}
+					mu.Lock()
+					codes[w.Code]++
+					mu.Unlock()
+				}()
+				time.Sleep(5 * time.Millisecond)
+			}
+			wg.Wait()
+
+			if fmt.Sprint(codes) != fmt.Sprint(tc.codes) {
+				t.Errorf("Unexpected codes %v; expected %v", codes, tc.codes)
+			}
+		})
+	}
+}
+
+func TestInvalidConcurrencyLimit(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	err := pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/a", Concurrency: &ConcurrencyLimit{}})
+	if err != ErrInvalidConcurrency {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package sdk
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+// Cache stores MRPC responses of GET endpoints.
+type Cache interface {
+	Get(key string) (*mrpcproxy.Response, bool)
+	Set(key string, res *mrpcproxy.Response, ttl time.Duration)
+}
+
+// ContextCache is implemented by caches accepting the context of the
+// request, e.g. to bound the calls to a remote store. The proxy prefers it to
+// Get and Set.
+type ContextCache interface {
+	GetContext(ctx context.Context, key string) (*mrpcproxy.Response, bool)
+	SetContext(ctx context.Context, key string, res *mrpcproxy.Response, ttl time.Duration)
+}
+
+// cacheGet gets key from the cache with ctx when it accepts it.
+func (pxy *Proxy) cacheGet(ctx context.Context, key string) (*mrpcproxy.Response, bool) {
+	if c, ok := pxy.cache.(ContextCache); ok {
+		return c.GetContext(ctx, key)
+	}
+	return pxy.cache.Get(key)
+}
+
+// cacheSet sets key in the cache with ctx when it accepts it.
+func (pxy *Proxy) cacheSet(ctx context.Context, key string, res *mrpcproxy.Response, ttl time.Duration) {
+	if c, ok := pxy.cache.(ContextCache); ok {
+		c.SetContext(ctx, key, res, ttl)
+		return
+	}
+	pxy.cache.Set(key, res, ttl)
+}
+
+// WithCache caches responses of GET endpoints for ttl unless the MRPC response
+// Cache-Control header says otherwise. Responses are cached only when ttl or
+// the response max-age is positive. Requests carrying credentials and
+// responses setting cookies are never cached, and the response Vary header
+// selects the request headers that are part of the cache key.
+func WithCache(c Cache, ttl time.Duration) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.cache = c
+		pxy.cacheTTL = ttl
+		return nil
+	}
+}
+
+func (pxy *Proxy) cachedMRPCRequest(r *http.Request, p httprouter.Params, ep Endpoint) (*mrpcproxy.Response, error) {
+	if pxy.cache == nil || r.Method != "GET" || hasCredentials(r) {
+		return pxy.coalescedMRPCRequest(r, p, ep)
+	}
+
+	key := ep.Topic + " " + cacheKey(r)
+	if ep.version != "" {
+		key += "\n" + AcceptVersionHeader + ": " + ep.version
+	}
+	base := key
+	res, ok := pxy.cacheGet(r.Context(), key)
+	if ok {
+		if vary := varyFields(res.Headers); vary != nil {
+			key = varyKey(key, vary, r)
+			res, ok = pxy.cacheGet(r.Context(), key)
+		}
+	}
+
+	var stale *mrpcproxy.Response
+	if ok {
+		switch pxy.cacheState(r.Context(), key) {
+		case cacheFresh:
+			return cacheHit(r, res), nil
+		case cacheRevalidate:
+			pxy.revalidate(r, p, ep, base)
+			return cacheHit(r, res), nil
+		}
+		stale = res
+	}
+
+	res, err := pxy.coalescedMRPCRequest(r, p, ep)
+	if stale != nil && (err != nil || res.Code >= http.StatusInternalServerError) {
+		printfCtx(r.Context(), pxy.Debugger, "serving stale %v: %v", base, err)
+		return cacheHit(r, stale), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pxy.storeResponse(r, base, res)
+	return res, nil
+}
+
+// cacheHit returns a copy of the cached res for r.
+func cacheHit(r *http.Request, res *mrpcproxy.Response) *mrpcproxy.Response {
+	hit := cloneResponse(res)
+	hit.RequestID = RequestIDFromContext(r.Context())
+	return hit
+}
+
+// storeResponse caches res under key when it may be cached.
+func (pxy *Proxy) storeResponse(r *http.Request, key string, res *mrpcproxy.Response) {
+	if res.Code != http.StatusOK || !cacheable(res) {
+		return
+	}
+
+	ttl := cacheTTL(res.Headers, pxy.cacheTTL)
+	if ttl <= 0 {
+		return
+	}
+
+	// The response is handed to the transformers, so the cache keeps its
+	// own copy.
+	cached := cloneResponse(res)
+	revalidate, ifError := pxy.staleWindows(res.Headers)
+	keep := ttl + revalidate
+	if ifError > revalidate {
+		keep = ttl + ifError
+	}
+
+	pxy.cacheSet(r.Context(), key, cached, keep)
+	if vary := varyFields(res.Headers); vary != nil {
+		key = varyKey(key, vary, r)
+		pxy.cacheSet(r.Context(), key, cached, keep)
+	}
+	if keep > ttl {
+		pxy.setCacheMarkers(r.Context(), key, ttl, revalidate, keep)
+	}
+}
+
+// cloneResponse deep copies the body, headers and cookies of res.
+func cloneResponse(res *mrpcproxy.Response) *mrpcproxy.Response {
+	c := *res
+	c.Msg = append([]byte(nil), res.Msg...)
+	c.Headers = res.Headers.Clone()
+	if res.Cookies != nil {
+		c.Cookies = make([]*http.Cookie, len(res.Cookies))
+		for i, cookie := range res.Cookies {
+			cc := *cookie
+			c.Cookies[i] = &cc
+		}
+	}
+	return &c
+}
+
+// hasCredentials reports whether the response to r may depend on who sent it.
+func hasCredentials(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return true
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return true
+	}
+	return claimsFromContext(r.Context()) != nil
+}
+
+// cacheable reports whether res may be served to other clients.
+func cacheable(res *mrpcproxy.Response) bool {
+	if len(res.Cookies) > 0 || res.Headers.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, f := range varyFields(res.Headers) {
+		if f == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey identifies a request by method, host, path, sorted query, tenant
+// and locale.
+func cacheKey(r *http.Request) string {
+	key := r.Method + " " + r.Host + r.URL.Path + "?" + r.URL.Query().Encode()
+	if tenant := TenantFromContext(r.Context()); tenant != "" {
+		key += "\ntenant=" + tenant
+	}
+	if locale := LocaleFromContext(r.Context()); locale != "" {
+		key += "\nlocale=" + locale
+	}
+	return key
+}
+
+// varyFields returns the header names listed by the Vary headers of h.
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, http.CanonicalHeaderKey(f))
+			}
+		}
+	}
+	return fields
+}
+
+// varyKey extends key with the values of the request headers in fields.
+func varyKey(key string, fields []string, r *http.Request) string {
+	for _, f := range fields {
+		key += "\n" + f + ": " + strings.Join(r.Header.Values(f), ",")
+	}
+	return key
+}
+
+// cacheTTL returns how long a response with headers h may be cached.
+func cacheTTL(h http.Header, def time.Duration) time.Duration {
+	cc := h.Get("Cache-Control")
+	if cc == "" {
+		return def
+	}
+
+	ttl := def
+	for _, directive := range strings.Split(cc, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store", directive == "no-cache", directive == "private":
+			return 0
+		case strings.HasPrefix(directive, "s-maxage="):
+			if s, err := strconv.Atoi(directive[len("s-maxage="):]); err == nil {
+				return time.Duration(s) * time.Second
+			}
+		case strings.HasPrefix(directive, "max-age="):
+			if s, err := strconv.Atoi(directive[len("max-age="):]); err == nil {
+				ttl = time.Duration(s) * time.Second
+			}
+		}
+	}
+
+	return ttl
+}
+
+// LRUCache is an in-memory Cache evicting the least recently used entries.
+type LRUCache struct {
+	lru *lru
+}
+
+// NewLRUCache creates a cache holding at most size responses.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{newLRU(size)}
+}
+
+// Get returns the cached response for key if it hasn't expired.
+func (c *LRUCache) Get(key string) (*mrpcproxy.Response, bool) {
+	v, ok := c.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*mrpcproxy.Response), true
+}
+
+// Set caches res under key for ttl.
+func (c *LRUCache) Set(key string, res *mrpcproxy.Response, ttl time.Duration) {
+	c.lru.set(key, res, ttl)
+}
+
+// lru is an in-memory store of expiring values evicting the least recently
+// used ones.
+type lru struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		now:     time.Now,
+		list:    list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the value of key if it hasn't expired.
+func (c *lru) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*lruEntry)
+	if c.now().After(e.expires) {
+		c.list.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.list.MoveToFront(el)
+	return e.value, true
+}
+
+// set stores value under key for ttl.
+func (c *lru) set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, value, ttl)
+}
+
+// incr increments the int64 counter at key, created with ttl.
+func (c *lru) incr(key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok || c.now().After(el.Value.(*lruEntry).expires) {
+		c.setLocked(key, int64(1), ttl)
+		return 1, nil
+	}
+
+	e := el.Value.(*lruEntry)
+	n, ok := e.value.(int64)
+	if !ok {
+		return 0, ErrNotCounter
+	}
+	e.value = n + 1
+	c.list.MoveToFront(el)
+	return n + 1, nil
+}
+
+// each calls f with the values that haven't expired.
+func (c *lru) each(f func(key string, value interface{})) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for el := c.list.Front(); el != nil; el = el.Next() {
+		if e := el.Value.(*lruEntry); !now.After(e.expires) {
+			f(e.key, e.value)
+		}
+	}
+}
+
+func (c *lru) setLocked(key string, value interface{}, ttl time.Duration) {
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, c.now().Add(ttl)
+		c.list.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.list.PushFront(&lruEntry{key, value, c.now().Add(ttl)})
+
+	for c.list.Len() > c.size {
+		el := c.list.Back()
+		c.list.Remove(el)
+		delete(c.entries, el.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,192 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestCacheTTL(t *testing.T) {
+	cases := []struct {
+		cc  string
+		ttl time.Duration
+	}{
+		{"", time.Minute},
+		{"max-age=10", 10 * time.Second},
+		{"public, max-age=10, s-maxage=20", 20 * time.Second},
+		{"no-store", 0},
+		{"private, max-age=10", 0},
+		{"must-revalidate", time.Minute},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			h := http.Header{}
+			if tc.cc != "" {
+				h.Set("Cache-Control", tc.cc)
+			}
+			if ttl := cacheTTL(h, time.Minute); ttl != tc.ttl {
+				t.Errorf("Unexpected ttl: got %v want %v", ttl, tc.ttl)
+			}
+		})
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Now()
+	c := NewLRUCache(2)
+	c.lru.now = func() time.Time { return now }
+
+	c.Set("a", &mrpcproxy.Response{Msg: []byte("a")}, time.Second)
+	c.Set("b", &mrpcproxy.Response{Msg: []byte("b")}, time.Minute)
+	c.Get("a")
+	c.Set("c", &mrpcproxy.Response{Msg: []byte("c")}, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Least recently used entry not evicted")
+	}
+
+	if res, ok := c.Get("a"); !ok || string(res.Msg) != "a" {
+		t.Error("Recently used entry evicted")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expired entry returned")
+	}
+
+	if _, ok := c.Get("c"); !ok {
+		t.Error("Entry missing")
+	}
+}
+
+func TestCachedTopicHandler(t *testing.T) {
+	var calls int32
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("cached", func(w mrpc.TopicWriter, data []byte) {
+		n := atomic.AddInt32(&calls, 1)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(fmt.Sprint(n))})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithCache(NewLRUCache(10), time.Minute))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	h, _ := pxy.getTopicHandler(Endpoint{Topic: "service.cached", Method: "GET", Path: "/cached"})
+
+	for _, u := range []string{"/cached?a=1&b=2", "/cached?b=2&a=1"} {
+		req, _ := http.NewRequest("GET", u, nil)
+		rr := httptest.NewRecorder()
+		h(rr, req, nil)
+
+		if rr.Body.String() != "1" {
+			t.Errorf("Response not served from cache: %v", rr.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/cached?a=2", nil)
+	rr := httptest.NewRecorder()
+	h(rr, req, nil)
+	if rr.Body.String() != "2" {
+		t.Errorf("Different query served from cache: %v", rr.Body.String())
+	}
+}
+
+func TestCachePrivacy(t *testing.T) {
+	var calls int32
+	service, _ := mrpc.NewService(mem.New())
+	reply := func(res mrpcproxy.Response) func(w mrpc.TopicWriter, data []byte) {
+		return func(w mrpc.TopicWriter, data []byte) {
+			res.Code = 200
+			res.Msg = []byte(fmt.Sprint(atomic.AddInt32(&calls, 1)))
+			msg, _ := json.Marshal(&res)
+			w.Write(msg)
+		}
+	}
+	service.HandleFunc("plain", reply(mrpcproxy.Response{}))
+	service.HandleFunc("cookie", reply(mrpcproxy.Response{Cookies: []*http.Cookie{{Name: "s", Value: "1"}}}))
+	service.HandleFunc("vary", reply(mrpcproxy.Response{Headers: http.Header{"Vary": {"Accept-Language"}}}))
+	service.HandleFunc("varyall", reply(mrpcproxy.Response{Headers: http.Header{"Vary": {"*"}}}))
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		topic   string
+		headers []http.Header
+		bodies  []string
+	}{
+		{"plain", []http.Header{{"Authorization": {"Bearer a"}}, {"Authorization": {"Bearer b"}}}, []string{"1", "2"}},
+		{"plain", []http.Header{{"Cookie": {"s=a"}}, {"Cookie": {"s=a"}}}, []string{"1", "2"}},
+		{"plain", []http.Header{{}, {}}, []string{"1", "1"}},
+		{"cookie", []http.Header{{}, {}}, []string{"1", "2"}},
+		{"vary", []http.Header{{"Accept-Language": {"en"}}, {"Accept-Language": {"de"}}, {"Accept-Language": {"en"}}, {"Accept-Language": {"de"}}}, []string{"1", "2", "1", "2"}},
+		{"varyall", []http.Header{{}, {}}, []string{"1", "2"}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			pxy, _ := New(":80", service, WithCache(NewLRUCache(10), time.Minute))
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			h, _ := pxy.getTopicHandler(Endpoint{Topic: "service." + tc.topic, Method: "GET", Path: "/a"})
+
+			for j, header := range tc.headers {
+				req, _ := http.NewRequest("GET", "/a", nil)
+				req.Header = header
+				rr := httptest.NewRecorder()
+				h(rr, req, nil)
+
+				if rr.Body.String() != tc.bodies[j] {
+					t.Errorf("Unexpected body of request %v: got %v want %v", j, rr.Body.String(), tc.bodies[j])
+				}
+			}
+		})
+	}
+}
+
+func TestCachedResponseTransformer(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("t", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("1"), Headers: http.Header{"X-Wrapped": {"0"}}})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithCache(NewLRUCache(10), time.Minute))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.ResponseTransformer = func(r *http.Request, res *mrpcproxy.Response) (*mrpcproxy.Response, error) {
+		res.Msg = append(append([]byte(`{"data":`), res.Msg...), '}')
+		res.Headers["X-Wrapped"][0] = "1"
+		return res, nil
+	}
+	pxy.Handle(Endpoint{Topic: "service.t", Method: "GET", Path: "/t"})
+
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest("GET", "/t", nil)
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+
+		if body := w.Body.String(); body != `{"data":1}` {
+			t.Errorf("Unexpected body of request %v: %v", i, body)
+		}
+		if h := w.Header().Get("X-Wrapped"); h != "1" {
+			t.Errorf("Unexpected header of request %v: %v", i, h)
+		}
+	}
+}
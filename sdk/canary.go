@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// DefaultCanaryHeader is the request header forcing the variant of canary
+// endpoints.
+const DefaultCanaryHeader = "X-Canary"
+
+// Canary routes a share of the endpoint requests to another topic.
+type Canary struct {
+	Topic string `json:"topic"`
+	// Percent of the requests sent to the canary topic, e.g. 5 or 0.5.
+	Percent float64 `json:"percent"`
+	// Header forcing the variant with the value "canary" or "stable".
+	// Defaults to DefaultCanaryHeader.
+	Header string `json:"header"`
+}
+
+// VariantStats counts the requests sent to a variant of a canary endpoint.
+type VariantStats struct {
+	Requests int64
+	// Errors are the requests failing or responding with a server error.
+	Errors int64
+}
+
+// CanaryStats reports the requests of a canary endpoint by variant.
+type CanaryStats struct {
+	Stable VariantStats
+	Canary VariantStats
+}
+
+type canaryCounters struct {
+	stable, canary VariantStats
+}
+
+func (c *canaryCounters) record(canary bool, res *mrpcproxy.Response, err error) {
+	v := &c.stable
+	if canary {
+		v = &c.canary
+	}
+
+	atomic.AddInt64(&v.Requests, 1)
+	if err != nil || res.Code >= http.StatusInternalServerError {
+		atomic.AddInt64(&v.Errors, 1)
+	}
+}
+
+type canaryRegistry struct {
+	mu       sync.Mutex
+	counters map[string]*canaryCounters
+}
+
+// canaryCounters returns the counters of ep, creating them on first use.
+func (pxy *Proxy) canaryCounters(ep Endpoint) *canaryCounters {
+	pxy.canaries.mu.Lock()
+	defer pxy.canaries.mu.Unlock()
+
+	if pxy.canaries.counters == nil {
+		pxy.canaries.counters = map[string]*canaryCounters{}
+	}
+
+	key := ep.Method + " " + ep.Host + ep.Path
+	c, ok := pxy.canaries.counters[key]
+	if !ok {
+		c = &canaryCounters{}
+		pxy.canaries.counters[key] = c
+	}
+	return c
+}
+
+// CanaryStats returns the stats of the canary endpoints keyed by method, host
+// and path, e.g. "GET /users".
+func (pxy *Proxy) CanaryStats() map[string]CanaryStats {
+	pxy.canaries.mu.Lock()
+	defer pxy.canaries.mu.Unlock()
+
+	stats := make(map[string]CanaryStats, len(pxy.canaries.counters))
+	for key, c := range pxy.canaries.counters {
+		stats[key] = CanaryStats{
+			Stable: VariantStats{atomic.LoadInt64(&c.stable.Requests), atomic.LoadInt64(&c.stable.Errors)},
+			Canary: VariantStats{atomic.LoadInt64(&c.canary.Requests), atomic.LoadInt64(&c.canary.Errors)},
+		}
+	}
+	return stats
+}
+
+// useCanary picks the variant of a request.
+func useCanary(r *http.Request, c *Canary) bool {
+	header := c.Header
+	if header == "" {
+		header = DefaultCanaryHeader
+	}
+
+	switch r.Header.Get(header) {
+	case "canary":
+		return true
+	case "stable":
+		return false
+	}
+
+	return rand.Float64()*100 < c.Percent
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestCanary(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("stable")})
+		w.Write(msg)
+	})
+	service.HandleFunc("canary", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 500, Msg: []byte("canary")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		canary  Canary
+		header  string
+		value   string
+		body    string
+		variant CanaryStats
+	}{
+		{Canary{Topic: "a.canary", Percent: 0}, "", "", "stable", CanaryStats{Stable: VariantStats{1, 0}}},
+		{Canary{Topic: "a.canary", Percent: 100}, "", "", "canary", CanaryStats{Canary: VariantStats{1, 1}}},
+		{Canary{Topic: "a.canary", Percent: 0}, DefaultCanaryHeader, "canary", "canary", CanaryStats{Canary: VariantStats{1, 1}}},
+		{Canary{Topic: "a.canary", Percent: 100, Header: "X-Variant"}, "X-Variant", "stable", "stable", CanaryStats{Stable: VariantStats{1, 0}}},
+		{Canary{Topic: "{{.name}}.canary", Percent: 100}, "", "", "canary", CanaryStats{Canary: VariantStats{1, 1}}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			canary := tc.canary
+			pxy.Handle(Endpoint{Topic: "a", Canary: &canary, Method: "GET", Path: "/:name"})
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+
+			expected := map[string]CanaryStats{"GET /:name": tc.variant}
+			if stats := pxy.CanaryStats(); !reflect.DeepEqual(stats, expected) {
+				t.Errorf("Unexpected stats %+v; expected %+v", stats, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package chirouter adapts chi to sdk.Router. It lives in its own package so
+// that the sdk doesn't depend on chi.
+package chirouter
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy/sdk"
+)
+
+// router adapts chi.Mux to sdk.Router.
+type router struct {
+	mux    *chi.Mux
+	routes map[string]httprouter.Handle
+}
+
+// New returns an sdk.Router backed by chi, given to sdk.WithRouter. Paths
+// take {name} params and a final * catch-all, whose value is the param named
+// *.
+func New(notFound, methodNotAllowed http.Handler) sdk.Router {
+	mux := chi.NewRouter()
+	mux.NotFound(notFound.ServeHTTP)
+	mux.MethodNotAllowed(methodNotAllowed.ServeHTTP)
+	return &router{mux: mux, routes: map[string]httprouter.Handle{}}
+}
+
+func (rt *router) Handle(method, path string, h httprouter.Handle) {
+	rt.mux.MethodFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, params(chi.RouteContext(r.Context())))
+	})
+	rt.routes[method+" "+path] = h
+}
+
+func (rt *router) Lookup(method, path string) (httprouter.Handle, httprouter.Params, bool) {
+	rctx := chi.NewRouteContext()
+	if !rt.mux.Match(rctx, method, path) {
+		return nil, nil, false
+	}
+	return rt.routes[method+" "+rctx.RoutePattern()], params(rctx), false
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// params returns the URL params of the route matched in rctx.
+func params(rctx *chi.Context) httprouter.Params {
+	if rctx == nil || len(rctx.URLParams.Keys) == 0 {
+		return nil
+	}
+
+	p := make(httprouter.Params, len(rctx.URLParams.Keys))
+	for i, k := range rctx.URLParams.Keys {
+		p[i] = httprouter.Param{Key: k, Value: rctx.URLParams.Values[i]}
+	}
+	return p
+}
@@ -0,0 +1,108 @@
+package chirouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+	"github.com/miracl/mrpcproxy/sdk"
+)
+
+// nopLogger discards the logs.
+type nopLogger struct{}
+
+func (nopLogger) Println(v ...interface{})               {}
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+func TestRouter(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"users", "files"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			req := mrpcproxy.Request{}
+			json.Unmarshal(data, &req)
+			body := topic + ":" + req.Params.Get("id") + req.Params.Get("*")
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(body)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := sdk.New(":80", service, sdk.WithRouter(New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Logger = nopLogger{}
+	pxy.Requests = nopLogger{}
+	pxy.Handle(
+		sdk.Endpoint{Method: "GET", Path: "/users/{id:[0-9]+}", Topic: "users"},
+		sdk.Endpoint{Method: "GET", Path: "/files/*", Topic: "files"},
+	)
+
+	cases := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"GET", "/users/1", 200, "users:1"},
+		{"HEAD", "/users/1", 200, ""},
+		{"GET", "/files/a/b.txt", 200, "files:a/b.txt"},
+		{"GET", "/users/a", 404, ""},
+		{"POST", "/users/1", 405, ""},
+		{"GET", "/missing", 404, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if tc.body != "" {
+				if body := strings.TrimSpace(w.Body.String()); body != tc.body {
+					t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterLookup(t *testing.T) {
+	rt := New(http.NotFoundHandler(), http.NotFoundHandler())
+	rt.Handle("GET", "/a/{id}/b/*", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {})
+
+	cases := []struct {
+		method, path string
+		found        bool
+		id, rest     string
+	}{
+		{"GET", "/a/1/b/c/d", true, "1", "c/d"},
+		{"POST", "/a/1/b/c", false, "", ""},
+		{"GET", "/a/1", false, "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			h, p, _ := rt.Lookup(tc.method, tc.path)
+			if (h != nil) != tc.found {
+				t.Fatalf("Unexpected handler: got %v want %v", h != nil, tc.found)
+			}
+			if p.ByName("id") != tc.id || p.ByName("*") != tc.rest {
+				t.Errorf("Unexpected params: %v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+var (
+	// ErrPartFailed is logged when a part of a chunked response body fails.
+	ErrPartFailed = errors.New("response part failed")
+)
+
+// writeParts writes the body of a response split in parts, see
+// mrpcproxy.Response.Next. Each part is flushed to the client as it arrives
+// instead of buffering the whole body. A failing part aborts the response so
+// the client sees it truncated.
+func (pxy *Proxy) writeParts(w http.ResponseWriter, r *http.Request, p httprouter.Params, ep Endpoint, res *mrpcproxy.Response) {
+	w.Header().Del("Content-Length")
+	w.WriteHeader(res.Code)
+
+	rc := http.NewResponseController(w)
+	timeout := pxy.timeout(r, ep)
+	for {
+		if _, err := w.Write(res.Msg); err != nil {
+			printfCtx(r.Context(), pxy.Logger, "writing to http.ResponseWriter failed: %v", err)
+			return
+		}
+		if res.Next == "" {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			printlnCtx(r.Context(), pxy.Debugger, err)
+		}
+
+		req := pxy.newRequest(res.RequestID, res.Next, ep.Method)
+		// The params were accepted with the first part.
+		pxy.setRequestParams(req, r, p)
+
+		next, err := pxy.Call(r.Context(), res.Next, req, timeout)
+		if err == nil && next.Code >= http.StatusBadRequest {
+			err = fmt.Errorf("%w: status %v", ErrPartFailed, next.Code)
+		}
+		if err != nil {
+			printlnCtx(r.Context(), pxy.Debugger, err)
+			pxy.logRequest(r, "%v:%v, part topic: %v, aborted: %v, Id: %v", r.Method, r.URL.Path, res.Next, err, res.RequestID)
+			panic(http.ErrAbortHandler)
+		}
+		res = next
+	}
+}
@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestWriteParts(t *testing.T) {
+	released := make(chan struct{})
+	parts := map[string]mrpcproxy.Response{
+		"a":      {Code: 200, Msg: []byte("a"), Next: "b"},
+		"b":      {Code: 200, Msg: []byte("b"), Next: "c"},
+		"c":      {Code: 200, Msg: []byte("c")},
+		"first":  {Code: 200, Msg: []byte("first"), Next: "second"},
+		"second": {Code: 200, Msg: []byte("second")},
+		"broken": {Code: 200, Msg: []byte("x"), Next: "failed"},
+		"failed": {Code: 500},
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	for topic, res := range parts {
+		topic, res := topic, res
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			if topic == "second" {
+				<-released
+			}
+			msg, _ := json.Marshal(&res)
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Debugger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Topic: "a", Method: "GET", Path: "/abc"},
+		Endpoint{Topic: "first", Method: "GET", Path: "/slow"},
+		Endpoint{Topic: "broken", Method: "GET", Path: "/broken"},
+	)
+
+	s := httptest.NewServer(pxy)
+	defer s.Close()
+
+	t.Run("Parts", func(t *testing.T) {
+		res, err := http.Get(s.URL + "/abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "abc" {
+			t.Errorf("Unexpected body %q", body)
+		}
+		if fmt.Sprint(res.TransferEncoding) != "[chunked]" {
+			t.Errorf("Unexpected transfer encoding %v", res.TransferEncoding)
+		}
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		res, err := http.Get(s.URL + "/slow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		first := make([]byte, len("first"))
+		if _, err := io.ReadFull(res.Body, first); err != nil {
+			t.Fatal(err)
+		}
+		close(released)
+
+		rest, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(first)+string(rest) != "firstsecond" {
+			t.Errorf("Unexpected body %q", string(first)+string(rest))
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		res, err := http.Get(s.URL + "/broken")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if _, err := ioutil.ReadAll(res.Body); err == nil {
+			t.Error("Expected truncated body error")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package sdk
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies configures the proxies in front of the proxy whose
+// forwarding headers are honored.
+type TrustedProxies struct {
+	// CIDRs or addresses of the trusted proxies.
+	CIDRs []string
+	// Hops limits the number of trusted proxies skipped when resolving the
+	// client IP. Unlimited when 0.
+	Hops int
+	// Header is the forwarding header the trusted proxies append to, either
+	// Forwarded (RFC 7239) or an X-Forwarded-For like list of addresses.
+	// X-Forwarded-For by default. The other forwarding headers are ignored,
+	// as the proxies pass them through from the clients.
+	Header string
+}
+
+type trustedProxies struct {
+	nets   []*net.IPNet
+	hops   int
+	header string
+}
+
+// WithTrustedProxies resolves the client IP from the forwarding header of
+// requests coming from trusted proxies, see TrustedProxies.Header. The
+// addresses are walked from the nearest one and the first address that isn't
+// a trusted proxy is the client. Without trusted proxies the forwarding
+// headers are ignored.
+func WithTrustedProxies(cfg TrustedProxies) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		nets, err := parseCIDRs(cfg.CIDRs)
+		if err != nil {
+			return err
+		}
+
+		header := http.CanonicalHeaderKey(cfg.Header)
+		if header == "" {
+			header = "X-Forwarded-For"
+		}
+
+		pxy.trusted = &trustedProxies{nets, cfg.Hops, header}
+		return nil
+	}
+}
+
+// clientIP returns the address of the client that sent r.
+func (pxy *Proxy) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if pxy.trusted == nil {
+		return ip
+	}
+
+	chain := forwardedFor(r.Header, pxy.trusted.header)
+	for i, hops := len(chain)-1, 0; i >= 0; i, hops = i-1, hops+1 {
+		if pxy.trusted.hops > 0 && hops == pxy.trusted.hops {
+			break
+		}
+
+		parsed := net.ParseIP(ip)
+		if parsed == nil || !containsIP(pxy.trusted.nets, parsed) {
+			break
+		}
+
+		// Obfuscated and unknown identifiers end the chain.
+		if net.ParseIP(chain[i]) == nil {
+			break
+		}
+		ip = chain[i]
+	}
+
+	return ip
+}
+
+// fromTrustedProxy reports whether r was sent by a trusted proxy.
+func (pxy *Proxy) fromTrustedProxy(r *http.Request) bool {
+	if pxy.trusted == nil {
+		return false
+	}
+
+	ip := net.ParseIP(remoteIP(r))
+	return ip != nil && containsIP(pxy.trusted.nets, ip)
+}
+
+func remoteIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.Split(host, "/")[0]
+}
+
+// forwardedFor returns the client addresses forwarded in header, the nearest
+// proxy last.
+func forwardedFor(h http.Header, header string) []string {
+	var chain []string
+	if header == "Forwarded" {
+		for _, v := range h.Values(header) {
+			for _, elem := range strings.Split(v, ",") {
+				addr := ""
+				for _, pair := range strings.Split(elem, ";") {
+					pair = strings.TrimSpace(pair)
+					if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+						addr = pair[4:]
+					}
+				}
+				chain = append(chain, forwardedAddr(addr))
+			}
+		}
+		return chain
+	}
+
+	for _, v := range h.Values(header) {
+		for _, addr := range strings.Split(v, ",") {
+			chain = append(chain, forwardedAddr(addr))
+		}
+	}
+	return chain
+}
+
+// forwardedAddr strips the quotes, brackets and port of a forwarded address.
+func forwardedAddr(addr string) string {
+	addr = strings.Trim(strings.TrimSpace(addr), `"`)
+	if strings.HasPrefix(addr, "[") {
+		if i := strings.Index(addr, "]"); i > 0 {
+			return addr[1:i]
+		}
+	}
+	if h, _, err := net.SplitHostPort(addr); err == nil && strings.Count(addr, ":") == 1 {
+		return h
+	}
+	return addr
+}
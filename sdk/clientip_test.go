@@ -0,0 +1,69 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		trusted *TrustedProxies
+		remote  string
+		headers http.Header
+		ip      string
+	}{
+		{nil, "1.1.1.1:1234", nil, "1.1.1.1"},
+		{nil, "[2001:db8::1]:1234", nil, "2001:db8::1"},
+		{nil, "1.1.1.1:1234", http.Header{"X-Forwarded-For": {"2.2.2.2"}}, "1.1.1.1"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "1.1.1.1:1234", http.Header{"X-Forwarded-For": {"2.2.2.2"}}, "1.1.1.1"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"2.2.2.2"}}, "2.2.2.2"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 2.2.2.2, 10.0.0.2"}}, "2.2.2.2"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6", "2.2.2.2"}}, "2.2.2.2"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}, Hops: 1}, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"2.2.2.2, 10.0.0.2"}}, "10.0.0.2"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}, Header: "Forwarded"}, "10.0.0.1:1234", http.Header{
+			"Forwarded":       {`for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`},
+			"X-Forwarded-For": {"2.2.2.2"},
+		}, "2001:db8:cafe::17"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8", "2001:db8:cafe::/48"}, Header: "forwarded"}, "10.0.0.1:1234", http.Header{
+			"Forwarded": {`for=192.0.2.60:8080, for="[2001:db8:cafe::17]:4711"`},
+		}, "192.0.2.60"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}, Header: "Forwarded"}, "10.0.0.1:1234", http.Header{"Forwarded": {"for=_hidden"}}, "10.0.0.1"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}}, "10.0.0.1:1234", http.Header{
+			"Forwarded":       {"for=127.0.0.1"},
+			"X-Forwarded-For": {"6.6.6.6"},
+		}, "6.6.6.6"},
+		{&TrustedProxies{CIDRs: []string{"10.0.0.0/8"}, Header: "X-Real-Client"}, "10.0.0.1:1234", http.Header{
+			"X-Real-Client":   {"3.3.3.3"},
+			"X-Forwarded-For": {"6.6.6.6"},
+		}, "3.3.3.3"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			var opts []func(*Proxy) error
+			if tc.trusted != nil {
+				opts = append(opts, WithTrustedProxies(*tc.trusted))
+			}
+			pxy, err := New(":80", service, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remote
+			if tc.headers != nil {
+				r.Header = tc.headers
+			}
+
+			if ip := pxy.clientIP(r); ip != tc.ip {
+				t.Errorf("Unexpected IP %v; expected %v", ip, tc.ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+// coalescer tracks the MRPC requests in flight by key.
+type coalescer struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an MRPC request shared by identical requests.
+type flight struct {
+	done chan struct{}
+	res  *mrpcproxy.Response
+	err  error
+}
+
+// WithCoalescing sends a single MRPC request for identical concurrent GET
+// requests to an endpoint and answers all of them with its response.
+// Requests are identical when they have the same host, path, query, API
+// version and Accept and Accept-Language headers. Requests carrying
+// credentials are never coalesced.
+func WithCoalescing() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.coalescer = &coalescer{flights: map[string]*flight{}}
+		return nil
+	}
+}
+
+// coalescedMRPCRequest joins the MRPC request in flight for an identical
+// request or sends it.
+func (pxy *Proxy) coalescedMRPCRequest(r *http.Request, p httprouter.Params, ep Endpoint) (*mrpcproxy.Response, error) {
+	c := pxy.coalescer
+	if c == nil || r.Method != http.MethodGet || hasCredentials(r) {
+		return pxy.mrpcRequest(r, p, ep)
+	}
+
+	key := ep.Topic + " " + cacheKey(r) + "\n" + ep.version + "\n" +
+		strings.Join(r.Header.Values("Accept"), ",") + "\n" +
+		strings.Join(r.Header.Values("Accept-Language"), ",")
+
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		return f.wait(r, func() (*mrpcproxy.Response, error) { return pxy.mrpcRequest(r, p, ep) })
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	res, err := pxy.mrpcRequest(r, p, ep)
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	c.mu.Unlock()
+
+	// The waiters copy the response while the transformers may change res.
+	f.err = err
+	if err == nil {
+		f.res = cloneResponse(res)
+	}
+	close(f.done)
+
+	return res, err
+}
+
+// wait returns a copy of the response of the flight. The request is sent
+// with send when the flight was cancelled by the client that started it.
+func (f *flight) wait(r *http.Request, send func() (*mrpcproxy.Response, error)) (*mrpcproxy.Response, error) {
+	select {
+	case <-f.done:
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+
+	if errors.Is(f.err, context.Canceled) {
+		return send()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	res := cloneResponse(f.res)
+	res.RequestID = RequestIDFromContext(r.Context())
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestCoalescing(t *testing.T) {
+	var calls int32
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("items", func(w mrpc.TopicWriter, data []byte) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("items")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		coalescing bool
+		paths      []string
+		auth       string
+		calls      int32
+	}{
+		{true, []string{"/items", "/items", "/items", "/items"}, "", 1},
+		{true, []string{"/items", "/items", "/items?page=2", "/items?page=2"}, "", 2},
+		{true, []string{"/items", "/items"}, "Bearer token", 2},
+		{false, []string{"/items", "/items", "/items"}, "", 3},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			var opts []func(*Proxy) error
+			if tc.coalescing {
+				opts = append(opts, WithCoalescing())
+			}
+			pxy, _ := New(":80", service, opts...)
+			pxy.Requests = &MockLogger{}
+			pxy.Handle(Endpoint{Method: "GET", Path: "/items", Topic: "items"})
+			atomic.StoreInt32(&calls, 0)
+
+			var wg sync.WaitGroup
+			for _, path := range tc.paths {
+				wg.Add(1)
+				go func(path string) {
+					defer wg.Done()
+					r, _ := http.NewRequest("GET", path, nil)
+					if tc.auth != "" {
+						r.Header.Set("Authorization", tc.auth)
+					}
+					w := httptest.NewRecorder()
+					pxy.ServeHTTP(w, r)
+					if w.Code != http.StatusOK || w.Body.String() != "items" {
+						t.Errorf("Unexpected response: %v %q", w.Code, w.Body.String())
+					}
+				}(path)
+			}
+			wg.Wait()
+
+			if n := atomic.LoadInt32(&calls); n != tc.calls {
+				t.Errorf("Unexpected MRPC calls: got %v want %v", n, tc.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package sdk
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const defaultCompressionMinSize = 1024
+
+// Supported content encodings.
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+var compressors = map[string]func(io.Writer) resetWriteCloser{
+	EncodingBrotli: func(w io.Writer) resetWriteCloser { return brotli.NewWriter(w) },
+	EncodingGzip:   func(w io.Writer) resetWriteCloser { return gzip.NewWriter(w) },
+	EncodingDeflate: func(w io.Writer) resetWriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	},
+}
+
+// CompressionConfig configures response compression.
+type CompressionConfig struct {
+	// MinSize is the smallest body in bytes that gets compressed. Defaults to
+	// 1024.
+	MinSize int
+	// ExcludedContentTypes are media types that are sent uncompressed, e.g.
+	// already compressed images. Matched by prefix.
+	ExcludedContentTypes []string
+	// Encodings in order of server preference. Defaults to br, gzip, deflate.
+	Encodings []string
+}
+
+// WithCompression compresses response bodies with the best encoding accepted
+// by the client.
+func WithCompression(cfg CompressionConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.MinSize == 0 {
+			cfg.MinSize = defaultCompressionMinSize
+		}
+		if cfg.Encodings == nil {
+			cfg.Encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+		}
+		pxy.compression = &cfg
+		return nil
+	}
+}
+
+// compress returns body encoded according to the request Accept-Encoding and
+// sets the matching response headers. The body is returned unchanged when
+// compression is disabled or not applicable.
+func (pxy *Proxy) compress(w http.ResponseWriter, r *http.Request, code int, body []byte) []byte {
+	cfg := pxy.compression
+	if cfg == nil || len(body) < cfg.MinSize || code == http.StatusNoContent || code == http.StatusNotModified {
+		return body
+	}
+
+	if w.Header().Get("Content-Encoding") != "" {
+		return body
+	}
+
+	ct := w.Header().Get("Content-Type")
+	for _, excluded := range cfg.ExcludedContentTypes {
+		if strings.HasPrefix(ct, excluded) {
+			return body
+		}
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+	if enc == "" {
+		return body
+	}
+
+	var buf bytes.Buffer
+	cw := getCompressor(enc, &buf)
+	defer releaseCompressor(enc, cw)
+	if _, err := cw.Write(body); err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		return body
+	}
+	if err := cw.Close(); err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		return body
+	}
+
+	w.Header().Set("Content-Encoding", enc)
+	w.Header().Del("Content-Length")
+	return buf.Bytes()
+}
+
+// negotiateEncoding picks the supported encoding with the highest q-value in
+// the Accept-Encoding header, ties broken by the order of supported.
+func negotiateEncoding(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		q[name] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		if _, ok := compressors[enc]; !ok {
+			continue
+		}
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+
+	return best
+}
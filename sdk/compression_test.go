@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	cases := []struct {
+		accept string
+		enc    string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", EncodingGzip},
+		{"*", EncodingBrotli},
+		{"br;q=0, *;q=0.1", EncodingGzip},
+		{"identity", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if enc := negotiateEncoding(tc.accept, supported); enc != tc.enc {
+				t.Errorf("Unexpected encoding: got %q want %q", enc, tc.enc)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"key":"value"}`), 100)
+	decoders := map[string]func(io.Reader) io.Reader{
+		"":              func(r io.Reader) io.Reader { return r },
+		EncodingGzip:    func(r io.Reader) io.Reader { gr, _ := gzip.NewReader(r); return gr },
+		EncodingDeflate: func(r io.Reader) io.Reader { return flate.NewReader(r) },
+		EncodingBrotli:  func(r io.Reader) io.Reader { return brotli.NewReader(r) },
+	}
+
+	cases := []struct {
+		accept      string
+		contentType string
+		body        []byte
+		enc         string
+	}{
+		{"gzip", "application/json", body, EncodingGzip},
+		{"deflate", "application/json", body, EncodingDeflate},
+		{"br", "application/json", body, EncodingBrotli},
+		{"gzip", "image/png", body, ""},
+		{"gzip", "application/json", []byte("small"), ""},
+		{"", "application/json", body, ""},
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service, WithCompression(CompressionConfig{ExcludedContentTypes: []string{"image/"}}))
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", tc.accept)
+			w := httptest.NewRecorder()
+			w.Header().Set("Content-Type", tc.contentType)
+
+			out := pxy.compress(w, r, http.StatusOK, tc.body)
+
+			if enc := w.Header().Get("Content-Encoding"); enc != tc.enc {
+				t.Fatalf("Unexpected Content-Encoding: got %q want %q", enc, tc.enc)
+			}
+
+			decoded, err := ioutil.ReadAll(decoders[tc.enc](bytes.NewReader(out)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, tc.body) {
+				t.Errorf("Body doesn't round trip")
+			}
+		})
+	}
+}
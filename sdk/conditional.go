@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// notModified returns the 304 Not Modified response to the GET request r
+// when the successful res wasn't modified since the If-Modified-Since date
+// of r, and res otherwise. If-Modified-Since is ignored with If-None-Match.
+func notModified(r *http.Request, res *mrpcproxy.Response) *mrpcproxy.Response {
+	if res.LastModified == 0 || res.Code != http.StatusOK || r.Method != "GET" && r.Method != "HEAD" {
+		return res
+	}
+	if r.Header.Get("If-None-Match") != "" {
+		return res
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified(res).After(since) {
+		return res
+	}
+
+	nm := *res
+	nm.Code = http.StatusNotModified
+	nm.Msg = nil
+	nm.BodyRef = ""
+	nm.Next = ""
+	return &nm
+}
+
+// setLastModified sets the Last-Modified header of the response res.
+func setLastModified(w http.ResponseWriter, res *mrpcproxy.Response) {
+	if res.LastModified != 0 {
+		w.Header().Set("Last-Modified", lastModified(res).Format(http.TimeFormat))
+	}
+}
+
+// lastModified returns the last modification of res, truncated to the
+// second precision of the HTTP dates.
+func lastModified(res *mrpcproxy.Response) time.Time {
+	return time.Unix(0, res.LastModified).UTC().Truncate(time.Second)
+}
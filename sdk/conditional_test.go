@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestConditionalGet(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("doc", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("doc"), LastModified: modified.UnixNano()})
+		w.Write(msg)
+	})
+	service.HandleFunc("missing", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 404, Msg: []byte("missing"), LastModified: modified.UnixNano()})
+		w.Write(msg)
+	})
+	service.HandleFunc("plain", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("plain")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/doc", Topic: "doc"},
+		Endpoint{Method: "POST", Path: "/doc", Topic: "doc"},
+		Endpoint{Method: "GET", Path: "/missing", Topic: "missing"},
+		Endpoint{Method: "GET", Path: "/plain", Topic: "plain"},
+	)
+
+	lastModified := "Fri, 01 Mar 2024 12:00:00 GMT"
+	cases := []struct {
+		method       string
+		path         string
+		header       http.Header
+		status       int
+		body         string
+		lastModified string
+	}{
+		{"GET", "/doc", http.Header{}, 200, "doc", lastModified},
+		{"GET", "/doc", http.Header{"If-Modified-Since": {lastModified}}, 304, "", lastModified},
+		{"GET", "/doc", http.Header{"If-Modified-Since": {"Sat, 02 Mar 2024 00:00:00 GMT"}}, 304, "", lastModified},
+		{"GET", "/doc", http.Header{"If-Modified-Since": {"Fri, 01 Mar 2024 11:59:59 GMT"}}, 200, "doc", lastModified},
+		{"GET", "/doc", http.Header{"If-Modified-Since": {"yesterday"}}, 200, "doc", lastModified},
+		{"GET", "/doc", http.Header{"If-Modified-Since": {lastModified}, "If-None-Match": {`"a"`}}, 200, "doc", lastModified},
+		{"HEAD", "/doc", http.Header{"If-Modified-Since": {lastModified}}, 304, "", lastModified},
+		{"POST", "/doc", http.Header{"If-Modified-Since": {lastModified}}, 200, "doc", lastModified},
+		{"GET", "/missing", http.Header{"If-Modified-Since": {lastModified}}, 404, "missing", lastModified},
+		{"GET", "/plain", http.Header{"If-Modified-Since": {lastModified}}, 200, "plain", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			r.Header = tc.header
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			body := tc.body
+			if tc.method == "HEAD" {
+				body = ""
+			}
+			if w.Code != tc.status || w.Body.String() != body {
+				t.Errorf("Unexpected response: got %v %q want %v %q", w.Code, w.Body.String(), tc.status, body)
+			}
+			if lm := w.Header().Get("Last-Modified"); lm != tc.lastModified {
+				t.Errorf("Unexpected Last-Modified: got %q want %q", lm, tc.lastModified)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package sdk
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/miracl/mrpc"
+)
+
+const (
+	defaultAddr = ":8080"
+
+	// LogLevelDebug logs the debug messages, requests and proxy messages.
+	LogLevelDebug = "debug"
+	// LogLevelInfo logs the requests and proxy messages, the default.
+	LogLevelInfo = "info"
+	// LogLevelQuiet logs only the proxy messages.
+	LogLevelQuiet = "quiet"
+)
+
+var (
+	// ErrInvalidLogLevel is returned by NewFromConfig for unknown log levels.
+	ErrInvalidLogLevel = errors.New("invalid log level")
+)
+
+// Config configures a proxy built by NewFromConfig. The fields other than
+// Service and Options can be read from the environment with ConfigFromEnv
+// and from the command line with Flags.
+type Config struct {
+	// Addr is the address of the HTTP server, :8080 by default. Env ADDR.
+	Addr string
+	// Timeout is the default MRPC timeout, 1 second by default. Env TIMEOUT
+	// as a duration, e.g. 500ms.
+	Timeout time.Duration
+	// MaxTimeout caps the endpoint and request timeouts. Env MAX_TIMEOUT.
+	MaxTimeout time.Duration
+	// TimeoutHeader is the request header overriding the timeout. Env
+	// TIMEOUT_HEADER.
+	TimeoutHeader string
+	// MaxInFlight caps the requests handled at once. Env MAX_IN_FLIGHT.
+	MaxInFlight int64
+
+	// CertFile and KeyFile serve HTTPS when set. Env CERT_FILE and KEY_FILE.
+	CertFile string
+	KeyFile  string
+
+	// LogLevel is debug, info or quiet, info by default. Env LOG_LEVEL.
+	LogLevel string
+
+	// EndpointsFile is an endpoints JSON file, see ParseMapping, whose
+	// endpoints are added to the proxy. Env ENDPOINTS_FILE.
+	EndpointsFile string
+	// TopicPrefix is prepended to the endpoint topics, see WithTopicPrefix.
+	// Env TOPIC_PREFIX.
+	TopicPrefix string
+
+	// Service is the MRPC service of the proxy.
+	Service *mrpc.Service
+	// Options are applied after the configuration.
+	Options []func(*Proxy) error
+}
+
+// ConfigFromEnv reads the configuration from the environment variables
+// named after the fields with prefix prepended, e.g. MRPCPROXY_ADDR for the
+// prefix MRPCPROXY_. Unset variables leave the defaults.
+func ConfigFromEnv(prefix string) (Config, error) {
+	cfg := Config{}
+	env := func(name string) (string, bool) {
+		return os.LookupEnv(prefix + name)
+	}
+
+	texts := map[string]*string{
+		"ADDR":           &cfg.Addr,
+		"TIMEOUT_HEADER": &cfg.TimeoutHeader,
+		"CERT_FILE":      &cfg.CertFile,
+		"KEY_FILE":       &cfg.KeyFile,
+		"LOG_LEVEL":      &cfg.LogLevel,
+		"ENDPOINTS_FILE": &cfg.EndpointsFile,
+		"TOPIC_PREFIX":   &cfg.TopicPrefix,
+	}
+	for name, field := range texts {
+		if v, ok := env(name); ok {
+			*field = v
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"TIMEOUT":     &cfg.Timeout,
+		"MAX_TIMEOUT": &cfg.MaxTimeout,
+	}
+	for name, field := range durations {
+		v, ok := env(name)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("%v%v: %v", prefix, name, err)
+		}
+		*field = d
+	}
+
+	if v, ok := env("MAX_IN_FLIGHT"); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("%vMAX_IN_FLIGHT: %v", prefix, err)
+		}
+		cfg.MaxInFlight = n
+	}
+
+	return cfg, nil
+}
+
+// Flags registers the fields read by ConfigFromEnv as flags of fs, with the
+// current values as defaults, so that flags override the environment.
+func (c *Config) Flags(fs *flag.FlagSet) {
+	if c.Addr == "" {
+		c.Addr = defaultAddr
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = LogLevelInfo
+	}
+
+	fs.StringVar(&c.Addr, "addr", c.Addr, "Address of the HTTP server")
+	fs.DurationVar(&c.Timeout, "timeout", c.Timeout, "Default MRPC timeout")
+	fs.DurationVar(&c.MaxTimeout, "max-timeout", c.MaxTimeout, "Maximum MRPC timeout")
+	fs.StringVar(&c.TimeoutHeader, "timeout-header", c.TimeoutHeader, "Request header overriding the timeout")
+	fs.Int64Var(&c.MaxInFlight, "max-in-flight", c.MaxInFlight, "Maximum requests handled at once")
+	fs.StringVar(&c.CertFile, "cert-file", c.CertFile, "TLS certificate file")
+	fs.StringVar(&c.KeyFile, "key-file", c.KeyFile, "TLS key file")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "Log level: debug, info or quiet")
+	fs.StringVar(&c.EndpointsFile, "endpoints", c.EndpointsFile, "Endpoints JSON file")
+	fs.StringVar(&c.TopicPrefix, "topic-prefix", c.TopicPrefix, "Prefix of the endpoint topics")
+}
+
+// NewFromConfig creates a proxy configured by cfg and adds the endpoints of
+// its endpoints file.
+func NewFromConfig(cfg Config) (*Proxy, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = defaultAddr
+	}
+
+	opts := []func(*Proxy) error{
+		func(pxy *Proxy) error {
+			if cfg.Timeout > 0 {
+				pxy.Timeout = cfg.Timeout
+			}
+			pxy.MaxTimeout = cfg.MaxTimeout
+			pxy.TimeoutHeader = cfg.TimeoutHeader
+			pxy.MaxInFlight = cfg.MaxInFlight
+			return setLogLevel(pxy, cfg.LogLevel)
+		},
+	}
+	if cfg.CertFile != "" {
+		opts = append(opts, WithTLS(cfg.CertFile, cfg.KeyFile))
+	}
+	if cfg.TopicPrefix != "" {
+		opts = append(opts, WithTopicPrefix(cfg.TopicPrefix))
+	}
+
+	pxy, err := New(cfg.Addr, cfg.Service, append(opts, cfg.Options...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.EndpointsFile != "" {
+		data, err := ioutil.ReadFile(cfg.EndpointsFile)
+		if err != nil {
+			return nil, err
+		}
+		eps, err := ParseMapping(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := pxy.Handle(eps...); err != nil {
+			return nil, err
+		}
+	}
+
+	return pxy, nil
+}
+
+// setLogLevel discards the loggers below level.
+func setLogLevel(pxy *Proxy, level string) error {
+	discard := log.New(ioutil.Discard, "", 0)
+	switch level {
+	case LogLevelDebug:
+	case LogLevelInfo, "":
+		pxy.Debugger = discard
+	case LogLevelQuiet:
+		pxy.Debugger = discard
+		pxy.Requests = discard
+	default:
+		return ErrInvalidLogLevel
+	}
+	return nil
+}
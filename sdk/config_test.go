@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"MRPCPROXY_TEST_ADDR":           ":9000",
+		"MRPCPROXY_TEST_TIMEOUT":        "250ms",
+		"MRPCPROXY_TEST_MAX_IN_FLIGHT":  "100",
+		"MRPCPROXY_TEST_LOG_LEVEL":      "debug",
+		"MRPCPROXY_TEST_ENDPOINTS_FILE": "endpoints.json",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	cfg, err := ConfigFromEnv("MRPCPROXY_TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Config{Addr: ":9000", Timeout: 250 * time.Millisecond, MaxInFlight: 100, LogLevel: "debug", EndpointsFile: "endpoints.json"}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Unexpected config %+v; expected %+v", cfg, expected)
+	}
+
+	// Flags override the environment.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.Flags(fs)
+	if err := fs.Parse([]string{"-addr", ":9001", "-topic-prefix", "staging."}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9001" || cfg.TopicPrefix != "staging." || cfg.Timeout != 250*time.Millisecond {
+		t.Errorf("Unexpected config after flags: %+v", cfg)
+	}
+
+	os.Setenv("MRPCPROXY_TEST_TIMEOUT", "soon")
+	if _, err := ConfigFromEnv("MRPCPROXY_TEST_"); err == nil {
+		t.Error("Invalid duration accepted")
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "endpoints.json")
+	ioutil.WriteFile(file, []byte(`{"/a": {"endpoints": [{"topic": "a", "method": "GET"}]}}`), 0600)
+
+	pxy, err := NewFromConfig(Config{
+		Timeout:       2 * time.Second,
+		EndpointsFile: file,
+		TopicPrefix:   "staging.",
+		Service:       &mrpc.Service{},
+		Options:       []func(*Proxy) error{WithRequestIDHeader("X-Request-ID")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pxy.http.Addr != defaultAddr || pxy.Timeout != 2*time.Second || pxy.topicPrefix != "staging." || pxy.RequestIDHeader != "X-Request-ID" {
+		t.Errorf("Unexpected proxy: %+v", pxy)
+	}
+	if _, ok := pxy.Lookup("GET", "/a"); !ok {
+		t.Error("Endpoint not added")
+	}
+
+	if _, err := NewFromConfig(Config{LogLevel: "verbose", Service: &mrpc.Service{}}); err != (FuncOptsError{ErrInvalidLogLevel}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := NewFromConfig(Config{EndpointsFile: filepath.Join(dir, "missing.json"), Service: &mrpc.Service{}}); err == nil {
+		t.Error("Missing endpoints file accepted")
+	}
+}
@@ -0,0 +1,54 @@
+package sdk
+
+import "sync/atomic"
+
+// DebugInfo is a snapshot of the proxy state, served on /debug/proxy by the
+// debug package.
+type DebugInfo struct {
+	Endpoints    []DebugEndpoint        `json:"endpoints"`
+	InFlight     int                    `json:"inFlight"`
+	MaxInFlight  int64                  `json:"maxInFlight,omitempty"`
+	Rejected     int64                  `json:"rejected,omitempty"`
+	Panics       int64                  `json:"panics,omitempty"`
+	ShuttingDown bool                   `json:"shuttingDown"`
+	Canaries     map[string]CanaryStats `json:"canaries,omitempty"`
+	Latencies    *LatencyHistogram      `json:"latencies,omitempty"`
+	Deprecated   map[string]int64       `json:"deprecated,omitempty"`
+	Throttled    map[string]int64       `json:"throttled,omitempty"`
+	Transport    TransportStatus        `json:"transport"`
+}
+
+// DebugEndpoint describes a registered endpoint.
+type DebugEndpoint struct {
+	Method string   `json:"method"`
+	Host   string   `json:"host,omitempty"`
+	Path   string   `json:"path"`
+	Topic  string   `json:"topic,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+// DebugInfo returns a snapshot of the proxy state.
+func (pxy *Proxy) DebugInfo() *DebugInfo {
+	eps := pxy.Endpoints()
+	info := &DebugInfo{
+		Endpoints:    make([]DebugEndpoint, len(eps)),
+		InFlight:     pxy.InFlight(),
+		MaxInFlight:  atomic.LoadInt64(&pxy.MaxInFlight),
+		Rejected:     pxy.Rejected(),
+		Panics:       pxy.Panics(),
+		ShuttingDown: atomic.LoadInt32(&pxy.shuttingDown) == 1,
+		Canaries:     pxy.CanaryStats(),
+		Deprecated:   pxy.DeprecatedUsage(),
+		Throttled:    pxy.Throttled(),
+		Transport:    pxy.TransportStatus(),
+	}
+	if pxy.slowLog != nil {
+		latencies := pxy.Latencies()
+		info.Latencies = &latencies
+	}
+	for i, ep := range eps {
+		info.Endpoints[i] = DebugEndpoint{ep.Method, ep.Host, ep.Path, ep.Topic, ep.Topics}
+	}
+
+	return info
+}
@@ -0,0 +1,40 @@
+// Package debug serves net/http/pprof, expvar and the proxy state of an
+// sdk.Proxy on a separate listener. It lives in its own package as importing
+// net/http/pprof and expvar registers their handlers on
+// http.DefaultServeMux.
+package debug
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/miracl/mrpcproxy/sdk"
+)
+
+// WithEndpoints serves net/http/pprof on /debug/pprof/, expvar on /debug/vars
+// and the proxy state on /debug/proxy on addr, started by Serve. The listener
+// must not be reachable publicly.
+func WithEndpoints(addr string) func(*sdk.Proxy) error {
+	return func(pxy *sdk.Proxy) error {
+		srv := &http.Server{Addr: addr, Handler: Handler(pxy)}
+		return sdk.WithServer("debug endpoints", srv)(pxy)
+	}
+}
+
+// Handler returns the handler of the debug endpoints of pxy.
+func Handler(pxy *sdk.Proxy) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/proxy", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pxy.DebugInfo())
+	})
+	return mux
+}
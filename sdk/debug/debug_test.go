@@ -0,0 +1,49 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy/sdk"
+)
+
+func TestHandler(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, err := sdk.New(":80", service, WithEndpoints(":0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Handle(sdk.Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+	h := Handler(pxy)
+
+	r, _ := http.NewRequest("GET", "/debug/proxy", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	info := &sdk.DebugInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), info); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &sdk.DebugInfo{
+		Endpoints: []sdk.DebugEndpoint{{Method: "GET", Path: "/a", Topic: "service.a"}},
+		Transport: sdk.TransportStatus{Connected: true},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Unexpected info %+v; expected %+v", info, expected)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("Unexpected code %v for %v", w.Code, path)
+		}
+	}
+}
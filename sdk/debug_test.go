@@ -0,0 +1,30 @@
+package sdk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestDebugInfo(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Handle(
+		Endpoint{Topic: "service.a", Method: "GET", Path: "/a"},
+		Endpoint{Topics: []string{"service.a", "service.b"}, Host: "api.example.com", Method: "GET", Path: "/ab"},
+	)
+
+	expected := []DebugEndpoint{
+		{Method: "GET", Path: "/a", Topic: "service.a"},
+		{Method: "GET", Host: "api.example.com", Path: "/ab", Topics: []string{"service.a", "service.b"}},
+	}
+	info := pxy.DebugInfo()
+	if !reflect.DeepEqual(info.Endpoints, expected) {
+		t.Errorf("Unexpected endpoints %+v; expected %+v", info.Endpoints, expected)
+	}
+	if info.InFlight != 0 || info.ShuttingDown {
+		t.Errorf("Unexpected state %+v", info)
+	}
+}
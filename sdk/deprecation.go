@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Deprecation marks an endpoint deprecated, driving the migration of its
+// clients with the Deprecation, Sunset and Link headers.
+type Deprecation struct {
+	// Since is when the endpoint was deprecated. The Deprecation header is
+	// "true" when zero.
+	Since time.Time `json:"since"`
+	// Sunset is when the endpoint stops being served, sent as the Sunset
+	// header when set.
+	Sunset time.Time `json:"sunset"`
+	// Replacement links to the endpoint replacing this one, sent as a Link
+	// header with the successor-version relation when set.
+	Replacement string `json:"replacement"`
+}
+
+type deprecationRegistry struct {
+	mu       sync.Mutex
+	counters map[string]*int64
+}
+
+// deprecate sets the deprecation headers of the responses of deprecated
+// endpoints and counts their requests.
+func (pxy *Proxy) deprecate(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	d := ep.Deprecated
+	if d == nil {
+		return h
+	}
+
+	deprecation := "true"
+	if !d.Since.IsZero() {
+		deprecation = fmt.Sprintf("@%v", d.Since.Unix())
+	}
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	var link string
+	if d.Replacement != "" {
+		link = fmt.Sprintf(`<%v>; rel="successor-version"`, d.Replacement)
+	}
+	count := pxy.deprecationCounter(ep)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		atomic.AddInt64(count, 1)
+		w.Header().Set("Deprecation", deprecation)
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		if link != "" {
+			w.Header().Add("Link", link)
+		}
+		h(w, r, p)
+	}
+}
+
+// deprecationCounter returns the request counter of ep, creating it on first
+// use.
+func (pxy *Proxy) deprecationCounter(ep Endpoint) *int64 {
+	pxy.deprecations.mu.Lock()
+	defer pxy.deprecations.mu.Unlock()
+
+	if pxy.deprecations.counters == nil {
+		pxy.deprecations.counters = map[string]*int64{}
+	}
+
+	key := ep.Method + " " + ep.Host + ep.Path
+	c, ok := pxy.deprecations.counters[key]
+	if !ok {
+		c = new(int64)
+		pxy.deprecations.counters[key] = c
+	}
+	return c
+}
+
+// DeprecatedUsage returns the number of requests to the deprecated endpoints
+// keyed by method, host and path, e.g. "GET /users".
+func (pxy *Proxy) DeprecatedUsage() map[string]int64 {
+	pxy.deprecations.mu.Lock()
+	defer pxy.deprecations.mu.Unlock()
+
+	usage := make(map[string]int64, len(pxy.deprecations.counters))
+	for key, c := range pxy.deprecations.counters {
+		usage[key] = atomic.LoadInt64(c)
+	}
+	return usage
+}
@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestDeprecatedEndpoints(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("users", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/users", Topic: "users"},
+		Endpoint{Method: "GET", Path: "/old/users", Topic: "users", Deprecated: &Deprecation{
+			Since:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Sunset:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600)),
+			Replacement: "https://api.example.com/users",
+		}},
+		Endpoint{Method: "POST", Path: "/old/users", Topic: "missing", KeepAlive: 10, Deprecated: &Deprecation{}},
+	)
+
+	cases := []struct {
+		method      string
+		path        string
+		status      int
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{"GET", "/users", 200, "", "", ""},
+		{"GET", "/old/users", 200, "@1704067200", "Tue, 31 Dec 2024 23:00:00 GMT", `<https://api.example.com/users>; rel="successor-version"`},
+		{"POST", "/old/users", 504, "true", "", ""},
+		{"GET", "/old/users", 200, "@1704067200", "Tue, 31 Dec 2024 23:00:00 GMT", `<https://api.example.com/users>; rel="successor-version"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			h := w.Header()
+			if w.Code != tc.status || h.Get("Deprecation") != tc.deprecation || h.Get("Sunset") != tc.sunset || h.Get("Link") != tc.link {
+				t.Errorf("Unexpected response: got %v %v", w.Code, h)
+			}
+		})
+	}
+
+	usage := map[string]int64{"GET /old/users": 2, "POST /old/users": 1}
+	if u := pxy.DeprecatedUsage(); !reflect.DeepEqual(u, usage) {
+		t.Errorf("Unexpected usage: got %v want %v", u, usage)
+	}
+	if info := pxy.DebugInfo(); !reflect.DeepEqual(info.Deprecated, usage) {
+		t.Errorf("Unexpected debug info usage: %v", info.Deprecated)
+	}
+}
@@ -0,0 +1,198 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const defaultDumpMaxBodySize = 64 << 10
+
+var (
+	// ErrNoDumps is returned by SetDumping without WithRequestDumps.
+	ErrNoDumps = errors.New("request dumps not configured")
+
+	// defaultDumpRedactFields are always redacted from dumps.
+	defaultDumpRedactFields = []string{"password"}
+)
+
+// DumpConfig configures the dumps of the endpoint requests.
+type DumpConfig struct {
+	// Enabled dumps the requests from the start. Dumping is turned on and
+	// off with SetDumping or /admin/dumps.
+	Enabled bool
+	// RedactHeaders are redacted in addition to Authorization, Cookie and
+	// Set-Cookie.
+	RedactHeaders []string
+	// RedactFields are the names of the query parameters and of the JSON and
+	// form body fields redacted in addition to password, compared
+	// case-insensitively.
+	RedactFields []string
+	// MaxBodySize limits the dumped bodies, 64KiB by default. Larger bodies
+	// are left out, as they cannot be redacted.
+	MaxBodySize int
+}
+
+// dumper redacts and dumps the endpoint requests while enabled.
+type dumper struct {
+	redactor
+	maxBody int
+	enabled int32
+}
+
+// adminDumps is the body of /admin/dumps.
+type adminDumps struct {
+	Enabled bool `json:"enabled"`
+}
+
+// WithRequestDumps dumps the endpoint requests and their responses to
+// Debugger while enabled, with the credentials headers and the fields of cfg
+// redacted. It is meant for debugging in production, where dumping is turned
+// on for a while with SetDumping or the admin API.
+func WithRequestDumps(cfg DumpConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.MaxBodySize <= 0 {
+			cfg.MaxBodySize = defaultDumpMaxBodySize
+		}
+
+		d := &dumper{
+			redactor: newRedactor(cfg.RedactHeaders, append(append([]string(nil), defaultDumpRedactFields...), cfg.RedactFields...)),
+			maxBody:  cfg.MaxBodySize,
+		}
+		if cfg.Enabled {
+			d.enabled = 1
+		}
+
+		pxy.dumper = d
+		return nil
+	}
+}
+
+// SetDumping turns the request dumps on or off.
+func (pxy *Proxy) SetDumping(enabled bool) error {
+	d := pxy.dumper
+	if d == nil {
+		return ErrNoDumps
+	}
+
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&d.enabled, v)
+	return nil
+}
+
+// Dumping reports whether the requests are dumped.
+func (pxy *Proxy) Dumping() bool {
+	return pxy.dumper != nil && atomic.LoadInt32(&pxy.dumper.enabled) == 1
+}
+
+// dumpRequests dumps the requests handled by h while dumping is enabled.
+func (pxy *Proxy) dumpRequests(h httprouter.Handle) httprouter.Handle {
+	d := pxy.dumper
+	if d == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if atomic.LoadInt32(&d.enabled) == 0 {
+			h(w, r, p)
+			return
+		}
+
+		// One byte over the limit tells the truncated bodies.
+		var reqBody *limitedBuffer
+		if r.Body != nil {
+			reqBody = &limitedBuffer{max: d.maxBody + 1}
+			r.Body = teeReadCloser{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		sw := &sampleWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}, body: &limitedBuffer{max: d.maxBody + 1}}
+
+		h(sw, r, p)
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "dump %v:%v, Id: %v\n", r.Method, r.URL.Path, RequestIDFromContext(r.Context()))
+		fmt.Fprintf(&buf, "%v %v %v\r\nHost: %v\r\n", r.Method, d.redactURI(r.URL), r.Proto, r.Host)
+		d.dumpMessage(&buf, r.Header, reqBody)
+		fmt.Fprintf(&buf, "%v %v %v\r\n", r.Proto, sw.status, http.StatusText(sw.status))
+		d.dumpMessage(&buf, w.Header(), sw.body)
+		printlnCtx(r.Context(), pxy.Debugger, buf.String())
+	}
+}
+
+// dumpMessage writes the redacted header and body to buf.
+func (d *dumper) dumpMessage(buf *bytes.Buffer, header http.Header, body *limitedBuffer) {
+	h := header.Clone()
+	d.redactHeaders(h)
+	h.Write(buf)
+	buf.WriteString("\r\n")
+
+	switch {
+	case body == nil || body.Len() == 0:
+	case body.Len() > d.maxBody:
+		fmt.Fprintf(buf, "[body over %v bytes]\r\n", d.maxBody)
+	default:
+		buf.Write(d.redactDumpBody(header.Get("Content-Type"), body.Bytes()))
+		buf.WriteString("\r\n")
+	}
+}
+
+// redactDumpBody redacts the fields of JSON and form bodies. Other bodies
+// are kept.
+func (d *dumper) redactDumpBody(contentType string, body []byte) []byte {
+	if mt, _, _ := mime.ParseMediaType(contentType); mt != "application/x-www-form-urlencoded" {
+		return d.redactBody(body)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil || !d.redactValues(form) {
+		return body
+	}
+	return []byte(form.Encode())
+}
+
+// redactURI returns the request URI of u with the query fields redacted.
+func (d *dumper) redactURI(u *url.URL) string {
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		// The unparsed query may hide fields to redact.
+		c := *u
+		c.RawQuery = redacted
+		return c.RequestURI()
+	}
+	if !d.redactValues(q) {
+		return u.RequestURI()
+	}
+
+	c := *u
+	c.RawQuery = q.Encode()
+	return c.RequestURI()
+}
+
+func (pxy *Proxy) adminDumps(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	writeAdminJSON(w, http.StatusOK, adminDumps{pxy.Dumping()})
+}
+
+func (pxy *Proxy) adminUpdateDumps(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var d adminDumps
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	if err := pxy.SetDumping(d.Enabled); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+	pxy.Logger.Printf("admin: request dumps enabled: %v", d.Enabled)
+	writeAdminJSON(w, http.StatusOK, d)
+}
@@ -0,0 +1,135 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestRequestDumps(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(`{"user":"u","token":"t"}`)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		enabled     bool
+		contentType string
+		body        string
+		maxBody     int
+		contains    []string
+		excludes    []string
+	}{
+		{false, "application/json", `{"password":"p"}`, 0, nil, nil},
+		{true, "application/json", `{"user":"u","password":"p"}`, 0,
+			[]string{"POST /a HTTP/1.1", "Authorization: [REDACTED]", `{"password":"[REDACTED]","user":"u"}`, "HTTP/1.1 200 OK", `{"token":"[REDACTED]","user":"u"}`},
+			[]string{"Bearer secret", `"p"`, `"t"`}},
+		{true, "application/x-www-form-urlencoded", "user=u&password=p", 0,
+			[]string{"password=%5BREDACTED%5D&user=u"},
+			[]string{"password=p"}},
+		{true, "application/json", `{"password":"p"}`, 8,
+			[]string{"[body over 8 bytes]"},
+			[]string{`"p"`}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithRequestDumps(DumpConfig{RedactFields: []string{"Token"}, MaxBodySize: tc.maxBody}))
+			pxy.Requests = &MockLogger{}
+			debugger := &MockLogger{}
+			pxy.Debugger = debugger
+			pxy.Handle(Endpoint{Topic: "a", Method: "POST", Path: "/a"})
+			if err := pxy.SetDumping(tc.enabled); err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := http.NewRequest("POST", "/a", bytes.NewBufferString(tc.body))
+			r.Header.Set("Authorization", "Bearer secret")
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 {
+				t.Fatalf("Unexpected code %v", w.Code)
+			}
+			if !tc.enabled {
+				if len(debugger.storage) != 0 {
+					t.Errorf("Unexpected dump %q", debugger.storage)
+				}
+				return
+			}
+			if len(debugger.storage) != 1 {
+				t.Fatalf("Unexpected dumps %q", debugger.storage)
+			}
+
+			dump := debugger.storage[0]
+			for _, s := range tc.contains {
+				if !strings.Contains(dump, s) {
+					t.Errorf("Dump without %q: %q", s, dump)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(dump, s) {
+					t.Errorf("Dump with %q: %q", s, dump)
+				}
+			}
+		})
+	}
+}
+
+func TestSetDumping(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{})
+	if err := pxy.SetDumping(true); err != ErrNoDumps {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if pxy.Dumping() {
+		t.Error("Dumping without WithRequestDumps")
+	}
+
+	pxy, _ = New(":80", &mrpc.Service{}, WithRequestDumps(DumpConfig{Enabled: true}))
+	if !pxy.Dumping() {
+		t.Error("Dumping not enabled")
+	}
+	pxy.SetDumping(false)
+	if pxy.Dumping() {
+		t.Error("Dumping not disabled")
+	}
+}
+
+func TestDumpRedactURI(t *testing.T) {
+	d := &dumper{redactor: newRedactor(nil, []string{"password", "access_token"})}
+
+	cases := []struct {
+		uri      string
+		expected string
+	}{
+		{"/a", "/a"},
+		{"/a?b=1&c=2", "/a?b=1&c=2"},
+		{"/a?access_token=t&b=1", "/a?access_token=%5BREDACTED%5D&b=1"},
+		{"/a?Password=p", "/a?Password=%5BREDACTED%5D"},
+		{"/a?password=%zz", "/a?[REDACTED]"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			u, _ := url.ParseRequestURI(tc.uri)
+			if uri := d.redactURI(u); uri != tc.expected {
+				t.Errorf("Unexpected URI %q; expected %q", uri, tc.expected)
+			}
+		})
+	}
+}
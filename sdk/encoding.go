@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// Envelope encodings selectable by Endpoint.Encoding.
+const (
+	EnvelopeJSON        = "json"
+	EnvelopeProto       = "proto"
+	EnvelopeMessagePack = "msgpack"
+)
+
+var envelopes = map[string]mrpcproxy.Encoding{
+	EnvelopeJSON:        mrpcproxy.JSONEncoding,
+	EnvelopeProto:       mrpcproxy.ProtoEncoding,
+	EnvelopeMessagePack: mrpcproxy.MessagePackEncoding,
+}
+
+var (
+	// ErrUnknownEnvelope is returned on registration of an endpoint with an
+	// unknown envelope encoding.
+	ErrUnknownEnvelope = errors.New("unknown envelope encoding")
+)
+
+// WithEncoding encodes the MRPC envelopes of topics with enc, or of all
+// topics when none are given. Responses starting with '{' are decoded as
+// JSON whatever the encoding, so services can be migrated one at a time.
+func WithEncoding(enc mrpcproxy.Encoding, topics ...string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if len(topics) == 0 {
+			pxy.encoding = enc
+			return nil
+		}
+
+		if pxy.topicEncodings == nil {
+			pxy.topicEncodings = map[string]mrpcproxy.Encoding{}
+		}
+		for _, topic := range topics {
+			pxy.topicEncodings[topic] = enc
+		}
+		return nil
+	}
+}
+
+// endpointEncoding returns the envelope encoding selected by ep, nil when
+// unset.
+func endpointEncoding(ep Endpoint) (mrpcproxy.Encoding, error) {
+	if ep.Encoding == "" {
+		return nil, nil
+	}
+
+	enc, ok := envelopes[ep.Encoding]
+	if !ok {
+		return nil, ErrUnknownEnvelope
+	}
+	return enc, nil
+}
+
+type encodingKey struct{}
+
+func withEncoding(ctx context.Context, enc mrpcproxy.Encoding) context.Context {
+	return context.WithValue(ctx, encodingKey{}, enc)
+}
+
+// topicEncoding returns the envelope encoding of the endpoint ctx belongs
+// to, or else of topic.
+func (pxy *Proxy) topicEncoding(ctx context.Context, topic string) mrpcproxy.Encoding {
+	if enc, ok := ctx.Value(encodingKey{}).(mrpcproxy.Encoding); ok {
+		return enc
+	}
+	if enc, ok := pxy.topicEncodings[topic]; ok {
+		return enc
+	}
+	if pxy.encoding != nil {
+		return pxy.encoding
+	}
+	return mrpcproxy.JSONEncoding
+}
+
+// marshalRequest encodes req for topic, signed and then encrypted when
+// configured.
+func (pxy *Proxy) marshalRequest(ctx context.Context, topic string, req *mrpcproxy.Request) ([]byte, error) {
+	data, err := pxy.topicEncoding(ctx, topic).MarshalRequest(req)
+	if err == nil && pxy.signingKey != nil {
+		data, err = mrpcproxy.Sign(data, *pxy.signingKey)
+	}
+	if err == nil && pxy.encryptionKeys != nil {
+		data, err = mrpcproxy.Encrypt(data, pxy.encryptionKeys[0])
+	}
+	return data, err
+}
+
+// unmarshalResponse decrypts and decodes the response of topic into res.
+func (pxy *Proxy) unmarshalResponse(ctx context.Context, topic string, data []byte, res *mrpcproxy.Response) error {
+	data, err := pxy.decryptResponse(data)
+	if err != nil {
+		return err
+	}
+
+	enc := pxy.topicEncoding(ctx, topic)
+	if len(data) > 0 && data[0] == '{' {
+		enc = mrpcproxy.JSONEncoding
+	}
+	return enc.UnmarshalResponse(data, res)
+}
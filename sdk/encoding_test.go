@@ -0,0 +1,208 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func testEnvelopes() (*mrpcproxy.Request, *mrpcproxy.Response) {
+	req := &mrpcproxy.Request{
+		RequestID:     "id",
+		Timestamp:     1551675000000,
+		Hops:          2,
+		Topic:         "service.a",
+		Action:        "POST",
+		IPAddress:     "1.1.1.1",
+		Params:        url.Values{"a": {"1", ""}, "empty": {}},
+		Msg:           []byte(`{"a":1}`),
+		Headers:       http.Header{"X-A": {"b"}},
+		Head:          true,
+		SchemaVersion: "2",
+		Claims:        map[string]interface{}{"sub": "a", "exp": float64(1)},
+		ClientCert:    &mrpcproxy.ClientCert{Subject: "CN=a", Issuer: "CN=ca", SerialNumber: "1", DNSNames: []string{"a", "b"}, Fingerprint: "ff"},
+		Cookies:       []*http.Cookie{{Name: "s", Value: "v"}},
+		Files:         []mrpcproxy.File{{Field: "f", Filename: "a.txt", ContentType: "text/plain", Size: 3, Key: "k"}},
+		Tenant:        "acme",
+		Deadline:      1700000000000000000,
+		Meta:          map[string]string{"locale": "en-GB", "device": ""},
+		PathParams:    map[string]string{"id": "1"},
+		QueryParams:   url.Values{"a": {"1", ""}},
+	}
+	res := &mrpcproxy.Response{
+		RequestID:    "id",
+		Code:         302,
+		Msg:          []byte("body"),
+		Headers:      http.Header{"X-A": {"b", "c"}},
+		Cookies:      []*http.Cookie{{Name: "s", Value: "v", Path: "/", Raw: "s=v; Path=/"}},
+		Location:     "/next",
+		BodyRef:      "ref",
+		Next:         "part",
+		Error:        &mrpcproxy.Error{Code: "moved", Message: "moved away", Details: json.RawMessage(`{"to":"/next"}`)},
+		LastModified: 1700000000000000000,
+	}
+	return req, res
+}
+
+func TestEnvelopeEncodings(t *testing.T) {
+	cases := []struct {
+		enc       mrpcproxy.Encoding
+		malformed [][]byte
+		err       error
+	}{
+		{mrpcproxy.ProtoEncoding, [][]byte{{0x0a, 0x05, 'a'}, {0x08}, {0x00, 0x01}, {0x0b}}, mrpcproxy.ErrMalformedProto},
+		{mrpcproxy.MessagePackEncoding, [][]byte{{0x81, 0xa1}, {0x81, 0xa4, 'C', 'o', 'd', 'e', 0xa1, 'a'}, {0x80, 0x01}, {0x01}}, mrpcproxy.ErrMalformedMessagePack},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			req, res := testEnvelopes()
+
+			data, _ := tc.enc.MarshalRequest(req)
+			decodedReq := &mrpcproxy.Request{}
+			if err := tc.enc.UnmarshalRequest(data, decodedReq); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decodedReq, req) {
+				t.Errorf("Unexpected request %+v", decodedReq)
+			}
+
+			data, _ = tc.enc.MarshalResponse(res)
+			decodedRes := &mrpcproxy.Response{}
+			if err := tc.enc.UnmarshalResponse(data, decodedRes); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decodedRes, res) {
+				t.Errorf("Unexpected response %+v", decodedRes)
+			}
+
+			for _, data := range tc.malformed {
+				if err := tc.enc.UnmarshalResponse(data, &mrpcproxy.Response{}); err != tc.err {
+					t.Errorf("Unexpected error for %x: %v", data, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEnvelopeEncodings(b *testing.B) {
+	req, res := testEnvelopes()
+	encodings := []struct {
+		name string
+		enc  mrpcproxy.Encoding
+	}{
+		{EnvelopeJSON, mrpcproxy.JSONEncoding},
+		{EnvelopeProto, mrpcproxy.ProtoEncoding},
+		{EnvelopeMessagePack, mrpcproxy.MessagePackEncoding},
+	}
+
+	for _, e := range encodings {
+		b.Run(e.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var size int
+			for i := 0; i < b.N; i++ {
+				reqData, _ := e.enc.MarshalRequest(req)
+				e.enc.UnmarshalRequest(reqData, &mrpcproxy.Request{})
+				resData, _ := e.enc.MarshalResponse(res)
+				e.enc.UnmarshalResponse(resData, &mrpcproxy.Response{})
+				size = len(reqData) + len(resData)
+			}
+			b.ReportMetric(float64(size), "bytes/roundtrip")
+		})
+	}
+}
+
+func TestWithEncoding(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("proto", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		code := 200
+		if err := mrpcproxy.ProtoEncoding.UnmarshalRequest(data, req); err != nil || req.Topic != "proto" {
+			code = 500
+		}
+		msg, _ := mrpcproxy.ProtoEncoding.MarshalResponse(&mrpcproxy.Response{Code: code, Msg: []byte("proto")})
+		w.Write(msg)
+	})
+	service.HandleFunc("json", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		code := 200
+		if err := json.Unmarshal(data, req); err != nil {
+			code = 500
+		}
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: code, Msg: []byte("json")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		opt   func(*Proxy) error
+		topic string
+		code  int
+	}{
+		{WithEncoding(mrpcproxy.ProtoEncoding), "proto", 200},
+		{WithEncoding(mrpcproxy.ProtoEncoding, "proto"), "proto", 200},
+		{WithEncoding(mrpcproxy.ProtoEncoding, "proto"), "json", 200},
+		{WithEncoding(mrpcproxy.ProtoEncoding), "json", 500},
+		{WithEncoding(mrpcproxy.MessagePackEncoding), "json", 500},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, tc.opt)
+			res, err := pxy.Call(context.Background(), tc.topic, pxy.newRequest("id", tc.topic, "GET"), time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Code != tc.code {
+				t.Errorf("Unexpected code: got %v want %v", res.Code, tc.code)
+			}
+		})
+	}
+}
+
+func TestEndpointEncoding(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("msgpack", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		if err := mrpcproxy.MessagePackEncoding.UnmarshalRequest(data, req); err != nil {
+			req.Msg = []byte(err.Error())
+		}
+		msg, _ := mrpcproxy.MessagePackEncoding.MarshalResponse(&mrpcproxy.Response{Code: 200, Msg: req.Msg})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithEncoding(mrpcproxy.ProtoEncoding))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.Handle(Endpoint{Topic: "msgpack", Method: "POST", Path: "/a", Encoding: EnvelopeMessagePack}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("POST", "/a", strings.NewReader("payload"))
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != "payload" {
+		t.Errorf("Unexpected response %v %q", w.Code, w.Body.String())
+	}
+
+	if err := pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/b", Encoding: "xml"}); err != ErrUnknownEnvelope {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
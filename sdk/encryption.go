@@ -0,0 +1,35 @@
+package sdk
+
+import (
+	"crypto/aes"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// WithPayloadEncryption encrypts the MRPC requests with key in a
+// mrpcproxy.EncryptedMessage and requires the services to answer with
+// responses encrypted the same way, so the broker never sees plaintext.
+// Responses are decrypted with key or one of the previous keys still being
+// rotated out.
+func WithPayloadEncryption(key mrpcproxy.EncryptionKey, previous ...mrpcproxy.EncryptionKey) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		keys := append([]mrpcproxy.EncryptionKey{key}, previous...)
+		for _, k := range keys {
+			if _, err := aes.NewCipher(k.Key); err != nil {
+				return err
+			}
+		}
+
+		pxy.encryptionKeys = keys
+		return nil
+	}
+}
+
+// decryptResponse returns the payload of an encrypted response, or data when
+// encryption is disabled.
+func (pxy *Proxy) decryptResponse(data []byte) ([]byte, error) {
+	if pxy.encryptionKeys == nil {
+		return data, nil
+	}
+	return mrpcproxy.Decrypt(data, pxy.encryptionKeys...)
+}
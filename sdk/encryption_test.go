@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestPayloadEncryption(t *testing.T) {
+	current := mrpcproxy.EncryptionKey{ID: "k2", Key: bytes.Repeat([]byte{2}, 32)}
+	old := mrpcproxy.EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte{1}, 16)}
+	other := mrpcproxy.EncryptionKey{ID: "k2", Key: bytes.Repeat([]byte{3}, 32)}
+
+	cases := []struct {
+		serviceKeys []mrpcproxy.EncryptionKey // decrypting requests
+		responseKey *mrpcproxy.EncryptionKey  // encrypting responses, plaintext when nil
+		code        int
+		err         bool
+	}{
+		{[]mrpcproxy.EncryptionKey{current}, &current, 200, false},
+		{[]mrpcproxy.EncryptionKey{old, current}, &old, 200, false},
+		{[]mrpcproxy.EncryptionKey{current}, nil, 0, true},
+		{[]mrpcproxy.EncryptionKey{current}, &other, 0, true},
+		{[]mrpcproxy.EncryptionKey{other}, &current, 500, false},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+				code := 200
+				req := &mrpcproxy.Request{}
+				payload, err := mrpcproxy.Decrypt(data, tc.serviceKeys...)
+				if err == nil {
+					err = json.Unmarshal(payload, req)
+				}
+				if err != nil || string(req.Msg) != "secret" {
+					code = 500
+				}
+
+				msg, _ := json.Marshal(&mrpcproxy.Response{Code: code})
+				if tc.responseKey != nil {
+					msg, _ = mrpcproxy.Encrypt(msg, *tc.responseKey)
+				}
+				w.Write(msg)
+			})
+			go service.Serve()
+			defer service.Stop(nil)
+			time.Sleep(1 * time.Millisecond)
+
+			pxy, err := New(":80", service, WithPayloadEncryption(current, old))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := pxy.newRequest("id", "a", "POST")
+			req.Msg = []byte("secret")
+			res, err := pxy.Call(context.Background(), "a", req, time.Second)
+			if (err != nil) != tc.err {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err == nil && res.Code != tc.code {
+				t.Errorf("Unexpected code: got %v want %v", res.Code, tc.code)
+			}
+		})
+	}
+}
+
+func TestPayloadEncryptionInvalidKey(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	_, err := New(":80", service, WithPayloadEncryption(mrpcproxy.EncryptionKey{Key: []byte("short")}))
+	if err != (FuncOptsError{aes.KeySizeError(5)}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
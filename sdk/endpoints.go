@@ -4,6 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpcproxy"
 )
 
 // ParseError is returned when endpoints.json can't be parsed.
@@ -22,10 +27,141 @@ var (
 
 // Endpoint is the the representation of a single route.
 type Endpoint struct {
+	// Path in the syntax of the router, e.g. /files/*path on httprouter.
+	// Params given as {name:regexp} only match values matching regexp.
 	Path      string
+	Host      string `json:"host"` // Served for all hosts when empty. Supports *.example.com wildcards
 	Method    string `json:"method"`
 	Topic     string `json:"topic"`
 	KeepAlive int    `json:"keepAlive"` // In Millisecond. Overrides the default NATS timeout
+
+	// TimeoutStatus and TimeoutBody override the response to the requests
+	// timing out, see WithTimeoutResponse.
+	TimeoutStatus int    `json:"timeoutStatus"`
+	TimeoutBody   string `json:"timeoutBody"`
+
+	// Topics of fan-out endpoints. The request is sent to all of them
+	// concurrently and the responses are combined according to Merge.
+	Topics []string      `json:"topics"`
+	Merge  MergeStrategy `json:"merge"`
+
+	// Fallbacks are tried in order when the topic times out or responds
+	// with a server error.
+	Fallbacks []string `json:"fallbacks"`
+
+	// Shadow is a topic receiving a copy of each request. Its responses are
+	// ignored.
+	Shadow string `json:"shadow"`
+
+	// TopicFields are fields of the JSON request body given to the topic
+	// templates like the path params, which take precedence. For example
+	// payments.{{.type}} routes {"type": "refund"} to payments.refund.
+	TopicFields []string `json:"topicFields"`
+
+	// APIVersions route the versions of the endpoint to their topics. Each
+	// version is also served under its path prefix, e.g. /v1/path. Topic is
+	// ignored when set.
+	APIVersions []APIVersion `json:"apiVersions"`
+
+	// Deprecated endpoints are served with the Deprecation, Sunset and Link
+	// headers of the deprecation and their requests are counted, see
+	// Proxy.DeprecatedUsage.
+	Deprecated *Deprecation `json:"deprecated"`
+
+	// Canary sends a share of the requests to another topic.
+	Canary *Canary `json:"canary"`
+
+	// Concurrency limits the MRPC calls to the topic running at once.
+	Concurrency *ConcurrencyLimit `json:"concurrency"`
+
+	// IPFilter allows or denies clients of this endpoint in addition to the
+	// proxy filter.
+	IPFilter *IPFilter `json:"ipFilter"`
+
+	// ForwardHeaders lists the request headers copied to the MRPC request.
+	// Overrides Proxy.ForwardHeaders. All headers are forwarded when both are nil.
+	ForwardHeaders []string `json:"forwardHeaders"`
+
+	// InjectHeaders maps headers added to the MRPC requests, e.g.
+	// X-Environment, to the names of their values in Proxy.ValueProvider.
+	// The values are resolved when the endpoint is added and replace the
+	// headers sent by the client.
+	InjectHeaders map[string]string `json:"injectHeaders"`
+
+	// SecurityHeaders overrides the headers set by WithSecurityHeaders.
+	// Headers with empty value are not sent.
+	SecurityHeaders map[string]string `json:"securityHeaders"`
+
+	// ResponseHeaders rewrites the headers of the responses.
+	ResponseHeaders *HeaderPolicy `json:"responseHeaders"`
+
+	// RawBody endpoints send the HTTP body as it is on the topic, without the
+	// mrpcproxy.Request envelope, and answer with the reply as the body of a
+	// 200 response. It is meant for services not speaking the envelope, so
+	// request signing and payload encryption don't apply.
+	RawBody bool `json:"rawBody"`
+
+	// Upstream is the URL of an HTTP service the endpoint is forwarded to
+	// instead of the MRPC topic. The request path is appended to the URL
+	// path and the endpoint timeout applies.
+	Upstream string `json:"upstream"`
+
+	// LongPoll endpoints subscribe to Topic and hold each request until the
+	// next message on it, sent as the body, or until the timeout, answered
+	// with 204. Topic is not a template for them and is subscribed as is,
+	// without pattern matching. The subscription outlives the endpoint.
+	LongPoll bool `json:"longPoll"`
+
+	// Encoding of the MRPC envelopes of this endpoint, json, proto or
+	// msgpack. Overrides WithEncoding.
+	Encoding string `json:"encoding"`
+
+	// Service is the name of the MRPC service the endpoint publishes
+	// through, added with WithService. Defaults to Proxy.MRPCService.
+	Service string `json:"service"`
+
+	// NoTopicPrefix opts the endpoint out of WithTopicPrefix.
+	NoTopicPrefix bool `json:"noTopicPrefix"`
+
+	// Multipart endpoints parse multipart/form-data bodies. Files are saved to
+	// Proxy.FileStorage and form fields are added to the request params.
+	Multipart bool `json:"multipart"`
+
+	// Form endpoints parse application/x-www-form-urlencoded bodies and add
+	// the fields to the request params instead of sending the body. Other
+	// bodies are sent as they are.
+	Form bool `json:"form"`
+
+	// DisableOptions turns off the automatic OPTIONS handler of the path. It
+	// otherwise answers with the Allow header listing the routed methods.
+	DisableOptions bool `json:"disableOptions"`
+
+	// Middleware applied to this endpoint after the proxy middleware.
+	Middleware []Middleware `json:"-"`
+
+	// JSON Schemas validating the request body and query params before the
+	// request is sent over MRPC. Inline schemas take precedence over files.
+	// Query params are validated as an object of strings, or arrays of strings
+	// for repeated params.
+	BodySchema      json.RawMessage `json:"bodySchema"`
+	BodySchemaFile  string          `json:"bodySchemaFile"`
+	QuerySchema     json.RawMessage `json:"querySchema"`
+	QuerySchemaFile string          `json:"querySchemaFile"`
+
+	// Versions of the payload schemas, validated instead of BodySchema and
+	// QuerySchema. The version is negotiated from the Accept-Version header,
+	// the last one by default, and sent as Request.SchemaVersion.
+	Versions []SchemaVersion `json:"versions"`
+
+	schemas  *endpointSchemas
+	versions *versionedSchemas
+	version  string
+	encoding mrpcproxy.Encoding
+	service  *mrpc.Service
+	injected http.Header
+
+	// timeoutBody is the parsed TimeoutBody.
+	timeoutBody *template.Template
 }
 
 type endpointsJSON map[string]struct {
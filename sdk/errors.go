@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+var (
+	// ErrNotFound is rendered for requests not matching any route.
+	ErrNotFound = errors.New("not found")
+	// ErrMethodNotAllowed is rendered for requests matching a route with
+	// other methods.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// ErrorRenderer returns the body and headers of an error response.
+type ErrorRenderer func(code int, err error, r *http.Request) ([]byte, http.Header)
+
+// ProblemDetails is an RFC 7807 problem details document.
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// Code and Details of structured backend errors.
+	Code    string          `json:"code,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
+
+	// Errors lists the failed validations of the request.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ProblemDetailsRenderer renders errors as RFC 7807 JSON documents. The error
+// message is only included for client errors so internal failures don't leak,
+// except for the structured errors of backend responses.
+func ProblemDetailsRenderer(code int, err error, r *http.Request) ([]byte, http.Header) {
+	pd := ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(code),
+		Status:    code,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+	if err != nil && code < http.StatusInternalServerError {
+		pd.Detail = err.Error()
+	}
+
+	var berr *mrpcproxy.Error
+	if errors.As(err, &berr) {
+		pd.Detail, pd.Code, pd.Details = berr.Message, berr.Code, berr.Details
+	}
+
+	var verr ValidationError
+	if errors.As(err, &verr) {
+		pd.Errors = verr.Errors
+	}
+
+	body, _ := json.Marshal(pd)
+	return body, http.Header{"Content-Type": {"application/problem+json"}}
+}
+
+// writeError writes an error response rendered by the ErrorRenderer.
+func (pxy *Proxy) writeError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	hookError(r, err)
+
+	renderer := pxy.ErrorRenderer
+	if renderer == nil {
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			w.WriteHeader(code)
+			return
+		}
+		renderer = renderValidationError
+	}
+
+	body, h := renderer(code, err, r)
+	for k, vs := range h {
+		w.Header()[http.CanonicalHeaderKey(k)] = vs
+	}
+
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		printfCtx(r.Context(), pxy.Logger, "writing to http.ResponseWriter failed: %v", err)
+	}
+}
+
+// writeBackendError renders the structured error of res, with the
+// ErrorRenderer or as problem details when not set.
+func (pxy *Proxy) writeBackendError(w http.ResponseWriter, r *http.Request, res *mrpcproxy.Response) {
+	hookError(r, res.Error)
+
+	code := res.Code
+	if code < http.StatusBadRequest {
+		code = http.StatusInternalServerError
+	}
+	printfCtx(r.Context(), pxy.Logger, "%v:%v, status: %v, Id: %v, error: %v", r.Method, r.URL.Path, code, res.RequestID, res.Error)
+
+	renderer := pxy.ErrorRenderer
+	if renderer == nil {
+		renderer = ProblemDetailsRenderer
+	}
+
+	body, h := renderer(code, res.Error, r)
+	for k, vs := range h {
+		w.Header()[http.CanonicalHeaderKey(k)] = vs
+	}
+	w.Header().Del("Content-Length")
+
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		printfCtx(r.Context(), pxy.Logger, "writing to http.ResponseWriter failed: %v", err)
+	}
+}
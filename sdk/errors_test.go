@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		renderer    ErrorRenderer
+		code        int
+		err         error
+		body        string
+		contentType string
+	}{
+		{nil, http.StatusNotFound, ErrNotFound, "", ""},
+		{
+			ProblemDetailsRenderer, http.StatusNotFound, ErrNotFound,
+			`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/a","requestId":"uuid"}`,
+			"application/problem+json",
+		},
+		{
+			ProblemDetailsRenderer, http.StatusInternalServerError, errors.New("internal detail"),
+			`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/a","requestId":"uuid"}`,
+			"application/problem+json",
+		},
+		{
+			ProblemDetailsRenderer, http.StatusConflict, &mrpcproxy.Error{Code: "taken", Message: "name taken", Details: json.RawMessage(`{"name":"a"}`)},
+			`{"type":"about:blank","title":"Conflict","status":409,"detail":"name taken","instance":"/a","requestId":"uuid","code":"taken","details":{"name":"a"}}`,
+			"application/problem+json",
+		},
+		{
+			func(code int, err error, r *http.Request) ([]byte, http.Header) {
+				return []byte(fmt.Sprintf("oops %v", code)), http.Header{"content-type": {"text/plain"}}
+			},
+			http.StatusRequestTimeout, nil, "oops 408", "text/plain",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, _ := New(":80", service)
+			pxy.GetID = func() string { return "uuid" }
+			pxy.ErrorRenderer = tc.renderer
+
+			rr := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/a", nil)
+			pxy.writeError(rr, pxy.withRequestID(rr, r), tc.code, tc.err)
+
+			if rr.Code != tc.code {
+				t.Errorf("Unexpected status: got %v want %v", rr.Code, tc.code)
+			}
+
+			if rr.Body.String() != tc.body {
+				t.Errorf("Unexpected body:\ngot  %v\nwant %v", rr.Body.String(), tc.body)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("Unexpected Content-Type: got %v want %v", ct, tc.contentType)
+			}
+		})
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("users", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{
+			Code:  http.StatusNotFound,
+			Error: &mrpcproxy.Error{Code: "user_not_found", Message: "no user 1"},
+		})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		renderer ErrorRenderer
+		body     string
+	}{
+		{nil, `{"type":"about:blank","title":"Not Found","status":404,"detail":"no user 1","instance":"/users/1","requestId":"uuid","code":"user_not_found"}`},
+		{
+			func(code int, err error, r *http.Request) ([]byte, http.Header) {
+				return []byte(err.Error()), nil
+			},
+			"user_not_found: no user 1",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			l := &MockLogger{}
+			pxy, _ := New(":80", service)
+			pxy.GetID = func() string { return "uuid" }
+			pxy.Logger = l
+			pxy.Requests = &MockLogger{}
+			pxy.ErrorRenderer = tc.renderer
+			pxy.Handle(Endpoint{Method: "GET", Path: "/users/:id", Topic: "users"})
+
+			r, _ := http.NewRequest("GET", "/users/1", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: %v %v", w.Code, w.Body.String())
+			}
+			want := "GET:/users/1, status: 404, Id: uuid, error: user_not_found: no user 1"
+			if len(l.storage) == 0 || l.storage[len(l.storage)-1] != want {
+				t.Errorf("Unexpected log: %v", l.storage)
+			}
+		})
+	}
+}
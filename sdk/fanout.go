@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// MergeStrategy combines the responses of fan-out endpoints.
+type MergeStrategy string
+
+const (
+	// MergeObject merges the JSON object responses key by key. Later topics
+	// override the keys of earlier ones. It is the default strategy.
+	MergeObject MergeStrategy = "object"
+	// MergeArray responds with a JSON array of the responses in topic order.
+	MergeArray MergeStrategy = "array"
+	// MergeFirst responds with the first successful response.
+	MergeFirst MergeStrategy = "first"
+)
+
+// checkMerge returns an error for unknown merge strategies.
+func checkMerge(m MergeStrategy) error {
+	switch m {
+	case "", MergeObject, MergeArray, MergeFirst:
+		return nil
+	}
+	return fmt.Errorf("unknown merge strategy %q", m)
+}
+
+type fanOutResult struct {
+	i   int
+	res *mrpcproxy.Response
+	err error
+}
+
+// fanOut sends req to all topics of ep. Unless the strategy is MergeFirst all
+// responses must be successful, otherwise the first failure in topic order is
+// returned.
+func (pxy *Proxy) fanOut(ctx context.Context, req *mrpcproxy.Request, ep Endpoint, timeout time.Duration) (*mrpcproxy.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan fanOutResult, len(ep.Topics))
+	for i, topic := range ep.Topics {
+		go func(i int, topic string) {
+			topicReq := *req
+			topicReq.Topic = topic
+			res, err := pxy.Call(ctx, pxy.tenantTopic(topic, req.Tenant), &topicReq, timeout)
+			results <- fanOutResult{i, res, err}
+		}(i, topic)
+	}
+
+	responses := make([]*mrpcproxy.Response, len(ep.Topics))
+	errs := make([]error, len(ep.Topics))
+	for range ep.Topics {
+		r := <-results
+		if ep.Merge == MergeFirst && r.err == nil && successful(r.res) {
+			return r.res, nil
+		}
+		responses[r.i], errs[r.i] = r.res, r.err
+	}
+
+	for i, res := range responses {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if !successful(res) {
+			return res, nil
+		}
+	}
+
+	return mergeResponses(ep.Merge, responses)
+}
+
+func successful(res *mrpcproxy.Response) bool {
+	return res.Code >= 200 && res.Code < 300
+}
+
+func mergeResponses(strategy MergeStrategy, responses []*mrpcproxy.Response) (*mrpcproxy.Response, error) {
+	merged := &mrpcproxy.Response{RequestID: responses[0].RequestID, Code: http.StatusOK, Headers: http.Header{}}
+	for _, res := range responses {
+		for k, vs := range res.Headers {
+			merged.Headers[k] = vs
+		}
+		merged.Cookies = append(merged.Cookies, res.Cookies...)
+	}
+
+	var err error
+	if strategy == MergeArray {
+		msgs := make([]json.RawMessage, len(responses))
+		for i, res := range responses {
+			msgs[i] = json.RawMessage("null")
+			if len(res.Msg) > 0 {
+				if err := json.Unmarshal(res.Msg, &msgs[i]); err != nil {
+					return nil, ResponseError{err}
+				}
+			}
+		}
+		merged.Msg, err = json.Marshal(msgs)
+	} else {
+		obj := map[string]json.RawMessage{}
+		for _, res := range responses {
+			if len(res.Msg) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(res.Msg, &obj); err != nil {
+				return nil, ResponseError{err}
+			}
+		}
+		merged.Msg, err = json.Marshal(obj)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	merged.Headers.Set("Content-Type", "application/json")
+	return merged, nil
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestFanOut(t *testing.T) {
+	respond := func(code int, msg string, delay time.Duration) func(mrpc.TopicWriter, []byte) {
+		return func(w mrpc.TopicWriter, data []byte) {
+			time.Sleep(delay)
+			res, _ := json.Marshal(&mrpcproxy.Response{Code: code, Msg: []byte(msg), Headers: http.Header{"X-Topic": {msg}}})
+			w.Write(res)
+		}
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", respond(200, `{"a":1,"b":1}`, 5*time.Millisecond))
+	service.HandleFunc("b", respond(200, `{"b":2}`, 0))
+	service.HandleFunc("c", respond(503, ``, 0))
+	service.HandleFunc("d", respond(200, `not json`, 0))
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		topics []string
+		merge  MergeStrategy
+		code   int
+		body   string
+	}{
+		{[]string{"service.a", "service.b"}, "", 200, `{"a":1,"b":2}`},
+		{[]string{"service.b", "service.a"}, MergeObject, 200, `{"a":1,"b":1}`},
+		{[]string{"service.a", "service.b"}, MergeArray, 200, `[{"a":1,"b":1},{"b":2}]`},
+		{[]string{"service.a", "service.b"}, MergeFirst, 200, `{"b":2}`},
+		{[]string{"service.c", "service.a"}, MergeFirst, 200, `{"a":1,"b":1}`},
+		{[]string{"service.a", "service.c"}, MergeObject, 503, ``},
+		{[]string{"service.a", "service.d"}, MergeArray, 500, ``},
+		{[]string{"service.{{.name}}", "service.b"}, MergeArray, 200, `[{"a":1,"b":1},{"b":2}]`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			if err := pxy.Handle(Endpoint{Topics: tc.topics, Merge: tc.merge, Method: "GET", Path: "/:name"}); err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestFanOutUnknownMerge(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	if err := pxy.Handle(Endpoint{Topics: []string{"a"}, Merge: "sum", Method: "GET", Path: "/"}); err == nil {
+		t.Error("Expected error for unknown merge strategy")
+	}
+}
@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// maxFormSize limits the urlencoded bodies of form endpoints, as
+// http.Request.ParseForm does.
+const maxFormSize = 10 << 20
+
+// isForm reports whether r has an application/x-www-form-urlencoded body.
+func isForm(r *http.Request) bool {
+	if r.Body == nil {
+		return false
+	}
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && ct == "application/x-www-form-urlencoded"
+}
+
+// readForm adds the fields of the urlencoded body of r to the request params.
+func readForm(r *http.Request, req *mrpcproxy.Request) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxFormSize))
+	if err != nil {
+		return uploadError(err)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return StatusError{http.StatusBadRequest, err}
+	}
+	for k, vs := range form {
+		req.Params[k] = append(req.Params[k], vs...)
+	}
+	return nil
+}
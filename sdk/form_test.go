@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestFormEndpoint(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("form", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: data})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "POST", Path: "/form/:id", Topic: "form", Form: true},
+		Endpoint{Method: "POST", Path: "/raw", Topic: "form"},
+	)
+
+	form := "application/x-www-form-urlencoded"
+	cases := []struct {
+		path   string
+		ct     string
+		body   string
+		status int
+		params url.Values
+		msg    string
+	}{
+		{"/form/1?a=q", form, "a=1&b=2&b=3", 200, url.Values{"a": {"q", "1"}, "b": {"2", "3"}, "id": {"1"}}, ""},
+		{"/form/1", form + "; charset=utf-8", "name=J%C3%BCrgen+M", 200, url.Values{"name": {"Jürgen M"}, "id": {"1"}}, ""},
+		{"/form/1", "application/json", `{"a":1}`, 200, url.Values{"id": {"1"}}, `{"a":1}`},
+		{"/form/1", form, "a=%zz", 400, nil, ""},
+		{"/form/1", form, strings.Repeat("a", maxFormSize+1), 413, nil, ""},
+		{"/raw", form, "a=1", 200, url.Values{}, "a=1"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.ct)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.status != 200 {
+				return
+			}
+			req := mrpcproxy.Request{}
+			json.Unmarshal(w.Body.Bytes(), &req)
+			if !reflect.DeepEqual(req.Params, tc.params) || string(req.Msg) != tc.msg {
+				t.Errorf("Unexpected request: got %v %q want %v %q", req.Params, req.Msg, tc.params, tc.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,578 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// maxGraphQLBodySize limits the size of GraphQL POST bodies.
+	maxGraphQLBodySize = 1 << 20
+	// maxGraphQLCalls limits the resolver calls of a query.
+	maxGraphQLCalls = 1000
+	// maxGraphQLConcurrency limits the concurrent resolver calls of a query.
+	maxGraphQLConcurrency = 16
+)
+
+var (
+	// ErrGraphQLBatch is returned when a batch resolver doesn't respond with
+	// one result per source.
+	ErrGraphQLBatch = errors.New("batch resolver response doesn't match the sources")
+	// ErrGraphQLTooManyCalls is returned for the fields of queries needing
+	// more resolver calls than allowed.
+	ErrGraphQLTooManyCalls = errors.New("query requires too many resolver calls")
+)
+
+// GraphQLResolver maps a GraphQL field to an MRPC topic.
+type GraphQLResolver struct {
+	Topic string
+	// Batch resolves all occurrences of the field on the same depth of a
+	// query with one MRPC call. The service receives Sources and responds
+	// with a JSON array holding a result for each of them.
+	Batch bool
+}
+
+// GraphQLResolve is the MRPC message sent to resolver topics.
+type GraphQLResolve struct {
+	Field     string                 `json:"field"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Source    interface{}            `json:"source,omitempty"`
+	Sources   []interface{}          `json:"sources,omitempty"`
+}
+
+// GraphQLError is an entry of the errors of a GraphQL response.
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type graphQLParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// HandleGraphQL serves GraphQL queries on GET and POST requests to ep.Path.
+// The resolvers are keyed by the field path from the query root, e.g. "user"
+// and "user.orders". Root fields must have a resolver while other fields
+// without one are read from the result of their parent. Resolvers receive a
+// GraphQLResolve with the field arguments and the parent object as source,
+// and respond with the JSON field value. A query makes at most 1000 resolver
+// calls, 16 at a time.
+func (pxy *Proxy) HandleGraphQL(ep Endpoint, resolvers map[string]GraphQLResolver) error {
+	h := func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		code, res := pxy.serveGraphQL(r, ep, resolvers)
+		pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, code, RequestIDFromContext(r.Context()))
+
+		body, err := json.Marshal(res)
+		if err != nil {
+			printlnCtx(r.Context(), pxy.Debugger, err)
+			pxy.writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		pxy.setHeaders(w)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(body)
+	}
+
+	hs := map[string]httprouter.Handle{}
+	for _, method := range []string{"GET", "POST"} {
+		ep.Method = method
+		mh, err := pxy.endpointMiddleware(ep, h)
+		if err != nil {
+			return err
+		}
+		hs[method] = mh
+	}
+
+	for _, method := range []string{"GET", "POST"} {
+		pxy.addRoute(route{ep.Host, method, ep.Path, hs[method], false})
+	}
+	return nil
+}
+
+func (pxy *Proxy) serveGraphQL(r *http.Request, ep Endpoint, resolvers map[string]GraphQLResolver) (int, *graphQLResponse) {
+	params, err := readGraphQLParams(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, graphQLErrorResponse(err)
+		}
+		return http.StatusBadRequest, graphQLErrorResponse(err)
+	}
+
+	doc, err := parseGraphQL(params.Query)
+	if err != nil {
+		return http.StatusBadRequest, graphQLErrorResponse(err)
+	}
+
+	op, err := doc.operation(params.OperationName)
+	if err != nil {
+		return http.StatusBadRequest, graphQLErrorResponse(err)
+	}
+
+	if op.typ == "subscription" {
+		return http.StatusBadRequest, graphQLErrorResponse(errors.New("subscriptions are not supported"))
+	}
+	if op.typ == "mutation" && r.Method == "GET" {
+		return http.StatusMethodNotAllowed, graphQLErrorResponse(errors.New("mutations require POST"))
+	}
+
+	vars := map[string]interface{}{}
+	for _, v := range op.vars {
+		vars[v.name] = v.def
+	}
+	for k, v := range params.Variables {
+		vars[k] = v
+	}
+
+	ex := &gqlExec{
+		pxy:       pxy,
+		r:         r,
+		ep:        ep,
+		resolvers: resolvers,
+		doc:       doc,
+		vars:      vars,
+		action:    strings.ToUpper(op.typ),
+		timeout:   pxy.timeout(r, ep),
+	}
+
+	data := ex.execute(op)
+	return http.StatusOK, &graphQLResponse{Data: data, Errors: ex.errors}
+}
+
+func graphQLErrorResponse(err error) *graphQLResponse {
+	return &graphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+}
+
+func readGraphQLParams(r *http.Request) (*graphQLParams, error) {
+	params := &graphQLParams{}
+	if r.Method == "GET" {
+		q := r.URL.Query()
+		params.Query = q.Get("query")
+		params.OperationName = q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := decodeJSONNumbers([]byte(vars), &params.Variables); err != nil {
+				return nil, err
+			}
+		}
+		return params, nil
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxGraphQLBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+		params.Query = string(body)
+		return params, nil
+	}
+
+	if err := decodeJSONNumbers(body, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.ops) > 1 {
+			return nil, errors.New("operationName is required for documents with several operations")
+		}
+		return doc.ops[0], nil
+	}
+
+	for _, op := range doc.ops {
+		if op.name == name {
+			return op, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// gqlExec executes an operation resolving each depth of the query with a
+// single round of concurrent MRPC calls.
+type gqlExec struct {
+	pxy       *Proxy
+	r         *http.Request
+	ep        Endpoint
+	resolvers map[string]GraphQLResolver
+	doc       *gqlDocument
+	vars      map[string]interface{}
+	action    string
+	timeout   time.Duration
+	errors    []GraphQLError
+	// Resolver calls made so far.
+	calls int
+}
+
+// gqlTask is a field waiting for its resolver.
+type gqlTask struct {
+	key    string
+	field  *gqlField
+	source interface{}
+	out    *gqlObject
+	path   []interface{}
+}
+
+type gqlResult struct {
+	value interface{}
+	err   error
+}
+
+func (ex *gqlExec) execute(op *gqlOperation) *gqlObject {
+	data := newGQLObject()
+
+	var tasks []gqlTask
+	for _, f := range ex.collect(op.sel) {
+		data.set(f.key(), nil)
+		tasks = append(tasks, gqlTask{f.name, f, nil, data, []interface{}{f.key()}})
+	}
+
+	// Mutation root fields run one after the other.
+	serial := op.typ == "mutation"
+	for len(tasks) > 0 {
+		results := ex.resolve(tasks, serial)
+		serial = false
+
+		var next []gqlTask
+		for i, t := range tasks {
+			if err := results[i].err; err != nil {
+				ex.errorf(t.path, err)
+				continue
+			}
+			t.out.set(t.field.key(), ex.complete(t.key, t.field, results[i].value, t.path, &next))
+		}
+		tasks = next
+	}
+
+	return data
+}
+
+// complete applies the selection set of f to the value v. Subfields with
+// resolvers are added to next.
+func (ex *gqlExec) complete(key string, f *gqlField, v interface{}, path []interface{}, next *[]gqlTask) interface{} {
+	if len(f.sel) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = ex.complete(key, f, item, appendPath(path, i), next)
+		}
+		return list
+	case map[string]interface{}:
+		obj := newGQLObject()
+		for _, sub := range ex.collect(f.sel) {
+			subKey := key + "." + sub.name
+			subPath := appendPath(path, sub.key())
+			obj.set(sub.key(), nil)
+			if _, ok := ex.resolvers[subKey]; ok {
+				*next = append(*next, gqlTask{subKey, sub, v, obj, subPath})
+				continue
+			}
+			obj.set(sub.key(), ex.complete(subKey, sub, v[sub.name], subPath, next))
+		}
+		return obj
+	}
+
+	return v
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), elem)
+}
+
+// gqlCall is an MRPC call shared by one or more tasks.
+type gqlCall struct {
+	topic string
+	batch bool
+	msg   GraphQLResolve
+	tasks []int
+}
+
+// resolve calls the resolvers of tasks. Identical calls are made once and
+// batch resolvers get a single call per field and arguments.
+func (ex *gqlExec) resolve(tasks []gqlTask, serial bool) []gqlResult {
+	results := make([]gqlResult, len(tasks))
+
+	var calls []*gqlCall
+	index := map[string]*gqlCall{}
+	for i, t := range tasks {
+		res, ok := ex.resolvers[t.key]
+		if !ok {
+			results[i].err = StatusError{http.StatusBadRequest, fmt.Errorf("no resolver for field %q", t.key)}
+			continue
+		}
+
+		args := ex.args(t.field.args)
+		id, _ := json.Marshal(args)
+		key := t.key + string(id)
+		if !res.Batch {
+			source, _ := json.Marshal(t.source)
+			key += string(source)
+		}
+
+		c, ok := index[key]
+		if !ok && ex.calls == maxGraphQLCalls {
+			results[i].err = StatusError{http.StatusBadRequest, ErrGraphQLTooManyCalls}
+			continue
+		}
+		if !ok {
+			ex.calls++
+			c = &gqlCall{topic: res.Topic, batch: res.Batch, msg: GraphQLResolve{Field: t.key, Arguments: args}}
+			if !res.Batch {
+				c.msg.Source = t.source
+			}
+			index[key] = c
+			calls = append(calls, c)
+		}
+		if res.Batch {
+			c.msg.Sources = append(c.msg.Sources, t.source)
+		}
+		c.tasks = append(c.tasks, i)
+	}
+
+	run := func(c *gqlCall) {
+		v, err := ex.call(c.topic, &c.msg)
+
+		var batch []interface{}
+		if err == nil && c.batch {
+			var ok bool
+			if batch, ok = v.([]interface{}); !ok || len(batch) != len(c.tasks) {
+				err = ErrGraphQLBatch
+			}
+		}
+
+		for i, t := range c.tasks {
+			results[t] = gqlResult{v, err}
+			if batch != nil {
+				results[t].value = batch[i]
+			}
+		}
+	}
+
+	if serial {
+		for _, c := range calls {
+			run(c)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(calls))
+	sem := make(chan struct{}, maxGraphQLConcurrency)
+	for _, c := range calls {
+		sem <- struct{}{}
+		go func(c *gqlCall) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			run(c)
+		}(c)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (ex *gqlExec) call(topic string, msg *GraphQLResolve) (interface{}, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := ex.r
+	req := ex.pxy.newRequest(RequestIDFromContext(r.Context()), topic, ex.action)
+	req.Msg = body
+	req.Headers = ex.pxy.forwardHeaders(r.Header, ex.ep)
+	req.Claims = claimsFromContext(r.Context())
+	req.ClientCert = clientCert(r)
+	req.Cookies = r.Cookies()
+	req.Tenant = TenantFromContext(r.Context())
+	req.Meta = ex.pxy.requestMeta(r)
+	req.IPAddress = ex.pxy.clientIP(r)
+
+	if ex.pxy.RequestTransformer != nil {
+		if err := ex.pxy.RequestTransformer(r, req); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := ex.pxy.Call(r.Context(), ex.pxy.tenantTopic(topic, req.Tenant), req, ex.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Code < 200 || res.Code >= 300 {
+		text := string(res.Msg)
+		if text == "" {
+			text = http.StatusText(res.Code)
+		}
+		return nil, StatusError{res.Code, errors.New(text)}
+	}
+
+	if len(res.Msg) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := decodeJSONNumbers(res.Msg, &v); err != nil {
+		return nil, ResponseError{err}
+	}
+	return v, nil
+}
+
+func (ex *gqlExec) errorf(path []interface{}, err error) {
+	msg := err.Error()
+	if _, ok := err.(StatusError); !ok && err != ErrGraphQLBatch {
+		printlnCtx(ex.r.Context(), ex.pxy.Debugger, err)
+		msg = http.StatusText(errorStatus(err))
+	}
+	ex.errors = append(ex.errors, GraphQLError{Message: msg, Path: path})
+}
+
+// collect flattens the fragments of a selection set and merges the fields
+// with the same response key.
+func (ex *gqlExec) collect(sel []gqlSelection) []*gqlField {
+	var fields []*gqlField
+	ex.collectInto(sel, &fields, map[string]int{}, map[string]bool{})
+	return fields
+}
+
+func (ex *gqlExec) collectInto(sel []gqlSelection, fields *[]*gqlField, index map[string]int, visited map[string]bool) {
+	for _, s := range sel {
+		if !ex.included(s.directives) {
+			continue
+		}
+
+		switch {
+		case s.field != nil:
+			key := s.field.key()
+			if i, ok := index[key]; ok {
+				merged := *(*fields)[i]
+				merged.sel = append(append([]gqlSelection{}, merged.sel...), s.field.sel...)
+				(*fields)[i] = &merged
+				continue
+			}
+			index[key] = len(*fields)
+			*fields = append(*fields, s.field)
+		case s.spread != "":
+			if visited[s.spread] {
+				continue
+			}
+			visited[s.spread] = true
+			ex.collectInto(ex.doc.fragments[s.spread], fields, index, visited)
+		default:
+			ex.collectInto(s.inline, fields, index, visited)
+		}
+	}
+}
+
+// included evaluates the @skip and @include directives.
+func (ex *gqlExec) included(ds []gqlDirective) bool {
+	for _, d := range ds {
+		cond, _ := ex.args(d.args)["if"].(bool)
+		if (d.name == "skip" && cond) || (d.name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *gqlExec) args(args []gqlArg) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	m := make(map[string]interface{}, len(args))
+	for _, a := range args {
+		m[a.name] = ex.value(a.value)
+	}
+	return m
+}
+
+func (ex *gqlExec) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case gqlVar:
+		return ex.vars[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = ex.value(item)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			obj[k] = ex.value(item)
+		}
+		return obj
+	}
+	return v
+}
+
+// gqlObject is a JSON object keeping the order of the selection set.
+type gqlObject struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+func newGQLObject() *gqlObject {
+	return &gqlObject{vals: map[string]interface{}{}}
+}
+
+func (o *gqlObject) set(key string, v interface{}) {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+// MarshalJSON encodes the object with the keys in selection order.
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		val, err := json.Marshal(o.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	cases := []struct {
+		query string
+		err   bool
+	}{
+		{`{ a }`, false},
+		{`query Q($id: ID! = "1", $n: [Int]) { a: user(id: $id, n: [1, 2.5], o: {k: true, e: RED}) @include(if: true) { ...F ... on User { b } } } fragment F on User { c }`, false},
+		{`mutation { a(s: "x\né") } # comment`, false},
+		{`{ a(s: """block""") }`, false},
+		{`{ }`, true},
+		{`{ a `, true},
+		{`{ ...Missing }`, true},
+		{`{ a(s: "x) }`, true},
+		{`{ a(n: -) }`, true},
+		{``, true},
+		{strings.Repeat("{a", 60) + strings.Repeat("}", 60), false},
+		{strings.Repeat("{a", 100000) + strings.Repeat("}", 100000), true},
+		{"{ a(l: " + strings.Repeat("[", 100000) + strings.Repeat("]", 100000) + ") }", true},
+		{"query($v: " + strings.Repeat("[", 100000) + "Int" + strings.Repeat("]", 100000) + ") { a }", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			_, err := parseGraphQL(tc.query)
+			if (err != nil) != tc.err {
+				t.Errorf("Unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleGraphQL(t *testing.T) {
+	var ordersCalls int32
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("user", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		resolve := &GraphQLResolve{}
+		json.Unmarshal(req.Msg, resolve)
+
+		if resolve.Arguments["id"] == "missing" {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 404, Msg: []byte("no such user")})
+			w.Write(msg)
+			return
+		}
+
+		user, _ := json.Marshal(map[string]interface{}{
+			"id": resolve.Arguments["id"], "name": "User " + fmt.Sprint(resolve.Arguments["id"]), "secret": "s",
+		})
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: user})
+		w.Write(msg)
+	})
+	service.HandleFunc("users", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(`[{"id":"1"},{"id":"2"}]`)})
+		w.Write(msg)
+	})
+	service.HandleFunc("orders", func(w mrpc.TopicWriter, data []byte) {
+		atomic.AddInt32(&ordersCalls, 1)
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		resolve := &GraphQLResolve{}
+		json.Unmarshal(req.Msg, resolve)
+
+		res := []interface{}{}
+		for _, s := range resolve.Sources {
+			id := s.(map[string]interface{})["id"]
+			res = append(res, []interface{}{map[string]interface{}{"total": id}})
+		}
+		body, _ := json.Marshal(res)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: body})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.HandleGraphQL(Endpoint{Path: "/graphql"}, map[string]GraphQLResolver{
+		"user":         {Topic: "user"},
+		"users":        {Topic: "users"},
+		"users.orders": {Topic: "orders", Batch: true},
+	})
+
+	cases := []struct {
+		method string
+		query  string
+		vars   string
+		code   int
+		body   string
+		orders int32
+	}{
+		{
+			"POST", `{ user(id: "1") { name id } }`, "",
+			200, `{"data":{"user":{"name":"User 1","id":"1"}}}`, 0,
+		},
+		{
+			"POST", `query U($id: ID) { a: user(id: $id) { name } b: user(id: "missing") { name } }`, `{"id": "2"}`,
+			200, `{"data":{"a":{"name":"User 2"},"b":null},"errors":[{"message":"no such user","path":["b"]}]}`, 0,
+		},
+		{
+			"POST", `{ users { id orders { total } } }`, "",
+			200, `{"data":{"users":[{"id":"1","orders":[{"total":"1"}]},{"id":"2","orders":[{"total":"2"}]}]}}`, 1,
+		},
+		{
+			"GET", `{ user(id: "3") { ...F } } fragment F on User { name @skip(if: true) id }`, "",
+			200, `{"data":{"user":{"id":"3"}}}`, 0,
+		},
+		{
+			"POST", `{ unknown }`, "",
+			200, `{"data":{"unknown":null},"errors":[{"message":"no resolver for field \"unknown\"","path":["unknown"]}]}`, 0,
+		},
+		{
+			"GET", `mutation { user }`, "",
+			405, `{"errors":[{"message":"mutations require POST"}]}`, 0,
+		},
+		{
+			"POST", `{ user(`, "",
+			400, `{"errors":[{"message":"syntax error at 7: unexpected end of document"}]}`, 0,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			atomic.StoreInt32(&ordersCalls, 0)
+
+			var r *http.Request
+			if tc.method == "GET" {
+				r, _ = http.NewRequest("GET", "/graphql?"+url.Values{"query": {tc.query}}.Encode(), nil)
+			} else {
+				params := map[string]interface{}{"query": tc.query}
+				if tc.vars != "" {
+					params["variables"] = json.RawMessage(tc.vars)
+				}
+				body, _ := json.Marshal(params)
+				r, _ = http.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+			}
+
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body:\ngot  %v\nwant %v", body, tc.body)
+			}
+			if n := atomic.LoadInt32(&ordersCalls); n != tc.orders {
+				t.Errorf("Unexpected orders calls %v; expected %v", n, tc.orders)
+			}
+		})
+	}
+}
+
+func TestGraphQLBodyLimit(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.HandleGraphQL(Endpoint{Path: "/graphql"}, map[string]GraphQLResolver{})
+
+	body := `{ a(s: "` + strings.Repeat("a", maxGraphQLBodySize) + `") }`
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/graphql")
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Unexpected code %v", w.Code)
+	}
+}
+
+func TestGraphQLEndpointMiddleware(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.HandleGraphQL(Endpoint{Path: "/graphql", IPFilter: &IPFilter{Deny: []string{"0.0.0.0/0"}}}, map[string]GraphQLResolver{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ a }"), nil)
+	r.RemoteAddr = "1.1.1.1:1234"
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected code %v", w.Code)
+	}
+}
+
+func TestGraphQLCallLimit(t *testing.T) {
+	var calls int32
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		atomic.AddInt32(&calls, 1)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(`1`)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.HandleGraphQL(Endpoint{Path: "/graphql"}, map[string]GraphQLResolver{"a": {Topic: "a"}})
+
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i < maxGraphQLCalls+5; i++ {
+		fmt.Fprintf(&query, " f%v: a(n: %v)", i, i)
+	}
+	query.WriteString(" }")
+
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query.String()))
+	r.Header.Set("Content-Type", "application/graphql")
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	res := &graphQLResponse{}
+	json.Unmarshal(w.Body.Bytes(), res)
+	if calls != maxGraphQLCalls || len(res.Errors) != 5 || res.Errors[0].Message != ErrGraphQLTooManyCalls.Error() {
+		t.Errorf("Unexpected calls %v and errors %v", calls, res.Errors)
+	}
+}
@@ -0,0 +1,580 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// gqlDocument is a parsed GraphQL query document.
+type gqlDocument struct {
+	ops       []*gqlOperation
+	fragments map[string][]gqlSelection
+}
+
+type gqlOperation struct {
+	typ  string
+	name string
+	vars []gqlVarDef
+	sel  []gqlSelection
+}
+
+type gqlVarDef struct {
+	name string
+	def  interface{}
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment.
+type gqlSelection struct {
+	field      *gqlField
+	spread     string
+	inline     []gqlSelection
+	directives []gqlDirective
+}
+
+type gqlField struct {
+	alias string
+	name  string
+	args  []gqlArg
+	sel   []gqlSelection
+}
+
+// key is the name of the field in the response.
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type gqlArg struct {
+	name  string
+	value interface{}
+}
+
+type gqlDirective struct {
+	name string
+	args []gqlArg
+}
+
+// gqlVar is a variable reference in an argument value.
+type gqlVar string
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlNumber
+	gqlString
+)
+
+type gqlToken struct {
+	kind gqlTokenKind
+	val  string
+	pos  int
+}
+
+type gqlLexer struct {
+	src string
+	pos int
+}
+
+func (l *gqlLexer) next() (gqlToken, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.pos++
+			continue
+		}
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return gqlToken{gqlEOF, "", start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{gqlPunct, "...", start}, nil
+	case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+		l.pos++
+		return gqlToken{gqlPunct, string(c), start}, nil
+	case isGQLNameStart(c):
+		for l.pos < len(l.src) && (isGQLNameStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return gqlToken{gqlName, l.src[start:l.pos], start}, nil
+	case c == '-' || isDigit(c):
+		l.pos++
+		for l.pos < len(l.src) && strings.IndexByte("0123456789.eE+-", l.src[l.pos]) >= 0 {
+			l.pos++
+		}
+		return gqlToken{gqlNumber, l.src[start:l.pos], start}, nil
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return gqlToken{}, gqlSyntaxError(start, "unterminated string")
+		}
+		l.pos += end + 6
+		return gqlToken{gqlString, l.src[start+3 : l.pos-3], start}, nil
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return gqlToken{}, gqlSyntaxError(start, "unterminated string")
+		}
+		l.pos++
+
+		// GraphQL string escapes are the JSON ones.
+		var s string
+		if err := json.Unmarshal([]byte(l.src[start:l.pos]), &s); err != nil {
+			return gqlToken{}, gqlSyntaxError(start, "invalid string")
+		}
+		return gqlToken{gqlString, s, start}, nil
+	}
+
+	return gqlToken{}, gqlSyntaxError(start, fmt.Sprintf("unexpected character %q", c))
+}
+
+func isGQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func gqlSyntaxError(pos int, msg string) error {
+	return fmt.Errorf("syntax error at %v: %v", pos, msg)
+}
+
+// maxGQLDepth limits the nesting of selection sets, values and types.
+const maxGQLDepth = 64
+
+type gqlParser struct {
+	lex     *gqlLexer
+	tok     gqlToken
+	spreads []string
+	depth   int
+}
+
+// parseGraphQL parses an executable GraphQL document. Type conditions are
+// ignored as the proxy has no schema.
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lex: &gqlLexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{fragments: map[string][]gqlSelection{}}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.is(gqlPunct, "{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, &gqlOperation{typ: "query", sel: sel})
+		case p.is(gqlName, "query"), p.is(gqlName, "mutation"), p.is(gqlName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, op)
+		case p.is(gqlName, "fragment"):
+			name, sel, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = sel
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.ops) == 0 {
+		return nil, gqlSyntaxError(0, "no operations")
+	}
+
+	for _, name := range p.spreads {
+		if _, ok := doc.fragments[name]; !ok {
+			return nil, fmt.Errorf("unknown fragment %q", name)
+		}
+	}
+
+	return doc, nil
+}
+
+func (p *gqlParser) advance() (err error) {
+	p.tok, err = p.lex.next()
+	return err
+}
+
+func (p *gqlParser) is(kind gqlTokenKind, val string) bool {
+	return p.tok.kind == kind && p.tok.val == val
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.tok.kind == gqlEOF {
+		return gqlSyntaxError(p.tok.pos, "unexpected end of document")
+	}
+	return gqlSyntaxError(p.tok.pos, fmt.Sprintf("unexpected %q", p.tok.val))
+}
+
+// nest enters a nested selection set, value or type. Callers defer leave.
+func (p *gqlParser) nest() error {
+	p.depth++
+	if p.depth > maxGQLDepth {
+		return gqlSyntaxError(p.tok.pos, "maximum nesting depth exceeded")
+	}
+	return nil
+}
+
+func (p *gqlParser) leave() {
+	p.depth--
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.is(gqlPunct, punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.kind != gqlName {
+		return "", p.unexpected()
+	}
+	name := p.tok.val
+	return name, p.advance()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{typ: p.tok.val}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == gqlName {
+		op.name = p.tok.val
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.is(gqlPunct, "(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.is(gqlPunct, ")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	op.sel, err = p.selectionSet()
+	return op, err
+}
+
+func (p *gqlParser) varDef() (v gqlVarDef, err error) {
+	if err = p.expect("$"); err != nil {
+		return v, err
+	}
+	if v.name, err = p.name(); err != nil {
+		return v, err
+	}
+	if err = p.expect(":"); err != nil {
+		return v, err
+	}
+	if err = p.typeRef(); err != nil {
+		return v, err
+	}
+	if p.is(gqlPunct, "=") {
+		if err = p.advance(); err != nil {
+			return v, err
+		}
+		if v.def, err = p.value(); err != nil {
+			return v, err
+		}
+	}
+	_, err = p.directives()
+	return v, err
+}
+
+func (p *gqlParser) typeRef() error {
+	if err := p.nest(); err != nil {
+		return err
+	}
+	defer p.leave()
+
+	if p.is(gqlPunct, "[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+
+	if p.is(gqlPunct, "!") {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *gqlParser) fragment() (string, []gqlSelection, error) {
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	if !p.is(gqlName, "on") {
+		return "", nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+	if _, err := p.name(); err != nil {
+		return "", nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return "", nil, err
+	}
+	sel, err := p.selectionSet()
+	return name, sel, err
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var sel []gqlSelection
+	for !p.is(gqlPunct, "}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, s)
+	}
+
+	if len(sel) == 0 {
+		return nil, gqlSyntaxError(p.tok.pos, "empty selection set")
+	}
+
+	return sel, p.advance()
+}
+
+func (p *gqlParser) selection() (s gqlSelection, err error) {
+	if p.is(gqlPunct, "...") {
+		if err = p.advance(); err != nil {
+			return s, err
+		}
+
+		if p.tok.kind == gqlName && p.tok.val != "on" {
+			s.spread = p.tok.val
+			p.spreads = append(p.spreads, s.spread)
+			if err = p.advance(); err != nil {
+				return s, err
+			}
+			s.directives, err = p.directives()
+			return s, err
+		}
+
+		if p.is(gqlName, "on") {
+			if err = p.advance(); err != nil {
+				return s, err
+			}
+			if _, err = p.name(); err != nil {
+				return s, err
+			}
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.inline, err = p.selectionSet()
+		return s, err
+	}
+
+	f := &gqlField{}
+	if f.name, err = p.name(); err != nil {
+		return s, err
+	}
+	if p.is(gqlPunct, ":") {
+		if err = p.advance(); err != nil {
+			return s, err
+		}
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if f.args, err = p.args(); err != nil {
+		return s, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if p.is(gqlPunct, "{") {
+		if f.sel, err = p.selectionSet(); err != nil {
+			return s, err
+		}
+	}
+
+	s.field = f
+	return s, nil
+}
+
+func (p *gqlParser) args() ([]gqlArg, error) {
+	if !p.is(gqlPunct, "(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var args []gqlArg
+	for !p.is(gqlPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, gqlArg{name, v})
+	}
+
+	return args, p.advance()
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var ds []gqlDirective
+	for p.is(gqlPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, gqlDirective{name, args})
+	}
+	return ds, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	tok := p.tok
+	switch {
+	case p.is(gqlPunct, "$"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVar(name), err
+	case tok.kind == gqlNumber:
+		n := json.Number(tok.val)
+		if _, err := n.Float64(); err != nil {
+			return nil, gqlSyntaxError(tok.pos, fmt.Sprintf("invalid number %q", tok.val))
+		}
+		return n, p.advance()
+	case tok.kind == gqlString:
+		return tok.val, p.advance()
+	case tok.kind == gqlName:
+		var v interface{} = tok.val
+		switch tok.val {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		}
+		return v, p.advance()
+	case p.is(gqlPunct, "["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.is(gqlPunct, "]") {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case p.is(gqlPunct, "{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.is(gqlPunct, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+	}
+
+	return nil, p.unexpected()
+}
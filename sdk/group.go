@@ -0,0 +1,100 @@
+package sdk
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Group registers endpoints sharing a path prefix, defaults and middleware.
+type Group struct {
+	pxy        *Proxy
+	prefix     string
+	host       string
+	keepAlive  int
+	middleware []Middleware
+}
+
+// GroupOption configures a Group.
+type GroupOption func(*Group)
+
+// WithTimeout sets the default MRPC timeout of the group endpoints. Endpoints
+// with KeepAlive keep their own timeout. Timeouts are rounded up to whole
+// milliseconds.
+func WithTimeout(d time.Duration) GroupOption {
+	return func(g *Group) {
+		g.keepAlive = int((d + time.Millisecond - 1) / time.Millisecond)
+	}
+}
+
+// WithMiddleware adds middleware to the group endpoints.
+func WithMiddleware(mw ...Middleware) GroupOption {
+	return func(g *Group) {
+		g.middleware = append(g.middleware, mw...)
+	}
+}
+
+// WithAuth adds an authentication middleware, e.g. Proxy.JWTAuth, to the group
+// endpoints.
+func WithAuth(mw Middleware) GroupOption {
+	return WithMiddleware(mw)
+}
+
+// WithHost serves the group endpoints only for host.
+func WithHost(host string) GroupOption {
+	return func(g *Group) {
+		g.host = host
+	}
+}
+
+// Group creates a group of endpoints under prefix.
+func (pxy *Proxy) Group(prefix string, opts ...GroupOption) *Group {
+	g := &Group{pxy: pxy, prefix: prefix}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Group creates a nested group inheriting the prefix, defaults and middleware
+// of g.
+func (g *Group) Group(prefix string, opts ...GroupOption) *Group {
+	sub := &Group{
+		pxy:        g.pxy,
+		prefix:     joinPath(g.prefix, prefix),
+		host:       g.host,
+		keepAlive:  g.keepAlive,
+		middleware: append([]Middleware{}, g.middleware...),
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	return sub
+}
+
+// Handle adds endpoints to the proxy applying the group settings.
+func (g *Group) Handle(eps ...Endpoint) error {
+	grouped := make([]Endpoint, len(eps))
+	for i, ep := range eps {
+		ep.Path = joinPath(g.prefix, ep.Path)
+		if ep.Host == "" {
+			ep.Host = g.host
+		}
+		if ep.KeepAlive == 0 {
+			ep.KeepAlive = g.keepAlive
+		}
+		ep.Middleware = append(append([]Middleware{}, g.middleware...), ep.Middleware...)
+		grouped[i] = ep
+	}
+
+	return g.pxy.Handle(grouped...)
+}
+
+// joinPath joins URL paths keeping the trailing slash of p.
+func joinPath(prefix, p string) string {
+	joined := path.Join("/", prefix, p)
+	if strings.HasSuffix(p, "/") && joined != "/" {
+		joined += "/"
+	}
+	return joined
+}
@@ -0,0 +1,105 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestJoinPath(t *testing.T) {
+	cases := []struct {
+		prefix, p, joined string
+	}{
+		{"/v1", "/a", "/v1/a"},
+		{"/v1/", "a/", "/v1/a/"},
+		{"v1", "/a/:id", "/v1/a/:id"},
+		{"", "/", "/"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if joined := joinPath(tc.prefix, tc.p); joined != tc.joined {
+				t.Errorf("Unexpected path: got %v want %v", joined, tc.joined)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+			return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+				calls = append(calls, name)
+				w.WriteHeader(http.StatusTeapot)
+			}
+		}
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+
+	v1 := pxy.Group("/v1", WithTimeout(5*time.Second), WithAuth(mw("auth")))
+	admin := v1.Group("/admin", WithHost("admin.example.com"), WithMiddleware(mw("admin")))
+	v1.Handle(
+		Endpoint{Method: "GET", Path: "/a", Topic: "service.a"},
+		Endpoint{Method: "GET", Path: "/b", Topic: "service.b", KeepAlive: 10},
+	)
+	admin.Handle(Endpoint{Method: "GET", Path: "/c", Topic: "service.c"})
+
+	eps := []struct {
+		path      string
+		host      string
+		keepAlive int
+		mws       int
+	}{
+		{"/v1/a", "", 5000, 1},
+		{"/v1/b", "", 10, 1},
+		{"/v1/admin/c", "admin.example.com", 5000, 2},
+	}
+
+	for i, ep := range pxy.Eps {
+		if ep.Path != eps[i].path || ep.Host != eps[i].host || ep.KeepAlive != eps[i].keepAlive || len(ep.Middleware) != eps[i].mws {
+			t.Errorf("Unexpected endpoint %v: %+v", i, ep)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/v1/admin/c", nil)
+	r.Host = "admin.example.com"
+	pxy.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !reflect.DeepEqual(calls, []string{"auth"}) {
+		t.Errorf("Unexpected middleware calls: %v", calls)
+	}
+}
+
+func TestGroupTimeout(t *testing.T) {
+	cases := []struct {
+		d         time.Duration
+		keepAlive int
+	}{
+		{0, 0},
+		{5, 1},
+		{time.Millisecond, 1},
+		{1500 * time.Microsecond, 2},
+		{5 * time.Second, 5000},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			g := &Group{}
+			WithTimeout(tc.d)(g)
+			if g.keepAlive != tc.keepAlive {
+				t.Errorf("Unexpected keep alive: got %v want %v", g.keepAlive, tc.keepAlive)
+			}
+		})
+	}
+}
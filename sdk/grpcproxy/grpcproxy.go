@@ -0,0 +1,224 @@
+// Package grpcproxy serves unary gRPC calls by forwarding them to MRPC topics
+// through an sdk.Proxy, so gRPC and REST clients share the same backend
+// services.
+//
+// Calls go through the same handler as the proxy endpoints, so the proxy
+// middleware, IP filters, trusted proxies, header allowlists, timeouts,
+// shutdown draining and access logs apply to them. The gRPC metadata is the
+// request headers and the peer address is the remote address.
+//
+// Messages are forwarded without decoding: the serialized request message is
+// sent in mrpcproxy.Request.Msg and mrpcproxy.Response.Msg must hold the
+// serialized reply. The proxy has no access to the service descriptors, so
+// services shared with REST endpoints tell both apart by the Action of the
+// request. The response code is mapped to the gRPC status.
+package grpcproxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/miracl/mrpcproxy/sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Action is the mrpcproxy.Request action of forwarded gRPC calls.
+const Action = "GRPC"
+
+var (
+	// ErrNoRoutes is returned when the server is created without routes.
+	ErrNoRoutes = errors.New("no gRPC routes")
+)
+
+// Route maps a gRPC method to an MRPC topic.
+type Route struct {
+	// Method is the full gRPC method name, e.g. /pkg.Service/Method.
+	Method    string
+	Topic     string
+	KeepAlive int // In Millisecond. Overrides the proxy timeout
+
+	// ForwardHeaders lists the metadata keys copied to the MRPC request. See
+	// sdk.Endpoint.ForwardHeaders.
+	ForwardHeaders []string
+	// Middleware wraps the handler of the route after the proxy middleware.
+	Middleware []sdk.Middleware
+}
+
+// Server is a gRPC frontend of a proxy.
+type Server struct {
+	pxy      *sdk.Proxy
+	handlers map[string]http.Handler
+	grpc     *grpc.Server
+}
+
+// New creates a gRPC server forwarding the routed methods through pxy.
+func New(pxy *sdk.Proxy, routes []Route, opts ...grpc.ServerOption) (*Server, error) {
+	if len(routes) == 0 {
+		return nil, ErrNoRoutes
+	}
+
+	s := &Server{pxy: pxy, handlers: map[string]http.Handler{}}
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Method, "/") || strings.Count(r.Method, "/") != 2 {
+			return nil, fmt.Errorf("invalid gRPC method %q", r.Method)
+		}
+
+		h, err := pxy.EndpointHandler(sdk.Endpoint{
+			Topic:          r.Topic,
+			Method:         Action,
+			Path:           r.Method,
+			KeepAlive:      r.KeepAlive,
+			ForwardHeaders: r.ForwardHeaders,
+			Middleware:     r.Middleware,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.handlers[r.Method] = h
+	}
+
+	opts = append(opts, grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(s.handle))
+	s.grpc = grpc.NewServer(opts...)
+	return s, nil
+}
+
+// Serve accepts gRPC connections on l.
+func (s *Server) Serve(l net.Listener) error {
+	return s.grpc.Serve(l)
+}
+
+// Stop stops the server after the pending calls finish.
+func (s *Server) Stop() {
+	s.grpc.GracefulStop()
+}
+
+func (s *Server) handle(srv interface{}, stream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unknown method")
+	}
+
+	h, ok := s.handlers[method]
+	if !ok {
+		s.pxy.Debugger.Printf("unknown gRPC method %v", method)
+		return status.Errorf(codes.Unimplemented, "unknown method %v", method)
+	}
+
+	var msg frame
+	if err := stream.RecvMsg(&msg); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	r, err := http.NewRequestWithContext(ctx, "POST", method, bytes.NewReader(msg))
+	if err != nil {
+		return status.Error(codes.Internal, "internal error")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			if k == ":authority" && len(vs) > 0 {
+				r.Host = vs[0]
+			}
+			if !strings.HasPrefix(k, ":") {
+				r.Header[http.CanonicalHeaderKey(k)] = vs
+			}
+		}
+	}
+	// Replies are framed by gRPC and must not be compressed by the proxy.
+	r.Header.Del("Accept-Encoding")
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+
+	rec := newRecorder()
+	h.ServeHTTP(rec, r)
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+
+	if md := responseMetadata(rec.header); len(md) > 0 {
+		stream.SetHeader(md)
+	}
+
+	code := Code(rec.code)
+	if code != codes.OK {
+		text := rec.body.String()
+		if text == "" {
+			text = http.StatusText(rec.code)
+		}
+		return status.Error(code, text)
+	}
+
+	return stream.SendMsg(frame(rec.body.Bytes()))
+}
+
+// recorder buffers the response of a proxy handler.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, code: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	rec.code = code
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+func responseMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range h {
+		md.Append(k, vs...)
+	}
+	return md
+}
+
+// Code maps an HTTP status code to a gRPC status code.
+func Code(httpCode int) codes.Code {
+	return codes.Code(sdk.GRPCCode(httpCode))
+}
+
+// frame is a serialized message forwarded as is.
+type frame []byte
+
+// rawCodec passes messages through without decoding them.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	f, ok := v.(frame)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return f, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	f, ok := v.(*frame)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*f = append((*f)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,179 @@
+package grpcproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+	"github.com/miracl/mrpcproxy/sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Println(v ...interface{})               {}
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+func TestServer(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("echo", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{
+			Code: 200,
+			Msg:  append([]byte(req.Headers.Get("X-Prefix")), req.Msg...),
+		})
+		w.Write(msg)
+	})
+	service.HandleFunc("missing", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 404, Msg: []byte("no such thing")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+
+	pxy, _ := sdk.New(":80", service, func(pxy *sdk.Proxy) error {
+		pxy.Logger, pxy.Debugger, pxy.Requests = nopLogger{}, nopLogger{}, nopLogger{}
+		return nil
+	})
+	s, err := New(pxy, []Route{
+		{Method: "/test.Svc/Echo", Topic: "service.echo"},
+		{Method: "/test.Svc/Missing", Topic: "service.missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cases := []struct {
+		method string
+		reply  string
+		code   codes.Code
+	}{
+		{"/test.Svc/Echo", "> hello", codes.OK},
+		{"/test.Svc/Missing", "", codes.NotFound},
+		{"/test.Svc/Unknown", "", codes.Unimplemented},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-prefix", "> ")
+
+			var reply frame
+			err := conn.Invoke(ctx, tc.method, frame("hello"), &reply, grpc.ForceCodec(rawCodec{}))
+			if status.Code(err) != tc.code {
+				t.Fatalf("Unexpected status: %v", err)
+			}
+
+			if string(reply) != tc.reply {
+				t.Errorf("Unexpected reply: got %q want %q", reply, tc.reply)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(nil, nil); err != ErrNoRoutes {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := New(nil, []Route{{Method: "Svc.Method"}}); err == nil {
+		t.Error("Invalid method accepted")
+	}
+}
+
+func TestServerControls(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("info", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{
+			Code: 200,
+			Msg:  []byte(fmt.Sprintf("%v %v %v %v", req.Action, req.IPAddress, req.Headers.Get("X-Keep"), req.Headers.Get("X-Drop"))),
+		})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+
+	pxy, _ := sdk.New(":80", service, func(pxy *sdk.Proxy) error {
+		pxy.Logger, pxy.Debugger, pxy.Requests = nopLogger{}, nopLogger{}, nopLogger{}
+		return nil
+	})
+	pxy.Use(func(ep sdk.Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next(w, r, p)
+		}
+	})
+	s, err := New(pxy, []Route{{Method: "/test.Svc/Info", Topic: "service.info", ForwardHeaders: []string{"X-Keep"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback not available")
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cases := []struct {
+		md    []string
+		reply string
+		code  codes.Code
+	}{
+		{[]string{"x-keep", "a", "x-drop", "b"}, "", codes.Unauthenticated},
+		{[]string{"authorization", "Bearer t", "x-keep", "a", "x-drop", "b"}, "GRPC ::1 a ", codes.OK},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, tc.md...)
+
+			var reply frame
+			err := conn.Invoke(ctx, "/test.Svc/Info", frame("hello"), &reply, grpc.ForceCodec(rawCodec{}))
+			if status.Code(err) != tc.code {
+				t.Fatalf("Unexpected status: %v", err)
+			}
+
+			if string(reply) != tc.reply {
+				t.Errorf("Unexpected reply: got %q want %q", reply, tc.reply)
+			}
+		})
+	}
+}
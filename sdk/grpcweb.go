@@ -0,0 +1,269 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	connectVersionHeader   = "Connect-Protocol-Version"
+
+	grpcWebTrailerFlag = 0x80
+
+	// maxGRPCWebMessageSize limits the gRPC-Web request messages, as the
+	// default of the gRPC servers.
+	maxGRPCWebMessageSize = 4 << 20
+)
+
+var (
+	// ErrGRPCWebFrame is returned for malformed gRPC-Web request bodies.
+	ErrGRPCWebFrame = errors.New("malformed gRPC-Web frame")
+	// ErrGRPCWebTooLarge is returned for gRPC-Web request messages over
+	// 4MiB.
+	ErrGRPCWebTooLarge = errors.New("gRPC-Web message too large")
+)
+
+// gRPC status codes, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+var grpcCodeNames = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded",
+	"not_found", "already_exists", "permission_denied", "resource_exhausted",
+	"failed_precondition", "aborted", "out_of_range", "unimplemented",
+	"internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+// connectHTTPStatus maps gRPC status codes to the HTTP status of Connect error
+// responses.
+var connectHTTPStatus = []int{
+	200, 499, 500, 400, 504, 404, 409, 403, 429, 400, 409, 400, 501, 500, 503, 500, 401,
+}
+
+// GRPCCode maps an HTTP status code to a gRPC status code.
+func GRPCCode(httpCode int) int {
+	switch {
+	case httpCode >= 200 && httpCode < 300:
+		return 0
+	case httpCode == http.StatusBadRequest:
+		return 3
+	case httpCode == http.StatusUnauthorized:
+		return 16
+	case httpCode == http.StatusForbidden:
+		return 7
+	case httpCode == http.StatusNotFound:
+		return 5
+	case httpCode == http.StatusConflict:
+		return 6
+	case httpCode == http.StatusRequestTimeout, httpCode == http.StatusGatewayTimeout:
+		return 4
+	case httpCode == http.StatusTooManyRequests:
+		return 8
+	case httpCode == http.StatusNotImplemented:
+		return 12
+	case httpCode == http.StatusServiceUnavailable:
+		return 14
+	case httpCode >= 400 && httpCode < 500:
+		return 9
+	}
+	return 13
+}
+
+// WithGRPCWeb lets gRPC-Web and Connect clients call the endpoints with unary
+// requests. Register the endpoints with method POST and the gRPC path, e.g.
+// /pkg.Service/Method. Request and response messages are forwarded as is.
+func WithGRPCWeb() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.Use(pxy.grpcWeb)
+		return nil
+	}
+}
+
+func (pxy *Proxy) grpcWeb(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ct := r.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(ct, grpcWebContentType):
+			pxy.serveGRPCWeb(w, r, p, next, strings.HasPrefix(ct, grpcWebTextContentType))
+		case r.Header.Get(connectVersionHeader) != "":
+			pxy.serveConnect(w, r, p, next)
+		default:
+			next(w, r, p)
+		}
+	}
+}
+
+func (pxy *Proxy) serveGRPCWeb(w http.ResponseWriter, r *http.Request, p httprouter.Params, next httprouter.Handle, text bool) {
+	ct := r.Header.Get("Content-Type")
+	msg, err := readGRPCWebMessage(r, text)
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		code := GRPCCode(http.StatusBadRequest)
+		if err == ErrGRPCWebTooLarge {
+			// resource_exhausted
+			code = GRPCCode(http.StatusTooManyRequests)
+		}
+		writeGRPCWeb(w, ct, text, nil, code, err.Error())
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(msg))
+
+	bw := newBufferedWriter()
+	next(bw, uncompressed(r), p)
+
+	copyHeaders(w.Header(), bw.header)
+	code := GRPCCode(bw.code)
+	if code != 0 {
+		writeGRPCWeb(w, ct, text, nil, code, bw.body.String())
+		return
+	}
+	writeGRPCWeb(w, ct, text, bw.body.Bytes(), 0, "")
+}
+
+func readGRPCWebMessage(r *http.Request, text bool) ([]byte, error) {
+	// The body holds the frame prefix and the message, base64 encoded in
+	// text mode.
+	limit := int64(5 + maxGRPCWebMessageSize)
+	if text {
+		limit = int64(base64.StdEncoding.EncodedLen(int(limit)))
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, ErrGRPCWebTooLarge
+		}
+		return nil, err
+	}
+
+	if text {
+		body, err = base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return nil, ErrGRPCWebFrame
+		}
+	}
+
+	if len(body) < 5 || body[0]&grpcWebTrailerFlag != 0 {
+		return nil, ErrGRPCWebFrame
+	}
+
+	n := binary.BigEndian.Uint32(body[1:5])
+	if n > maxGRPCWebMessageSize {
+		return nil, ErrGRPCWebTooLarge
+	}
+	if uint32(len(body)-5) < n {
+		return nil, ErrGRPCWebFrame
+	}
+
+	return body[5 : 5+n], nil
+}
+
+// writeGRPCWeb writes an optional message frame followed by the trailer frame.
+func writeGRPCWeb(w http.ResponseWriter, ct string, text bool, msg []byte, code int, message string) {
+	var out bytes.Buffer
+	if msg != nil {
+		writeGRPCWebFrame(&out, 0, msg)
+	}
+
+	trailer := fmt.Sprintf("grpc-status: %v\r\n", code)
+	if message != "" {
+		trailer += fmt.Sprintf("grpc-message: %v\r\n", url.PathEscape(message))
+	}
+	writeGRPCWebFrame(&out, grpcWebTrailerFlag, []byte(trailer))
+
+	body := out.Bytes()
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, data []byte) {
+	var prefix [5]byte
+	prefix[0] = flag
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	buf.Write(prefix[:])
+	buf.Write(data)
+}
+
+func (pxy *Proxy) serveConnect(w http.ResponseWriter, r *http.Request, p httprouter.Params, next httprouter.Handle) {
+	bw := newBufferedWriter()
+	next(bw, uncompressed(r), p)
+
+	copyHeaders(w.Header(), bw.header)
+	code := GRPCCode(bw.code)
+	if code == 0 {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		w.Write(bw.body.Bytes())
+		return
+	}
+
+	body, _ := json.Marshal(struct {
+		Code    string `json:"code"`
+		Message string `json:"message,omitempty"`
+	}{grpcCodeNames[code], bw.body.String()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(connectHTTPStatus[code])
+	w.Write(body)
+}
+
+// bufferedWriter collects the response of an endpoint so it can be reframed.
+type bufferedWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	return &bufferedWriter{header: http.Header{}, code: http.StatusOK}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// uncompressed returns r without Accept-Encoding, as the endpoint response
+// is reframed and must not be compressed.
+func uncompressed(r *http.Request) *http.Request {
+	r = r.WithContext(r.Context())
+	r.Header = r.Header.Clone()
+	r.Header.Del("Accept-Encoding")
+	return r
+}
+
+// copyHeaders copies the endpoint response headers except those describing
+// the body, which is reframed.
+func copyHeaders(dst, src http.Header) {
+	for k, vs := range src {
+		switch k {
+		case "Content-Encoding", "Content-Length", "Vary":
+			continue
+		}
+		dst[k] = vs
+	}
+}
@@ -0,0 +1,184 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func grpcWebFrame(flag byte, data []byte) []byte {
+	var buf bytes.Buffer
+	writeGRPCWebFrame(&buf, flag, data)
+	return buf.Bytes()
+}
+
+func TestWithGRPCWeb(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("echo", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: req.Msg})
+		w.Write(msg)
+	})
+	service.HandleFunc("missing", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 404, Msg: []byte("no such item")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithGRPCWeb())
+	pxy.Logger = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "service.echo", Method: "POST", Path: "/pkg.Svc/Echo"})
+	pxy.Handle(Endpoint{Topic: "service.missing", Method: "POST", Path: "/pkg.Svc/Missing"})
+
+	trailer := func(s string) []byte { return grpcWebFrame(grpcWebTrailerFlag, []byte(s)) }
+
+	cases := []struct {
+		path    string
+		ct      string
+		connect bool
+		body    []byte
+		code    int
+		resCT   string
+		resBody []byte
+	}{
+		{
+			"/pkg.Svc/Echo", "application/grpc-web+proto", false, grpcWebFrame(0, []byte("hi")),
+			200, "application/grpc-web+proto", append(grpcWebFrame(0, []byte("hi")), trailer("grpc-status: 0\r\n")...),
+		},
+		{
+			"/pkg.Svc/Echo", "application/grpc-web-text", false,
+			[]byte(base64.StdEncoding.EncodeToString(grpcWebFrame(0, []byte("hi")))),
+			200, "application/grpc-web-text",
+			[]byte(base64.StdEncoding.EncodeToString(append(grpcWebFrame(0, []byte("hi")), trailer("grpc-status: 0\r\n")...))),
+		},
+		{
+			"/pkg.Svc/Missing", "application/grpc-web", false, grpcWebFrame(0, []byte("hi")),
+			200, "application/grpc-web", trailer("grpc-status: 5\r\ngrpc-message: no%20such%20item\r\n"),
+		},
+		{
+			"/pkg.Svc/Echo", "application/grpc-web", false, []byte{0, 0},
+			200, "application/grpc-web", trailer("grpc-status: 3\r\ngrpc-message: malformed%20gRPC-Web%20frame\r\n"),
+		},
+		{
+			"/pkg.Svc/Echo", "application/grpc-web", false, []byte{0, 0xff, 0xff, 0xff, 0xff},
+			200, "application/grpc-web", trailer("grpc-status: 8\r\ngrpc-message: gRPC-Web%20message%20too%20large\r\n"),
+		},
+		{
+			"/pkg.Svc/Echo", "application/grpc-web", false, grpcWebFrame(0, make([]byte, maxGRPCWebMessageSize+1)),
+			200, "application/grpc-web", trailer("grpc-status: 8\r\ngrpc-message: gRPC-Web%20message%20too%20large\r\n"),
+		},
+		{
+			"/pkg.Svc/Echo", "application/proto", true, []byte("hi"),
+			200, "application/proto", []byte("hi"),
+		},
+		{
+			"/pkg.Svc/Missing", "application/json", true, []byte("{}"),
+			404, "application/json", []byte(`{"code":"not_found","message":"no such item"}`),
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", tc.path, bytes.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.ct)
+			if tc.connect {
+				r.Header.Set(connectVersionHeader, "1")
+			}
+
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tc.resCT {
+				t.Errorf("Unexpected content type %q; expected %q", ct, tc.resCT)
+			}
+			if body, _ := ioutil.ReadAll(w.Body); !bytes.Equal(body, tc.resBody) {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.resBody)
+			}
+		})
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	cases := []struct {
+		http int
+		grpc int
+	}{
+		{200, 0}, {204, 0}, {400, 3}, {401, 16}, {403, 7}, {404, 5}, {408, 4},
+		{429, 8}, {422, 9}, {500, 13}, {501, 12}, {503, 14}, {504, 4},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if code := GRPCCode(tc.http); code != tc.grpc {
+				t.Errorf("Unexpected code %v for %v; expected %v", code, tc.http, tc.grpc)
+			}
+		})
+	}
+}
+
+func TestGRPCWebCompression(t *testing.T) {
+	msg := bytes.Repeat([]byte("a"), 2048)
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("big", func(w mrpc.TopicWriter, data []byte) {
+		res, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: msg})
+		w.Write(res)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithGRPCWeb(), WithCompression(CompressionConfig{}))
+	pxy.Logger = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "service.big", Method: "POST", Path: "/pkg.Svc/Big"})
+
+	cases := []struct {
+		ct      string
+		connect bool
+		body    []byte
+		resBody []byte
+	}{
+		{"application/grpc-web", false, grpcWebFrame(0, []byte("hi")), append(grpcWebFrame(0, msg), grpcWebFrame(grpcWebTrailerFlag, []byte("grpc-status: 0\r\n"))...)},
+		{"application/proto", true, []byte("hi"), msg},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/pkg.Svc/Big", bytes.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.ct)
+			r.Header.Set("Accept-Encoding", "gzip, deflate, br")
+			if tc.connect {
+				r.Header.Set(connectVersionHeader, "1")
+			}
+
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if enc := w.Header().Get("Content-Encoding"); enc != "" {
+				t.Errorf("Unexpected content encoding %q", enc)
+			}
+			if body, _ := ioutil.ReadAll(w.Body); !bytes.Equal(body, tc.resBody) {
+				t.Errorf("Unexpected body %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrInvalidHeaderPolicy is returned for header policies with empty
+	// header names.
+	ErrInvalidHeaderPolicy = errors.New("invalid header policy")
+)
+
+// HeaderPolicy rewrites the response headers of an endpoint before they are
+// sent, so that the headers internal to the services don't reach clients.
+// Headers are removed, then renamed, then set.
+type HeaderPolicy struct {
+	// Remove lists the removed headers. Names ending with * remove all the
+	// headers with that prefix, e.g. X-Internal-*.
+	Remove []string `json:"remove"`
+	// Rename maps header names to the names they are sent as.
+	Rename map[string]string `json:"rename"`
+	// Set headers replace the ones of the response, e.g. to force a
+	// Cache-Control.
+	Set map[string]string `json:"set"`
+}
+
+// validate checks that the header names of hp are not empty.
+func (hp *HeaderPolicy) validate() error {
+	if hp == nil {
+		return nil
+	}
+	for _, name := range hp.Remove {
+		if strings.TrimSuffix(name, "*") == "" {
+			return ErrInvalidHeaderPolicy
+		}
+	}
+	for from, to := range hp.Rename {
+		if from == "" || to == "" {
+			return ErrInvalidHeaderPolicy
+		}
+	}
+	for name := range hp.Set {
+		if name == "" {
+			return ErrInvalidHeaderPolicy
+		}
+	}
+	return nil
+}
+
+// apply rewrites h according to hp.
+func (hp *HeaderPolicy) apply(h http.Header) {
+	if hp == nil {
+		return
+	}
+
+	for _, name := range hp.Remove {
+		prefix, ok := strings.CutSuffix(name, "*")
+		if !ok {
+			h.Del(name)
+			continue
+		}
+		prefix = http.CanonicalHeaderKey(prefix)
+		for k := range h {
+			if strings.HasPrefix(k, prefix) {
+				delete(h, k)
+			}
+		}
+	}
+
+	for from, to := range hp.Rename {
+		if vs, ok := h[http.CanonicalHeaderKey(from)]; ok {
+			h.Del(from)
+			h[http.CanonicalHeaderKey(to)] = vs
+		}
+	}
+
+	for name, value := range hp.Set {
+		h.Set(name, value)
+	}
+}
@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestHeaderPolicy(t *testing.T) {
+	cases := []struct {
+		policy *HeaderPolicy
+		header http.Header
+		want   http.Header
+	}{
+		{nil, http.Header{"A": {"1"}}, http.Header{"A": {"1"}}},
+		{
+			&HeaderPolicy{Remove: []string{"x-internal-*", "Server"}},
+			http.Header{"X-Internal-Host": {"a"}, "X-Internal-Trace": {"b"}, "Server": {"s"}, "X-Public": {"c"}},
+			http.Header{"X-Public": {"c"}},
+		},
+		{
+			&HeaderPolicy{Rename: map[string]string{"x-backend-version": "X-Version", "Missing": "X-Other"}},
+			http.Header{"X-Backend-Version": {"1", "2"}},
+			http.Header{"X-Version": {"1", "2"}},
+		},
+		{
+			&HeaderPolicy{Set: map[string]string{"Cache-Control": "no-store"}},
+			http.Header{"Cache-Control": {"max-age=60"}},
+			http.Header{"Cache-Control": {"no-store"}},
+		},
+		{
+			&HeaderPolicy{Remove: []string{"X-Version"}, Rename: map[string]string{"X-Backend-Version": "X-Version"}},
+			http.Header{"X-Version": {"old"}, "X-Backend-Version": {"new"}},
+			http.Header{"X-Version": {"new"}},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			tc.policy.apply(tc.header)
+			if !reflect.DeepEqual(tc.header, tc.want) {
+				t.Errorf("Unexpected headers: got %v want %v", tc.header, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderPolicyValidate(t *testing.T) {
+	cases := []struct {
+		policy *HeaderPolicy
+		err    error
+	}{
+		{nil, nil},
+		{&HeaderPolicy{Remove: []string{"X-A-*"}, Rename: map[string]string{"A": "B"}, Set: map[string]string{"C": ""}}, nil},
+		{&HeaderPolicy{Remove: []string{"*"}}, ErrInvalidHeaderPolicy},
+		{&HeaderPolicy{Rename: map[string]string{"A": ""}}, ErrInvalidHeaderPolicy},
+		{&HeaderPolicy{Set: map[string]string{"": "a"}}, ErrInvalidHeaderPolicy},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if err := tc.policy.validate(); err != tc.err {
+				t.Errorf("Unexpected error: got %v want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("items", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("items"), Headers: http.Header{
+			"X-Internal-Node": {"node-1"},
+			"X-Backend-Id":    {"b"},
+			"Cache-Control":   {"max-age=600"},
+		}})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/items", Topic: "items", ResponseHeaders: &HeaderPolicy{
+		Remove: []string{"X-Internal-*"},
+		Rename: map[string]string{"X-Backend-Id": "X-Served-By"},
+		Set:    map[string]string{"Cache-Control": "no-cache"},
+	}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r, _ := http.NewRequest("GET", "/items", nil)
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if w.Code != 200 || w.Header().Get("X-Internal-Node") != "" || w.Header().Get("X-Backend-Id") != "" ||
+		w.Header().Get("X-Served-By") != "b" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Unexpected response: %v %v", w.Code, w.Header())
+	}
+
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/other", Topic: "items", ResponseHeaders: &HeaderPolicy{
+		Remove: []string{""},
+	}}); err != ErrInvalidHeaderPolicy {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultHealthPath   = "/healthz"
+	defaultReadyPath    = "/readyz"
+	defaultProbeTimeout = 1 * time.Second
+)
+
+var (
+	// ErrShuttingDown is reported by the readiness check during shutdown.
+	ErrShuttingDown = errors.New("proxy is shutting down")
+)
+
+// HealthConfig configures the health and readiness endpoints.
+type HealthConfig struct {
+	// HealthPath always answers 200 while the process serves HTTP. Defaults to
+	// /healthz.
+	HealthPath string
+	// ReadyPath answers 200 when all checks pass and 503 otherwise. Defaults to
+	// /readyz.
+	ReadyPath string
+
+	// ProbeTopic, when set, is requested on every readiness check. Any reply
+	// within ProbeTimeout means the MRPC transport is connected.
+	ProbeTopic   string
+	ProbeTimeout time.Duration
+
+	// Checks are additional readiness checks, e.g. transport connectivity.
+	Checks []func(ctx context.Context) error
+}
+
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WithHealthChecks serves liveness and readiness endpoints. They bypass the
+// proxy middleware and are not forwarded over MRPC.
+func WithHealthChecks(cfg HealthConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.HealthPath == "" {
+			cfg.HealthPath = defaultHealthPath
+		}
+		if cfg.ReadyPath == "" {
+			cfg.ReadyPath = defaultReadyPath
+		}
+		if cfg.ProbeTimeout == 0 {
+			cfg.ProbeTimeout = defaultProbeTimeout
+		}
+
+		health := func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			writeHealth(w, nil)
+		}
+		ready := func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			err := pxy.ready(r.Context(), cfg)
+			if err != nil {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+			}
+			writeHealth(w, err)
+		}
+		pxy.addRoute(route{"", "GET", cfg.HealthPath, health, false})
+		pxy.addRoute(route{"", "GET", cfg.ReadyPath, ready, false})
+		return nil
+	}
+}
+
+func (pxy *Proxy) ready(ctx context.Context, cfg HealthConfig) error {
+	if atomic.LoadInt32(&pxy.shuttingDown) == 1 {
+		return ErrShuttingDown
+	}
+
+	if pxy.transportDown() {
+		return ErrTransportDown
+	}
+
+	if cfg.ProbeTopic != "" {
+		if err := pxy.probe(ctx, cfg.ProbeTopic, cfg.ProbeTimeout); err != nil {
+			return err
+		}
+	}
+
+	for _, check := range cfg.Checks {
+		if err := check(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeHealth(w http.ResponseWriter, err error) {
+	status, code := healthStatus{Status: "ok"}, http.StatusOK
+	if err != nil {
+		status, code = healthStatus{Status: "unavailable", Error: err.Error()}, http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
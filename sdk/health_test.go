@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestHealthChecks(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("ping", func(w mrpc.TopicWriter, data []byte) {
+		w.Write([]byte("pong"))
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		cfg    HealthConfig
+		path   string
+		status int
+		body   string
+	}{
+		{
+			cfg:    HealthConfig{},
+			path:   "/healthz",
+			status: http.StatusOK,
+			body:   `{"status":"ok"}`,
+		},
+		{
+			cfg:    HealthConfig{ProbeTopic: "service.ping"},
+			path:   "/readyz",
+			status: http.StatusOK,
+			body:   `{"status":"ok"}`,
+		},
+		{
+			cfg:    HealthConfig{ProbeTopic: "service.missing", ProbeTimeout: time.Millisecond},
+			path:   "/readyz",
+			status: http.StatusServiceUnavailable,
+			body:   `{"status":"unavailable","error":"context deadline exceeded"}`,
+		},
+		{
+			cfg: HealthConfig{
+				ReadyPath: "/ready",
+				Checks:    []func(context.Context) error{func(context.Context) error { return errors.New("down") }},
+			},
+			path:   "/ready",
+			status: http.StatusServiceUnavailable,
+			body:   `{"status":"unavailable","error":"down"}`,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithHealthChecks(tc.cfg))
+			pxy.Debugger = &MockLogger{}
+
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			rr := httptest.NewRecorder()
+			pxy.router.ServeHTTP(rr, r)
+
+			if rr.Code != tc.status {
+				t.Errorf("Unexpected status: got %v want %v", rr.Code, tc.status)
+			}
+
+			if body := strings.TrimSpace(rr.Body.String()); body != tc.body {
+				t.Errorf("Unexpected body: got %v want %v", body, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RequestInfo describes an endpoint request to the lifecycle hooks.
+type RequestInfo struct {
+	Endpoint  Endpoint
+	RequestID string
+	Request   *http.Request
+	// Start is when the endpoint handler started.
+	Start time.Time
+}
+
+// ResponseInfo describes an endpoint request once answered.
+type ResponseInfo struct {
+	RequestInfo
+	Status   int
+	Duration time.Duration
+	// Timings of the phases of the request, zero unless the requests are
+	// timed, see TimingsFromContext.
+	Timings Timings
+}
+
+// lifecycleHooks are the hooks registered with OnStart, OnRequest,
+// OnResponse and OnError.
+type lifecycleHooks struct {
+	start    []func() error
+	request  []func(RequestInfo) error
+	response []func(ResponseInfo)
+	errors   []func(ResponseInfo, error)
+}
+
+// hookState collects the error answered to a request for the OnError hooks.
+type hookState struct {
+	err error
+}
+
+type hookStateKey struct{}
+
+// OnStart registers f to be run by Serve before it listens. An error stops
+// Serve.
+func (pxy *Proxy) OnStart(f func() error) {
+	pxy.hooks.start = append(pxy.hooks.start, f)
+}
+
+// OnRequest registers f to be run before the endpoint requests are handled,
+// e.g. for feature flags. A returned error is written as an error response,
+// with the status of a StatusError or 500. The hooks must be registered
+// before Serve.
+func (pxy *Proxy) OnRequest(f func(RequestInfo) error) {
+	pxy.hooks.request = append(pxy.hooks.request, f)
+}
+
+// OnResponse registers f to be run after each endpoint request is answered,
+// e.g. for audit or metrics. The hooks must be registered before Serve.
+func (pxy *Proxy) OnResponse(f func(ResponseInfo)) {
+	pxy.hooks.response = append(pxy.hooks.response, f)
+}
+
+// OnError registers f to be run after the endpoint requests answered with an
+// error of the proxy or a structured error of the backend, after the
+// OnResponse hooks. The hooks must be registered before Serve.
+func (pxy *Proxy) OnError(f func(ResponseInfo, error)) {
+	pxy.hooks.errors = append(pxy.hooks.errors, f)
+}
+
+// runStartHooks runs the OnStart hooks until one fails.
+func (pxy *Proxy) runStartHooks() error {
+	for _, f := range pxy.hooks.start {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHooks runs the request hooks around the requests to ep.
+func (pxy *Proxy) runHooks(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		hooks := &pxy.hooks
+		if len(hooks.request) == 0 && len(hooks.response) == 0 && len(hooks.errors) == 0 {
+			h(w, r, p)
+			return
+		}
+
+		state := &hookState{}
+		r = r.WithContext(context.WithValue(r.Context(), hookStateKey{}, state))
+		info := RequestInfo{Endpoint: ep, RequestID: RequestIDFromContext(r.Context()), Request: r, Start: time.Now()}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		for _, f := range hooks.request {
+			if err := f(info); err != nil {
+				status := errorStatus(err)
+				pxy.logEndpointRequest(r, status, ep.Topic, info.RequestID)
+				pxy.writeError(sw, r, status, err)
+				break
+			}
+		}
+		if state.err == nil {
+			h(sw, r, p)
+		}
+
+		res := ResponseInfo{RequestInfo: info, Status: sw.status, Duration: time.Since(info.Start)}
+		res.Timings, _ = TimingsFromContext(r.Context())
+		for _, f := range hooks.response {
+			f(res)
+		}
+		if state.err != nil {
+			for _, f := range hooks.errors {
+				f(res, state.err)
+			}
+		}
+	}
+}
+
+// hookError records err as the error answered to r for the OnError hooks.
+func hookError(r *http.Request, err error) {
+	if state, ok := r.Context().Value(hookStateKey{}).(*hookState); ok {
+		state.err = err
+	}
+}
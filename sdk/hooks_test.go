@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	service.HandleFunc("failing", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 409, Error: &mrpcproxy.Error{Code: "conflict", Message: "exists"}})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	errDisabled := StatusError{http.StatusForbidden, errors.New("feature disabled")}
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.GetID = func() string { return "id" }
+
+	var requests []RequestInfo
+	var responses []ResponseInfo
+	var errs []error
+	pxy.OnRequest(func(info RequestInfo) error {
+		requests = append(requests, info)
+		if info.Request.URL.Query().Get("beta") == "off" {
+			return errDisabled
+		}
+		return nil
+	})
+	pxy.OnResponse(func(info ResponseInfo) { responses = append(responses, info) })
+	pxy.OnError(func(info ResponseInfo, err error) { errs = append(errs, err) })
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/a", Topic: "a"},
+		Endpoint{Method: "GET", Path: "/failing", Topic: "failing"},
+	)
+
+	cases := []struct {
+		path   string
+		status int
+		err    string
+	}{
+		{"/a", 200, ""},
+		{"/a?beta=off", http.StatusForbidden, "feature disabled"},
+		{"/failing", 409, "conflict: exists"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			requests, responses, errs = nil, nil, nil
+
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if len(requests) != 1 || requests[0].RequestID != "id" || requests[0].Endpoint.Path != r.URL.Path {
+				t.Errorf("Unexpected requests: %+v", requests)
+			}
+			if len(responses) != 1 || responses[0].Status != tc.status || responses[0].Duration <= 0 {
+				t.Errorf("Unexpected responses: %+v", responses)
+			}
+			if tc.err == "" && len(errs) != 0 || tc.err != "" && (len(errs) != 1 || errs[0].Error() != tc.err) {
+				t.Errorf("Unexpected errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestOnStart(t *testing.T) {
+	pxy, _ := New(":0", &mrpc.Service{})
+	pxy.OnStart(func() error { return errors.New("not ready") })
+	if err := pxy.Serve(); err == nil || err.Error() != "not ready" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
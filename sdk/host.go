@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ServeHTTP routes r to the endpoints of its host, falling back to the
+// endpoints registered without a host. HEAD requests are served by the GET
+// routes of paths without HEAD route.
+func (pxy *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = pxy.startTimings(r)
+	pxy.setSecurityHeaders(w, nil)
+
+	pxy.routesMu.RLock()
+	router, hr := pxy.router, pxy.matchHost(r.Host)
+	pxy.routesMu.RUnlock()
+
+	if hr != nil {
+		if h, _, _ := hr.Lookup(r.Method, r.URL.Path); h != nil {
+			hr.ServeHTTP(w, r)
+			return
+		}
+		if serveHead(hr, w, r) {
+			return
+		}
+
+		// The host router answers 405 for paths it serves with other
+		// methods, unless the default router serves the method.
+		if h, _, _ := router.Lookup(r.Method, r.URL.Path); h == nil && hasPath(hr, r.URL.Path) {
+			hr.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if serveHead(router, w, r) {
+		return
+	}
+	router.ServeHTTP(w, r)
+}
+
+// serveHead serves HEAD requests with the GET handler of the path when rt
+// has no HEAD handler for it. The server discards the body written for HEAD
+// requests.
+func serveHead(rt Router, w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodHead {
+		return false
+	}
+	if h, _, _ := rt.Lookup(http.MethodHead, r.URL.Path); h != nil {
+		return false
+	}
+
+	h, p, _ := rt.Lookup(http.MethodGet, r.URL.Path)
+	if h == nil {
+		return false
+	}
+	h(w, r, p)
+	return true
+}
+
+// routeMethods are the methods checked by hasPath.
+var routeMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// hasPath reports whether r has a route for path with any method.
+func hasPath(r Router, path string) bool {
+	for _, m := range routeMethods {
+		if h, _, _ := r.Lookup(m, path); h != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedMethods returns the Allow header listing the methods routed for the
+// request path and host, including HEAD for GET routes.
+func (pxy *Proxy) allowedMethods(r *http.Request) string {
+	pxy.routesMu.RLock()
+	routers := []Router{pxy.router}
+	if hr := pxy.matchHost(r.Host); hr != nil {
+		routers = append(routers, hr)
+	}
+	pxy.routesMu.RUnlock()
+
+	var allowed []string
+	for _, m := range routeMethods {
+		for _, rt := range routers {
+			h, _, _ := rt.Lookup(m, r.URL.Path)
+			if h == nil && m == http.MethodHead {
+				h, _, _ = rt.Lookup(http.MethodGet, r.URL.Path)
+			}
+			if h != nil {
+				allowed = append(allowed, m)
+				break
+			}
+		}
+	}
+
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// hostRouter returns the router of the endpoints for host, creating it on
+// first use.
+func (pxy *Proxy) hostRouter(host string) Router {
+	if host == "" {
+		return pxy.router
+	}
+
+	host = strings.ToLower(host)
+	if pxy.hosts == nil {
+		pxy.hosts = map[string]Router{}
+	}
+
+	r, ok := pxy.hosts[host]
+	if !ok {
+		r = pxy.makeRouter()
+		pxy.hosts[host] = r
+	}
+
+	return r
+}
+
+// matchHost returns the router for the request host, preferring exact matches
+// over wildcards.
+func (pxy *Proxy) matchHost(host string) Router {
+	if len(pxy.hosts) == 0 {
+		return nil
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if r, ok := pxy.hosts[host]; ok {
+		return r
+	}
+
+	if i := strings.Index(host, "."); i > 0 {
+		return pxy.hosts["*"+host[i:]]
+	}
+
+	return nil
+}
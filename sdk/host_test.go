@@ -0,0 +1,134 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestHostRouting(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"api", "admin", "tenant", "shared"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Host: "api.example.com", Method: "GET", Path: "/x", Topic: "service.api"},
+		Endpoint{Host: "Admin.example.com", Method: "GET", Path: "/x", Topic: "service.admin"},
+		Endpoint{Host: "*.tenants.example.com", Method: "GET", Path: "/x", Topic: "service.tenant"},
+		Endpoint{Method: "GET", Path: "/shared", Topic: "service.shared"},
+	)
+	pxy.notFound = pxy.notFoundHandler()
+
+	cases := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{"api.example.com", "/x", http.StatusOK, "api"},
+		{"admin.example.com:8080", "/x", http.StatusOK, "admin"},
+		{"a.tenants.example.com", "/x", http.StatusOK, "tenant"},
+		{"api.example.com", "/shared", http.StatusOK, "shared"},
+		{"other.example.com", "/x", http.StatusNotFound, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			r.Host = tc.host
+			rr := httptest.NewRecorder()
+			pxy.ServeHTTP(rr, r)
+
+			body, _ := ioutil.ReadAll(rr.Body)
+			if rr.Code != tc.status || string(body) != tc.body {
+				t.Errorf("Unexpected response: got %v %q want %v %q", rr.Code, body, tc.status, tc.body)
+			}
+		})
+	}
+}
+
+func TestAutoHead(t *testing.T) {
+	heads := make(chan bool, 1)
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"get", "head"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			req := mrpcproxy.Request{}
+			json.Unmarshal(data, &req)
+			heads <- req.Head
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic), Headers: http.Header{"X-Topic": {topic}}})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/a", Topic: "get"},
+		Endpoint{Method: "GET", Path: "/b", Topic: "get"},
+		Endpoint{Method: "HEAD", Path: "/b", Topic: "head"},
+		Endpoint{Host: "api.example.com", Method: "GET", Path: "/c", Topic: "get"},
+	)
+	s := httptest.NewServer(pxy)
+	defer s.Close()
+
+	cases := []struct {
+		method string
+		host   string
+		path   string
+		topic  string
+		length int64
+		head   bool
+	}{
+		{"HEAD", "", "/a", "get", 3, true},
+		{"GET", "", "/a", "get", 3, false},
+		{"HEAD", "", "/b", "head", 4, true},
+		{"HEAD", "api.example.com", "/c", "get", 3, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, s.URL+tc.path, nil)
+			r.Host = tc.host
+			res, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, _ := ioutil.ReadAll(res.Body)
+
+			if res.StatusCode != 200 || res.Header.Get("X-Topic") != tc.topic || res.ContentLength != tc.length {
+				t.Errorf("Unexpected response %v %v %v", res.StatusCode, res.Header.Get("X-Topic"), res.ContentLength)
+			}
+			if tc.method == "HEAD" && len(body) != 0 {
+				t.Errorf("Unexpected HEAD body %q", body)
+			}
+			if head := <-heads; head != tc.head {
+				t.Errorf("Unexpected Head flag %v", head)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package sdk
+
+import (
+	"net/http"
+)
+
+// HTTP2Config configures HTTP/2. HTTP/2 is enabled on TLS listeners by
+// default.
+type HTTP2Config struct {
+	// H2C serves HTTP/2 without TLS on plaintext listeners, e.g. behind load
+	// balancers terminating TLS. HTTP/1 is served as well.
+	H2C bool
+	// MaxConcurrentStreams per connection. Uses the net/http default when 0.
+	MaxConcurrentStreams int
+}
+
+// WithHTTP2 configures HTTP/2 on the proxy listeners.
+func WithHTTP2(cfg HTTP2Config) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		protocols := &http.Protocols{}
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(cfg.H2C)
+
+		pxy.http.Protocols = protocols
+		pxy.http.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: cfg.MaxConcurrentStreams}
+		return nil
+	}
+}
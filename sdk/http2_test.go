@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestWithHTTP2H2C(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("OK")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+
+	addr := fmt.Sprintf("127.0.0.1:%v", *portFlag+2)
+	pxy, _ := New(addr, service, WithHTTP2(HTTP2Config{H2C: true, MaxConcurrentStreams: 10}))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+	go pxy.Serve()
+	defer pxy.Stop(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	res, err := client.Get("http://" + addr + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.ProtoMajor != 2 || string(body) != "OK" {
+		t.Errorf("Unexpected response %v %q", res.Proto, body)
+	}
+}
@@ -0,0 +1,210 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+// IdempotencyKeyHeader carries the key identifying retries of a request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var (
+	// ErrIdempotencyConflict is returned for requests reusing the key of a
+	// request still in flight.
+	ErrIdempotencyConflict = errors.New("request with the same idempotency key in flight")
+	// ErrIdempotencyMismatch is returned for requests reusing the key of a
+	// request with another body.
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with another request")
+)
+
+// IdempotencyRecord is the response stored for an idempotency key.
+type IdempotencyRecord struct {
+	// Fingerprint of the request body.
+	Fingerprint string
+	Response    *mrpcproxy.Response
+}
+
+// IdempotencyStore keeps the responses replayed for retried requests.
+type IdempotencyStore interface {
+	Get(key string) (*IdempotencyRecord, bool)
+	Set(key string, rec *IdempotencyRecord, ttl time.Duration)
+}
+
+// ContextIdempotencyStore is implemented by idempotency stores accepting a
+// context, e.g. to bound the calls to a remote store. The proxy prefers it to
+// Get and Set.
+type ContextIdempotencyStore interface {
+	GetContext(ctx context.Context, key string) (*IdempotencyRecord, bool)
+	SetContext(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration)
+}
+
+// LRUIdempotencyStore is an in-memory IdempotencyStore evicting the least
+// recently used records.
+type LRUIdempotencyStore struct {
+	lru *lru
+}
+
+// NewLRUIdempotencyStore creates a store holding at most size records.
+func NewLRUIdempotencyStore(size int) *LRUIdempotencyStore {
+	return &LRUIdempotencyStore{newLRU(size)}
+}
+
+// Get returns the record of key if it hasn't expired.
+func (s *LRUIdempotencyStore) Get(key string) (*IdempotencyRecord, bool) {
+	v, ok := s.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*IdempotencyRecord), true
+}
+
+// Set stores rec under key for ttl.
+func (s *LRUIdempotencyStore) Set(key string, rec *IdempotencyRecord, ttl time.Duration) {
+	s.lru.set(key, rec, ttl)
+}
+
+type idempotency struct {
+	store IdempotencyStore
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// WithIdempotency replays the stored response of POST requests retried with
+// the same Idempotency-Key for ttl. Keys are scoped to the request path and
+// credentials. Retries racing the first request get 409 and retries with
+// another body 422. Server errors aren't stored so they can be retried.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.idempotency = &idempotency{store: store, ttl: ttl, inFlight: map[string]bool{}}
+		return nil
+	}
+}
+
+// idempotentMRPCRequest sends the request unless a response is stored for its
+// idempotency key.
+func (pxy *Proxy) idempotentMRPCRequest(r *http.Request, p httprouter.Params, ep Endpoint) (*mrpcproxy.Response, error) {
+	idem := pxy.idempotency
+	idemKey := r.Header.Get(IdempotencyKeyHeader)
+	if idem == nil || idemKey == "" || r.Method != http.MethodPost {
+		return pxy.cachedMRPCRequest(r, p, ep)
+	}
+
+	fingerprint, err := requestFingerprint(r, ep)
+	if err != nil {
+		return nil, err
+	}
+
+	key := idempotencyKey(r, idemKey)
+	stored := func() (*mrpcproxy.Response, bool, error) {
+		rec, ok := idem.get(r.Context(), key)
+		if !ok {
+			return nil, false, nil
+		}
+		if rec.Fingerprint != fingerprint {
+			return nil, true, StatusError{http.StatusUnprocessableEntity, ErrIdempotencyMismatch}
+		}
+		return replayed(r, rec.Response), true, nil
+	}
+	if res, ok, err := stored(); ok {
+		return res, err
+	}
+
+	idem.mu.Lock()
+	if idem.inFlight[key] {
+		idem.mu.Unlock()
+		return nil, StatusError{http.StatusConflict, ErrIdempotencyConflict}
+	}
+	idem.inFlight[key] = true
+	idem.mu.Unlock()
+
+	defer func() {
+		idem.mu.Lock()
+		delete(idem.inFlight, key)
+		idem.mu.Unlock()
+	}()
+
+	// The first request may have completed since the lookup above.
+	if res, ok, err := stored(); ok {
+		return res, err
+	}
+
+	res, err := pxy.cachedMRPCRequest(r, p, ep)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Code < http.StatusInternalServerError {
+		// The record must be stored even when the client went away, so that
+		// its retry is replayed.
+		idem.set(pxy.ctx, key, &IdempotencyRecord{fingerprint, cloneResponse(res)})
+	}
+	return res, nil
+}
+
+// replayed returns a copy of the stored response marked as replayed.
+func replayed(r *http.Request, res *mrpcproxy.Response) *mrpcproxy.Response {
+	res = cloneResponse(res)
+	res.RequestID = RequestIDFromContext(r.Context())
+	if res.Headers == nil {
+		res.Headers = http.Header{}
+	}
+	res.Headers.Set("Idempotent-Replayed", "true")
+	return res
+}
+
+// idempotencyKey scopes the idempotency key to the request path and
+// credentials.
+func idempotencyKey(r *http.Request, key string) string {
+	h := sha256.New()
+	for _, v := range []string{r.Host, r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Cookie")} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)) + " " + key
+}
+
+// requestFingerprint hashes the query and body of the request. The body of
+// multipart endpoints is streamed to the file storage, so it's left out.
+func requestFingerprint(r *http.Request, ep Endpoint) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+
+	if !ep.Multipart && r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (idem *idempotency) get(ctx context.Context, key string) (*IdempotencyRecord, bool) {
+	if s, ok := idem.store.(ContextIdempotencyStore); ok {
+		return s.GetContext(ctx, key)
+	}
+	return idem.store.Get(key)
+}
+
+func (idem *idempotency) set(ctx context.Context, key string, rec *IdempotencyRecord) {
+	if s, ok := idem.store.(ContextIdempotencyStore); ok {
+		s.SetContext(ctx, key, rec, idem.ttl)
+		return
+	}
+	idem.store.Set(key, rec, idem.ttl)
+}
@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestIdempotency(t *testing.T) {
+	var calls int32
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("create", func(w mrpc.TopicWriter, data []byte) {
+		n := atomic.AddInt32(&calls, 1)
+		req := mrpcproxy.Request{}
+		json.Unmarshal(data, &req)
+		code := 201
+		if string(req.Msg) == "fail" {
+			code = 500
+		}
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: code, Msg: []byte(fmt.Sprint(n))})
+		w.Write(msg)
+	})
+	service.HandleFunc("slow", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(50 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 201})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithIdempotency(NewLRUIdempotencyStore(10), time.Minute))
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Topic: "create", Method: "POST", Path: "/items"},
+		Endpoint{Topic: "create", Method: "PUT", Path: "/items"},
+		Endpoint{Topic: "slow", Method: "POST", Path: "/slow"},
+	)
+
+	steps := []struct {
+		method, key, auth, body string
+
+		code     int
+		msg      string
+		replayed bool
+	}{
+		{"POST", "k1", "", "a", 201, "1", false},
+		{"POST", "k1", "", "a", 201, "1", true},
+		{"POST", "k1", "", "b", 422, "", false},
+		{"POST", "k1", "Bearer other", "a", 201, "2", false},
+		{"POST", "", "", "a", 201, "3", false},
+		{"POST", "", "", "a", 201, "4", false},
+		{"PUT", "k1", "", "a", 201, "5", false},
+		{"POST", "k2", "", "fail", 500, "6", false},
+		{"POST", "k2", "", "fail", 500, "7", false},
+	}
+
+	for i, s := range steps {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(s.method, "/items", bytes.NewBufferString(s.body))
+			if s.key != "" {
+				r.Header.Set(IdempotencyKeyHeader, s.key)
+			}
+			if s.auth != "" {
+				r.Header.Set("Authorization", s.auth)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != s.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, s.code)
+			}
+			if w.Body.String() != s.msg {
+				t.Errorf("Unexpected body %q; expected %q", w.Body.String(), s.msg)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != s.replayed {
+				t.Errorf("Unexpected replayed %v", replayed)
+			}
+		})
+	}
+
+	t.Run("InFlight", func(t *testing.T) {
+		done := make(chan int)
+		go func() {
+			r, _ := http.NewRequest("POST", "/slow", bytes.NewBufferString("a"))
+			r.Header.Set(IdempotencyKeyHeader, "k3")
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+			done <- w.Code
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		r, _ := http.NewRequest("POST", "/slow", bytes.NewBufferString("a"))
+		r.Header.Set(IdempotencyKeyHeader, "k3")
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("Unexpected code of concurrent retry %v", w.Code)
+		}
+		if code := <-done; code != 201 {
+			t.Errorf("Unexpected code of first request %v", code)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ListenFDsEnv is the environment variable holding the number of listeners
+// inherited by a process, as file descriptors starting at 3. It follows the
+// systemd socket activation convention.
+const ListenFDsEnv = "LISTEN_FDS"
+
+// listenFDsStart is the first inherited file descriptor.
+const listenFDsStart = 3
+
+var (
+	// ErrNotServing is returned by Handoff when the proxy isn't serving.
+	ErrNotServing = errors.New("proxy is not serving")
+)
+
+// WithInheritedListener serves the listeners inherited from the parent
+// process, started by Handoff or by systemd socket activation, instead of
+// opening the proxy address and the WithAddr and WithUnixSocket addresses.
+// The addresses are opened when nothing was inherited.
+func WithInheritedListener() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		ls, err := inheritedListeners(os.Getenv, listenFDsStart)
+		if err != nil {
+			return err
+		}
+
+		pxy.inherit = true
+		pxy.inherited = ls
+		return nil
+	}
+}
+
+// inheritedListeners creates the listeners of the file descriptors announced
+// in ListenFDsEnv.
+func inheritedListeners(getenv func(string) string, start int) ([]net.Listener, error) {
+	fds := getenv(ListenFDsEnv)
+	if fds == "" {
+		return nil, nil
+	}
+	if pid := getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid %v %q", ListenFDsEnv, fds)
+	}
+
+	ls := make([]net.Listener, 0, n)
+	for fd := start; fd < start+n; fd++ {
+		f := os.NewFile(uintptr(fd), "listener")
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, fmt.Errorf("inheriting listener %v: %v", fd, err)
+		}
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
+
+// Handoff starts a new process of the running command passing it the proxy
+// listeners. A new process created with WithInheritedListener accepts
+// connections on them while the caller shuts down and drains its requests,
+// for zero downtime upgrades.
+func (pxy *Proxy) Handoff() (*os.Process, error) {
+	pxy.servingMu.Lock()
+	ls := pxy.serving
+	pxy.servingMu.Unlock()
+	if len(ls) == 0 {
+		return nil, ErrNotServing
+	}
+
+	files := make([]*os.File, 0, len(ls))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range ls {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("listener %v can't be inherited", l.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = handoffEnv(os.Environ(), len(files))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// The socket files now belong to the new process.
+	for _, l := range ls {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	return cmd.Process, nil
+}
+
+// handoffEnv announces n inherited listeners in environ.
+func handoffEnv(environ []string, n int) []string {
+	env := make([]string, 0, len(environ)+1)
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, ListenFDsEnv+"=") || strings.HasPrefix(kv, "LISTEN_PID=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, ListenFDsEnv+"="+strconv.Itoa(n))
+}
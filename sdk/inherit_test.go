@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestInheritedListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cases := []struct {
+		env map[string]string
+		n   int
+		err bool
+	}{
+		{map[string]string{}, 0, false},
+		{map[string]string{"LISTEN_FDS": "1"}, 1, false},
+		{map[string]string{"LISTEN_FDS": "1", "LISTEN_PID": "1"}, 0, false},
+		{map[string]string{"LISTEN_FDS": "x"}, 0, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			// inheritedListeners takes ownership of the descriptor.
+			fd, err := syscall.Dup(int(f.Fd()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			getenv := func(k string) string { return tc.env[k] }
+			ls, err := inheritedListeners(getenv, fd)
+			if tc.n == 0 {
+				syscall.Close(fd)
+			}
+			if (err != nil) != tc.err {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if len(ls) != tc.n {
+				t.Fatalf("Unexpected listeners %v", ls)
+			}
+			if tc.n == 1 && ls[0].Addr().String() != l.Addr().String() {
+				t.Errorf("Unexpected address %v; expected %v", ls[0].Addr(), l.Addr())
+			}
+			for _, l := range ls {
+				l.Close()
+			}
+		})
+	}
+}
+
+func TestServeInherited(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("OK")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pxy, _ := New(":0", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.inherit, pxy.inherited = true, []net.Listener{l}
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+
+	if _, err := pxy.Handoff(); err != ErrNotServing {
+		t.Errorf("Unexpected handoff error %v", err)
+	}
+
+	served := make(chan error)
+	go func() { served <- pxy.Serve() }()
+	time.Sleep(10 * time.Millisecond)
+
+	res, err := http.Get("http://" + l.Addr().String() + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "OK" {
+		t.Errorf("Unexpected body %q", body)
+	}
+
+	pxy.Stop(context.Background())
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Unexpected serve error %v", err)
+	}
+}
+
+func TestHandoffEnv(t *testing.T) {
+	env := handoffEnv([]string{"A=1", "LISTEN_FDS=4", "LISTEN_PID=7", "B=2"}, 2)
+	expected := []string{"A=1", "B=2", "LISTEN_FDS=2"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Unexpected env %v; expected %v", env, expected)
+	}
+}
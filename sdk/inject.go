@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ValueNotFoundError is returned when a value injected into the requests of
+// an endpoint can't be resolved.
+type ValueNotFoundError struct {
+	Name string
+}
+
+func (e ValueNotFoundError) Error() string {
+	return fmt.Sprintf("value %v not found", e.Name)
+}
+
+// ValueProvider resolves the values of Endpoint.InjectHeaders, e.g. from
+// the environment or a secret store.
+type ValueProvider interface {
+	Value(name string) (string, error)
+}
+
+// EnvValues resolves values from the environment variables, with Prefix
+// prepended to the names.
+type EnvValues struct {
+	Prefix string
+}
+
+// Value returns the environment variable Prefix+name.
+func (e EnvValues) Value(name string) (string, error) {
+	v, ok := os.LookupEnv(e.Prefix + name)
+	if !ok {
+		return "", ValueNotFoundError{e.Prefix + name}
+	}
+	return v, nil
+}
+
+// StaticValues resolves values from a map.
+type StaticValues map[string]string
+
+// Value returns the value of name.
+func (s StaticValues) Value(name string) (string, error) {
+	v, ok := s[name]
+	if !ok {
+		return "", ValueNotFoundError{name}
+	}
+	return v, nil
+}
+
+// injectedHeaders resolves the headers injected into the requests of ep.
+func (pxy *Proxy) injectedHeaders(ep Endpoint) (http.Header, error) {
+	if len(ep.InjectHeaders) == 0 {
+		return nil, nil
+	}
+
+	provider := pxy.ValueProvider
+	if provider == nil {
+		provider = EnvValues{}
+	}
+
+	h := http.Header{}
+	for header, name := range ep.InjectHeaders {
+		v, err := provider.Value(name)
+		if err != nil {
+			return nil, err
+		}
+		h.Set(header, v)
+	}
+	return h, nil
+}
+
+// injectHeaders returns h with the injected headers of ep, replacing the
+// ones sent by the client.
+func injectHeaders(h http.Header, ep Endpoint) http.Header {
+	if ep.injected == nil {
+		return h
+	}
+
+	h = h.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	for k, vs := range ep.injected {
+		h[k] = vs
+	}
+	return h
+}
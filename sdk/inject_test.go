@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestInjectHeaders(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("audit", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(
+			req.Headers.Get("X-Environment") + "," + req.Headers.Get("X-Region") + "," + req.Headers.Get("X-Client"),
+		)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	os.Setenv("MRPCPROXY_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("MRPCPROXY_TEST_REGION")
+
+	cases := []struct {
+		provider ValueProvider
+		inject   map[string]string
+		err      error
+		body     string
+	}{
+		{StaticValues{"env": "staging"}, map[string]string{"X-Environment": "env"}, nil, "staging,,client"},
+		{EnvValues{Prefix: "MRPCPROXY_TEST_"}, map[string]string{"x-region": "REGION"}, nil, ",eu-west-1,client"},
+		{nil, map[string]string{"X-Region": "MRPCPROXY_TEST_REGION"}, nil, ",eu-west-1,client"},
+		{StaticValues{"env": "prod"}, map[string]string{"X-Environment": "env", "X-Client": "env"}, nil, "prod,,prod"},
+		{nil, nil, nil, ",,client"},
+		{StaticValues{}, map[string]string{"X-Environment": "env"}, ValueNotFoundError{"env"}, ""},
+		{EnvValues{}, map[string]string{"X-Environment": "MRPCPROXY_TEST_MISSING"}, ValueNotFoundError{"MRPCPROXY_TEST_MISSING"}, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Requests = &MockLogger{}
+			pxy.ValueProvider = tc.provider
+			err := pxy.Handle(Endpoint{Method: "GET", Path: "/audit", Topic: "audit", InjectHeaders: tc.inject})
+			if err != tc.err {
+				t.Fatalf("Unexpected error: got %v want %v", err, tc.err)
+			}
+			if err != nil {
+				return
+			}
+
+			r, _ := http.NewRequest("GET", "/audit", nil)
+			r.Header.Set("X-Client", "client")
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: got %v %q want %q", w.Code, w.Body.String(), tc.body)
+			}
+			if r.Header.Get("X-Client") != "client" {
+				t.Errorf("Client request headers changed: %v", r.Header)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package sdk
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Endpoints returns a copy of the endpoints of the proxy, including the
+// versioned endpoints added for Endpoint.APIVersions.
+func (pxy *Proxy) Endpoints() []Endpoint {
+	if pxy.admin != nil {
+		pxy.admin.mu.Lock()
+		defer pxy.admin.mu.Unlock()
+	}
+	return append([]Endpoint(nil), pxy.Eps...)
+}
+
+// Lookup returns the endpoint served for all hosts routing requests with
+// method and path, e.g. GET /users/42 for /users/:id. HEAD requests are
+// served by the GET endpoints of paths without HEAD endpoint.
+func (pxy *Proxy) Lookup(method, path string) (Endpoint, bool) {
+	eps := pxy.Endpoints()
+
+	// The endpoints are routed on a scratch router whose handlers report
+	// their index, and whose constraints leave it unset.
+	r := pxy.makeRouter()
+	found := -1
+	notFound := func(http.ResponseWriter, *http.Request) {}
+	for i, ep := range eps {
+		if ep.Host != "" {
+			continue
+		}
+
+		i := i
+		report := func(http.ResponseWriter, *http.Request, httprouter.Params) {
+			found = i
+		}
+		pxy.handleRouteFunc(r, route{ep.Host, ep.Method, ep.Path, report, true}, notFound)
+	}
+
+	h, p, _ := r.Lookup(method, path)
+	if h == nil && method == http.MethodHead {
+		h, p, _ = r.Lookup(http.MethodGet, path)
+	}
+	if h == nil {
+		return Endpoint{}, false
+	}
+	h(nil, &http.Request{Method: method, URL: &url.URL{Path: path}}, p)
+
+	if found < 0 {
+		return Endpoint{}, false
+	}
+	return eps[found], true
+}
@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miracl/mrpc"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"httprouter", "servemux"} {
+		var opts []func(*Proxy) error
+		if name == "servemux" {
+			opts = append(opts, WithRouter(NewServeMuxRouter))
+		}
+		pxy, _ := New(":80", &mrpc.Service{}, opts...)
+		pxy.MustHandle(
+			Endpoint{Method: "GET", Path: "/users/{id:[0-9]+}", Topic: "users.get"},
+			Endpoint{Method: "POST", Path: "/users", Topic: "users.create"},
+			Endpoint{Method: "GET", Host: "api.example.com", Path: "/status", Topic: "status"},
+		)
+
+		if eps := pxy.Endpoints(); len(eps) != 3 || eps[1].Topic != "users.create" {
+			t.Errorf("%v: unexpected endpoints %+v", name, eps)
+		}
+
+		cases := []struct {
+			method, path string
+			topic        string
+		}{
+			{"GET", "/users/42", "users.get"},
+			{"HEAD", "/users/42", "users.get"},
+			{"HEAD", "/users/abc", ""},
+			{"POST", "/users", "users.create"},
+			{"GET", "/users/abc", ""},
+			{"DELETE", "/users", ""},
+			{"GET", "/status", ""},
+		}
+
+		for i, tc := range cases {
+			t.Run(fmt.Sprintf("%v/Case%v", name, i), func(t *testing.T) {
+				ep, ok := pxy.Lookup(tc.method, tc.path)
+				if ok != (tc.topic != "") || ep.Topic != tc.topic {
+					t.Errorf("Unexpected lookup: %+v %v; expected %q", ep, ok, tc.topic)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrIPForbidden is returned when the client IP is blocked by an IP
+	// filter.
+	ErrIPForbidden = errors.New("client IP is not allowed")
+)
+
+// IPFilter allows or denies clients by IP address. Rules are CIDRs or single
+// addresses. Deny rules take precedence and, when Allow isn't empty, only the
+// clients matching one of its rules are allowed.
+type IPFilter struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// WithIPFilter applies f to all endpoints. Blocked clients get 403.
+func WithIPFilter(f IPFilter) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		mw, err := pxy.IPFilter(f)
+		if err != nil {
+			return err
+		}
+
+		pxy.Use(mw)
+		return nil
+	}
+}
+
+// IPFilter returns a middleware applying f as WithIPFilter, for use on
+// individual endpoints or groups.
+func (pxy *Proxy) IPFilter(f IPFilter) (Middleware, error) {
+	filter, err := compileIPFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			ip := pxy.clientIP(r)
+			if !filter.allowed(net.ParseIP(ip)) {
+				printfCtx(r.Context(), pxy.Debugger, "%v: %v", ErrIPForbidden, ip)
+				pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusForbidden, RequestIDFromContext(r.Context()))
+				pxy.writeError(w, r, http.StatusForbidden, ErrIPForbidden)
+				return
+			}
+
+			next(w, r, p)
+		}
+	}, nil
+}
+
+func compileIPFilter(f IPFilter) (*ipFilter, error) {
+	var err error
+	filter := &ipFilter{}
+	if filter.allow, err = parseCIDRs(f.Allow); err != nil {
+		return nil, err
+	}
+	if filter.deny, err = parseCIDRs(f.Deny); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseCIDRs parses CIDRs and single IP addresses.
+func parseCIDRs(rules []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(rules))
+	for _, rule := range rules {
+		if !strings.Contains(rule, "/") {
+			ip := net.ParseIP(rule)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", rule)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
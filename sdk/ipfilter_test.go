@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestIPFilter(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		global   IPFilter
+		endpoint *IPFilter
+		ip       string
+		code     int
+	}{
+		{IPFilter{}, nil, "1.1.1.1", 200},
+		{IPFilter{Allow: []string{"10.0.0.0/8"}}, nil, "10.1.2.3", 200},
+		{IPFilter{Allow: []string{"10.0.0.0/8"}}, nil, "1.1.1.1", 403},
+		{IPFilter{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}}, nil, "10.0.0.1", 403},
+		{IPFilter{Deny: []string{"2001:db8::/32"}}, nil, "2001:db8::1", 403},
+		{IPFilter{Deny: []string{"2001:db8::/32"}}, nil, "2001:db9::1", 200},
+		{IPFilter{}, &IPFilter{Allow: []string{"192.168.0.0/16"}}, "1.1.1.1", 403},
+		{IPFilter{Allow: []string{"1.1.1.1"}}, &IPFilter{Deny: []string{"1.1.1.1"}}, "1.1.1.1", 403},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, err := New(":80", service, WithIPFilter(tc.global))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			if err := pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a", IPFilter: tc.endpoint}); err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			r.RemoteAddr = "[" + tc.ip + "]:1234"
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+		})
+	}
+}
+
+func TestIPFilterInvalidRule(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, f := range []IPFilter{{Allow: []string{"10.0.0.0/33"}}, {Deny: []string{"host"}}} {
+		if _, err := New(":80", service, WithIPFilter(f)); err == nil {
+			t.Errorf("Expected error for %v", f)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package sdk
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultJWKSRefresh    = 1 * time.Hour
+	defaultJWKSMinRefetch = 30 * time.Second
+	defaultJWKSTimeout    = 10 * time.Second
+)
+
+var (
+	// ErrNoToken is returned when the request doesn't carry a bearer token.
+	ErrNoToken = errors.New("no bearer token")
+	// ErrInvalidToken is returned when the bearer token is malformed or its
+	// signature doesn't verify.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnsupportedAlg is returned when the token is signed with an algorithm
+	// that has no configured key.
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	// ErrTokenExpired is returned for tokens past their exp claim.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotValidYet is returned for tokens before their nbf claim.
+	ErrTokenNotValidYet = errors.New("token not valid yet")
+	// ErrInvalidIssuer is returned when the iss claim doesn't match.
+	ErrInvalidIssuer = errors.New("invalid issuer")
+	// ErrInvalidAudience is returned when the aud claim doesn't match.
+	ErrInvalidAudience = errors.New("invalid audience")
+	// ErrNoJWTKey is returned when JWTConfig has no verification key configured.
+	ErrNoJWTKey = errors.New("no JWT verification key configured")
+)
+
+// JWTConfig configures bearer token validation.
+type JWTConfig struct {
+	// Secret verifies HS256, HS384 and HS512 tokens.
+	Secret []byte
+	// PublicKey verifies RS256, RS384 and RS512 tokens.
+	PublicKey *rsa.PublicKey
+	// JWKSURL is the location of a JSON Web Key Set used to verify RS* tokens
+	// by their kid header. The set is refetched every JWKSRefresh and when an
+	// unknown kid is seen, but not more often than every JWKSMinRefetch
+	// (30 seconds by default).
+	JWKSURL        string
+	JWKSRefresh    time.Duration
+	JWKSMinRefetch time.Duration
+
+	// Issuer and Audience are checked against the iss and aud claims when set.
+	Issuer   string
+	Audience string
+	// Leeway is the allowed clock skew for exp and nbf.
+	Leeway time.Duration
+
+	// Client is used to fetch JWKSURL. Defaults to a client timing out after
+	// 10 seconds, as the requests with unknown keys wait for the fetch.
+	Client *http.Client
+}
+
+// WithJWTAuth rejects requests without a valid bearer token with 401 and
+// forwards the verified claims in mrpcproxy.Request.Claims.
+func WithJWTAuth(cfg JWTConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		mw, err := pxy.JWTAuth(cfg)
+		if err != nil {
+			return err
+		}
+
+		pxy.Use(mw)
+		return nil
+	}
+}
+
+// JWTAuth returns a middleware validating bearer tokens as WithJWTAuth, for
+// use on groups or single endpoints.
+func (pxy *Proxy) JWTAuth(cfg JWTConfig) (Middleware, error) {
+	v, err := newJWTVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			claims, err := v.verifyRequest(r)
+			if err != nil {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+				pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusUnauthorized, RequestIDFromContext(r.Context()))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				pxy.writeError(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			next(w, r.WithContext(withClaims(r.Context(), claims)), p)
+		}
+	}, nil
+}
+
+type claimsKey struct{}
+
+func withClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func claimsFromContext(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(claimsKey{}).(map[string]interface{})
+	return claims
+}
+
+type jwtVerifier struct {
+	cfg JWTConfig
+	now func() time.Time
+
+	mu          sync.Mutex
+	jwks        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetching    chan struct{}
+}
+
+func newJWTVerifier(cfg JWTConfig) (*jwtVerifier, error) {
+	if cfg.Secret == nil && cfg.PublicKey == nil && cfg.JWKSURL == "" {
+		return nil, ErrNoJWTKey
+	}
+	if cfg.JWKSRefresh == 0 {
+		cfg.JWKSRefresh = defaultJWKSRefresh
+	}
+	if cfg.JWKSMinRefetch == 0 {
+		cfg.JWKSMinRefetch = defaultJWKSMinRefetch
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultJWKSTimeout}
+	}
+
+	return &jwtVerifier{cfg: cfg, now: time.Now}, nil
+}
+
+func (v *jwtVerifier) verifyRequest(r *http.Request) (map[string]interface{}, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return nil, ErrNoToken
+	}
+
+	return v.verify(strings.TrimSpace(auth[7:]))
+}
+
+func (v *jwtVerifier) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := v.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *jwtVerifier) verifySignature(alg, kid string, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return ErrUnsupportedAlg
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return ErrUnsupportedAlg
+	}
+
+	switch alg[:2] {
+	case "HS":
+		if v.cfg.Secret == nil {
+			return ErrUnsupportedAlg
+		}
+		mac := hmac.New(hash.New, v.cfg.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+		return nil
+	case "RS":
+		key, err := v.rsaKey(kid)
+		if err != nil {
+			return err
+		}
+		h := hash.New()
+		h.Write(signed)
+		if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+
+	return ErrUnsupportedAlg
+}
+
+func (v *jwtVerifier) validateClaims(claims map[string]interface{}) error {
+	now := v.now()
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.cfg.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.cfg.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return ErrTokenNotValidYet
+	}
+
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if v.cfg.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud == v.cfg.Audience {
+				return nil
+			}
+		case []interface{}:
+			for _, a := range aud {
+				if a == v.cfg.Audience {
+					return nil
+				}
+			}
+		}
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+func (v *jwtVerifier) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if v.cfg.JWKSURL == "" {
+		if v.cfg.PublicKey == nil {
+			return nil, ErrUnsupportedAlg
+		}
+		return v.cfg.PublicKey, nil
+	}
+
+	v.mu.Lock()
+	key, ok := v.jwks[kid]
+	now := v.now()
+	if ok && now.Sub(v.fetchedAt) < v.cfg.JWKSRefresh {
+		v.mu.Unlock()
+		return key, nil
+	}
+
+	// Unknown kids come from unverified tokens, so they refetch the set at
+	// most once every JWKSMinRefetch. Callers without a key wait for a fetch
+	// already in flight.
+	fetching := v.fetching
+	if fetching == nil && now.Sub(v.attemptedAt) >= v.cfg.JWKSMinRefetch {
+		v.attemptedAt = now
+		v.fetching = make(chan struct{})
+		v.mu.Unlock()
+		return v.refetch(kid, key, ok)
+	}
+	v.mu.Unlock()
+
+	if fetching != nil && !ok {
+		<-fetching
+		v.mu.Lock()
+		key, ok = v.jwks[kid]
+		v.mu.Unlock()
+	}
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+// refetch downloads the key set outside of v.mu and returns the key for kid.
+// The stale key is kept when the fetch fails.
+func (v *jwtVerifier) refetch(kid string, stale *rsa.PublicKey, ok bool) (*rsa.PublicKey, error) {
+	keys, err := v.fetchJWKS()
+
+	v.mu.Lock()
+	if err == nil {
+		v.jwks = keys
+		v.fetchedAt = v.now()
+	}
+	close(v.fetching)
+	v.fetching = nil
+	v.mu.Unlock()
+
+	if err != nil {
+		if ok {
+			return stale, nil
+		}
+		return nil, err
+	}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+func (v *jwtVerifier) fetchJWKS() (map[string]*rsa.PublicKey, error) {
+	res, err := v.cfg.Client.Get(v.cfg.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: unexpected status %v", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %v", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS: %v", err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
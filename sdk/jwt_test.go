@@ -0,0 +1,210 @@
+package sdk
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func signHS256(claims map[string]interface{}, secret []byte) string {
+	unsigned := jwtSegments("HS256", "", claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(claims map[string]interface{}, kid string, key *rsa.PrivateKey) string {
+	unsigned := jwtSegments("RS256", kid, claims)
+	h := sha256.Sum256([]byte(unsigned))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func jwtSegments(alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	cases := []struct {
+		cfg    JWTConfig
+		auth   string
+		status int
+		claims map[string]interface{}
+	}{
+		{
+			cfg:    JWTConfig{Secret: secret},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"sub": "a", "exp": exp}, secret),
+			status: http.StatusOK,
+			claims: map[string]interface{}{"sub": "a", "exp": exp},
+		},
+		{
+			cfg:    JWTConfig{Secret: secret},
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    JWTConfig{Secret: secret},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"sub": "a"}, []byte("other")),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    JWTConfig{Secret: secret},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"exp": float64(time.Now().Add(-time.Hour).Unix())}, secret),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    JWTConfig{Secret: secret, Issuer: "iss", Audience: "aud"},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"iss": "iss", "aud": []string{"x", "aud"}}, secret),
+			status: http.StatusOK,
+			claims: map[string]interface{}{"iss": "iss", "aud": []interface{}{"x", "aud"}},
+		},
+		{
+			cfg:    JWTConfig{Secret: secret, Audience: "aud"},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"aud": "x"}, secret),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    JWTConfig{PublicKey: &rsaKey.PublicKey},
+			auth:   "Bearer " + signRS256(map[string]interface{}{"sub": "b"}, "", rsaKey),
+			status: http.StatusOK,
+			claims: map[string]interface{}{"sub": "b"},
+		},
+		{
+			cfg:    JWTConfig{PublicKey: &rsaKey.PublicKey},
+			auth:   "Bearer " + signHS256(map[string]interface{}{"sub": "b"}, secret),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    JWTConfig{JWKSURL: jwks.URL},
+			auth:   "Bearer " + signRS256(map[string]interface{}{"sub": "c"}, "k1", rsaKey),
+			status: http.StatusOK,
+			claims: map[string]interface{}{"sub": "c"},
+		},
+		{
+			cfg:    JWTConfig{JWKSURL: jwks.URL},
+			auth:   "Bearer " + signRS256(map[string]interface{}{"sub": "c"}, "k1", otherKey),
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, err := New(":80", service, WithJWTAuth(tc.cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pxy.Debugger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+
+			var claims map[string]interface{}
+			h := pxy.wrap(Endpoint{}, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+				claims = claimsFromContext(r.Context())
+			})
+
+			req, _ := http.NewRequest("GET", "/a", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rr := httptest.NewRecorder()
+			h(rr, req, nil)
+
+			if rr.Code != tc.status {
+				t.Errorf("Unexpected status: got %v want %v", rr.Code, tc.status)
+			}
+
+			if !reflect.DeepEqual(claims, tc.claims) {
+				t.Errorf("Unexpected claims: got %v want %v", claims, tc.claims)
+			}
+		})
+	}
+}
+
+func TestJWTAuthNoKey(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	_, err := New(":80", service, WithJWTAuth(JWTConfig{}))
+	if err != (FuncOptsError{ErrNoJWTKey}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestJWKSRefetch(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	var fetches int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	v, _ := newJWTVerifier(JWTConfig{JWKSURL: jwks.URL, JWKSMinRefetch: time.Minute})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := v.verify(signRS256(map[string]interface{}{}, "k1", rsaKey)); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if _, err := v.verify(signRS256(map[string]interface{}{}, fmt.Sprint("unknown", i), rsaKey)); err != ErrInvalidToken {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Unexpected fetches: got %v want 1", n)
+	}
+
+	now = now.Add(time.Minute)
+	v.verify(signRS256(map[string]interface{}{}, "unknown", rsaKey))
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("Unexpected fetches: got %v want 2", n)
+	}
+}
@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"net"
+	"net/http"
+	"os"
+)
+
+type listenAddr struct {
+	network string
+	addr    string
+}
+
+// WithListener serves the proxy on l in addition to its address. Pass an
+// empty address to New to serve only on the given listeners.
+func WithListener(l net.Listener) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.listeners = append(pxy.listeners, l)
+		return nil
+	}
+}
+
+// WithAddr serves the proxy on another TCP address.
+func WithAddr(addr string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.listenAddrs = append(pxy.listenAddrs, listenAddr{"tcp", addr})
+		return nil
+	}
+}
+
+// WithUnixSocket serves the proxy on a unix domain socket at path. A stale
+// socket left at path is removed, and the socket is removed on shutdown.
+func WithUnixSocket(path string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.listenAddrs = append(pxy.listenAddrs, listenAddr{"unix", path})
+		return nil
+	}
+}
+
+// listen opens the listeners configured in addition to the proxy address,
+// or returns the inherited ones. It returns nil when only the proxy address
+// is served.
+func (pxy *Proxy) listen() ([]net.Listener, error) {
+	if len(pxy.listeners) == 0 && len(pxy.listenAddrs) == 0 && !pxy.inherit {
+		return nil, nil
+	}
+
+	addrs := pxy.listenAddrs
+	if pxy.http.Addr != "" {
+		addrs = append([]listenAddr{{"tcp", pxy.http.Addr}}, addrs...)
+	}
+
+	listeners := append([]net.Listener{}, pxy.listeners...)
+	if len(pxy.inherited) > 0 {
+		return append(listeners, pxy.inherited...), nil
+	}
+
+	for _, a := range addrs {
+		if a.network == "unix" {
+			if fi, err := os.Stat(a.addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+				os.Remove(a.addr)
+			}
+		}
+
+		l, err := net.Listen(a.network, a.addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// serveListeners serves all listeners until the server is shut down or one
+// of them fails, which closes the others.
+func (pxy *Proxy) serveListeners(listeners []net.Listener) error {
+	// Serve configures TLSConfig for HTTP/2, so TLS is decided up front.
+	useTLS := pxy.certFile != "" || pxy.http.TLSConfig != nil
+
+	pxy.servingMu.Lock()
+	pxy.serving = listeners
+	pxy.servingMu.Unlock()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if useTLS {
+				errs <- pxy.http.ServeTLS(l, pxy.certFile, pxy.keyFile)
+				return
+			}
+			errs <- pxy.http.Serve(l)
+		}(l)
+	}
+
+	err := <-errs
+	if err != http.ErrServerClosed {
+		pxy.http.Close()
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestListeners(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("OK")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+
+	dir, err := ioutil.TempDir("", "mrpcproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "proxy.sock")
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pxy, _ := New("", service, WithListener(tcp), WithUnixSocket(sock))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+
+	served := make(chan error)
+	go func() { served <- pxy.Serve() }()
+	time.Sleep(10 * time.Millisecond)
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		},
+	}}
+
+	for _, c := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{http.DefaultClient, "http://" + tcp.Addr().String() + "/a"},
+		{unixClient, "http://unix/a"},
+	} {
+		res, err := c.client.Get(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != 200 || string(body) != "OK" {
+			t.Errorf("Unexpected response %v %q from %v", res.StatusCode, body, c.url)
+		}
+	}
+
+	pxy.Stop(context.Background())
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Unexpected serve error %v", err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("Socket was not removed: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// HandleFunc serves ep with h on the proxy instead of an MRPC topic, e.g.
+// for token exchange or redirects. The endpoint Topic is ignored and the
+// proxy and endpoint middleware apply as for the other endpoints. The path
+// params are available to h with httprouter.ParamsFromContext.
+func (pxy *Proxy) HandleFunc(ep Endpoint, h http.HandlerFunc) error {
+	if _, _, err := compilePath(pxy.router, ep.Path); err != nil {
+		return err
+	}
+
+	local := pxy.logStatic(h)
+	handle, err := pxy.endpointMiddleware(ep, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if len(p) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, p))
+		}
+		local.ServeHTTP(w, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	pxy.Eps = append(pxy.Eps, ep)
+	pxy.addRoute(route{ep.Host, ep.Method, ep.Path, handle, false})
+	return nil
+}
@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestHandleFunc(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	l := &MockLogger{}
+	pxy.Requests = l
+	pxy.Use(func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			w.Header().Set("X-Middleware", ep.Path)
+			next(w, r, p)
+		}
+	})
+
+	err := pxy.HandleFunc(Endpoint{Method: "GET", Path: "/token/:id"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(httprouter.ParamsFromContext(r.Context()).ByName("id")))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/login"}, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/token/1", http.StatusFound)
+	})
+	pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+	cases := []struct {
+		method, path string
+		status       int
+		body         string
+		middleware   string
+		log          string
+	}{
+		{"GET", "/token/abc", http.StatusOK, "abc", "/token/:id", "GET:/token/abc, status: 200"},
+		{"GET", "/login", http.StatusFound, "", "/login", "GET:/login, status: 302"},
+		{"OPTIONS", "/login", http.StatusOK, "", "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			l.storage = nil
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.body != "" && w.Body.String() != tc.body {
+				t.Errorf("Unexpected body: got %q want %q", w.Body.String(), tc.body)
+			}
+			if mw := w.Header().Get("X-Middleware"); mw != tc.middleware {
+				t.Errorf("Unexpected middleware header: got %q want %q", mw, tc.middleware)
+			}
+			if tc.log != "" && (len(l.storage) != 1 || l.storage[0] != tc.log) {
+				t.Errorf("Unexpected log: %v", l.storage)
+			}
+		})
+	}
+
+	if err := pxy.HandleFunc(Endpoint{Method: "GET", Path: "/{id:[}"}, nil); err == nil {
+		t.Error("Expected error for invalid path")
+	}
+}
@@ -0,0 +1,178 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// MetaLocale is the Request.Meta key of the locale resolved with WithLocales.
+const MetaLocale = "locale"
+
+var (
+	// ErrNoLocales is returned by WithLocales without supported locales.
+	ErrNoLocales = errors.New("no supported locales")
+	// ErrInvalidLocale is returned by WithLocales for malformed language
+	// tags.
+	ErrInvalidLocale = errors.New("invalid locale")
+)
+
+// WithLocales resolves the locale of the endpoint requests as the best match
+// of their Accept-Language header in supported, the first supported locale
+// being the default. The locale is sent to the services as the MetaLocale of
+// Request.Meta and in the Content-Language header of the responses that
+// don't set it.
+func WithLocales(supported ...string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if len(supported) == 0 {
+			return ErrNoLocales
+		}
+
+		locales := make([]string, len(supported))
+		for i, l := range supported {
+			if !validLocale(l) {
+				return ErrInvalidLocale
+			}
+			locales[i] = canonicalLocale(l)
+		}
+		pxy.locales = locales
+		return nil
+	}
+}
+
+type localeKey struct{}
+
+// LocaleFromContext returns the locale resolved for the request ctx belongs
+// to, or "" without WithLocales.
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+// localize resolves the locale of the requests.
+func (pxy *Proxy) localize(h httprouter.Handle) httprouter.Handle {
+	if pxy.locales == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		w.Header().Add("Vary", "Accept-Language")
+		locale := matchLocale(parseAcceptLanguage(r.Header.Get("Accept-Language")), pxy.locales)
+		h(w, r.WithContext(context.WithValue(r.Context(), localeKey{}, locale)), p)
+	}
+}
+
+// setContentLanguage sets the Content-Language header of the response to r
+// to its locale unless the service has set it.
+func setContentLanguage(w http.ResponseWriter, r *http.Request) {
+	locale := LocaleFromContext(r.Context())
+	if locale != "" && w.Header().Get("Content-Language") == "" {
+		w.Header().Set("Content-Language", locale)
+	}
+}
+
+// parseAcceptLanguage returns the language ranges of the Accept-Language
+// header h by decreasing quality, leaving out those with quality 0.
+func parseAcceptLanguage(h string) []string {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(h, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag != "*" && !validLocale(tag) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				var err error
+				if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
+
+// matchLocale returns the supported locale best matching the language
+// ranges: the same tag, then the same language, falling back to the first
+// supported locale.
+func matchLocale(ranges, supported []string) string {
+	for _, r := range ranges {
+		if r == "*" {
+			break
+		}
+		for _, l := range supported {
+			if strings.EqualFold(l, r) {
+				return l
+			}
+		}
+
+		lang := localeLanguage(r)
+		for _, l := range supported {
+			if strings.EqualFold(localeLanguage(l), lang) {
+				return l
+			}
+		}
+	}
+	return supported[0]
+}
+
+// localeLanguage returns the primary language subtag of the tag l.
+func localeLanguage(l string) string {
+	lang, _, _ := strings.Cut(l, "-")
+	return lang
+}
+
+// validLocale reports whether l is a language tag of alphanumeric subtags of
+// up to 8 characters separated by hyphens, starting with a language.
+func validLocale(l string) bool {
+	subtags := strings.Split(l, "-")
+	for i, s := range subtags {
+		if s == "" || len(s) > 8 {
+			return false
+		}
+		for _, c := range s {
+			letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+			if !letter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canonicalLocale returns the tag l with a lower case language and an upper
+// case region, e.g. en-GB for EN-gb.
+func canonicalLocale(l string) string {
+	subtags := strings.Split(l, "-")
+	for i, s := range subtags {
+		if i > 0 && len(s) == 2 {
+			subtags[i] = strings.ToUpper(s)
+		} else {
+			subtags[i] = strings.ToLower(s)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestLocales(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("greeting", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(req.Meta[MetaLocale])})
+		w.Write(msg)
+	})
+	service.HandleFunc("translated", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Headers: http.Header{"Content-Language": {"fr"}}})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service, WithLocales("en-gb", "fr", "de-DE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/greeting", Topic: "greeting"},
+		Endpoint{Method: "GET", Path: "/translated", Topic: "translated"},
+	)
+
+	cases := []struct {
+		path     string
+		accept   string
+		locale   string
+		language string
+	}{
+		{"/greeting", "", "en-GB", "en-GB"},
+		{"/greeting", "fr-CA, en;q=0.5", "fr", "fr"},
+		{"/greeting", "de", "de-DE", "de-DE"},
+		{"/greeting", "es, DE-de;q=0.8, fr;q=0.9", "fr", "fr"},
+		{"/greeting", "fr;q=0, de;q=0.1", "de-DE", "de-DE"},
+		{"/greeting", "ja, *;q=0.5", "en-GB", "en-GB"},
+		{"/greeting", "x;;q=a, en-US", "en-GB", "en-GB"},
+		{"/translated", "de", "", "fr"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept-Language", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 || w.Body.String() != tc.locale {
+				t.Errorf("Unexpected locale: got %v %q want %q", w.Code, w.Body.String(), tc.locale)
+			}
+			if l := w.Header().Get("Content-Language"); l != tc.language {
+				t.Errorf("Unexpected Content-Language: got %q want %q", l, tc.language)
+			}
+			if v := w.Header().Values("Vary"); !reflect.DeepEqual(v, []string{"Accept-Language"}) {
+				t.Errorf("Unexpected Vary: %v", v)
+			}
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	cases := []struct {
+		header string
+		ranges []string
+	}{
+		{"", []string{}},
+		{"da, en-GB;q=0.8, en;q=0.7", []string{"da", "en-GB", "en"}},
+		{"en;q=0.5, fr", []string{"fr", "en"}},
+		{"en;q=2, fr;q=0.000, *", []string{"*"}},
+		{"en_US, zh-Hant-TW", []string{"zh-Hant-TW"}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if ranges := parseAcceptLanguage(tc.header); !reflect.DeepEqual(ranges, tc.ranges) {
+				t.Errorf("Unexpected ranges: got %q want %q", ranges, tc.ranges)
+			}
+		})
+	}
+}
+
+func TestWithLocalesErrors(t *testing.T) {
+	cases := []struct {
+		supported []string
+		err       error
+	}{
+		{nil, ErrNoLocales},
+		{[]string{"en", "en_GB"}, ErrInvalidLocale},
+		{[]string{"1en"}, ErrInvalidLocale},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if _, err := New(":80", &mrpc.Service{}, WithLocales(tc.supported...)); err != (FuncOptsError{tc.err}) {
+				t.Errorf("Unexpected error: got %v want %v", err, tc.err)
+			}
+		})
+	}
+}
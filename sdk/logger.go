@@ -0,0 +1,31 @@
+package sdk
+
+import "context"
+
+// ContextLogger is implemented by loggers accepting the context of the
+// request being logged, e.g. to add its request ID or trace ID to structured
+// logs. The proxy loggers implementing it are called with PrintlnCtx and
+// PrintfCtx for the messages about a request, and with Println and Printf
+// for the others. The request ID is available with RequestIDFromContext.
+type ContextLogger interface {
+	PrintlnCtx(ctx context.Context, v ...interface{})
+	PrintfCtx(ctx context.Context, format string, v ...interface{})
+}
+
+// printlnCtx logs v to l with ctx when l accepts it.
+func printlnCtx(ctx context.Context, l logger, v ...interface{}) {
+	if cl, ok := l.(ContextLogger); ok {
+		cl.PrintlnCtx(ctx, v...)
+		return
+	}
+	l.Println(v...)
+}
+
+// printfCtx logs v to l with ctx when l accepts it.
+func printfCtx(ctx context.Context, l logger, format string, v ...interface{}) {
+	if cl, ok := l.(ContextLogger); ok {
+		cl.PrintfCtx(ctx, format, v...)
+		return
+	}
+	l.Printf(format, v...)
+}
@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+type mockContextLogger struct {
+	MockLogger
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *mockContextLogger) PrintlnCtx(ctx context.Context, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, RequestIDFromContext(ctx)+" "+fmt.Sprint(v...))
+}
+
+func (l *mockContextLogger) PrintfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, RequestIDFromContext(ctx)+" "+fmt.Sprintf(format, v...))
+}
+
+func TestContextLogger(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.GetID = func() string { return "req-1" }
+	logger, requests := &mockContextLogger{}, &mockContextLogger{}
+	pxy.Logger = logger
+	pxy.Requests = requests
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/a", Topic: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("GET", "/a", nil)
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("Unexpected status %v", w.Code)
+	}
+
+	for name, l := range map[string]*mockContextLogger{"logger": logger, "requests": requests} {
+		if len(l.entries) == 0 {
+			t.Errorf("No %v entries with context", name)
+		}
+		for _, e := range l.entries {
+			if !strings.HasPrefix(e, "req-1 ") {
+				t.Errorf("Unexpected %v entry %q", name, e)
+			}
+		}
+		if len(l.storage) != 0 {
+			t.Errorf("Unexpected %v entries without context: %q", name, l.storage)
+		}
+	}
+}
+
+func TestContextLoggerFallback(t *testing.T) {
+	l := &MockLogger{}
+	printlnCtx(context.Background(), l, "a", 1)
+	printfCtx(context.Background(), l, "b %v", 2)
+
+	if len(l.storage) != 2 || l.storage[0] != "a 1\n" || l.storage[1] != "b 2" {
+		t.Errorf("Unexpected entries %q", l.storage)
+	}
+}
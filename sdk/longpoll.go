@@ -0,0 +1,125 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+)
+
+// longPoll hands the events of a topic to the requests waiting for one.
+type longPoll struct {
+	mu      sync.Mutex
+	waiters map[chan []byte]struct{}
+}
+
+// publish delivers data to all waiting requests.
+func (lp *longPoll) publish(data []byte) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	for ch := range lp.waiters {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// wait returns the next event, or false when ctx is done first.
+func (lp *longPoll) wait(ctx context.Context) ([]byte, bool) {
+	ch := make(chan []byte, 1)
+	lp.mu.Lock()
+	lp.waiters[ch] = struct{}{}
+	lp.mu.Unlock()
+
+	defer func() {
+		lp.mu.Lock()
+		delete(lp.waiters, ch)
+		lp.mu.Unlock()
+	}()
+
+	select {
+	case data := <-ch:
+		return data, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+type longPollKey struct {
+	service *mrpc.Service
+	topic   string
+}
+
+// longPollRegistry holds the long polls of the subscribed topics. MRPC
+// services can't unsubscribe, so the subscriptions last as long as the proxy
+// and the events of topics without endpoints are dropped.
+type longPollRegistry struct {
+	mu    sync.Mutex
+	polls map[longPollKey]*longPoll
+}
+
+// longPoll returns the long poll of topic on s, subscribing to the topic on
+// first use. Endpoints re-registered by the admin API share it.
+func (pxy *Proxy) longPoll(s *mrpc.Service, topic string) (*longPoll, error) {
+	pxy.longPolls.mu.Lock()
+	defer pxy.longPolls.mu.Unlock()
+
+	if pxy.longPolls.polls == nil {
+		pxy.longPolls.polls = map[longPollKey]*longPoll{}
+	}
+
+	key := longPollKey{s, topic}
+	if lp, ok := pxy.longPolls.polls[key]; ok {
+		return lp, nil
+	}
+
+	lp := &longPoll{waiters: map[chan []byte]struct{}{}}
+	err := s.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+		lp.publish(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	pxy.longPolls.polls[key] = lp
+	return lp, nil
+}
+
+// longPollHandler holds the requests until the next message on the topic of
+// ep, answered with the message as body, or until the endpoint timeout,
+// answered with 204. The topic is subscribed at the first request, so that
+// the endpoints failing registration don't subscribe.
+func (pxy *Proxy) longPollHandler(ep Endpoint) (httprouter.Handle, error) {
+	s, err := pxy.endpointService(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		lp, err := pxy.longPoll(s, ep.Topic)
+		if err != nil {
+			pxy.writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), pxy.timeout(r, ep))
+		defer cancel()
+
+		data, ok := lp.wait(ctx)
+		id := RequestIDFromContext(r.Context())
+		if !ok {
+			pxy.logEndpointRequest(r, http.StatusNoContent, ep.Topic, id)
+			pxy.setHeaders(w)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		pxy.logEndpointRequest(r, http.StatusOK, ep.Topic, id)
+		pxy.setHeaders(w)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}, nil
+}
@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestLongPoll(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	go service.Serve()
+	defer service.Stop(nil)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/events", Topic: "events", LongPoll: true, KeepAlive: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			r, _ := http.NewRequest("GET", "/events", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+			results <- w
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	service.Request(ctx, "events", []byte(`{"id":1}`))
+	cancel()
+
+	for i := 0; i < 2; i++ {
+		w := <-results
+		if w.Code != http.StatusOK || w.Body.String() != `{"id":1}` {
+			t.Errorf("Unexpected response: %v %q", w.Code, w.Body.String())
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/events", nil)
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Unexpected status after timeout: %v", w.Code)
+	}
+}
+
+func TestLongPollSubscription(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	go service.Serve()
+	defer service.Stop(nil)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+
+	// The endpoints of a failed registration don't subscribe.
+	ep := Endpoint{Method: "GET", Path: "/events", Topic: "events", LongPoll: true, KeepAlive: 10}
+	if err := pxy.Handle(ep, Endpoint{Method: "GET", Path: "/b", Topic: "{{.x"}); err == nil {
+		t.Fatal("Invalid topic template accepted")
+	}
+	if len(pxy.longPolls.polls) != 0 {
+		t.Errorf("Unexpected subscriptions: %v", len(pxy.longPolls.polls))
+	}
+
+	if err := pxy.Handle(ep); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/events", nil)
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Unexpected status: %v", w.Code)
+		}
+	}
+
+	// Endpoints of the same topic share the subscription.
+	h, err := pxy.longPollHandler(Endpoint{Method: "GET", Path: "/other", Topic: "events", LongPoll: true, KeepAlive: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("GET", "/other", nil)
+	h(httptest.NewRecorder(), r, nil)
+	if len(pxy.longPolls.polls) != 1 {
+		t.Errorf("Unexpected subscriptions: %v", len(pxy.longPolls.polls))
+	}
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// maintenanceRetryAfter is the Retry-After in seconds sent in maintenance
+// mode.
+const maintenanceRetryAfter = 60
+
+var (
+	// ErrMaintenance is rendered for requests rejected in maintenance mode
+	// without message.
+	ErrMaintenance = errors.New("down for maintenance")
+)
+
+// Maintenance is the maintenance mode of the proxy, read and changed with
+// /admin/maintenance.
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// SetMaintenance turns maintenance mode on or off. In maintenance mode all
+// endpoints answer 503 with Retry-After and the message, while health checks
+// are still served.
+func (pxy *Proxy) SetMaintenance(enabled bool, message string) {
+	pxy.configMu.Lock()
+	pxy.maintenance = Maintenance{enabled, message}
+	pxy.configMu.Unlock()
+}
+
+// Maintenance returns the maintenance mode.
+func (pxy *Proxy) Maintenance() Maintenance {
+	pxy.configMu.RLock()
+	defer pxy.configMu.RUnlock()
+	return pxy.maintenance
+}
+
+// writeMaintenance writes the maintenance response. The message is the body
+// unless an ErrorRenderer is configured, which gets it as error.
+func (pxy *Proxy) writeMaintenance(w http.ResponseWriter, r *http.Request, m Maintenance) {
+	w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+
+	err := ErrMaintenance
+	if m.Message != "" {
+		err = errors.New(m.Message)
+	}
+	if pxy.ErrorRenderer != nil {
+		pxy.writeError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if _, err := w.Write([]byte(err.Error())); err != nil {
+		printfCtx(r.Context(), pxy.Logger, "writing to http.ResponseWriter failed: %v", err)
+	}
+}
+
+func (pxy *Proxy) adminMaintenance(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	writeAdminJSON(w, http.StatusOK, pxy.Maintenance())
+}
+
+func (pxy *Proxy) adminUpdateMaintenance(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var m Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+
+	pxy.SetMaintenance(m.Enabled, m.Message)
+	pxy.Logger.Printf("admin: maintenance mode enabled: %v", m.Enabled)
+	writeAdminJSON(w, http.StatusOK, m)
+}
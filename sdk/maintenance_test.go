@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestMaintenance(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		enabled  bool
+		message  string
+		renderer ErrorRenderer
+		path     string
+		code     int
+		body     string
+	}{
+		{false, "", nil, "/a", 200, "a"},
+		{true, "back at 10:00", nil, "/a", 503, "back at 10:00"},
+		{true, "", nil, "/a", 503, "down for maintenance"},
+		{true, "", nil, "/healthz", 200, "{\"status\":\"ok\"}\n"},
+		{true, "", nil, "/readyz", 200, "{\"status\":\"ok\"}\n"},
+		{true, "back at 10:00", func(code int, err error, r *http.Request) ([]byte, http.Header) {
+			return []byte(fmt.Sprintf("%v: %v", code, err)), nil
+		}, "/a", 503, "503: back at 10:00"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithHealthChecks(HealthConfig{}))
+			pxy.Requests = &MockLogger{}
+			pxy.ErrorRenderer = tc.renderer
+			pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/a"})
+			pxy.SetMaintenance(tc.enabled, tc.message)
+
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("Unexpected body %q", w.Body.String())
+			}
+			if retry := w.Header().Get("Retry-After"); (retry == "60") != (tc.code == 503) {
+				t.Errorf("Unexpected Retry-After %q", retry)
+			}
+		})
+	}
+}
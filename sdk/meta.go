@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNoMetaKey is returned by WithMeta for an empty key.
+	ErrNoMetaKey = errors.New("meta key is required")
+	// ErrNoMetaResolver is returned by WithMeta without a resolver.
+	ErrNoMetaResolver = errors.New("meta resolver is required")
+)
+
+// MetaResolver returns a value of the request context sent to the services
+// in Request.Meta, or "" to leave it out.
+type MetaResolver func(r *http.Request) string
+
+// MetaFromHeader resolves the value of the request header name.
+func MetaFromHeader(name string) MetaResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// MetaFromClaim resolves the claim name of the verified bearer token.
+func MetaFromClaim(name string) MetaResolver {
+	return func(r *http.Request) string {
+		v, ok := claimsFromContext(r.Context())[name]
+		if !ok || v == nil {
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// MetaFromTenant resolves the tenant of the request in multi-tenant mode.
+func MetaFromTenant() MetaResolver {
+	return func(r *http.Request) string {
+		return TenantFromContext(r.Context())
+	}
+}
+
+type metaResolver struct {
+	key     string
+	resolve MetaResolver
+}
+
+// WithMeta sends the value resolved for each request to the services as the
+// key of Request.Meta. Values set by middleware with ContextWithMeta take
+// precedence.
+func WithMeta(key string, resolver MetaResolver) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if key == "" {
+			return ErrNoMetaKey
+		}
+		if resolver == nil {
+			return ErrNoMetaResolver
+		}
+		pxy.meta = append(pxy.meta, metaResolver{key, resolver})
+		return nil
+	}
+}
+
+type metaKey struct{}
+
+// ContextWithMeta returns a copy of ctx with key set to value in the
+// Request.Meta of the proxied request, for middleware in front of the
+// endpoints.
+func ContextWithMeta(ctx context.Context, key, value string) context.Context {
+	parent := MetaFromContext(ctx)
+	meta := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		meta[k] = v
+	}
+	meta[key] = value
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFromContext returns the meta set with ContextWithMeta. The map must not
+// be modified.
+func MetaFromContext(ctx context.Context) map[string]string {
+	meta, _ := ctx.Value(metaKey{}).(map[string]string)
+	return meta
+}
+
+// requestMeta returns the meta of r, nil when there is none.
+func (pxy *Proxy) requestMeta(r *http.Request) map[string]string {
+	set := MetaFromContext(r.Context())
+	locale := LocaleFromContext(r.Context())
+	if len(pxy.meta) == 0 && len(set) == 0 && locale == "" {
+		return nil
+	}
+
+	meta := make(map[string]string, len(pxy.meta)+len(set)+1)
+	if locale != "" {
+		meta[MetaLocale] = locale
+	}
+	for _, m := range pxy.meta {
+		if v := m.resolve(r); v != "" {
+			meta[m.key] = v
+		}
+	}
+	for k, v := range set {
+		meta[k] = v
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
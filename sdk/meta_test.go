@@ -0,0 +1,131 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestRequestMeta(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("meta", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		body, _ := json.Marshal(req.Meta)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: body})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	device := func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			if d := r.Header.Get("X-Device"); d != "" {
+				r = r.WithContext(ContextWithMeta(r.Context(), "device", d))
+			}
+			next(w, r, p)
+		}
+	}
+
+	cases := []struct {
+		opts   []func(*Proxy) error
+		header http.Header
+		meta   map[string]string
+	}{
+		{nil, http.Header{}, nil},
+		{nil, http.Header{"X-Device": {"mobile"}}, map[string]string{"device": "mobile"}},
+		{
+			[]func(*Proxy) error{WithMeta("locale", MetaFromHeader("X-Locale"))},
+			http.Header{"X-Locale": {"en-GB"}},
+			map[string]string{"locale": "en-GB"},
+		},
+		{[]func(*Proxy) error{WithMeta("locale", MetaFromHeader("X-Locale"))}, http.Header{}, nil},
+		{
+			[]func(*Proxy) error{WithMeta("device", MetaFromHeader("X-Locale")), WithMeta("locale", MetaFromHeader("X-Locale"))},
+			http.Header{"X-Locale": {"fr"}, "X-Device": {"tablet"}},
+			map[string]string{"device": "tablet", "locale": "fr"},
+		},
+		{
+			[]func(*Proxy) error{
+				WithTenancy(TenancyConfig{Resolver: TenantFromHeader("X-Tenant")}),
+				WithMeta("tenant", MetaFromTenant()),
+			},
+			http.Header{"X-Tenant": {"acme"}},
+			map[string]string{"tenant": "acme"},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, err := New(":80", service, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pxy.Requests = &MockLogger{}
+			pxy.Use(device)
+			pxy.Handle(Endpoint{Method: "GET", Path: "/meta", Topic: "meta"})
+
+			r, _ := http.NewRequest("GET", "/meta", nil)
+			r.Header = tc.header
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			var meta map[string]string
+			json.Unmarshal(w.Body.Bytes(), &meta)
+			if w.Code != 200 || !reflect.DeepEqual(meta, tc.meta) {
+				t.Errorf("Unexpected meta: got %v %v want %v", w.Code, meta, tc.meta)
+			}
+		})
+	}
+}
+
+func TestMetaFromClaim(t *testing.T) {
+	cases := []struct {
+		claims map[string]interface{}
+		value  string
+	}{
+		{nil, ""},
+		{map[string]interface{}{"locale": "de"}, "de"},
+		{map[string]interface{}{"locale": float64(3)}, "3"},
+		{map[string]interface{}{"locale": nil}, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r = r.WithContext(withClaims(r.Context(), tc.claims))
+			if v := MetaFromClaim("locale")(r); v != tc.value {
+				t.Errorf("Unexpected value: got %q want %q", v, tc.value)
+			}
+		})
+	}
+}
+
+func TestWithMetaErrors(t *testing.T) {
+	cases := []struct {
+		key      string
+		resolver MetaResolver
+		err      error
+	}{
+		{"", MetaFromHeader("X-A"), ErrNoMetaKey},
+		{"a", nil, ErrNoMetaResolver},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if _, err := New(":80", &mrpc.Service{}, WithMeta(tc.key, tc.resolver)); err != (FuncOptsError{tc.err}) {
+				t.Errorf("Unexpected error: got %v want %v", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package sdk
+
+import (
+	"github.com/miracl/mrpcproxy/internal/msgpack"
+)
+
+// MessagePackEncoder serializes a JSON payload as MessagePack. Integral
+// numbers are encoded as integers and the other numbers as float64.
+func MessagePackEncoder(payload []byte) ([]byte, error) {
+	v, err := decodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Append(nil, v)
+}
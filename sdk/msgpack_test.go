@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMessagePackEncoder(t *testing.T) {
+	cases := []struct {
+		json string
+		out  []byte
+		err  bool
+	}{
+		{`null`, []byte{0xc0}, false},
+		{`true`, []byte{0xc3}, false},
+		{`false`, []byte{0xc2}, false},
+		{`1`, []byte{0x01}, false},
+		{`-1`, []byte{0xff}, false},
+		{`-33`, []byte{0xd0, 0xdf}, false},
+		{`200`, []byte{0xcc, 0xc8}, false},
+		{`65536`, []byte{0xce, 0x00, 0x01, 0x00, 0x00}, false},
+		{`-40000`, []byte{0xd2, 0xff, 0xff, 0x63, 0xc0}, false},
+		{`1.5`, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, false},
+		{`"ab"`, []byte{0xa2, 'a', 'b'}, false},
+		{`"` + strings.Repeat("a", 32) + `"`, append([]byte{0xd9, 32}, strings.Repeat("a", 32)...), false},
+		{`[1,"a"]`, []byte{0x92, 0x01, 0xa1, 'a'}, false},
+		{`{"b":1,"a":{}}`, []byte{0x82, 0xa1, 'a', 0x80, 0xa1, 'b', 0x01}, false},
+		{`[` + strings.Repeat("0,", 15) + `0]`, append([]byte{0xdc, 0, 16}, make([]byte, 16)...), false},
+		{`{`, nil, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			out, err := MessagePackEncoder([]byte(tc.json))
+			if (err != nil) != tc.err {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !bytes.Equal(out, tc.out) {
+				t.Errorf("Unexpected output: got %x want %x", out, tc.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+const (
+	maxFormValueSize      = 1 << 20
+	defaultMaxUploadSize  = 32 << 20
+	defaultMaxUploadParts = 100
+)
+
+var (
+	// ErrNoFileStorage is returned when a multipart endpoint receives a file
+	// and Proxy.FileStorage isn't configured.
+	ErrNoFileStorage = errors.New("no file storage configured")
+	// ErrFormValueTooLarge is returned for multipart form fields over 1MB.
+	ErrFormValueTooLarge = errors.New("form value too large")
+	// ErrTooManyParts is returned for multipart bodies with more fields and
+	// files than Proxy.MaxUploadParts.
+	ErrTooManyParts = errors.New("too many multipart parts")
+)
+
+// FileStorage saves uploaded files.
+type FileStorage interface {
+	// Store saves the content of r and returns the key locating it and the
+	// number of bytes written.
+	Store(ctx context.Context, filename, contentType string, r io.Reader) (key string, size int64, err error)
+}
+
+// FileRemover is implemented by file storages able to delete stored files.
+// The files of failed uploads are removed when the storage implements it.
+type FileRemover interface {
+	Remove(ctx context.Context, key string) error
+}
+
+// DiskStorage saves uploaded files in a directory.
+type DiskStorage struct {
+	Dir string
+}
+
+// Store writes r to a new file in the storage directory and returns its path.
+func (s DiskStorage) Store(ctx context.Context, filename, contentType string, r io.Reader) (string, int64, error) {
+	f, err := ioutil.TempFile(s.Dir, "upload-*"+filepath.Ext(filename))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+
+	return f.Name(), n, nil
+}
+
+// Remove deletes the file at key.
+func (s DiskStorage) Remove(ctx context.Context, key string) error {
+	return os.Remove(key)
+}
+
+// ObjectPutter is implemented by S3-compatible object storage clients.
+type ObjectPutter interface {
+	PutObject(ctx context.Context, bucket, key, contentType string, r io.Reader) (size int64, err error)
+}
+
+// ObjectRemover is implemented by object storage clients able to delete
+// objects.
+type ObjectRemover interface {
+	RemoveObject(ctx context.Context, bucket, key string) error
+}
+
+// ObjectStorage saves uploaded files in an object storage bucket.
+type ObjectStorage struct {
+	Client ObjectPutter
+	Bucket string
+	Prefix string
+	// NewKey generates object keys. Defaults to a random hex string followed by
+	// the file name.
+	NewKey func(filename string) string
+}
+
+// Store uploads r as a new object and returns its key.
+func (s ObjectStorage) Store(ctx context.Context, filename, contentType string, r io.Reader) (string, int64, error) {
+	newKey := s.NewKey
+	if newKey == nil {
+		newKey = randomKey
+	}
+
+	key := path.Join(s.Prefix, newKey(filename))
+	n, err := s.Client.PutObject(ctx, s.Bucket, key, contentType, r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return key, n, nil
+}
+
+// Remove deletes the object at key when the client is an ObjectRemover.
+func (s ObjectStorage) Remove(ctx context.Context, key string) error {
+	if c, ok := s.Client.(ObjectRemover); ok {
+		return c.RemoveObject(ctx, s.Bucket, key)
+	}
+	return nil
+}
+
+func randomKey(filename string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b) + "-" + path.Base(filename)
+}
+
+// readMultipart streams the file parts of the multipart body to the file
+// storage and adds the form fields to the request params. The stored files
+// are removed when reading the body fails.
+func (pxy *Proxy) readMultipart(r *http.Request, req *mrpcproxy.Request) error {
+	maxSize, maxParts := pxy.MaxUploadSize, pxy.MaxUploadParts
+	if maxSize == 0 {
+		maxSize = defaultMaxUploadSize
+	}
+	if maxParts == 0 {
+		maxParts = defaultMaxUploadParts
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, maxSize)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return StatusError{http.StatusBadRequest, err}
+	}
+
+	for parts := 0; ; parts++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil && parts == maxParts {
+			err = StatusError{http.StatusRequestEntityTooLarge, ErrTooManyParts}
+		}
+		if err == nil {
+			err = pxy.readPart(r, req, part)
+		}
+		if err != nil {
+			pxy.removeFiles(r.Context(), req.Files)
+			req.Files = nil
+			return uploadError(err)
+		}
+	}
+}
+
+func (pxy *Proxy) readPart(r *http.Request, req *mrpcproxy.Request, part *multipart.Part) error {
+	if part.FileName() == "" {
+		v, err := ioutil.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+		if err != nil {
+			return err
+		}
+		if len(v) > maxFormValueSize {
+			return StatusError{http.StatusRequestEntityTooLarge, ErrFormValueTooLarge}
+		}
+		req.Params.Add(part.FormName(), string(v))
+		return nil
+	}
+
+	if pxy.FileStorage == nil {
+		return ErrNoFileStorage
+	}
+
+	ct := part.Header.Get("Content-Type")
+	key, size, err := pxy.FileStorage.Store(r.Context(), part.FileName(), ct, part)
+	if err != nil {
+		return err
+	}
+
+	req.Files = append(req.Files, mrpcproxy.File{
+		Field:       part.FormName(),
+		Filename:    part.FileName(),
+		ContentType: ct,
+		Size:        size,
+		Key:         key,
+	})
+	return nil
+}
+
+// uploadError maps body read errors to client error statuses.
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return StatusError{http.StatusRequestEntityTooLarge, err}
+	case err == ErrNoFileStorage:
+		return err
+	}
+
+	if _, ok := err.(StatusError); ok {
+		return err
+	}
+	return StatusError{http.StatusBadRequest, err}
+}
+
+// removeFiles deletes uploaded files of a failed request from storages
+// implementing FileRemover.
+func (pxy *Proxy) removeFiles(ctx context.Context, files []mrpcproxy.File) {
+	fr, ok := pxy.FileStorage.(FileRemover)
+	if !ok {
+		return
+	}
+
+	for _, f := range files {
+		if err := fr.Remove(context.Background(), f.Key); err != nil {
+			printfCtx(ctx, pxy.Logger, "removing uploaded file %v failed: %v", f.Key, err)
+		}
+	}
+}
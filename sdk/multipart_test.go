@@ -0,0 +1,177 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestReadMultipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("doc", "report.txt")
+	fw.Write([]byte("file content"))
+	mw.Close()
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.FileStorage = DiskStorage{Dir: dir}
+
+	r, _ := http.NewRequest("POST", "/upload?a=1", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	req, err := pxy.newRequestFromHTTP(r, nil, Endpoint{Method: "POST", Multipart: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(req.Params, url.Values{"a": {"1"}, "title": {"report"}}) {
+		t.Errorf("Unexpected params: %v", req.Params)
+	}
+
+	if len(req.Msg) != 0 {
+		t.Errorf("Multipart body forwarded: %s", req.Msg)
+	}
+
+	if len(req.Files) != 1 {
+		t.Fatalf("Unexpected files: %v", req.Files)
+	}
+
+	f := req.Files[0]
+	if f.Field != "doc" || f.Filename != "report.txt" || f.ContentType != "application/octet-stream" || f.Size != 12 {
+		t.Errorf("Unexpected file: %+v", f)
+	}
+
+	content, err := ioutil.ReadFile(f.Key)
+	if err != nil || string(content) != "file content" {
+		t.Errorf("Unexpected stored file: %s %v", content, err)
+	}
+}
+
+func TestReadMultipartErrors(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+
+	r, _ := http.NewRequest("POST", "/upload", bytes.NewBufferString("not multipart"))
+	_, err := pxy.newRequestFromHTTP(r, nil, Endpoint{Method: "POST", Multipart: true})
+	if errorStatus(err) != http.StatusBadRequest {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.CreateFormFile("doc", "report.txt")
+	mw.Close()
+	r, _ = http.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, err := pxy.newRequestFromHTTP(r, nil, Endpoint{Method: "POST", Multipart: true}); err != ErrNoFileStorage {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReadMultipartLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		files  int
+		fields int
+		size   int
+		status int
+	}{
+		{2, 2, 10, 0},
+		{2, 2, 2000, http.StatusRequestEntityTooLarge},
+		{3, 3, 10, http.StatusRequestEntityTooLarge},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for j := 0; j < tc.files; j++ {
+				fw, _ := mw.CreateFormFile("doc", "report.txt")
+				fw.Write(bytes.Repeat([]byte("a"), tc.size))
+			}
+			for j := 0; j < tc.fields; j++ {
+				mw.WriteField("title", "report")
+			}
+			mw.Close()
+
+			service, _ := mrpc.NewService(mem.New())
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.FileStorage = DiskStorage{Dir: dir}
+			pxy.MaxUploadSize = 1500
+			pxy.MaxUploadParts = 5
+
+			r, _ := http.NewRequest("POST", "/upload", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+
+			req, err := pxy.newRequestFromHTTP(r, nil, Endpoint{Method: "POST", Multipart: true})
+			if tc.status == 0 {
+				if err != nil || len(req.Files) != tc.files {
+					t.Errorf("Unexpected result: %v %v", req, err)
+				}
+				pxy.removeFiles(r.Context(), req.Files)
+			} else if errorStatus(err) != tc.status {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Errorf("Stored files not removed: %v", len(files))
+			}
+		})
+	}
+}
+
+func TestReadMultipartCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("doc", "report.txt")
+	fw.Write([]byte("file content"))
+	mw.Close()
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.FileStorage = DiskStorage{Dir: dir}
+	pxy.RequestTransformer = func(r *http.Request, req *mrpcproxy.Request) error {
+		return errors.New("rejected")
+	}
+
+	r, _ := http.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, err := pxy.newRequestFromHTTP(r, nil, Endpoint{Method: "POST", Multipart: true}); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Stored files not removed: %v", len(files))
+	}
+}
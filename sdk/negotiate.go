@@ -0,0 +1,253 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of the built-in encoders.
+const (
+	MediaTypeJSON        = "application/json"
+	MediaTypeMessagePack = "application/msgpack"
+	MediaTypeXML         = "application/xml"
+)
+
+var (
+	// ErrInvalidXMLName is returned by XMLEncoder for object keys that are
+	// not valid XML element names.
+	ErrInvalidXMLName = errors.New("invalid XML element name")
+)
+
+// Encoder serializes a canonical JSON payload returned by a backend into
+// another media type.
+type Encoder func(payload []byte) ([]byte, error)
+
+type mediaEncoder struct {
+	mediaType string
+	encode    Encoder
+}
+
+// WithEncoder registers an encoder for the media type. Responses with a JSON
+// payload are serialized with the encoder when the request Accept header
+// prefers its media type over JSON. Encoders registered first win ties.
+func WithEncoder(mediaType string, enc Encoder) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.encoders = append(pxy.encoders, mediaEncoder{strings.ToLower(mediaType), enc})
+		return nil
+	}
+}
+
+// WithContentNegotiation registers the MessagePack and XML encoders.
+func WithContentNegotiation() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if err := WithEncoder(MediaTypeMessagePack, MessagePackEncoder)(pxy); err != nil {
+			return err
+		}
+		return WithEncoder(MediaTypeXML, XMLEncoder)(pxy)
+	}
+}
+
+// negotiate returns the response body serialized in the media type preferred
+// by the request Accept header and sets the Content-Type. The body is
+// returned unchanged without encoders, for non JSON payloads and when JSON is
+// preferred.
+func (pxy *Proxy) negotiate(w http.ResponseWriter, r *http.Request, body []byte) []byte {
+	if len(pxy.encoders) == 0 || len(body) == 0 {
+		return body
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "" && !isJSON(ct) {
+		return body
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	supported := make([]string, len(pxy.encoders)+1)
+	supported[0] = MediaTypeJSON
+	for i, e := range pxy.encoders {
+		supported[i+1] = e.mediaType
+	}
+
+	i := negotiateMediaType(r.Header.Get("Accept"), supported)
+	if i <= 0 {
+		return body
+	}
+
+	enc := pxy.encoders[i-1]
+	encoded, err := enc.encode(body)
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		return body
+	}
+
+	w.Header().Set("Content-Type", enc.mediaType)
+	w.Header().Del("Content-Length")
+	return encoded
+}
+
+// isJSON reports whether the content type is JSON.
+func isJSON(ct string) bool {
+	ct = strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+	return ct == MediaTypeJSON || strings.HasSuffix(ct, "+json")
+}
+
+// negotiateMediaType returns the index of the supported media type with the
+// highest q-value in the Accept header, ties broken by the order of supported.
+// It returns -1 when none is acceptable and 0 without Accept header.
+func negotiateMediaType(accept string, supported []string) int {
+	if accept == "" {
+		return 0
+	}
+
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		slash := strings.Index(name, "/")
+		if slash < 0 {
+			continue
+		}
+		mr := mediaRange{name[:slash], name[slash+1:], 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					mr.q = v
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	best, bestQ := -1, 0.0
+	for i, mt := range supported {
+		slash := strings.Index(mt, "/")
+		typ, sub := mt[:slash], mt[slash+1:]
+
+		// The most specific matching range sets the q-value.
+		weight, specificity := 0.0, -1
+		for _, mr := range ranges {
+			var s int
+			switch {
+			case mr.typ == typ && mr.sub == sub:
+				s = 2
+			case mr.typ == typ && mr.sub == "*":
+				s = 1
+			case mr.typ == "*" && mr.sub == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				weight, specificity = mr.q, s
+			}
+		}
+		if weight > bestQ {
+			best, bestQ = i, weight
+		}
+	}
+
+	return best
+}
+
+// decodeJSON decodes a JSON payload keeping numbers as json.Number.
+func decodeJSON(payload []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// XMLEncoder serializes a JSON payload as XML in a response root element.
+// Object keys become elements and array elements are repeated item elements.
+func XMLEncoder(payload []byte) ([]byte, error) {
+	v, err := decodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	e := xml.NewEncoder(&buf)
+	if err := encodeXML(e, "response", v); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXML(e *xml.Encoder, name string, v interface{}) error {
+	if !validXMLName(name) {
+		return ErrInvalidXMLName
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXML(e, k, v[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXML(e, "item", item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := e.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := e.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	case bool:
+		if err := e.EncodeToken(xml.CharData(strconv.FormatBool(v))); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// validXMLName reports whether name is a valid XML element name.
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c > 0x7f:
+		case i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
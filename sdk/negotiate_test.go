@@ -0,0 +1,106 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	supported := []string{MediaTypeJSON, MediaTypeMessagePack, MediaTypeXML}
+	cases := []struct {
+		accept string
+		index  int
+	}{
+		{"", 0},
+		{"application/json", 0},
+		{"application/msgpack", 1},
+		{"application/xml, application/json;q=0.9", 2},
+		{"*/*", 0},
+		{"application/*;q=0.5, application/xml", 2},
+		{"application/json;q=0, application/*;q=0.1", 1},
+		{"text/html", -1},
+		{"text/html, */*;q=0.1", 0},
+		{"application/msgpack;q=0.5, application/xml;q=0.5", 1},
+		{"APPLICATION/XML", 2},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if index := negotiateMediaType(tc.accept, supported); index != tc.index {
+				t.Errorf("Unexpected media type: got %v want %v", index, tc.index)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	body := []byte(`{"b":[1,true],"a":"x"}`)
+	cases := []struct {
+		accept      string
+		contentType string
+		body        []byte
+		outType     string
+		out         []byte
+	}{
+		{"", "", body, "", body},
+		{"application/json", "application/json", body, "application/json", body},
+		{"application/xml", "", body, MediaTypeXML, []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><a>x</a><b><item>1</item><item>true</item></b></response>`)},
+		{"application/msgpack", "application/json; charset=utf-8", body, MediaTypeMessagePack, []byte("\x82\xa1a\xa1x\xa1b\x92\x01\xc3")},
+		{"application/xml", "text/plain", body, "text/plain", body},
+		{"application/xml", "", []byte(`{"1a":1}`), "", []byte(`{"1a":1}`)},
+		{"application/xml", "", nil, "", nil},
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service, WithContentNegotiation())
+	pxy.Debugger = &MockLogger{}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			if tc.contentType != "" {
+				w.Header().Set("Content-Type", tc.contentType)
+			}
+
+			out := pxy.negotiate(w, r, tc.body)
+
+			if ct := w.Header().Get("Content-Type"); ct != tc.outType {
+				t.Errorf("Unexpected Content-Type: got %q want %q", ct, tc.outType)
+			}
+			if !bytes.Equal(out, tc.out) {
+				t.Errorf("Unexpected body: got %q want %q", out, tc.out)
+			}
+		})
+	}
+}
+
+func TestCustomEncoder(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service, WithEncoder("text/csv", func(payload []byte) ([]byte, error) {
+		return []byte("a,b\n1,2\n"), nil
+	}))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	out := pxy.negotiate(w, r, []byte(`{}`))
+
+	if string(out) != "a,b\n1,2\n" {
+		t.Errorf("Unexpected body %q", out)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("Unexpected Vary %q", vary)
+	}
+}
@@ -0,0 +1,462 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+const (
+	defaultOIDCCookie     = "mrpcproxy_session"
+	defaultOIDCLoginPath  = "/login"
+	defaultOIDCLogoutPath = "/logout"
+	defaultSessionTTL     = 8 * time.Hour
+	defaultOIDCTimeout    = 10 * time.Second
+	oidcStateTTL          = 10 * time.Minute
+)
+
+var (
+	// ErrIncompleteOIDCConfig is returned by WithOIDC without an issuer, a
+	// client ID, a redirect URL or a session key.
+	ErrIncompleteOIDCConfig = errors.New("OIDC requires an issuer, a client ID, a redirect URL and a session key")
+	// ErrInvalidOIDCState is returned for callbacks without the state of a
+	// login started by the proxy.
+	ErrInvalidOIDCState = errors.New("invalid OIDC state")
+	// ErrInvalidNonce is returned for ID tokens without the nonce of the
+	// login.
+	ErrInvalidNonce = errors.New("invalid ID token nonce")
+	// ErrNoSession is returned for requests without a session when the
+	// session is required.
+	ErrNoSession = errors.New("no session")
+)
+
+// OIDCConfig configures the OpenID Connect login of the browser clients.
+type OIDCConfig struct {
+	// Issuer is the URL of the OpenID provider. Its endpoints are discovered
+	// from /.well-known/openid-configuration on first use.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the provider. Its path
+	// is served by the proxy.
+	RedirectURL string
+	// Scopes requested in addition to openid.
+	Scopes []string
+
+	// LoginPath and LogoutPath default to /login and /logout. The login takes
+	// the path to return to in the next query param.
+	LoginPath  string
+	LogoutPath string
+	// PostLogoutURL is where the clients are sent after the logout when the
+	// provider has no end session endpoint. Defaults to /.
+	PostLogoutURL string
+
+	// SessionKeys encrypt the session cookies with AES-GCM. The first key
+	// encrypts, all decrypt, so that the keys can be rotated.
+	SessionKeys []mrpcproxy.EncryptionKey
+	// CookieName of the session, mrpcproxy_session by default.
+	CookieName string
+	// SessionTTL is the lifetime of the sessions, 8 hours by default.
+	SessionTTL time.Duration
+
+	// Required sends the GET requests without a session to the login and
+	// answers the others with 401. The requests are served without claims
+	// otherwise.
+	Required bool
+
+	// Client is used to reach the provider. Defaults to a client timing out
+	// after 10 seconds.
+	Client *http.Client
+}
+
+// oidcProvider holds the endpoints of the provider.
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+
+	verifier *jwtVerifier
+}
+
+type oidc struct {
+	cfg          OIDCConfig
+	callbackPath string
+	secure       bool
+	now          func() time.Time
+
+	mu       sync.Mutex
+	provider *oidcProvider
+}
+
+// oidcState is the state of a login kept in a cookie until the callback.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// oidcSession is the content of the session cookie.
+type oidcSession struct {
+	Claims  map[string]interface{} `json:"claims"`
+	Expires int64                  `json:"expires"`
+}
+
+// WithOIDC serves the OpenID Connect login, callback and logout of the
+// browser clients, keeping their identity in an encrypted session cookie.
+// The claims of the ID token of the session are forwarded in
+// mrpcproxy.Request.Claims. Logins use PKCE and a nonce.
+func WithOIDC(cfg OIDCConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" || len(cfg.SessionKeys) == 0 {
+			return ErrIncompleteOIDCConfig
+		}
+		redirect, err := url.Parse(cfg.RedirectURL)
+		if err != nil {
+			return err
+		}
+		if cfg.LoginPath == "" {
+			cfg.LoginPath = defaultOIDCLoginPath
+		}
+		if cfg.LogoutPath == "" {
+			cfg.LogoutPath = defaultOIDCLogoutPath
+		}
+		if cfg.PostLogoutURL == "" {
+			cfg.PostLogoutURL = "/"
+		}
+		if cfg.CookieName == "" {
+			cfg.CookieName = defaultOIDCCookie
+		}
+		if cfg.SessionTTL == 0 {
+			cfg.SessionTTL = defaultSessionTTL
+		}
+		if cfg.Client == nil {
+			cfg.Client = &http.Client{Timeout: defaultOIDCTimeout}
+		}
+
+		o := &oidc{cfg: cfg, callbackPath: redirect.Path, secure: redirect.Scheme == "https", now: time.Now}
+		pxy.oidc = o
+		pxy.addRoute(route{"", "GET", cfg.LoginPath, pxy.oidcLogin, false})
+		pxy.addRoute(route{"", "GET", o.callbackPath, pxy.oidcCallback, false})
+		pxy.addRoute(route{"", "GET", cfg.LogoutPath, pxy.oidcLogout, false})
+		pxy.Use(pxy.oidcSessions)
+		return nil
+	}
+}
+
+// oidcSessions forwards the claims of the session of the requests.
+func (pxy *Proxy) oidcSessions(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+	o := pxy.oidc
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, err := o.session(r)
+		if err == nil {
+			next(w, r.WithContext(withClaims(r.Context(), session.Claims)), p)
+			return
+		}
+		if !o.cfg.Required {
+			next(w, r, p)
+			return
+		}
+
+		pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusUnauthorized, RequestIDFromContext(r.Context()))
+		if r.Method == http.MethodGet {
+			http.Redirect(w, r, o.cfg.LoginPath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+		pxy.writeError(w, r, http.StatusUnauthorized, err)
+	}
+}
+
+// oidcLogin redirects to the provider with a new login state.
+func (pxy *Proxy) oidcLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	o := pxy.oidc
+	provider, err := o.discover(r.Context())
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusBadGateway)
+		pxy.writeError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	state := oidcState{State: randomToken(), Nonce: randomToken(), Verifier: randomToken(), Next: localPath(r.URL.Query().Get("next"))}
+	if err := o.setCookie(w, o.stateCookie(), state, oidcStateTTL, o.callbackPath); err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusInternalServerError)
+		pxy.writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.cfg.ClientID},
+		"redirect_uri":          {o.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, o.cfg.Scopes...), " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusFound)
+	http.Redirect(w, r, provider.AuthorizationEndpoint+"?"+q.Encode(), http.StatusFound)
+}
+
+// oidcCallback exchanges the code of the provider for the ID token and
+// starts the session.
+func (pxy *Proxy) oidcCallback(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	o := pxy.oidc
+	status, next, err := o.callback(w, r)
+	if err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, status)
+		pxy.writeError(w, r, status, err)
+		return
+	}
+
+	pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusFound)
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func (o *oidc) callback(w http.ResponseWriter, r *http.Request) (int, string, error) {
+	var state oidcState
+	err := o.cookie(r, o.stateCookie(), &state)
+	o.clearCookie(w, o.stateCookie(), o.callbackPath)
+	q := r.URL.Query()
+	if err != nil || state.State == "" || q.Get("state") != state.State {
+		return http.StatusBadRequest, "", ErrInvalidOIDCState
+	}
+	if e := q.Get("error"); e != "" {
+		return http.StatusUnauthorized, "", fmt.Errorf("OIDC login failed: %v %v", e, q.Get("error_description"))
+	}
+
+	provider, err := o.discover(r.Context())
+	if err != nil {
+		return http.StatusBadGateway, "", err
+	}
+	token, err := o.exchange(r.Context(), provider, q.Get("code"), state.Verifier)
+	if err != nil {
+		return http.StatusBadGateway, "", err
+	}
+	claims, err := provider.verifier.verify(token)
+	if err != nil {
+		return http.StatusUnauthorized, "", err
+	}
+	if claims["nonce"] != state.Nonce {
+		return http.StatusUnauthorized, "", ErrInvalidNonce
+	}
+
+	session := oidcSession{Claims: claims, Expires: o.now().Add(o.cfg.SessionTTL).Unix()}
+	if err := o.setCookie(w, o.cfg.CookieName, session, o.cfg.SessionTTL, "/"); err != nil {
+		return http.StatusInternalServerError, "", err
+	}
+
+	next := state.Next
+	if next == "" {
+		next = "/"
+	}
+	return http.StatusFound, next, nil
+}
+
+// oidcLogout ends the session and the session of the provider when it
+// supports it.
+func (pxy *Proxy) oidcLogout(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	o := pxy.oidc
+	o.clearCookie(w, o.cfg.CookieName, "/")
+
+	target := o.cfg.PostLogoutURL
+	if provider, err := o.discover(r.Context()); err != nil {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+	} else if provider.EndSessionEndpoint != "" {
+		q := url.Values{"client_id": {o.cfg.ClientID}}
+		if o.cfg.PostLogoutURL != "/" {
+			q.Set("post_logout_redirect_uri", o.cfg.PostLogoutURL)
+		}
+		target = provider.EndSessionEndpoint + "?" + q.Encode()
+	}
+
+	pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusFound)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// discover returns the endpoints of the provider, fetched on first use.
+// Failed discoveries are retried with the next request. The fetch is made
+// without holding the lock, so that a slow provider doesn't serialize the
+// requests.
+func (o *oidc) discover(ctx context.Context) (*oidcProvider, error) {
+	o.mu.Lock()
+	provider := o.provider
+	o.mu.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(o.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := o.cfg.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC discovery: unexpected status %v", res.StatusCode)
+	}
+
+	provider = &oidcProvider{}
+	if err := json.NewDecoder(res.Body).Decode(provider); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %v", err)
+	}
+
+	var secret []byte
+	if o.cfg.ClientSecret != "" {
+		secret = []byte(o.cfg.ClientSecret)
+	}
+	provider.verifier, err = newJWTVerifier(JWTConfig{
+		Secret:   secret,
+		JWKSURL:  provider.JWKSURI,
+		Issuer:   o.cfg.Issuer,
+		Audience: o.cfg.ClientID,
+		Client:   o.cfg.Client,
+	})
+	if err != nil {
+		return nil, err
+	}
+	provider.verifier.now = o.now
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// Keep the provider of a concurrent discovery, whose verifier may
+	// already hold the keys.
+	if o.provider == nil {
+		o.provider = provider
+	}
+	return o.provider, nil
+}
+
+// exchange returns the ID token of the authorization code.
+func (o *oidc) exchange(ctx context.Context, provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.cfg.RedirectURL},
+		"client_id":     {o.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+
+	res, err := o.cfg.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC token exchange: unexpected status %v", res.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("OIDC token exchange: %v", err)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("OIDC token exchange: no ID token")
+	}
+	return tokens.IDToken, nil
+}
+
+// session returns the unexpired session of r.
+func (o *oidc) session(r *http.Request) (*oidcSession, error) {
+	session := &oidcSession{}
+	if err := o.cookie(r, o.cfg.CookieName, session); err != nil {
+		return nil, ErrNoSession
+	}
+	if o.now().Unix() >= session.Expires {
+		return nil, ErrNoSession
+	}
+	return session, nil
+}
+
+func (o *oidc) stateCookie() string {
+	return o.cfg.CookieName + "_state"
+}
+
+// setCookie sets the cookie name to v encrypted with the session key.
+func (o *oidc) setCookie(w http.ResponseWriter, name string, v interface{}, ttl time.Duration, path string) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	encrypted, err := mrpcproxy.Encrypt(payload, o.cfg.SessionKeys[0])
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    base64.RawURLEncoding.EncodeToString(encrypted),
+		Path:     path,
+		MaxAge:   int(ttl / time.Second),
+		Secure:   o.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// cookie decrypts the cookie name of r into v.
+func (o *oidc) cookie(r *http.Request, name string, v interface{}) error {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+	encrypted, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return err
+	}
+	payload, err := mrpcproxy.Decrypt(encrypted, o.cfg.SessionKeys...)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+func (o *oidc) clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Path: path, MaxAge: -1, Secure: o.secure, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// localPath returns next when it is a path on the proxy, so that logins
+// can't redirect elsewhere.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return ""
+	}
+	return next
+}
@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	secret := []byte("client-secret")
+	var nonce, challenge string
+	var exchanged url.Values
+
+	mux := http.NewServeMux()
+	provider := httptest.NewServer(mux)
+	defer provider.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": provider.URL + "/authorize",
+			"token_endpoint":         provider.URL + "/token",
+			"end_session_endpoint":   provider.URL + "/end",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		exchanged = r.PostForm
+		if id, pw, _ := r.BasicAuth(); id != "app" || pw != string(secret) || r.PostForm.Get("code") != "code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := signHS256(map[string]interface{}{
+			"iss": provider.URL, "aud": "app", "sub": "alice", "nonce": nonce, "exp": float64(time.Now().Add(time.Hour).Unix()),
+		}, secret)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": token})
+	})
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("whoami", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		sub, _ := req.Claims["sub"].(string)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(sub)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service, WithOIDC(OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     "app",
+		ClientSecret: string(secret),
+		RedirectURL:  "https://app.example.com/auth/callback",
+		Scopes:       []string{"email"},
+		SessionKeys:  []mrpcproxy.EncryptionKey{{ID: "1", Key: make([]byte, 32)}},
+		Required:     true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/whoami", Topic: "whoami"},
+		Endpoint{Method: "POST", Path: "/whoami", Topic: "whoami"},
+	)
+
+	serve := func(method, target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, target, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		return w
+	}
+
+	// Requests without a session are sent to the login.
+	w := serve("GET", "/whoami?a=1", nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login?next=%2Fwhoami%3Fa%3D1" {
+		t.Fatalf("Unexpected response: %v %v", w.Code, w.Header())
+	}
+	if w = serve("POST", "/whoami", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected response: %v", w.Code)
+	}
+
+	w = serve("GET", "/login?next=/whoami", nil)
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	q := loc.Query()
+	if w.Code != http.StatusFound || !strings.HasPrefix(loc.String(), provider.URL+"/authorize?") ||
+		q.Get("client_id") != "app" || q.Get("scope") != "openid email" || q.Get("code_challenge_method") != "S256" ||
+		q.Get("redirect_uri") != "https://app.example.com/auth/callback" {
+		t.Fatalf("Unexpected login redirect: %v %v", w.Code, loc)
+	}
+	nonce, challenge = q.Get("nonce"), q.Get("code_challenge")
+	stateCookies := w.Result().Cookies()
+	if len(stateCookies) != 1 || !stateCookies[0].HttpOnly || !stateCookies[0].Secure || stateCookies[0].Path != "/auth/callback" {
+		t.Fatalf("Unexpected state cookie: %v", stateCookies)
+	}
+
+	cases := []struct {
+		query   string
+		cookies []*http.Cookie
+		status  int
+	}{
+		{"state=" + q.Get("state") + "&code=code", nil, http.StatusBadRequest},
+		{"state=other&code=code", stateCookies, http.StatusBadRequest},
+		{"state=" + q.Get("state") + "&error=access_denied", stateCookies, http.StatusUnauthorized},
+		{"state=" + q.Get("state") + "&code=wrong", stateCookies, http.StatusBadGateway},
+		{"state=" + q.Get("state") + "&code=code", stateCookies, http.StatusFound},
+	}
+
+	var session []*http.Cookie
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			w := serve("GET", "/auth/callback?"+tc.query, tc.cookies)
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if w.Code == http.StatusFound {
+				if w.Header().Get("Location") != "/whoami" {
+					t.Errorf("Unexpected redirect: %v", w.Header().Get("Location"))
+				}
+				for _, c := range w.Result().Cookies() {
+					if c.Name == defaultOIDCCookie {
+						session = append(session, c)
+					}
+				}
+			}
+		})
+	}
+	if exchanged.Get("redirect_uri") != "https://app.example.com/auth/callback" {
+		t.Errorf("Unexpected token request: %v", exchanged)
+	}
+
+	if w = serve("GET", "/whoami", session); w.Code != 200 || w.Body.String() != "alice" {
+		t.Errorf("Unexpected session response: %v %q", w.Code, w.Body.String())
+	}
+
+	w = serve("GET", "/logout", session)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != provider.URL+"/end?client_id=app" {
+		t.Errorf("Unexpected logout: %v %v", w.Code, w.Header())
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].Name != defaultOIDCCookie || c[0].MaxAge != -1 {
+		t.Errorf("Unexpected logout cookies: %v", c)
+	}
+
+	// Expired sessions are ignored.
+	pxy.oidc.now = func() time.Time { return time.Now().Add(9 * time.Hour) }
+	if w = serve("POST", "/whoami", session); w.Code != http.StatusUnauthorized {
+		t.Errorf("Unexpected expired session response: %v", w.Code)
+	}
+}
+
+func TestOIDCConfigErrors(t *testing.T) {
+	key := []mrpcproxy.EncryptionKey{{ID: "1", Key: make([]byte, 32)}}
+	cases := []OIDCConfig{
+		{ClientID: "app", RedirectURL: "https://a/cb", SessionKeys: key},
+		{Issuer: "https://idp", RedirectURL: "https://a/cb", SessionKeys: key},
+		{Issuer: "https://idp", ClientID: "app", SessionKeys: key},
+		{Issuer: "https://idp", ClientID: "app", RedirectURL: "https://a/cb"},
+	}
+
+	for i, cfg := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if _, err := New(":80", &mrpc.Service{}, WithOIDC(cfg)); err != (FuncOptsError{ErrIncompleteOIDCConfig}) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	cases := map[string]string{
+		"/a?b=c":             "/a?b=c",
+		"":                   "",
+		"https://evil.com/a": "",
+		"//evil.com":         "",
+		"/\\evil.com":        "",
+	}
+	for next, want := range cases {
+		if got := localPath(next); got != want {
+			t.Errorf("Unexpected path for %q: got %q want %q", next, got, want)
+		}
+	}
+}
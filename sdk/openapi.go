@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+	openAPIParam   = regexp.MustCompile(`\{([^}/]+)\}`)
+)
+
+type openAPIOperation struct {
+	OperationID string `json:"operationId"`
+	Topic       string `json:"x-mrpc-topic"`
+	KeepAlive   int    `json:"x-mrpc-keepAlive"`
+}
+
+// EndpointsFromOpenAPI creates endpoints from the operations of an OpenAPI 3
+// JSON document. The topic of each operation is topicMapper(operationId), or
+// its x-mrpc-topic extension when topicMapper is nil. The x-mrpc-keepAlive
+// extension sets Endpoint.KeepAlive. Path templates like /users/{id} become
+// /users/:id.
+func EndpointsFromOpenAPI(doc []byte, topicMapper func(operationID string) string) ([]Endpoint, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, ParseError{err}
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	eps := []Endpoint{}
+	for _, p := range paths {
+		for _, method := range openAPIMethods {
+			raw, ok := spec.Paths[p][method]
+			if !ok {
+				continue
+			}
+
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, ParseError{err}
+			}
+
+			topic := op.Topic
+			if topicMapper != nil {
+				if op.OperationID == "" {
+					return nil, ParseError{fmt.Errorf("%v %v: missing operationId", strings.ToUpper(method), p)}
+				}
+				topic = topicMapper(op.OperationID)
+			}
+			if topic == "" {
+				return nil, ParseError{fmt.Errorf("%v %v: no topic", strings.ToUpper(method), p)}
+			}
+
+			eps = append(eps, Endpoint{
+				Path:      openAPIParam.ReplaceAllString(p, ":$1"),
+				Method:    strings.ToUpper(method),
+				Topic:     topic,
+				KeepAlive: op.KeepAlive,
+			})
+		}
+	}
+
+	if len(eps) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	return eps, nil
+}
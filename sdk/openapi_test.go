@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestEndpointsFromOpenAPI(t *testing.T) {
+	doc := []byte(`{
+		"openapi": "3.0.0",
+		"paths": {
+			"/users/{id}": {
+				"parameters": [],
+				"get": {"operationId": "getUser", "x-mrpc-topic": "users.get", "x-mrpc-keepAlive": 500},
+				"delete": {"operationId": "deleteUser", "x-mrpc-topic": "users.delete"}
+			},
+			"/users": {
+				"post": {"operationId": "createUser", "x-mrpc-topic": "users.create"}
+			}
+		}
+	}`)
+
+	cases := []struct {
+		doc    []byte
+		mapper func(string) string
+		eps    []Endpoint
+		err    string
+	}{
+		{
+			doc: doc,
+			eps: []Endpoint{
+				{Path: "/users", Method: "POST", Topic: "users.create"},
+				{Path: "/users/:id", Method: "GET", Topic: "users.get", KeepAlive: 500},
+				{Path: "/users/:id", Method: "DELETE", Topic: "users.delete"},
+			},
+		},
+		{
+			doc:    doc,
+			mapper: func(id string) string { return "svc." + id },
+			eps: []Endpoint{
+				{Path: "/users", Method: "POST", Topic: "svc.createUser"},
+				{Path: "/users/:id", Method: "GET", Topic: "svc.getUser", KeepAlive: 500},
+				{Path: "/users/:id", Method: "DELETE", Topic: "svc.deleteUser"},
+			},
+		},
+		{
+			doc: []byte(`{"paths": {"/a": {"get": {}}}}`),
+			err: "error parsing endpoints: GET /a: no topic",
+		},
+		{
+			doc:    []byte(`{"paths": {"/a": {"get": {}}}}`),
+			mapper: func(id string) string { return id },
+			err:    "error parsing endpoints: GET /a: missing operationId",
+		},
+		{
+			doc: []byte(`{"paths": {}}`),
+			err: ErrNoEndpoints.Error(),
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			eps, err := EndpointsFromOpenAPI(tc.doc, tc.mapper)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(eps, tc.eps) {
+				t.Errorf("Endpoints don't match\nExpected: %v\nReceived: %v", tc.eps, eps)
+			}
+		})
+	}
+}
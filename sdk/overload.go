@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// ErrOverloaded is returned for requests rejected because the proxy
+	// already handles MaxInFlight requests.
+	ErrOverloaded = errors.New("too many requests in flight")
+)
+
+// admit counts a new in-flight request. It returns false, without counting
+// it, when the proxy is at MaxInFlight.
+func (pxy *Proxy) admit() bool {
+	n := atomic.AddInt64(&pxy.inFlight, 1)
+	if max := atomic.LoadInt64(&pxy.MaxInFlight); max > 0 && n > max {
+		atomic.AddInt64(&pxy.inFlight, -1)
+		atomic.AddInt64(&pxy.rejected, 1)
+		return false
+	}
+	return true
+}
+
+// Rejected returns the number of requests rejected because the proxy was at
+// MaxInFlight.
+func (pxy *Proxy) Rejected() int64 {
+	return atomic.LoadInt64(&pxy.rejected)
+}
+
+// Load returns the in-flight requests as a fraction of MaxInFlight, for
+// autoscalers. It is 0 without MaxInFlight.
+func (pxy *Proxy) Load() float64 {
+	max := atomic.LoadInt64(&pxy.MaxInFlight)
+	if max <= 0 {
+		return 0
+	}
+	return float64(pxy.InFlight()) / float64(max)
+}
@@ -0,0 +1,86 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("slow", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(50 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		max      int64
+		codes    map[int]int
+		rejected int64
+		load     float64
+	}{
+		{0, map[int]int{200: 3}, 0, 0},
+		{1, map[int]int{200: 1, 503: 2}, 2, 1},
+		{2, map[int]int{200: 2, 503: 1}, 1, 1},
+		{3, map[int]int{200: 3}, 0, 1},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.MaxInFlight = tc.max
+			pxy.Handle(Endpoint{Topic: "service.slow", Method: "GET", Path: "/slow"})
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			codes := map[int]int{}
+			for j := 0; j < 3; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, _ := http.NewRequest("GET", "/slow", nil)
+					w := httptest.NewRecorder()
+					pxy.ServeHTTP(w, r)
+
+					if w.Code == 503 && w.Header().Get("Retry-After") == "" {
+						t.Error("Retry-After missing")
+					}
+					mu.Lock()
+					codes[w.Code]++
+					mu.Unlock()
+				}()
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			if pxy.Load() != tc.load {
+				t.Errorf("Unexpected load %v; expected %v", pxy.Load(), tc.load)
+			}
+			wg.Wait()
+
+			if fmt.Sprint(codes) != fmt.Sprint(tc.codes) {
+				t.Errorf("Unexpected codes %v; expected %v", codes, tc.codes)
+			}
+			if pxy.Rejected() != tc.rejected {
+				t.Errorf("Unexpected rejected count %v; expected %v", pxy.Rejected(), tc.rejected)
+			}
+			if pxy.InFlight() != 0 {
+				t.Errorf("Unexpected in-flight requests %v", pxy.InFlight())
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+var (
+	// ErrParamConflict is returned for requests with a query param named
+	// after a path param with ParamConflictError.
+	ErrParamConflict = errors.New("query param conflicts with path param")
+)
+
+// ParamConflict is how Request.Params merges the path and query params with
+// the same name.
+type ParamConflict int
+
+const (
+	// ParamsMerged keeps the query values followed by the path value.
+	ParamsMerged ParamConflict = iota
+	// PathParamsWin keeps only the path value.
+	PathParamsWin
+	// QueryParamsWin keeps only the query values.
+	QueryParamsWin
+	// ParamConflictError answers the requests with 400.
+	ParamConflictError
+)
+
+// WithParamConflict sets how Request.Params merges the path and query params
+// with the same name, ParamsMerged by default. Request.PathParams and
+// Request.QueryParams always hold them apart.
+func WithParamConflict(policy ParamConflict) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.paramConflict = policy
+		return nil
+	}
+}
+
+// pathConstraint is a path param constrained by a regular expression.
+type pathConstraint struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// compilePath returns the path of the route on r and the constraints of the
+// params given as {name:regexp}. The braces are rewritten to :name on
+// httprouter and to {name} on the other routers. Expressions can't contain
+// slashes.
+func compilePath(r Router, path string) (string, []pathConstraint, error) {
+	_, colon := r.(*httprouter.Router)
+
+	segments := strings.Split(path, "/")
+	var constraints []pathConstraint
+	for i, s := range segments {
+		if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+			continue
+		}
+
+		name, expr, ok := strings.Cut(s[1:len(s)-1], ":")
+		if ok {
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return "", nil, fmt.Errorf("path %v: %w", path, err)
+			}
+			constraints = append(constraints, pathConstraint{name, re})
+		}
+
+		switch {
+		case colon && !strings.HasSuffix(name, "..."):
+			segments[i] = ":" + name
+		case !colon && ok:
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), constraints, nil
+}
+
+// handleRoute registers rt on r. Requests with params not matching their
+// constraints are answered as not found.
+func (pxy *Proxy) handleRoute(r Router, rt route) {
+	pxy.handleRouteFunc(r, rt, pxy.serveNotFound)
+}
+
+// handleRouteFunc registers rt on r like handleRoute, serving the requests
+// with params not matching their constraints with notFound.
+func (pxy *Proxy) handleRouteFunc(r Router, rt route, notFound http.HandlerFunc) {
+	path, constraints, err := compilePath(r, rt.path)
+	if err != nil {
+		panic(err)
+	}
+
+	h := rt.h
+	if len(constraints) > 0 {
+		h = func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+			for _, c := range constraints {
+				if !c.re.MatchString(p.ByName(c.name)) {
+					notFound(w, req)
+					return
+				}
+			}
+			rt.h(w, req, p)
+		}
+	}
+
+	r.Handle(rt.method, path, h)
+}
+
+// setRequestParams sets the merged, path and query params of req from the
+// path params p of r.
+func (pxy *Proxy) setRequestParams(req *mrpcproxy.Request, r *http.Request, p httprouter.Params) error {
+	query := r.URL.Query()
+	if len(query) > 0 {
+		req.QueryParams = query
+	}
+	if len(p) > 0 {
+		req.PathParams = make(map[string]string, len(p))
+		for _, param := range p {
+			req.PathParams[param.Key] = param.Value
+		}
+	}
+
+	if pxy.paramConflict == ParamsMerged {
+		req.Params = mergeRequestParams(r, p)
+		return nil
+	}
+
+	params := make(url.Values, len(query)+len(p))
+	for k, vs := range query {
+		params[k] = append([]string(nil), vs...)
+	}
+	for _, param := range p {
+		if _, ok := query[param.Key]; ok {
+			switch pxy.paramConflict {
+			case QueryParamsWin:
+				continue
+			case ParamConflictError:
+				return StatusError{http.StatusBadRequest, fmt.Errorf("%w: %v", ErrParamConflict, param.Key)}
+			}
+		}
+		params[param.Key] = []string{param.Value}
+	}
+	req.Params = params
+	return nil
+}
@@ -0,0 +1,168 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestPathParams(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("params", func(w mrpc.TopicWriter, data []byte) {
+		req := mrpcproxy.Request{}
+		json.Unmarshal(data, &req)
+		body := req.Params.Get("id") + "|" + req.Params.Get("path")
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(body)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	eps := map[string][]Endpoint{
+		"httprouter": {
+			{Method: "GET", Path: "/users/{id:[0-9]+}", Topic: "params"},
+			{Method: "GET", Path: "/files/*path", Topic: "params"},
+		},
+		"servemux": {
+			{Method: "GET", Path: "/users/{id:[0-9]+}", Topic: "params"},
+			{Method: "GET", Path: "/files/{path...}", Topic: "params"},
+		},
+	}
+	wantPath := map[string]string{"httprouter": "/a/b.txt", "servemux": "a/b.txt"}
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/users/42", http.StatusOK, "42|"},
+		{"/users/abc", http.StatusNotFound, ""},
+		{"/files/a/b.txt", http.StatusOK, "|path"},
+	}
+
+	for _, name := range []string{"httprouter", "servemux"} {
+		var opts []func(*Proxy) error
+		if name == "servemux" {
+			opts = append(opts, WithRouter(NewServeMuxRouter))
+		}
+		pxy, _ := New(":80", service, opts...)
+		pxy.Logger = &MockLogger{}
+		pxy.Requests = &MockLogger{}
+		if err := pxy.Handle(eps[name]...); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, tc := range cases {
+			t.Run(fmt.Sprintf("%vCase%v", name, i), func(t *testing.T) {
+				r, _ := http.NewRequest("GET", tc.path, nil)
+				w := httptest.NewRecorder()
+				pxy.ServeHTTP(w, r)
+
+				body := tc.body
+				if body == "|path" {
+					body = "|" + wantPath[name]
+				}
+				if w.Code != tc.status {
+					t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+				}
+				if tc.status == http.StatusOK && w.Body.String() != body {
+					t.Errorf("Unexpected body: got %q want %q", w.Body.String(), body)
+				}
+			})
+		}
+	}
+}
+
+func TestCompilePath(t *testing.T) {
+	httpRouter := NewHTTPRouter(nil, nil)
+	muxRouter := NewServeMuxRouter(nil, nil)
+
+	cases := []struct {
+		router      Router
+		path        string
+		want        string
+		constraints int
+		err         bool
+	}{
+		{httpRouter, "/users/{id:[0-9]+}", "/users/:id", 1, false},
+		{httpRouter, "/users/{id}/files/*path", "/users/:id/files/*path", 0, false},
+		{httpRouter, "/users/:id", "/users/:id", 0, false},
+		{muxRouter, "/users/{id:[0-9]+}/{rest...}", "/users/{id}/{rest...}", 1, false},
+		{muxRouter, "/users/{id}", "/users/{id}", 0, false},
+		{httpRouter, "/users/{id:[0-9}", "", 0, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			path, constraints, err := compilePath(tc.router, tc.path)
+			if (err != nil) != tc.err {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if path != tc.want || len(constraints) != tc.constraints {
+				t.Errorf("Unexpected path: got %v %v want %v %v", path, len(constraints), tc.want, tc.constraints)
+			}
+		})
+	}
+}
+
+func TestParamConflict(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("conflict", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: data})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		policy ParamConflict
+		url    string
+		status int
+		params url.Values
+		path   map[string]string
+		query  url.Values
+	}{
+		{ParamsMerged, "/users/1?id=2&b=3", 200, url.Values{"id": {"2", "1"}, "b": {"3"}}, map[string]string{"id": "1"}, url.Values{"id": {"2"}, "b": {"3"}}},
+		{PathParamsWin, "/users/1?id=2&b=3", 200, url.Values{"id": {"1"}, "b": {"3"}}, map[string]string{"id": "1"}, url.Values{"id": {"2"}, "b": {"3"}}},
+		{QueryParamsWin, "/users/1?id=2&id=4", 200, url.Values{"id": {"2", "4"}}, map[string]string{"id": "1"}, url.Values{"id": {"2", "4"}}},
+		{ParamConflictError, "/users/1?id=2", 400, nil, nil, nil},
+		{ParamConflictError, "/users/1?b=3", 200, url.Values{"id": {"1"}, "b": {"3"}}, map[string]string{"id": "1"}, url.Values{"b": {"3"}}},
+		{PathParamsWin, "/users/1", 200, url.Values{"id": {"1"}}, map[string]string{"id": "1"}, nil},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithParamConflict(tc.policy))
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Method: "GET", Path: "/users/:id", Topic: "conflict"})
+
+			r, _ := http.NewRequest("GET", tc.url, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.status != 200 {
+				return
+			}
+			req := mrpcproxy.Request{}
+			json.Unmarshal(w.Body.Bytes(), &req)
+			if !reflect.DeepEqual(req.Params, tc.params) || !reflect.DeepEqual(req.PathParams, tc.path) || !reflect.DeepEqual(req.QueryParams, tc.query) {
+				t.Errorf("Unexpected params: got %v %v %v", req.Params, req.PathParams, req.QueryParams)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package sdk
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// Buffers grown above maxPooledBuffer are dropped instead of being reused, so
+// a few large bodies don't pin memory.
+const maxPooledBuffer = 64 << 10
+
+// pooledRequest is an MRPC request reused across HTTP requests, with the
+// buffer holding its body.
+type pooledRequest struct {
+	mrpcproxy.Request
+	body bytes.Buffer
+}
+
+var requestPool = sync.Pool{
+	New: func() interface{} { return &pooledRequest{} },
+}
+
+func getRequest() *pooledRequest {
+	return requestPool.Get().(*pooledRequest)
+}
+
+// releaseRequest returns pr to the pool. pr must not be used afterwards.
+func releaseRequest(pr *pooledRequest) {
+	if pr.body.Cap() > maxPooledBuffer {
+		return
+	}
+
+	pr.Request = mrpcproxy.Request{}
+	pr.body.Reset()
+	requestPool.Put(pr)
+}
+
+// resetWriteCloser is a compressor that can be reused for another writer.
+type resetWriteCloser interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{}
+
+func init() {
+	for enc, newCompressor := range compressors {
+		newCompressor := newCompressor
+		compressorPools[enc] = &sync.Pool{
+			New: func() interface{} { return newCompressor(nil) },
+		}
+	}
+}
+
+// getCompressor returns a pooled compressor of enc writing to w.
+func getCompressor(enc string, w io.Writer) resetWriteCloser {
+	cw := compressorPools[enc].Get().(resetWriteCloser)
+	cw.Reset(w)
+	return cw
+}
+
+// releaseCompressor returns the closed compressor cw of enc to the pool.
+func releaseCompressor(enc string, cw resetWriteCloser) {
+	cw.Reset(nil)
+	compressorPools[enc].Put(cw)
+}
@@ -0,0 +1,113 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func echoRequestService() *mrpc.Service {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("echo", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: data})
+		w.Write(msg)
+	})
+	go service.Serve()
+	time.Sleep(1 * time.Millisecond)
+	return service
+}
+
+func TestRequestPool(t *testing.T) {
+	service := echoRequestService()
+	defer service.Stop(nil)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "echo", Method: "POST", Path: "/echo"})
+
+	cases := []struct {
+		body   string
+		header string
+		cookie string
+	}{
+		{strings.Repeat("a", maxPooledBuffer+1), "a", "a"},
+		{"first", "b", "b"},
+		{"", "", ""},
+		{"second", "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/echo", strings.NewReader(tc.body))
+			if tc.header != "" {
+				r.Header.Set("X-Test", tc.header)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "c", Value: tc.cookie})
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			req := &mrpcproxy.Request{}
+			if err := json.Unmarshal(w.Body.Bytes(), req); err != nil {
+				t.Fatal(err)
+			}
+			if string(req.Msg) != tc.body || req.Headers.Get("X-Test") != tc.header {
+				t.Errorf("Unexpected request: %q %v", req.Msg, req.Headers)
+			}
+			if (len(req.Cookies) == 1) != (tc.cookie != "") {
+				t.Errorf("Unexpected cookies: %v", req.Cookies)
+			}
+		})
+	}
+}
+
+func BenchmarkProxyRequest(b *testing.B) {
+	service := echoRequestService()
+	defer service.Stop(nil)
+
+	cases := []struct {
+		name string
+		opts []func(*Proxy) error
+		enc  string
+	}{
+		{"JSON", nil, ""},
+		{"Proto", []func(*Proxy) error{WithEncoding(mrpcproxy.ProtoEncoding)}, ""},
+		{"Gzip", []func(*Proxy) error{WithCompression(CompressionConfig{MinSize: 1})}, "gzip"},
+	}
+
+	body := `{"name":"` + strings.Repeat("a", 512) + `"}`
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			pxy, _ := New(":80", service, tc.opts...)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: "echo", Method: "POST", Path: "/echo"})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r, _ := http.NewRequest("POST", "/echo", strings.NewReader(body))
+				if tc.enc != "" {
+					r.Header.Set("Accept-Encoding", tc.enc)
+				}
+				w := httptest.NewRecorder()
+				pxy.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					b.Fatalf("Unexpected code %v", w.Code)
+				}
+			}
+		})
+	}
+}
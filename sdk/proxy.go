@@ -3,15 +3,16 @@ package sdk
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -24,10 +25,14 @@ const (
 	defaultTimeout = 1 * time.Second
 )
 
+// StatusClientClosedRequest is logged for requests whose client went away
+// before the MRPC response arrived.
+const StatusClientClosedRequest = 499
+
 var (
 	defaultDebugger = log.New(os.Stdout, "[DEBUG]", log.LstdFlags|log.LUTC)
 	defaultLogger   = log.New(os.Stdout, "[PROXY]", log.LstdFlags|log.LUTC)
-	defaultRequests = log.New(os.Stdout, "[R]", log.LstdFlags|log.LUTC)
+	defaultRequests = NewLineLogger(os.Stdout, "[R]")
 
 	// ErrNoService is returned when proxy doesn't have service.
 	ErrNoService = errors.New("service should not be nil")
@@ -39,21 +44,173 @@ type Proxy struct {
 	MRPCService *mrpc.Service
 	Timeout     time.Duration
 
+	// TimeoutHeader names a request header carrying a timeout override in
+	// milliseconds. It is honored only on requests from trusted proxies, see
+	// WithTrustedProxies. Disabled when empty.
+	TimeoutHeader string
+	// MaxTimeout caps the endpoint and request timeouts when positive.
+	MaxTimeout time.Duration
+	// Status and body template of the requests timing out, see
+	// WithTimeoutResponse.
+	timeoutStatus int
+	timeoutBody   *template.Template
+
+	// MaxInFlight caps the endpoint requests handled at once. Requests over
+	// it are rejected with 503 and Retry-After. Unlimited when 0. Accessed
+	// atomically, so it can be changed while serving.
+	MaxInFlight int64
+
 	// Request ID generator
 	GetID func() string
+	// RequestIDHeader is the header request IDs are accepted from and echoed
+	// in. Disabled when empty.
+	RequestIDHeader string
 
 	// List of headers that will be added to every response
 	Headers map[string]string
+
+	// BaseURL is the public URL of the proxy used to resolve relative redirect
+	// locations. Locations are resolved against the request URL when nil.
+	BaseURL *url.URL
+
+	// FileStorage stores the files uploaded to multipart endpoints.
+	FileStorage FileStorage
+	// MaxUploadSize limits the size of multipart bodies, 32MB by default.
+	MaxUploadSize int64
+	// MaxUploadParts limits the number of fields and files of multipart
+	// bodies, 100 by default.
+	MaxUploadParts int
+
+	// Default list of request headers forwarded to MRPC. See
+	// Endpoint.ForwardHeaders.
+	ForwardHeaders []string
+	// ValueProvider resolves Endpoint.InjectHeaders when the endpoints are
+	// added. The environment variables are used when nil.
+	ValueProvider ValueProvider
+
 	Handler func(w http.ResponseWriter, r *http.Request, res *mrpcproxy.Response)
 
-	Eps    []Endpoint
-	router *httprouter.Router
+	// RequestTransformer modifies the MRPC requests before they are sent. A
+	// returned error is written as an error response. The requests are reused
+	// once sent, so they must not be retained.
+	RequestTransformer func(r *http.Request, req *mrpcproxy.Request) error
+
+	// ResponseTransformer rewrites the MRPC responses of endpoints before
+	// they are written. A returned error is written as an error response.
+	ResponseTransformer func(r *http.Request, res *mrpcproxy.Response) (*mrpcproxy.Response, error)
+
+	// NotFound handles the requests not matching any route. Errors are
+	// rendered by the ErrorRenderer when nil.
+	NotFound http.Handler
+	// MethodNotAllowed handles the requests matching a route with other
+	// methods. The Allow header is set before it's called. Errors are
+	// rendered by the ErrorRenderer when nil.
+	MethodNotAllowed http.Handler
+
+	// ErrorRenderer renders the body and headers of error responses generated
+	// by the proxy and of backend error responses without body. Errors have
+	// no body when nil.
+	ErrorRenderer ErrorRenderer
+
+	Eps        []Endpoint
+	router     Router
+	hosts      map[string]Router
+	newRouter  NewRouterFunc
+	middleware []Middleware
+
+	// Registered routes, replayed when the admin API rebuilds the routers.
+	routes   []route
+	routesMu sync.RWMutex
+	// 404 and 405 handlers of the routers, guarded by routesMu.
+	notFound, methodNotAllowed http.Handler
+
+	configMu sync.RWMutex
+	// Guarded by configMu.
+	maintenance Maintenance
+	admin       *adminAPI
+
+	// Servers started by Serve alongside the proxy.
+	servers []auxServer
+
+	// Listeners served in addition to the proxy address.
+	listeners   []net.Listener
+	listenAddrs []listenAddr
+	// Listeners inherited from the parent process, see
+	// WithInheritedListener.
+	inherit   bool
+	inherited []net.Listener
+	// Listeners being served, passed on by Handoff.
+	serving   []net.Listener
+	servingMu sync.Mutex
+
+	// TLS certificate and key files. Serve uses TLS when set.
+	certFile string
+	keyFile  string
+
+	cache          Cache
+	cacheTTL       time.Duration
+	coalescer      *coalescer
+	recorder       *recorder
+	dumper         *dumper
+	sampling       *sampling
+	tenancy        *tenancy
+	slowLog        *slowLog
+	serverTiming   bool
+	meta           []metaResolver
+	locales        []string
+	paramConflict  ParamConflict
+	quotas         *quotas
+	oidc           *oidc
+	throttle       *throttle
+	transport      *transportWatcher
+	services       map[string]*mrpc.Service
+	topicPrefix    string
+	idempotency    *idempotency
+	authorizer     Authorizer
+	signingKey     *mrpcproxy.SigningKey
+	encoding       mrpcproxy.Encoding
+	topicEncodings map[string]mrpcproxy.Encoding
+	// The first key encrypts, all decrypt.
+	encryptionKeys []mrpcproxy.EncryptionKey
+
+	// Stale cache windows and the keys being revalidated.
+	staleRevalidate, staleIfError time.Duration
+	revalidating                  map[string]bool
+	revalidatingMu                sync.Mutex
+
+	trusted         *trustedProxies
+	canaries        canaryRegistry
+	deprecations    deprecationRegistry
+	bulkheads       bulkheadRegistry
+	longPolls       longPollRegistry
+	compression     *CompressionConfig
+	securityHeaders map[string]string
+	encoders        []mediaEncoder
+	fetcher         BodyFetcher
+	accessLogFn     AccessLogFormatter
+	spaDir          string
+
+	// Base context of all requests, cancelled when shutdown aborts them.
+	ctx           context.Context
+	cancel        context.CancelFunc
+	inFlight      int64
+	logSampling   uint64
+	logSampled    uint64
+	rejected      int64
+	panics        int64
+	shuttingDown  int32
+	shutdownHooks []func()
+	hooks         lifecycleHooks
 
 	Debugger logger
 	Logger   logger
 	Requests logger
 }
 
+// Middleware wraps the handler of an endpoint. It is applied when the endpoint
+// is registered with Handle.
+type Middleware func(ep Endpoint, next httprouter.Handle) httprouter.Handle
+
 type logger interface {
 	Println(v ...interface{})
 	Printf(format string, v ...interface{})
@@ -77,26 +234,88 @@ func (e ResponseError) Error() string {
 	return fmt.Sprintf("Malformed mrpcproxy Response: %v", e.err)
 }
 
+// StatusError is an error answered with a specific HTTP status code instead of
+// 500.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e StatusError) Unwrap() error {
+	return e.Err
+}
+
+// shadow sends a copy of req to topic ignoring the response. The request is
+// not cancelled when the client goes away.
+func (pxy *Proxy) shadow(topic string, req mrpcproxy.Request, timeout time.Duration) {
+	req.Topic = topic
+	if _, err := pxy.Call(pxy.ctx, topic, &req, timeout); err != nil {
+		pxy.Debugger.Println(err)
+	}
+}
+
+// shouldFallback reports whether the next fallback topic should be tried
+// after a request failed with a timeout or a server error.
+func shouldFallback(res *mrpcproxy.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return res.Timeout || res.Code >= http.StatusInternalServerError
+}
+
+// requestErrorStatus returns the HTTP status code for an error of an endpoint
+// request. The MRPC wait is cancelled when the client closes the connection
+// or when the shutdown aborts the request.
+func (pxy *Proxy) requestErrorStatus(err error) int {
+	if !errors.Is(err, context.Canceled) {
+		return errorStatus(err)
+	}
+	if pxy.ctx.Err() != nil {
+		return http.StatusServiceUnavailable
+	}
+	return StatusClientClosedRequest
+}
+
+// errorStatus returns the HTTP status code for err.
+func errorStatus(err error) int {
+	if se, ok := err.(StatusError); ok {
+		return se.Code
+	}
+	return http.StatusInternalServerError
+}
+
 // New creates new Proxy.
 func New(addr string, s *mrpc.Service, opts ...func(*Proxy) error) (*Proxy, error) {
 	if s == nil {
 		return nil, ErrNoService
 	}
-	r := httprouter.New()
+	ctx, cancel := context.WithCancel(context.Background())
 	pxy := &Proxy{
-		http:        &http.Server{Addr: addr, Handler: r},
+		http: &http.Server{
+			Addr:        addr,
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		},
 		MRPCService: s,
 		Timeout:     defaultTimeout,
 
 		GetID: func() string { return "" },
 
-		router: r,
+		ctx:    ctx,
+		cancel: cancel,
 
 		Debugger: defaultDebugger,
 		Logger:   defaultLogger,
 		Requests: defaultRequests,
 	}
 
+	pxy.http.Handler = pxy
+	pxy.router = pxy.makeRouter()
+
 	for _, opt := range opts {
 		if err := opt(pxy); err != nil {
 			return nil, FuncOptsError{err}
@@ -106,67 +325,399 @@ func New(addr string, s *mrpc.Service, opts ...func(*Proxy) error) (*Proxy, erro
 	return pxy, nil
 }
 
-// Handle adds endpoints to the proxy.
+// Handle adds endpoints to the proxy. Versioned endpoints are also added
+// under the prefix of each version, see Endpoint.APIVersions. None are added
+// when one is invalid, duplicated or conflicts with the routes, reported as
+// an EndpointError.
 func (pxy *Proxy) Handle(eps ...Endpoint) error {
-	pxy.Eps = append(pxy.Eps, eps...)
-	for _, ep := range eps {
-		h, err := pxy.getTopicHandler(ep)
+	eps = expandAPIVersions(eps)
+	if err := pxy.validateEndpoints(eps); err != nil {
+		return err
+	}
+
+	// The handlers are built before any endpoint is added, so that a failing
+	// one leaves the proxy unchanged.
+	hs := make([]httprouter.Handle, len(eps))
+	for i, ep := range eps {
+		h, err := pxy.endpointHandler(ep)
 		if err != nil {
 			return err
 		}
-		pxy.router.Handle(ep.Method, ep.Path, h)
+		hs[i] = h
+	}
+
+	pxy.Eps = append(pxy.Eps, eps...)
+	for i, ep := range eps {
+		pxy.addRoute(route{ep.Host, ep.Method, ep.Path, hs[i], true})
 	}
 
 	return nil
 }
 
+// endpointHandler returns the handler of ep with the proxy and endpoint
+// middleware applied.
+func (pxy *Proxy) endpointHandler(ep Endpoint) (httprouter.Handle, error) {
+	ep = pxy.prefixTopics(ep)
+
+	var h httprouter.Handle
+	var err error
+	switch {
+	case ep.Upstream != "":
+		h, err = pxy.upstreamHandler(ep)
+	case ep.LongPoll:
+		h, err = pxy.longPollHandler(ep)
+	default:
+		h, err = pxy.getTopicHandler(ep)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pxy.endpointMiddleware(ep, h)
+}
+
+// endpointMiddleware applies the proxy and endpoint middleware to h.
+func (pxy *Proxy) endpointMiddleware(ep Endpoint, h httprouter.Handle) (httprouter.Handle, error) {
+	var err error
+	if ep.Concurrency != nil {
+		if h, err = pxy.limitConcurrency(ep, h); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tenants and quotas are resolved after authentication, so that rejected
+	// requests don't count and resolvers see the claims.
+	h = pxy.wrap(ep, pxy.tenants(ep, pxy.enforceQuotas(ep, h)))
+	if ep.IPFilter != nil {
+		filter, err := pxy.IPFilter(*ep.IPFilter)
+		if err != nil {
+			return nil, err
+		}
+		h = filter(ep, h)
+	}
+
+	h = pxy.endpointSecurityHeaders(ep, pxy.deprecate(ep, pxy.localize(h)))
+
+	return pxy.requestIDs(pxy.runHooks(ep, pxy.timeRequests(ep, pxy.sample(ep, pxy.accessLog(ep, pxy.track(pxy.recoverPanics(ep, pxy.dumpRequests(h)))))))), nil
+}
+
+// EndpointHandler returns the handler of ep with the proxy and endpoint
+// middleware applied, without registering a route. Frontends other than HTTP
+// use it to apply the same controls as the proxy endpoints.
+func (pxy *Proxy) EndpointHandler(ep Endpoint) (http.Handler, error) {
+	h, err := pxy.endpointHandler(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, nil)
+	}), nil
+}
+
+// route is a handler registered on the routers.
+type route struct {
+	host     string
+	method   string
+	path     string
+	h        httprouter.Handle
+	endpoint bool
+}
+
+func (pxy *Proxy) addRoute(rt route) {
+	pxy.routes = append(pxy.routes, rt)
+	pxy.handleRoute(pxy.hostRouter(rt.host), rt)
+}
+
+// Use adds middleware applied to all endpoints registered after the call.
+func (pxy *Proxy) Use(mw ...Middleware) {
+	pxy.middleware = append(pxy.middleware, mw...)
+}
+
+// wrap applies the proxy and then the endpoint middleware to h. The first
+// registered middleware is the outermost one.
+func (pxy *Proxy) wrap(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	for i := len(ep.Middleware) - 1; i >= 0; i-- {
+		h = ep.Middleware[i](ep, h)
+	}
+	for i := len(pxy.middleware) - 1; i >= 0; i-- {
+		h = pxy.middleware[i](ep, h)
+	}
+	return h
+}
+
 // Serve starts the HTTP server.
 func (pxy *Proxy) Serve() error {
-	pxy.router.NotFound = &notFoundHandler{pxy.Requests}
+	pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+	if err := pxy.runStartHooks(); err != nil {
+		return err
+	}
+
+	for _, s := range pxy.servers {
+		go pxy.serveAux(s.name, s.srv)
+	}
+
+	listeners, err := pxy.listen()
+	if err != nil {
+		return err
+	}
+	if listeners != nil {
+		return pxy.serveListeners(listeners)
+	}
+
+	if pxy.certFile != "" || pxy.http.TLSConfig != nil {
+		return pxy.http.ListenAndServeTLS(pxy.certFile, pxy.keyFile)
+	}
+
+	return pxy.http.ListenAndServe()
+}
+
+type auxServer struct {
+	name string
+	srv  *http.Server
+}
+
+// WithServer serves srv on its own address alongside the proxy, e.g. for
+// internal endpoints that must not be reachable through the proxy address.
+// Serve starts it and Shutdown closes it.
+func WithServer(name string, srv *http.Server) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.servers = append(pxy.servers, auxServer{name, srv})
+		pxy.OnShutdown(func() { srv.Close() })
+		return nil
+	}
+}
+
+// serveAux serves a listener started alongside the proxy.
+func (pxy *Proxy) serveAux(name string, srv *http.Server) {
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		pxy.Logger.Printf("%v: %v", name, err)
+	}
+}
 
-	for _, ep := range pxy.Eps {
-		if ep.Method == "OPTIONS" {
+// finishRouters sets the 404 and 405 handlers and adds the default OPTIONS
+// handlers of the endpoints.
+func (pxy *Proxy) finishRouters(router Router, hosts map[string]Router, hostRouter func(string) Router, eps []Endpoint) {
+	notFound, methodNotAllowed := pxy.notFoundHandler(), pxy.methodNotAllowedHandler()
+	pxy.routesMu.Lock()
+	pxy.notFound, pxy.methodNotAllowed = notFound, methodNotAllowed
+	pxy.routesMu.Unlock()
+
+	disabled := map[string]bool{}
+	for _, ep := range eps {
+		if ep.DisableOptions {
+			disabled[strings.ToLower(ep.Host)+ep.Path] = true
+		}
+	}
+
+	for _, ep := range eps {
+		if ep.Method == "OPTIONS" || disabled[strings.ToLower(ep.Host)+ep.Path] {
 			continue
 		}
 
-		h, _, _ := pxy.router.Lookup("OPTIONS", ep.Path)
+		r := hostRouter(ep.Host)
+		h, _, _ := r.Lookup("OPTIONS", ep.Path)
 		if h == nil {
-			pxy.router.Handle("OPTIONS", ep.Path, pxy.defaultOptionsHandler)
+			pxy.handleRoute(r, route{ep.Host, "OPTIONS", ep.Path, pxy.defaultOptionsHandler, false})
 		}
 	}
-
-	return pxy.http.ListenAndServe()
 }
 
-// Stop shutdowns the HTTP server
+// Stop shutdowns the HTTP server. See Shutdown.
 func (pxy *Proxy) Stop(ctx context.Context) error {
-	return pxy.http.Shutdown(ctx)
+	_, err := pxy.Shutdown(ctx)
+	return err
 }
 
 func (pxy *Proxy) getTopicHandler(ep Endpoint) (httprouter.Handle, error) {
-	topicTmpl, err := template.New("topic").Parse(ep.Topic)
+	topicTmpl, err := parseTopic(ep.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMerge(ep.Merge); err != nil {
+		return nil, err
+	}
+
+	topicTmpls, err := parseTopics(ep.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackTmpls, err := parseTopics(ep.Fallbacks)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowTmpl, err := parseTopic(ep.Shadow)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := parseAPIVersions(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	var canaryTmpl *template.Template
+	var canaries *canaryCounters
+	if ep.Canary != nil {
+		if canaryTmpl, err = parseTopic(ep.Canary.Topic); err != nil {
+			return nil, err
+		}
+		canaries = pxy.canaryCounters(ep)
+	}
+
+	ep.schemas, err = compileSchemas(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.versions, err = compileVersions(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.encoding, err = endpointEncoding(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.service, err = pxy.endpointService(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ep.ResponseHeaders.validate(); err != nil {
+		return nil, err
+	}
+
+	ep.injected, err = pxy.injectedHeaders(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.timeoutBody, err = parseTimeoutBody(ep.TimeoutBody)
 	if err != nil {
 		return nil, err
 	}
 
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		r = pxy.withRequestID(w, r)
+		id := RequestIDFromContext(r.Context())
+
+		// The topic is resolved per request.
+		ep := ep
+
 		var err error
-		ep.Topic, err = getTopic(topicTmpl, p)
+		tmpl := topicTmpl
+		if versions != nil {
+			if tmpl, err = versions.topic(w, r); err != nil {
+				pxy.logEndpointRequest(r, http.StatusBadRequest, ep.Topic, id)
+				pxy.writeError(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		tp := p
+		if len(ep.TopicFields) > 0 {
+			fields, err := topicFieldParams(r, ep.TopicFields)
+			if err != nil {
+				status := pxy.requestErrorStatus(err)
+				pxy.logEndpointRequest(r, status, ep.Topic, id)
+				pxy.writeError(w, r, status, err)
+				return
+			}
+			tp = append(fields, p...)
+		}
+
+		canary := canaryTmpl != nil && useCanary(r, ep.Canary)
+		if canary {
+			ep.Topic, err = getTopic(canaryTmpl, tp)
+		} else {
+			ep.Topic, err = getTopic(tmpl, tp)
+		}
+		if err == nil && len(topicTmpls) > 0 {
+			ep.Topics, err = getTopics(topicTmpls, tp)
+			ep.Topic = strings.Join(ep.Topics, ",")
+		}
+		if err == nil && len(fallbackTmpls) > 0 {
+			ep.Fallbacks, err = getTopics(fallbackTmpls, tp)
+		}
+		if err == nil && ep.Shadow != "" {
+			ep.Shadow, err = getTopic(shadowTmpl, tp)
+		}
+		if errors.Is(err, ErrInvalidTopicParam) {
+			pxy.logEndpointRequest(r, http.StatusBadRequest, ep.Topic, id)
+			pxy.writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
 		if err != nil {
-			pxy.Debugger.Println(err)
-			pxy.Requests.Printf("%v:%v, status: %v, topic: %v", r.Method, r.URL.Path, http.StatusInternalServerError, ep.Topic)
-			w.WriteHeader(http.StatusInternalServerError)
+			printlnCtx(r.Context(), pxy.Debugger, err)
+			pxy.logEndpointRequest(r, http.StatusInternalServerError, ep.Topic, id)
+			pxy.writeError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		res, err := pxy.mrpcRequest(r, p, ep)
+		if ep.versions != nil {
+			w.Header().Add("Vary", AcceptVersionHeader)
+			ep.version, ep.schemas, err = ep.versions.negotiate(r.Header.Get(AcceptVersionHeader))
+			if err != nil {
+				pxy.logEndpointRequest(r, http.StatusNotAcceptable, ep.Topic, id)
+				pxy.writeError(w, r, http.StatusNotAcceptable, err)
+				return
+			}
+		}
+
+		if status, err := pxy.authorize(r, ep); err != nil {
+			if status != http.StatusForbidden {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+			}
+			pxy.logEndpointRequest(r, status, ep.Topic, id)
+			pxy.writeError(w, r, status, err)
+			return
+		}
+
+		res, err := pxy.idempotentMRPCRequest(r, p, ep)
+		if canaries != nil {
+			canaries.record(canary, res, err)
+		}
+		if err == nil && res.Timeout {
+			err = pxy.renderTimeout(res, ep, pxy.timeout(r, ep))
+		}
+		if err == nil && pxy.ResponseTransformer != nil {
+			start := time.Now()
+			res, err = pxy.ResponseTransformer(r, res)
+			addTiming(r.Context(), phaseTransform, time.Since(start))
+		}
 		if err != nil {
-			pxy.Debugger.Println(err)
-			pxy.Requests.Printf("%v:%v, status: %v, topic: %v", r.Method, r.URL.Path, http.StatusInternalServerError, ep.Topic)
-			w.WriteHeader(http.StatusInternalServerError)
+			status := pxy.requestErrorStatus(err)
+			if status != StatusClientClosedRequest {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+			}
+			pxy.logEndpointRequest(r, status, ep.Topic, id)
+			if status == StatusClientClosedRequest {
+				recordStatus(w, status)
+				return
+			}
+			pxy.writeError(w, r, status, err)
 			return
 		}
 
+		res = notModified(r, res)
+
+		var fetched *FetchedBody
+		if res.BodyRef != "" {
+			if fetched, err = pxy.fetchBody(r, res.BodyRef); err != nil {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+				pxy.logEndpointRequest(r, http.StatusBadGateway, ep.Topic, res.RequestID)
+				pxy.writeError(w, r, http.StatusBadGateway, err)
+				return
+			}
+			defer fetched.Close()
+		}
+
 		// Set default headers
 		pxy.setHeaders(w)
 
@@ -177,20 +728,86 @@ func (pxy *Proxy) getTopicHandler(ep Endpoint) (httprouter.Handle, error) {
 			}
 		}
 
-		pxy.Requests.Printf("%v:%v, status: %v, topic: %v, Id: %v", r.Method, r.URL.Path, res.Code, ep.Topic, res.RequestID)
+		setLastModified(w, res)
+
+		for _, c := range res.Cookies {
+			http.SetCookie(w, c)
+		}
+
+		if res.Location != "" && res.Code >= 300 && res.Code < 400 {
+			loc, err := pxy.resolveLocation(r, res.Location)
+			if err != nil {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+			} else {
+				w.Header().Set("Location", loc)
+			}
+		}
+
+		ep.ResponseHeaders.apply(w.Header())
+		setContentLanguage(w, r)
+		pxy.setServerTiming(w, r)
+
+		pxy.logEndpointRequest(r, res.Code, ep.Topic, res.RequestID)
 
 		// Run custom handler
 		if pxy.Handler != nil {
 			pxy.Handler(w, r, res)
 		}
 
+		if res.Error != nil {
+			pxy.writeBackendError(w, r, res)
+			return
+		}
+
+		// Referenced bodies are streamed as they are.
+		if fetched != nil {
+			pxy.streamBody(w, r, res.Code, fetched)
+			return
+		}
+
+		if res.Next != "" {
+			pxy.writeParts(w, r, p, ep, res)
+			return
+		}
+
+		if len(res.Msg) == 0 && res.Code >= http.StatusBadRequest && pxy.ErrorRenderer != nil {
+			pxy.writeError(w, r, res.Code, errors.New(http.StatusText(res.Code)))
+			return
+		}
+
+		body := pxy.compress(w, r, res.Code, pxy.negotiate(w, r, res.Msg))
+
 		w.WriteHeader(res.Code)
-		if _, err := w.Write(res.Msg); err != nil {
-			pxy.Logger.Printf("writing to http.ResponseWriter failed: %v", err)
+		if _, err := w.Write(body); err != nil {
+			printfCtx(r.Context(), pxy.Logger, "writing to http.ResponseWriter failed: %v", err)
 		}
 	}, nil
 }
 
+// parseTopics parses a list of topic templates.
+func parseTopics(topics []string) ([]*template.Template, error) {
+	tmpls := make([]*template.Template, len(topics))
+	for i, topic := range topics {
+		var err error
+		if tmpls[i], err = parseTopic(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpls, nil
+}
+
+func getTopics(tmpls []*template.Template, p httprouter.Params) ([]string, error) {
+	topics := make([]string, len(tmpls))
+	for i, t := range tmpls {
+		var err error
+		if topics[i], err = getTopic(t, p); err != nil {
+			return nil, err
+		}
+	}
+	return topics, nil
+}
+
 func getTopic(t *template.Template, p httprouter.Params) (string, error) {
 	params := map[string]string{}
 	for _, p := range p {
@@ -210,37 +827,106 @@ func getTopic(t *template.Template, p httprouter.Params) (string, error) {
 	return string(topic), nil
 }
 
-func (pxy *Proxy) mrpcRequest(r *http.Request, p httprouter.Params, ep Endpoint) (*mrpcproxy.Response, error) {
-	req, err := pxy.newRequestFromHTTP(r, p, ep)
-	if err != nil {
-		return nil, err
+func (pxy *Proxy) mrpcRequest(r *http.Request, p httprouter.Params, ep Endpoint) (res *mrpcproxy.Response, err error) {
+	if ep.service != nil && ep.service != pxy.MRPCService {
+		r = r.WithContext(withService(r.Context(), ep.service))
+	}
+	if ep.RawBody {
+		return pxy.rawRequest(r, ep)
+	}
+	if ep.encoding != nil {
+		r = r.WithContext(withEncoding(r.Context(), ep.encoding))
 	}
 
-	mrpcReq, err := json.Marshal(req)
+	pr, err := pxy.newRequestFromHTTP(r, p, ep)
 	if err != nil {
 		return nil, err
 	}
+	req := &pr.Request
+
+	// The request is reused unless a shadow or first-wins fan-out call may
+	// still hold it.
+	if ep.Shadow == "" && ep.Merge != MergeFirst {
+		defer releaseRequest(pr)
+	}
+
+	// Uploads are removed when the request never reached the service.
+	defer func() {
+		if err != nil {
+			pxy.removeFiles(r.Context(), req.Files)
+		}
+	}()
+
+	printfCtx(r.Context(), pxy.Logger, "%v:%v, remote Addr: %v, Id: %v", r.Method, r.URL.Path, req.IPAddress, req.RequestID)
 
-	setTimeout := pxy.Timeout
-	if ep.KeepAlive > 0 {
-		setTimeout = time.Duration(ep.KeepAlive) * time.Millisecond
+	timeout := pxy.timeout(r, ep)
+	if ep.Shadow != "" {
+		go pxy.shadow(pxy.tenantTopic(ep.Shadow, req.Tenant), *req, timeout)
 	}
 
-	pxy.Logger.Printf("%v:%v, remote Addr: %v, Id: %v", r.Method, r.URL.Path, req.IPAddress, req.RequestID)
+	if len(ep.Topics) > 0 {
+		return pxy.fanOut(r.Context(), req, ep, timeout)
+	}
 
-	res := &mrpcproxy.Response{RequestID: req.RequestID}
-	ctx, cancel := context.WithTimeout(r.Context(), setTimeout)
+	res, err = pxy.Call(r.Context(), pxy.tenantTopic(ep.Topic, req.Tenant), req, timeout)
+	for _, topic := range ep.Fallbacks {
+		if !shouldFallback(res, err) {
+			break
+		}
+
+		printfCtx(r.Context(), pxy.Logger, "%v:%v, fallback topic: %v, Id: %v", r.Method, r.URL.Path, topic, req.RequestID)
+		req.Topic = topic
+		res, err = pxy.Call(r.Context(), pxy.tenantTopic(topic, req.Tenant), req, timeout)
+	}
+
+	return res, err
+}
+
+// Call sends req over MRPC to topic and waits for the response up to timeout,
+// sent to the service as Request.Deadline. Calls timing out return a response
+// with Timeout set and status 504, see WithTimeoutResponse. Calls shed by
+// WithThrottling or made while the transport is down fail with 503.
+func (pxy *Proxy) Call(ctx context.Context, topic string, req *mrpcproxy.Request, timeout time.Duration) (res *mrpcproxy.Response, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	resBytes, err := pxy.MRPCService.Request(ctx, ep.Topic, mrpcReq)
+
+	// The services may stop working on the request at the deadline.
+	deadline, _ := ctx.Deadline()
+	req.Deadline = deadline.UnixNano()
+
+	if pxy.transportDown() {
+		return nil, StatusError{http.StatusServiceUnavailable, ErrTransportDown}
+	}
+
+	if pxy.throttle != nil {
+		if !pxy.throttle.allow(topic) {
+			return nil, StatusError{http.StatusServiceUnavailable, ErrThrottled}
+		}
+		defer func() { pxy.throttle.record(topic, res, err) }()
+	}
+
+	mrpcReq, err := pxy.marshalRequest(ctx, topic, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if pxy.recorder != nil {
+		start := time.Now()
+		defer func() { pxy.record(ctx, topic, start, req, res, err) }()
+	}
+
+	res = &mrpcproxy.Response{RequestID: req.RequestID}
+	start := time.Now()
+	resBytes, err := pxy.mrpcService(ctx).Request(ctx, topic, mrpcReq)
+	addTiming(ctx, phaseMRPC, time.Since(start))
 	if err != nil {
-		if err == context.DeadlineExceeded {
-			res.Code = http.StatusRequestTimeout
-			return res, nil
+		if errors.Is(err, context.DeadlineExceeded) {
+			return pxy.timeoutResponse(req.RequestID), nil
 		}
 		return nil, err
 	}
 
-	if err := json.Unmarshal(resBytes, res); err != nil {
+	if err := pxy.unmarshalResponse(ctx, topic, resBytes, res); err != nil {
 		return nil, ResponseError{err}
 	}
 
@@ -249,6 +935,7 @@ func (pxy *Proxy) mrpcRequest(r *http.Request, p httprouter.Params, ep Endpoint)
 }
 
 func (pxy *Proxy) defaultOptionsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	w.Header().Set("Allow", pxy.allowedMethods(r))
 	pxy.setHeaders(w)
 
 	// Run custom handler
@@ -256,45 +943,142 @@ func (pxy *Proxy) defaultOptionsHandler(w http.ResponseWriter, r *http.Request,
 		pxy.Handler(w, r, nil)
 	}
 
-	pxy.Requests.Printf("%v:%v, status: %v", r.Method, r.URL.Path, http.StatusOK)
+	pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusOK)
 }
 
 func (pxy *Proxy) setHeaders(w http.ResponseWriter) {
+	pxy.configMu.RLock()
+	defer pxy.configMu.RUnlock()
+
 	for header, value := range pxy.Headers {
 		w.Header().Set(header, value)
 	}
 }
 
-func (pxy *Proxy) newRequestFromHTTP(r *http.Request, p httprouter.Params, ep Endpoint) (*mrpcproxy.Request, error) {
-	req := pxy.newRequest(ep.Topic, ep.Method)
+// newRequestFromHTTP returns a pooled MRPC request of r, released by the
+// caller when sent.
+func (pxy *Proxy) newRequestFromHTTP(r *http.Request, p httprouter.Params, ep Endpoint) (*pooledRequest, error) {
+	pr := getRequest()
+	req := &pr.Request
+	pxy.initRequest(req, RequestIDFromContext(r.Context()), ep.Topic, ep.Method)
+	if err := pxy.setRequestParams(req, r, p); err != nil {
+		releaseRequest(pr)
+		return nil, err
+	}
+	req.SchemaVersion = ep.version
+	req.Head = r.Method == http.MethodHead
 
-	if r.Body != nil {
-		var err error
-		req.Msg, err = ioutil.ReadAll(r.Body)
+	start := time.Now()
+	if ep.Multipart {
+		if err := pxy.readMultipart(r, req); err != nil {
+			releaseRequest(pr)
+			return nil, err
+		}
+	} else if ep.Form && isForm(r) {
+		if err := readForm(r, req); err != nil {
+			releaseRequest(pr)
+			return nil, err
+		}
+	} else if r.Body != nil {
+		if _, err := pr.body.ReadFrom(r.Body); err != nil {
+			releaseRequest(pr)
+			return nil, err
+		}
+		req.Msg = pr.body.Bytes()
+		if req.Msg == nil {
+			req.Msg = []byte{}
+		}
+	}
+	addTiming(r.Context(), phaseRead, time.Since(start))
+
+	if err := ep.schemas.validate(r.URL.Query(), req.Msg); err != nil {
+		pxy.removeFiles(r.Context(), req.Files)
+		releaseRequest(pr)
+		return nil, err
+	}
+
+	req.Headers = injectHeaders(pxy.forwardHeaders(r.Header, ep), ep)
+	req.Claims = claimsFromContext(r.Context())
+	req.ClientCert = clientCert(r)
+	req.Cookies = r.Cookies()
+	req.Tenant = TenantFromContext(r.Context())
+	req.Meta = pxy.requestMeta(r)
+
+	req.IPAddress = pxy.clientIP(r)
+
+	if pxy.RequestTransformer != nil {
+		start := time.Now()
+		err := pxy.RequestTransformer(r, req)
+		addTiming(r.Context(), phaseTransform, time.Since(start))
 		if err != nil {
+			pxy.removeFiles(r.Context(), req.Files)
+			releaseRequest(pr)
 			return nil, err
 		}
 	}
 
-	req.Params = mergeRequestParams(r, p)
-	req.Headers = r.Header
+	return pr, nil
+}
+
+// NewRequest creates an MRPC request for topic with a new request ID, for
+// frontends other than HTTP sending requests with Call.
+func (pxy *Proxy) NewRequest(topic, action string) *mrpcproxy.Request {
+	return pxy.newRequest(pxy.GetID(), topic, action)
+}
+
+func (pxy *Proxy) newRequest(id, topic, action string) *mrpcproxy.Request {
+	req := &mrpcproxy.Request{}
+	pxy.initRequest(req, id, topic, action)
+	return req
+}
+
+func (pxy *Proxy) initRequest(req *mrpcproxy.Request, id, topic, action string) {
+	req.RequestID = id
+	req.Timestamp = time.Now().UnixNano()
+	req.Hops = 1
+	req.Topic = topic
+	req.Action = action
+}
+
+// resolveLocation resolves a redirect location against the proxy base URL or
+// the request URL.
+func (pxy *Proxy) resolveLocation(r *http.Request, location string) (string, error) {
+	loc, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	if loc.IsAbs() {
+		return loc.String(), nil
+	}
 
-	req.IPAddress = r.Header.Get("X-Forwarded-For")
-	if req.IPAddress == "" {
-		req.IPAddress = strings.Split(strings.Split(r.RemoteAddr, ":")[0], "/")[0]
+	if pxy.BaseURL != nil {
+		return pxy.BaseURL.ResolveReference(loc).String(), nil
 	}
 
-	return req, nil
+	return r.URL.ResolveReference(loc).String(), nil
 }
 
-func (pxy *Proxy) newRequest(topic, action string) *mrpcproxy.Request {
-	return &mrpcproxy.Request{
-		RequestID: pxy.GetID(),
-		Timestamp: time.Now().UnixNano(),
-		Hops:      1,
-		Topic:     topic,
-		Action:    action,
+// forwardHeaders returns the subset of h allowed for ep.
+func (pxy *Proxy) forwardHeaders(h http.Header, ep Endpoint) http.Header {
+	allowed := ep.ForwardHeaders
+	if allowed == nil {
+		pxy.configMu.RLock()
+		allowed = pxy.ForwardHeaders
+		pxy.configMu.RUnlock()
+	}
+	if allowed == nil {
+		return h
+	}
+
+	fwd := http.Header{}
+	for _, name := range allowed {
+		if vs, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			fwd[http.CanonicalHeaderKey(name)] = vs
+		}
 	}
+
+	return fwd
 }
 
 // mergeRequestParams request with path parameters.
@@ -307,11 +1091,45 @@ func mergeRequestParams(r *http.Request, p httprouter.Params) url.Values {
 	return params
 }
 
+// notFoundHandler returns the handler for requests not matching any route.
+func (pxy *Proxy) notFoundHandler() http.Handler {
+	var h http.Handler = &notFoundHandler{pxy}
+	if pxy.NotFound != nil {
+		h = pxy.NotFound
+	}
+	if pxy.spaDir != "" {
+		h = &spaHandler{
+			files:    pxy.logStatic(spaFiles(pxy.spaDir)),
+			notFound: h,
+		}
+	}
+
+	return pxy.accessLogHandler(h, "")
+}
+
 type notFoundHandler struct {
-	Requests logger
+	pxy *Proxy
 }
 
 func (h *notFoundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.Requests.Printf("%v:%v, status: %v", r.Method, r.URL.Path, http.StatusNotFound)
-	w.WriteHeader(http.StatusNotFound)
+	h.pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusNotFound)
+	h.pxy.writeError(w, r, http.StatusNotFound, ErrNotFound)
+}
+
+func (pxy *Proxy) methodNotAllowedHandler() http.Handler {
+	h := pxy.MethodNotAllowed
+	if h == nil {
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, http.StatusMethodNotAllowed)
+			pxy.writeError(w, r, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		})
+	}
+
+	// The Allow header of the router misses the HEAD of GET routes.
+	allow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", pxy.allowedMethods(r))
+		h.ServeHTTP(w, r)
+	})
+
+	return pxy.accessLogHandler(allow, "")
 }
@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -12,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -215,6 +217,21 @@ func TestGetTopicHandler(t *testing.T) {
 	service.HandleFunc("e", func(w mrpc.TopicWriter, data []byte) {
 		w.Write([]byte("MRPC response that is not mrpcproxy.Response formatted"))
 	})
+	service.HandleFunc("k", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+
+		msg, _ := json.Marshal(&mrpcproxy.Response{
+			Code:    200,
+			Cookies: []*http.Cookie{{Name: "echo", Value: req.Cookies[0].Value, HttpOnly: true}},
+		})
+
+		w.Write(msg)
+	})
+	service.HandleFunc("r", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: http.StatusFound, Location: "/k"})
+		w.Write(msg)
+	})
 	service.HandleFunc("w.1", func(w mrpc.TopicWriter, data []byte) {
 		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("w.1")})
 		w.Write(msg)
@@ -262,7 +279,7 @@ func TestGetTopicHandler(t *testing.T) {
 		},
 		{
 			topic:    "a",
-			logger:   []string{"GET:/a, remote Addr: 2.2.2.2, Id: uuid"},
+			logger:   []string{"GET:/a, remote Addr: 1.1.1.1, Id: uuid"},
 			requests: []string{"GET:/a, status: 200, topic: service.a, Id: uuid"},
 			reqHeaders: map[string][]string{
 				"X-Forwarded-For": {"2.2.2.2"},
@@ -272,15 +289,15 @@ func TestGetTopicHandler(t *testing.T) {
 			resHeaders: map[string][]string{
 				"X-Test-Handler-Header": {"OK"},
 				"X-Test-Header":         {"OK"},
-				"X-Test-Ip":             {"2.2.2.2"},
+				"X-Test-Ip":             {"1.1.1.1"},
 			},
 		},
 		{
 			topic:     "b",
 			timeout:   1,
 			logger:    []string{"GET:/b, remote Addr: 1.1.1.1, Id: uuid"},
-			requests:  []string{"GET:/b, status: 408, topic: service.b, Id: uuid"},
-			resStatus: http.StatusRequestTimeout,
+			requests:  []string{"GET:/b, status: 504, topic: service.b, Id: uuid"},
+			resStatus: http.StatusGatewayTimeout,
 			resHeaders: map[string][]string{
 				"X-Test-Handler-Header": {"OK"},
 			},
@@ -289,8 +306,8 @@ func TestGetTopicHandler(t *testing.T) {
 			topic:     "c",
 			timeout:   1,
 			logger:    []string{"GET:/c, remote Addr: 1.1.1.1, Id: uuid"},
-			requests:  []string{"GET:/c, status: 408, topic: service.c, Id: uuid"},
-			resStatus: http.StatusRequestTimeout,
+			requests:  []string{"GET:/c, status: 504, topic: service.c, Id: uuid"},
+			resStatus: http.StatusGatewayTimeout,
 			resHeaders: map[string][]string{
 				"X-Test-Handler-Header": {"OK"},
 			},
@@ -310,7 +327,7 @@ func TestGetTopicHandler(t *testing.T) {
 		{
 			topic:      "a",
 			debugger:   []string{"Request body read error\n"},
-			requests:   []string{"GET:/a, status: 500, topic: service.a"},
+			requests:   []string{"GET:/a, status: 500, topic: service.a, Id: uuid"},
 			reqBody:    &MockReader{err: errors.New("Request body read error")},
 			resStatus:  http.StatusInternalServerError,
 			resHeaders: map[string][]string{},
@@ -319,10 +336,33 @@ func TestGetTopicHandler(t *testing.T) {
 			topic:      "e",
 			debugger:   []string{"Malformed mrpcproxy Response: invalid character 'M' looking for beginning of value\n"},
 			logger:     []string{"GET:/e, remote Addr: 1.1.1.1, Id: uuid"},
-			requests:   []string{"GET:/e, status: 500, topic: service.e"},
+			requests:   []string{"GET:/e, status: 500, topic: service.e, Id: uuid"},
 			resStatus:  http.StatusInternalServerError,
 			resHeaders: map[string][]string{},
 		},
+		{
+			topic:    "k",
+			logger:   []string{"GET:/k, remote Addr: 1.1.1.1, Id: uuid"},
+			requests: []string{"GET:/k, status: 200, topic: service.k, Id: uuid"},
+			reqHeaders: map[string][]string{
+				"Cookie": {"session=abc"},
+			},
+			resStatus: http.StatusOK,
+			resHeaders: map[string][]string{
+				"X-Test-Handler-Header": {"OK"},
+				"Set-Cookie":            {"echo=abc; HttpOnly"},
+			},
+		},
+		{
+			topic:     "r",
+			logger:    []string{"GET:/r, remote Addr: 1.1.1.1, Id: uuid"},
+			requests:  []string{"GET:/r, status: 302, topic: service.r, Id: uuid"},
+			resStatus: http.StatusFound,
+			resHeaders: map[string][]string{
+				"X-Test-Handler-Header": {"OK"},
+				"Location":              {"/k"},
+			},
+		},
 		{
 			topic:      "w.{{.id}}",
 			pattern:    "/w/:id",
@@ -448,14 +488,19 @@ func TestGetTopicHandler(t *testing.T) {
 }
 
 type MockLogger struct {
+	mu      sync.Mutex
 	storage []string
 }
 
 func (l *MockLogger) Println(v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.storage = append(l.storage, fmt.Sprintln(v...))
 }
 
 func (l *MockLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.storage = append(l.storage, fmt.Sprintf(format, v...))
 }
 
@@ -631,3 +676,522 @@ func (r *MockReader) Read(p []byte) (n int, err error) {
 	p = r.p
 	return r.n, r.err
 }
+
+func TestForwardHeaders(t *testing.T) {
+	h := http.Header{
+		"Authorization": {"Bearer token"},
+		"X-Custom":      {"a", "b"},
+		"Cookie":        {"c=1"},
+	}
+
+	cases := []struct {
+		global   []string
+		endpoint []string
+		fwd      http.Header
+	}{
+		{nil, nil, h},
+		{[]string{"authorization"}, nil, http.Header{"Authorization": {"Bearer token"}}},
+		{[]string{"Authorization"}, []string{"x-custom", "X-Missing"}, http.Header{"X-Custom": {"a", "b"}}},
+		{[]string{"Authorization"}, []string{}, http.Header{}},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, _ := New(":80", service)
+			pxy.ForwardHeaders = tc.global
+
+			fwd := pxy.forwardHeaders(h, Endpoint{ForwardHeaders: tc.endpoint})
+			if !reflect.DeepEqual(fwd, tc.fwd) {
+				t.Errorf("Unexpected headers: got %v want %v", fwd, tc.fwd)
+			}
+		})
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	base, _ := url.Parse("https://api.example.com/v1/")
+	cases := []struct {
+		base     *url.URL
+		reqURL   string
+		location string
+		resolved string
+	}{
+		{nil, "/users/1", "https://other.example.com/x", "https://other.example.com/x"},
+		{nil, "/users/1", "2", "/users/2"},
+		{nil, "/users/1", "/login", "/login"},
+		{base, "/users/1", "users/2", "https://api.example.com/v1/users/2"},
+		{base, "/users/1", "/login", "https://api.example.com/login"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, _ := New(":80", service)
+			pxy.BaseURL = tc.base
+
+			r, _ := http.NewRequest("GET", tc.reqURL, nil)
+			loc, err := pxy.resolveLocation(r, tc.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loc != tc.resolved {
+				t.Errorf("Unexpected location: got %v want %v", loc, tc.resolved)
+			}
+		})
+	}
+}
+
+func TestResponseTransformer(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(`{"id":1,"secret":"s"}`)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		transformer func(*http.Request, *mrpcproxy.Response) (*mrpcproxy.Response, error)
+		code        int
+		header      string
+		body        string
+	}{
+		{
+			func(r *http.Request, res *mrpcproxy.Response) (*mrpcproxy.Response, error) {
+				return &mrpcproxy.Response{
+					Code:    201,
+					Msg:     []byte(`{"data":{"id":1}}`),
+					Headers: http.Header{"X-Transformed": {"yes"}},
+				}, nil
+			},
+			201, "yes", `{"data":{"id":1}}`,
+		},
+		{
+			func(r *http.Request, res *mrpcproxy.Response) (*mrpcproxy.Response, error) {
+				return nil, StatusError{http.StatusBadGateway, errors.New("bad response")}
+			},
+			502, "", "",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.ResponseTransformer = tc.transformer
+			pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/a"})
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if h := w.Header().Get("X-Transformed"); h != tc.header {
+				t.Errorf("Unexpected header %q; expected %q", h, tc.header)
+			}
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestRequestTransformer(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(req.Params.Get("tenant"))})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		transformer func(*http.Request, *mrpcproxy.Request) error
+		code        int
+		body        string
+	}{
+		{
+			func(r *http.Request, req *mrpcproxy.Request) error {
+				req.Params.Set("tenant", "acme")
+				return nil
+			},
+			200, "acme",
+		},
+		{
+			func(r *http.Request, req *mrpcproxy.Request) error {
+				return StatusError{http.StatusForbidden, errors.New("unknown tenant")}
+			},
+			403, "",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.RequestTransformer = tc.transformer
+			pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/a"})
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(100 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	l := &MockLogger{}
+	pxy, _ := New(":80", service)
+	pxy.GetID = func() string { return "uuid" }
+	pxy.Timeout = time.Second
+	pxy.Logger = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Requests = l
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequest("GET", "/a", nil)
+	r = r.WithContext(ctx)
+	time.AfterFunc(5*time.Millisecond, cancel)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Request wasn't cancelled, took %v", d)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Unexpected body %q", w.Body.String())
+	}
+	expected := []string{"GET:/a, status: 499, topic: service.a, Id: uuid"}
+	if !reflect.DeepEqual(l.storage, expected) {
+		t.Errorf("Unexpected request log %v; expected %v", l.storage, expected)
+	}
+}
+
+func TestClientDisconnectAccessLog(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(100 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	l := &MockLogger{}
+	pxy, _ := New(":80", service, WithAccessLog(func(e *AccessLogEntry) string {
+		return fmt.Sprintf("%v %v", e.Path, e.Status)
+	}))
+	pxy.Logger = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Requests = l
+	pxy.Handle(Endpoint{Topic: "service.a", Method: "GET", Path: "/a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequest("GET", "/a", nil)
+	r = r.WithContext(ctx)
+	time.AfterFunc(5*time.Millisecond, cancel)
+	pxy.ServeHTTP(httptest.NewRecorder(), r)
+
+	expected := []string{"/a 499\n"}
+	if !reflect.DeepEqual(l.storage, expected) {
+		t.Errorf("Unexpected access log %v; expected %v", l.storage, expected)
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	respond := func(code int, delay time.Duration) func(mrpc.TopicWriter, []byte) {
+		return func(w mrpc.TopicWriter, data []byte) {
+			time.Sleep(delay)
+			req := &mrpcproxy.Request{}
+			json.Unmarshal(data, req)
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: code, Msg: []byte(req.Topic)})
+			w.Write(msg)
+		}
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("ok", respond(200, 0))
+	service.HandleFunc("slow", respond(200, 50*time.Millisecond))
+	service.HandleFunc("fail", respond(500, 0))
+	service.HandleFunc("missing", respond(404, 0))
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		topic     string
+		fallbacks []string
+		code      int
+		body      string
+		logger    []string
+	}{
+		{"v2.slow", []string{"v1.ok"}, 200, "v1.ok", []string{"GET:/a, fallback topic: v1.ok, Id: uuid"}},
+		{"v2.fail", []string{"v1.fail", "v0.ok"}, 200, "v0.ok", []string{
+			"GET:/a, fallback topic: v1.fail, Id: uuid",
+			"GET:/a, fallback topic: v0.ok, Id: uuid",
+		}},
+		{"v2.missing", []string{"v1.ok"}, 404, "v2.missing", nil},
+		{"v2.fail", []string{"v1.fail"}, 500, "v1.fail", []string{"GET:/a, fallback topic: v1.fail, Id: uuid"}},
+		{"v2.ok", []string{"v1.{{.name}}"}, 200, "v2.ok", nil},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			l := &MockLogger{}
+			pxy, _ := New(":80", service)
+			pxy.GetID = func() string { return "uuid" }
+			pxy.Timeout = 20 * time.Millisecond
+			pxy.Logger = l
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: tc.topic, Fallbacks: tc.fallbacks, Method: "GET", Path: "/:name"})
+
+			r, _ := http.NewRequest("GET", "/a", nil)
+			r.RemoteAddr = "1.1.1.1:1234"
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code %v; expected %v", w.Code, tc.code)
+			}
+			if body := w.Body.String(); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+			logger := append([]string{"GET:/a, remote Addr: 1.1.1.1, Id: uuid"}, tc.logger...)
+			if !reflect.DeepEqual(l.storage, logger) {
+				t.Errorf("Unexpected log %v; expected %v", l.storage, logger)
+			}
+		})
+	}
+}
+
+func TestShadow(t *testing.T) {
+	shadowed := make(chan *mrpcproxy.Request, 1)
+
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("primary")})
+		w.Write(msg)
+	})
+	service.HandleFunc("mirror", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		shadowed <- req
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 500, Msg: []byte("shadow")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.GetID = func() string { return "uuid" }
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(Endpoint{Topic: "v1.a", Shadow: "{{.name}}.mirror", Method: "POST", Path: "/:name"})
+
+	r, _ := http.NewRequest("POST", "/next", bytes.NewBufferString("body"))
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if w.Code != 200 || w.Body.String() != "primary" {
+		t.Errorf("Unexpected response %v %q", w.Code, w.Body.String())
+	}
+
+	select {
+	case req := <-shadowed:
+		if req.Topic != "next.mirror" || string(req.Msg) != "body" || req.RequestID != "uuid" {
+			t.Errorf("Unexpected shadow request %+v", req)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Request was not shadowed")
+	}
+}
+
+func TestNotFoundHandlers(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	custom := func(code int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, "custom %v", code)
+		})
+	}
+
+	cases := []struct {
+		notFound, methodNotAllowed http.Handler
+
+		method, host, path string
+		code               int
+		allow              string
+		body               string
+	}{
+		{nil, nil, "GET", "", "/a", 200, "", "a"},
+		{nil, nil, "POST", "", "/a", 405, "GET, HEAD, OPTIONS", ""},
+		{nil, nil, "GET", "", "/b", 404, "", ""},
+		{nil, nil, "DELETE", "api.example.com", "/h", 405, "OPTIONS, PUT", ""},
+		{nil, nil, "DELETE", "api.example.com", "/a", 405, "GET, HEAD, OPTIONS", ""},
+		{nil, nil, "GET", "other.example.com", "/h", 404, "", ""},
+		{custom(404), custom(405), "GET", "", "/b", 404, "", "custom 404"},
+		{custom(404), custom(405), "POST", "", "/a", 405, "GET, HEAD, OPTIONS", "custom 405"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Requests = &MockLogger{}
+			pxy.NotFound = tc.notFound
+			pxy.MethodNotAllowed = tc.methodNotAllowed
+			pxy.Handle(
+				Endpoint{Topic: "a", Method: "GET", Path: "/a"},
+				Endpoint{Topic: "a", Host: "api.example.com", Method: "PUT", Path: "/h"},
+			)
+			pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if allow := w.Header().Get("Allow"); allow != tc.allow {
+				t.Errorf("Unexpected Allow %q; expected %q", allow, tc.allow)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("Unexpected body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptionsAllow(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Topic: "a", Method: "GET", Path: "/a"},
+		Endpoint{Topic: "a", Method: "POST", Path: "/a"},
+		Endpoint{Topic: "a", Method: "DELETE", Path: "/items/:id"},
+		Endpoint{Topic: "a", Method: "PUT", Path: "/b", DisableOptions: true},
+		Endpoint{Topic: "a", Method: "GET", Path: "/b"},
+		Endpoint{Topic: "a", Host: "api.example.com", Method: "PATCH", Path: "/a"},
+	)
+	pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+	cases := []struct {
+		host  string
+		path  string
+		code  int
+		allow string
+	}{
+		{"", "/a", 200, "GET, HEAD, OPTIONS, POST"},
+		{"", "/items/1", 200, "DELETE, OPTIONS"},
+		{"", "/b", 405, "GET, HEAD, PUT"},
+		{"api.example.com", "/a", 200, "GET, HEAD, OPTIONS, PATCH, POST"},
+		{"", "/c", 404, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("OPTIONS", tc.path, nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if allow := w.Header().Get("Allow"); allow != tc.allow {
+				t.Errorf("Unexpected Allow %q; expected %q", allow, tc.allow)
+			}
+		})
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("deadline", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		ctx, cancel := req.Context(context.Background())
+		defer cancel()
+		deadline, _ := ctx.Deadline()
+		remaining <- time.Until(deadline)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+
+	cases := []struct {
+		ctxTimeout, timeout time.Duration
+		want                time.Duration
+	}{
+		{0, time.Second, time.Second},
+		{200 * time.Millisecond, time.Second, 200 * time.Millisecond},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			ctx := context.Background()
+			if tc.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.ctxTimeout)
+				defer cancel()
+			}
+
+			if _, err := pxy.Call(ctx, "deadline", pxy.NewRequest("deadline", "GET"), tc.timeout); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if d := <-remaining; d > tc.want || d < tc.want-100*time.Millisecond {
+				t.Errorf("Unexpected remaining time: got %v want %v", d, tc.want)
+			}
+		})
+	}
+}
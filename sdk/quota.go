@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrQuotaExceeded is returned for requests of clients over their quota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrNoQuotaStore is returned by WithQuotas without a store.
+	ErrNoQuotaStore = errors.New("no quota store")
+	// ErrNoClientResolver is returned by WithQuotas without a client
+	// resolver.
+	ErrNoClientResolver = errors.New("no client resolver")
+	// ErrNoQuotas is returned by QuotaUsage without WithQuotas.
+	ErrNoQuotas = errors.New("quotas not enabled")
+)
+
+// QuotaPeriod is the period after which the usage of a quota resets.
+type QuotaPeriod int
+
+const (
+	// QuotaDaily quotas reset at midnight UTC.
+	QuotaDaily QuotaPeriod = iota
+	// QuotaMonthly quotas reset on the first day of the month UTC.
+	QuotaMonthly
+)
+
+// Quota limits the requests of a client in each period.
+type Quota struct {
+	// Limit of the requests per period. Clients are not limited when it is
+	// 0.
+	Limit  int64       `json:"limit"`
+	Period QuotaPeriod `json:"period"`
+}
+
+// ClientResolver returns the client of r, e.g. its API key, or "" when it
+// has none. It is called after the middleware added with Use and the IP
+// filter, so only for requests they let through.
+type ClientResolver func(r *http.Request) string
+
+// ClientFromHeader resolves the client from the request header, e.g.
+// X-API-Key.
+func ClientFromHeader(header string) ClientResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// QuotaConfig configures the client quotas.
+type QuotaConfig struct {
+	// Store keeps the usage counters, shared by all proxy instances for
+	// quotas enforced across them.
+	Store Store
+	// Client resolves the client of each request. Requests without a client
+	// are not limited.
+	Client ClientResolver
+	// Quota of the clients without their own quota in Quotas, e.g. their
+	// plan limits.
+	Quota  Quota
+	Quotas map[string]Quota
+}
+
+// QuotaUsage is the usage of the quota of a client in the current period.
+type QuotaUsage struct {
+	Client    string    `json:"client"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+type quotas struct {
+	cfg QuotaConfig
+	now func() time.Time
+}
+
+// WithQuotas counts the endpoint requests of each client in the periods of
+// their quota and answers those over it with 429. The responses carry the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers,
+// the reset in seconds. Requests are let through when the store fails. The
+// admin API serves the usage of a client on /admin/quotas?client=<client>.
+func WithQuotas(cfg QuotaConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Store == nil {
+			return ErrNoQuotaStore
+		}
+		if cfg.Client == nil {
+			return ErrNoClientResolver
+		}
+		pxy.quotas = &quotas{cfg: cfg, now: time.Now}
+		return nil
+	}
+}
+
+// QuotaUsage returns the usage of the quota of client in the current period.
+func (pxy *Proxy) QuotaUsage(ctx context.Context, client string) (QuotaUsage, error) {
+	q := pxy.quotas
+	if q == nil {
+		return QuotaUsage{}, ErrNoQuotas
+	}
+
+	quota := q.quota(client)
+	key, reset := q.period(client, quota.Period)
+	usage := QuotaUsage{Client: client, Limit: quota.Limit, Remaining: quota.Limit, Reset: reset}
+
+	v, ok, err := q.cfg.Store.Get(ctx, key)
+	if err != nil || !ok {
+		return usage, err
+	}
+	if usage.Used, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+		return QuotaUsage{}, ErrNotCounter
+	}
+	usage.Remaining = remaining(quota.Limit, usage.Used)
+	return usage, nil
+}
+
+// enforceQuotas counts the requests to ep against the quota of their client.
+func (pxy *Proxy) enforceQuotas(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	q := pxy.quotas
+	if q == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		client := q.cfg.Client(r)
+		quota := q.quota(client)
+		if client == "" || quota.Limit == 0 {
+			h(w, r, p)
+			return
+		}
+
+		key, reset := q.period(client, quota.Period)
+		wait := reset.Sub(q.now())
+		used, err := q.cfg.Store.Incr(r.Context(), key, wait)
+		if err != nil {
+			printfCtx(r.Context(), pxy.Debugger, "quota of %v: %v", client, err)
+			h(w, r, p)
+			return
+		}
+
+		resetSeconds := strconv.Itoa(int((wait + time.Second - 1) / time.Second))
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(quota.Limit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining(quota.Limit, used), 10))
+		w.Header().Set("X-RateLimit-Reset", resetSeconds)
+		if used > quota.Limit {
+			pxy.logEndpointRequest(r, http.StatusTooManyRequests, ep.Topic, RequestIDFromContext(r.Context()))
+			w.Header().Set("Retry-After", resetSeconds)
+			pxy.writeError(w, r, http.StatusTooManyRequests, ErrQuotaExceeded)
+			return
+		}
+		h(w, r, p)
+	}
+}
+
+func (q *quotas) quota(client string) Quota {
+	if quota, ok := q.cfg.Quotas[client]; ok {
+		return quota
+	}
+	return q.cfg.Quota
+}
+
+// period returns the counter key of client in the current period and the
+// end of the period.
+func (q *quotas) period(client string, period QuotaPeriod) (string, time.Time) {
+	now := q.now().UTC()
+	if period == QuotaMonthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return "quota:" + client + ":" + start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return "quota:" + client + ":" + start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
+
+func remaining(limit, used int64) int64 {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+func (pxy *Proxy) adminQuota(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	client := r.URL.Query().Get("client")
+	if client == "" {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{"client required"})
+		return
+	}
+
+	usage, err := pxy.QuotaUsage(r.Context(), client)
+	switch {
+	case err == ErrNoQuotas:
+		writeAdminJSON(w, http.StatusNotFound, adminError{err.Error()})
+	case err != nil:
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{err.Error()})
+	default:
+		writeAdminJSON(w, http.StatusOK, usage)
+	}
+}
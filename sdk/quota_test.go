@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestQuotas(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("quota", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service,
+		WithAdminAPI(AdminConfig{Addr: ":0", Token: "secret"}),
+		WithQuotas(QuotaConfig{
+			Store:  NewMemoryStore(100),
+			Client: ClientFromHeader("X-API-Key"),
+			Quota:  Quota{Limit: 2},
+			Quotas: map[string]Quota{"pro": {Limit: 3, Period: QuotaMonthly}, "internal": {}},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 2, 28, 23, 59, 30, 0, time.UTC)
+	pxy.quotas.now = func() time.Time { return now }
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Method: "GET", Path: "/quota", Topic: "quota"})
+
+	cases := []struct {
+		client    string
+		advance   time.Duration
+		status    int
+		remaining string
+		reset     string
+	}{
+		{"free", 0, 200, "1", "30"},
+		{"free", 0, 200, "0", "30"},
+		{"free", 0, 429, "0", "30"},
+		{"free", time.Minute, 200, "1", "86370"},
+		{"pro", 0, 200, "2", "86370"},
+		{"pro", 0, 200, "1", "86370"},
+		{"pro", 0, 200, "0", "86370"},
+		{"pro", 0, 429, "0", "86370"},
+		{"internal", 0, 200, "", ""},
+		{"", 0, 200, "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			now = now.Add(tc.advance)
+			r, _ := http.NewRequest("GET", "/quota", nil)
+			if tc.client != "" {
+				r.Header.Set("X-API-Key", tc.client)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			h := w.Header()
+			if w.Code != tc.status || h.Get("X-RateLimit-Remaining") != tc.remaining || h.Get("X-RateLimit-Reset") != tc.reset {
+				t.Errorf("Unexpected response: got %v %v", w.Code, h)
+			}
+			if w.Code == 429 && h.Get("Retry-After") != tc.reset {
+				t.Errorf("Unexpected Retry-After: %q", h.Get("Retry-After"))
+			}
+		})
+	}
+
+	usage, err := pxy.QuotaUsage(context.Background(), "pro")
+	want := QuotaUsage{Client: "pro", Limit: 3, Used: 4, Remaining: 0, Reset: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if err != nil || usage != want {
+		t.Errorf("Unexpected usage: got %+v %v want %+v", usage, err, want)
+	}
+
+	r, _ := http.NewRequest("GET", "/admin/quotas?client=free", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	pxy.admin.http.Handler.ServeHTTP(w, r)
+	body := `{"client":"free","limit":2,"used":1,"remaining":1,"reset":"2024-03-01T00:00:00Z"}` + "\n"
+	if w.Code != 200 || w.Body.String() != body {
+		t.Errorf("Unexpected admin response: got %v %q", w.Code, w.Body.String())
+	}
+}
+
+func TestQuotaErrors(t *testing.T) {
+	cases := []struct {
+		cfg QuotaConfig
+		err error
+	}{
+		{QuotaConfig{Client: ClientFromHeader("X-API-Key")}, ErrNoQuotaStore},
+		{QuotaConfig{Store: NewMemoryStore(1)}, ErrNoClientResolver},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if _, err := New(":80", &mrpc.Service{}, WithQuotas(tc.cfg)); err != (FuncOptsError{tc.err}) {
+				t.Errorf("Unexpected error: got %v want %v", err, tc.err)
+			}
+		})
+	}
+
+	pxy, _ := New(":80", &mrpc.Service{})
+	if _, err := pxy.QuotaUsage(context.Background(), "a"); err != ErrNoQuotas {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestQuotasAfterAuthentication(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{}, WithQuotas(QuotaConfig{
+		Store:  NewMemoryStore(100),
+		Client: ClientFromHeader("X-API-Key"),
+		Quota:  Quota{Limit: 1},
+	}))
+	pxy.Requests = &MockLogger{}
+	pxy.Use(func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	pxy.Handle(Endpoint{Method: "GET", Path: "/quota", Topic: "quota"})
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/quota", nil)
+		r.Header.Set("X-API-Key", "free")
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected code %v", w.Code)
+		}
+	}
+
+	usage, err := pxy.QuotaUsage(context.Background(), "free")
+	if err != nil || usage.Used != 0 {
+		t.Errorf("Rejected requests counted: %+v %v", usage, err)
+	}
+}
@@ -0,0 +1,42 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// rawRequest sends the body of r as it is to the topic of ep and returns the
+// reply as the body of a 200 response. Replies timing out return the timeout
+// response of the proxy.
+func (pxy *Proxy) rawRequest(r *http.Request, ep Endpoint) (*mrpcproxy.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	id := RequestIDFromContext(r.Context())
+	printfCtx(r.Context(), pxy.Logger, "%v:%v, remote Addr: %v, Id: %v", r.Method, r.URL.Path, pxy.clientIP(r), id)
+
+	ctx, cancel := context.WithTimeout(r.Context(), pxy.timeout(r, ep))
+	defer cancel()
+
+	start := time.Now()
+	msg, err := pxy.mrpcService(ctx).Request(ctx, ep.Topic, body)
+	addTiming(ctx, phaseMRPC, time.Since(start))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return pxy.timeoutResponse(id), nil
+		}
+		return nil, err
+	}
+
+	return &mrpcproxy.Response{RequestID: id, Code: http.StatusOK, Msg: msg}, nil
+}
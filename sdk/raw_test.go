@@ -0,0 +1,55 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestRawBody(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("echo", func(w mrpc.TopicWriter, data []byte) {
+		w.Write(append([]byte("echo: "), data...))
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		topic string
+		body  string
+		code  int
+		res   string
+	}{
+		{"echo", "<legacy/>", 200, "echo: <legacy/>"},
+		{"echo", "", 200, "echo: "},
+		{"missing", "data", 504, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: tc.topic, Method: "POST", Path: "/raw", RawBody: true, KeepAlive: 20})
+
+			r, _ := http.NewRequest("POST", "/raw", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.res {
+				t.Errorf("Unexpected body %q", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+// redacted replaces the redacted values of recordings.
+const redacted = "[REDACTED]"
+
+// defaultRedactHeaders are always redacted from recordings.
+var defaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Recording is an MRPC request and its response captured by WithRecording.
+type Recording struct {
+	Time     time.Time           `json:"time"`
+	Topic    string              `json:"topic"`
+	Duration time.Duration       `json:"duration"`
+	Request  *mrpcproxy.Request  `json:"request"`
+	Response *mrpcproxy.Response `json:"response,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// RecordSink stores recordings. Record is called on the request path, so
+// slow sinks should buffer.
+type RecordSink interface {
+	Record(rec *Recording) error
+}
+
+// RecordConfig configures the redaction of recordings.
+type RecordConfig struct {
+	// RedactHeaders are redacted in addition to Authorization, Cookie and
+	// Set-Cookie.
+	RedactHeaders []string
+	// RedactFields are the names of the JSON body fields redacted at any
+	// depth, of the params and of the Meta keys, compared
+	// case-insensitively.
+	RedactFields []string
+	// Topics limits the recording to these topics. All are recorded when
+	// empty.
+	Topics []string
+}
+
+// recorder redacts the MRPC calls and passes them to the sink.
+type recorder struct {
+	redactor
+	sink   RecordSink
+	topics map[string]bool
+}
+
+// redactor redacts headers and JSON body fields.
+type redactor struct {
+	headers []string
+	fields  map[string]bool
+}
+
+// newRedactor redacts headers in addition to the default ones, and fields.
+func newRedactor(headers, fields []string) redactor {
+	rd := redactor{
+		headers: append(append([]string(nil), defaultRedactHeaders...), headers...),
+		fields:  map[string]bool{},
+	}
+	for _, f := range fields {
+		rd.fields[strings.ToLower(f)] = true
+	}
+	return rd
+}
+
+// WithRecording captures the MRPC requests and responses of the proxy to
+// sink, redacted according to cfg, for replaying them with Replay. Claims
+// and cookie values are never recorded.
+func WithRecording(sink RecordSink, cfg RecordConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		rec := &recorder{
+			redactor: newRedactor(cfg.RedactHeaders, cfg.RedactFields),
+			sink:     sink,
+		}
+		if len(cfg.Topics) > 0 {
+			rec.topics = map[string]bool{}
+			for _, t := range cfg.Topics {
+				rec.topics[t] = true
+			}
+		}
+
+		pxy.recorder = rec
+		return nil
+	}
+}
+
+// record captures a call to topic started at start.
+func (pxy *Proxy) record(ctx context.Context, topic string, start time.Time, req *mrpcproxy.Request, res *mrpcproxy.Response, err error) {
+	rc := pxy.recorder
+	if rc == nil || rc.topics != nil && !rc.topics[topic] {
+		return
+	}
+
+	rec := &Recording{
+		Time:     start,
+		Topic:    topic,
+		Duration: time.Since(start),
+		Request:  rc.redactRequest(req),
+	}
+	if res != nil {
+		c := cloneResponse(res)
+		c.Msg = rc.redactBody(c.Msg)
+		rc.redactHeaders(c.Headers)
+		for _, cookie := range c.Cookies {
+			cookie.Value, cookie.Raw = redacted, ""
+		}
+		rec.Response = c
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if err := rc.sink.Record(rec); err != nil {
+		printfCtx(ctx, pxy.Debugger, "recording %v: %v", topic, err)
+	}
+}
+
+// redactRequest returns a redacted copy of req, which may be reused.
+func (rc *recorder) redactRequest(req *mrpcproxy.Request) *mrpcproxy.Request {
+	c := *req
+	c.Msg = rc.redactBody(append([]byte(nil), req.Msg...))
+	c.Headers = req.Headers.Clone()
+	rc.redactHeaders(c.Headers)
+	c.Params = url.Values(http.Header(req.Params).Clone())
+	rc.redactValues(c.Params)
+	c.QueryParams = url.Values(http.Header(req.QueryParams).Clone())
+	rc.redactValues(c.QueryParams)
+	c.PathParams = rc.redactStrings(req.PathParams)
+	c.Meta = rc.redactStrings(req.Meta)
+	c.Claims = nil
+	c.Cookies = make([]*http.Cookie, len(req.Cookies))
+	for i, cookie := range req.Cookies {
+		c.Cookies[i] = &http.Cookie{Name: cookie.Name, Value: redacted}
+	}
+	c.Files = append([]mrpcproxy.File(nil), req.Files...)
+	return &c
+}
+
+func (rd *redactor) redactHeaders(h http.Header) {
+	for _, name := range rd.headers {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, redacted)
+		}
+	}
+}
+
+// redactValues redacts the fields of v and reports whether any was.
+func (rd *redactor) redactValues(v url.Values) bool {
+	found := false
+	for k := range v {
+		if rd.fields[strings.ToLower(k)] {
+			v[k] = []string{redacted}
+			found = true
+		}
+	}
+	return found
+}
+
+// redactStrings returns a copy of m with the fields redacted.
+func (rd *redactor) redactStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		if rd.fields[strings.ToLower(k)] {
+			v = redacted
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// redactBody redacts the fields of JSON bodies. Other bodies are kept.
+func (rd *redactor) redactBody(body []byte) []byte {
+	if len(rd.fields) == 0 || len(body) == 0 {
+		return body
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(rd.redactValue(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func (rd *redactor) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if rd.fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = rd.redactValue(fv)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rd.redactValue(item)
+		}
+	}
+	return v
+}
+
+// Replay sends the request of rec to topic, or to the recorded topic when
+// empty, and returns the new response.
+func (pxy *Proxy) Replay(ctx context.Context, rec *Recording, topic string) (*mrpcproxy.Response, error) {
+	if topic == "" {
+		topic = rec.Topic
+	}
+
+	req := *rec.Request
+	req.Topic = topic
+	return pxy.Call(ctx, topic, &req, pxy.Timeout)
+}
+
+// JSONLinesSink writes recordings as JSON lines, e.g. to a file.
+type JSONLinesSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLinesSink returns a sink writing to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{enc: json.NewEncoder(w)}
+}
+
+// Record writes rec as a line.
+func (s *JSONLinesSink) Record(rec *Recording) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(rec)
+}
+
+// ReadRecordings reads the recordings written by a JSONLinesSink.
+func ReadRecordings(r io.Reader) ([]*Recording, error) {
+	var recs []*Recording
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 64<<20)
+	for s.Scan() {
+		if len(strings.TrimSpace(s.Text())) == 0 {
+			continue
+		}
+		rec := &Recording{}
+		if err := json.Unmarshal(s.Bytes(), rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, s.Err()
+}
@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestRecording(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	echo := func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{
+			Code:    200,
+			Msg:     req.Msg,
+			Headers: http.Header{"Set-Cookie": {"session=secret"}},
+		})
+		w.Write(msg)
+	}
+	service.HandleFunc("login", echo)
+	service.HandleFunc("login-v2", echo)
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	pxy, err := New(":80", service, WithRecording(NewJSONLinesSink(buf), RecordConfig{
+		RedactHeaders: []string{"X-Api-Key"},
+		RedactFields:  []string{"password", "token", "device"},
+	}), WithMeta("device", MetaFromHeader("X-Device")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Method: "POST", Path: "/login", Topic: "login"})
+
+	body := `{"user":"bob","password":"hunter2","nested":[{"Password":"x"}]}`
+	r, _ := http.NewRequest("POST", "/login?token=secret&lang=en", strings.NewReader(body))
+	r.Header.Set("X-Device", "secret-device")
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Api-Key", "key")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+	w := httptest.NewRecorder()
+	pxy.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("Unexpected response: %v %q", w.Code, w.Body.String())
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "secret") ||
+		strings.Contains(buf.String(), "Bearer") {
+		t.Errorf("Secrets recorded: %v", buf.String())
+	}
+
+	recs, err := ReadRecordings(buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("Unexpected recordings: %v", len(recs))
+	}
+	rec := recs[0]
+	if rec.Topic != "login" || rec.Error != "" || rec.Response == nil || rec.Response.Code != 200 {
+		t.Errorf("Unexpected recording: %+v", rec)
+	}
+	if h := rec.Request.Headers.Get("X-Api-Key"); h != redacted {
+		t.Errorf("Unexpected X-Api-Key: %v", h)
+	}
+	if p := rec.Request.Params; p.Get("token") != redacted || p.Get("lang") != "en" {
+		t.Errorf("Unexpected params: %v", p)
+	}
+	if p := rec.Request.QueryParams; p.Get("token") != redacted || p.Get("lang") != "en" {
+		t.Errorf("Unexpected query params: %v", p)
+	}
+	if m := rec.Request.Meta["device"]; m != redacted {
+		t.Errorf("Unexpected meta: %v", m)
+	}
+	wantMsg := `{"nested":[{"Password":"[REDACTED]"}],"password":"[REDACTED]","user":"bob"}`
+	if string(rec.Request.Msg) != wantMsg {
+		t.Errorf("Unexpected recorded body: got %s want %s", rec.Request.Msg, wantMsg)
+	}
+
+	res, err := pxy.Replay(context.Background(), rec, "login-v2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.Code != 200 || string(res.Msg) != wantMsg {
+		t.Errorf("Unexpected replayed response: %v %s", res.Code, res.Msg)
+	}
+
+	recs, _ = ReadRecordings(buf)
+	if len(recs) != 1 || recs[0].Topic != "login-v2" {
+		t.Errorf("Unexpected replay recordings: %+v", recs)
+	}
+}
@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrPanic is returned for requests whose handler panicked. The panic
+	// itself is only logged.
+	ErrPanic = errors.New("internal server error")
+)
+
+// recoverPanics answers the requests whose handler panics with 500 and logs
+// the panic with its stack to the Debugger. Responses already started are
+// aborted instead, as are panics with http.ErrAbortHandler.
+func (pxy *Proxy) recoverPanics(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		pw := &panicWriter{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			atomic.AddInt64(&pxy.panics, 1)
+			id := RequestIDFromContext(r.Context())
+			printfCtx(r.Context(), pxy.Debugger, "panic serving %v:%v, Id: %v: %v\n%s", r.Method, r.URL.Path, id, rec, debug.Stack())
+			if pw.started {
+				panic(http.ErrAbortHandler)
+			}
+
+			pxy.logEndpointRequest(r, http.StatusInternalServerError, ep.Topic, id)
+			pxy.writeError(w, r, http.StatusInternalServerError, ErrPanic)
+		}()
+
+		h(pw, r, p)
+	}
+}
+
+// Panics returns the number of requests whose handler panicked.
+func (pxy *Proxy) Panics() int64 {
+	return atomic.LoadInt64(&pxy.panics)
+}
+
+// panicWriter records whether the response has started.
+type panicWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *panicWriter) WriteHeader(code int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *panicWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *panicWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
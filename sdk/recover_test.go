@@ -0,0 +1,76 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miracl/mrpc"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{})
+	pxy.GetID = func() string { return "uuid" }
+	pxy.Requests = &MockLogger{}
+	debugger := &MockLogger{}
+	pxy.Debugger = debugger
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/panic"}, func(w http.ResponseWriter, r *http.Request) {
+		panic("secret state")
+	})
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/started"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/abort"}, func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/ok"}, func(w http.ResponseWriter, r *http.Request) {})
+
+	cases := []struct {
+		path    string
+		status  int
+		aborted bool
+		panics  int64
+	}{
+		{"/ok", 200, false, 0},
+		{"/panic", 500, false, 1},
+		{"/started", 200, true, 2},
+		{"/abort", 200, true, 2},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			aborted := func() (aborted bool) {
+				defer func() {
+					if rec := recover(); rec != nil {
+						if rec != http.ErrAbortHandler {
+							t.Errorf("Unexpected panic: %v", rec)
+						}
+						aborted = true
+					}
+				}()
+				pxy.ServeHTTP(w, r)
+				return false
+			}()
+
+			if aborted != tc.aborted || w.Code != tc.status {
+				t.Errorf("Unexpected response: got %v aborted %v want %v aborted %v", w.Code, aborted, tc.status, tc.aborted)
+			}
+			if strings.Contains(w.Body.String(), "secret") {
+				t.Errorf("Panic leaked to the client: %q", w.Body.String())
+			}
+			if n := pxy.Panics(); n != tc.panics {
+				t.Errorf("Unexpected panics: got %v want %v", n, tc.panics)
+			}
+		})
+	}
+
+	if len(debugger.storage) == 0 || !strings.Contains(debugger.storage[0], "panic serving GET:/panic, Id: uuid: secret state") ||
+		!strings.Contains(debugger.storage[0], "goroutine") {
+		t.Errorf("Unexpected panic log: %v", debugger.storage)
+	}
+}
@@ -0,0 +1,301 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisMaxIdle = 4
+	defaultRedisTimeout = 1 * time.Second
+)
+
+var (
+	// ErrRedisProtocol is returned for malformed Redis replies.
+	ErrRedisProtocol = errors.New("redis: protocol error")
+)
+
+// RedisError is an error reply of the Redis server.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisConfig configures a RedisStore.
+type RedisConfig struct {
+	// Addr of the server, e.g. localhost:6379.
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to all keys.
+	Prefix string
+	// MaxIdle is the number of connections kept for reuse. Defaults to 4.
+	MaxIdle int
+	// Timeout bounds the dial and each operation whose context has no
+	// deadline. Defaults to 1 second.
+	Timeout time.Duration
+	// Dial opens the connections. Defaults to a TCP dialer.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// RedisStore is a Store backed by a Redis server, so limits and cached
+// responses are shared by the proxy instances.
+type RedisStore struct {
+	cfg RedisConfig
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+// NewRedisStore creates a store connecting to the Redis server on first use.
+func NewRedisStore(cfg RedisConfig) *RedisStore {
+	if cfg.MaxIdle == 0 {
+		cfg.MaxIdle = defaultRedisMaxIdle
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultRedisTimeout
+	}
+	if cfg.Dial == nil {
+		cfg.Dial = (&net.Dialer{Timeout: cfg.Timeout}).DialContext
+	}
+	return &RedisStore{cfg: cfg}
+}
+
+// Get returns the value of key.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	replies, err := s.do(ctx, []string{"GET", s.cfg.Prefix + key})
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch v := replies[0].(type) {
+	case nil:
+		return nil, false, nil
+	case []byte:
+		return v, true, nil
+	default:
+		return nil, false, ErrRedisProtocol
+	}
+}
+
+// Set stores value under key for ttl.
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, []string{"SET", s.cfg.Prefix + key, string(value), "PX", redisMillis(ttl)})
+	return err
+}
+
+// Incr increments the counter at key. The counter is created with its ttl
+// before incrementing it, so it expires even when the increment is lost.
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	key = s.cfg.Prefix + key
+	replies, err := s.do(ctx,
+		[]string{"SET", key, "0", "PX", redisMillis(ttl), "NX"},
+		[]string{"INCR", key},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := replies[1].(int64)
+	if !ok {
+		return 0, ErrRedisProtocol
+	}
+	return n, nil
+}
+
+func redisMillis(ttl time.Duration) string {
+	ms := int64(ttl / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
+
+// do pipelines the commands on a pooled connection and returns their replies.
+// Error replies fail the call.
+func (s *RedisStore) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	c, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := c.do(ctx, cmds...)
+	var rerr RedisError
+	if err != nil && !errors.As(err, &rerr) {
+		c.Close()
+		return nil, err
+	}
+
+	s.release(c)
+	return replies, err
+}
+
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	nc, err := s.cfg.Dial(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+
+	var setup [][]string
+	if s.cfg.Password != "" {
+		setup = append(setup, []string{"AUTH", s.cfg.Password})
+	}
+	if s.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.cfg.DB)})
+	}
+	if len(setup) > 0 {
+		if _, err := c.do(ctx, setup...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (s *RedisStore) release(c *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.idle) >= s.cfg.MaxIdle {
+		c.Close()
+		return
+	}
+	s.idle = append(s.idle, c)
+}
+
+// Close closes the idle connections.
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.idle {
+		c.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+// redisConn is a connection speaking the Redis protocol.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do writes the commands and reads one reply for each. It returns the first
+// error reply after reading all of them.
+func (c *redisConn) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(c.Conn)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	var rerr error
+	for i := range cmds {
+		reply, err := c.readReply()
+		if e, ok := err.(RedisError); ok {
+			if rerr == nil {
+				rerr = e
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+
+	return replies, rerr
+}
+
+// readReply reads a reply: a string, an int64, a []byte or nil bulk string,
+// or an []interface{} array.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, ErrRedisProtocol
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, RedisError(line)
+	case ':':
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, ErrRedisProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, ErrRedisProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, ErrRedisProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, ErrRedisProtocol
+	}
+}
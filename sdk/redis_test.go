@@ -0,0 +1,213 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the subset of the Redis protocol used by RedisStore.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]string
+	conns  int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRedis{ln: ln, password: password, values: map[string]string{}, ttls: map[string]string{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			header, _ := r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+			arg := make([]byte, size+2)
+			if _, err := io.ReadFull(r, arg); err != nil {
+				return
+			}
+			args[i] = string(arg[:size])
+		}
+
+		io.WriteString(c, f.reply(args, &authed))
+	}
+}
+
+func (f *fakeRedis) reply(args []string, authed *bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if args[0] == "AUTH" {
+		if args[1] != f.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	}
+	if !*authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch args[0] {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		if len(args) > 5 && args[5] == "NX" {
+			if _, ok := f.values[args[1]]; ok {
+				return "$-1\r\n"
+			}
+		}
+		f.values[args[1]], f.ttls[args[1]] = args[2], args[4]
+		return "+OK\r\n"
+	case "INCR":
+		n, err := strconv.Atoi(f.values[args[1]])
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		f.values[args[1]] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (f *fakeRedis) ttl(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ttls[key]
+}
+
+func TestRedisStore(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	defer f.ln.Close()
+
+	s := NewRedisStore(RedisConfig{Addr: f.ln.Addr().String(), Password: "secret", DB: 1, Prefix: "pxy:"})
+	defer s.Close()
+	ctx := context.Background()
+
+	if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+		t.Errorf("Unexpected missing key %v %v", ok, err)
+	}
+
+	if err := s.Set(ctx, "a", []byte("value\r\n"), 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := s.Get(ctx, "a"); !ok || err != nil || string(v) != "value\r\n" {
+		t.Errorf("Unexpected value %q %v %v", v, ok, err)
+	}
+	if ttl := f.ttl("pxy:a"); ttl != "1500" {
+		t.Errorf("Unexpected ttl %v", ttl)
+	}
+
+	for i := 1; i <= 3; i++ {
+		n, err := s.Incr(ctx, "n", time.Minute)
+		if err != nil || n != int64(i) {
+			t.Errorf("Unexpected counter %v %v", n, err)
+		}
+	}
+	if ttl := f.ttl("pxy:n"); ttl != "60000" {
+		t.Errorf("Unexpected counter ttl %v", ttl)
+	}
+
+	if _, err := s.Incr(ctx, "a", time.Minute); err == nil || !strings.Contains(err.Error(), "not an integer") {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns != 1 {
+		t.Errorf("Connection not reused: %v connections", f.conns)
+	}
+}
+
+func TestRedisStoreAuth(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	defer f.ln.Close()
+
+	s := NewRedisStore(RedisConfig{Addr: f.ln.Addr().String(), Password: "wrong"})
+	if _, _, err := s.Get(context.Background(), "a"); err == nil {
+		t.Error("Expected auth error")
+	}
+}
+
+func TestRedisStoreTimeout(t *testing.T) {
+	// The listener accepts the connections but never replies.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	s := NewRedisStore(RedisConfig{Addr: ln.Addr().String(), Timeout: 50 * time.Millisecond})
+	defer s.Close()
+
+	start := time.Now()
+	if _, _, err := s.Get(context.Background(), "a"); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Get not bounded by the timeout: %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, _, err := s.Get(ctx, "a"); err == nil {
+		t.Error("Expected deadline error")
+	}
+	if d := time.Since(start); d > 40*time.Millisecond {
+		t.Errorf("Get not bounded by the context deadline: %v", d)
+	}
+}
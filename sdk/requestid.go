@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// DefaultRequestIDHeader is the conventional request ID header.
+	DefaultRequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// WithRequestIDHeader accepts request IDs sent by clients in header, falling
+// back to GetID when missing or invalid, and echoes the ID in the response
+// header.
+func WithRequestIDHeader(header string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.RequestIDHeader = header
+		return nil
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the proxied request ctx belongs to.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDs assigns the request ID before the endpoint middleware runs.
+func (pxy *Proxy) requestIDs(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		h(w, pxy.withRequestID(w, r), p)
+	}
+}
+
+// withRequestID returns r with its request ID in the context. The ID is
+// resolved once per request.
+func (pxy *Proxy) withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return r
+	}
+
+	var id string
+	if pxy.RequestIDHeader != "" {
+		id = validRequestID(r.Header.Get(pxy.RequestIDHeader))
+	}
+	if id == "" {
+		id = pxy.GetID()
+	}
+
+	if pxy.RequestIDHeader != "" && id != "" {
+		w.Header().Set(pxy.RequestIDHeader, id)
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// validRequestID returns id if it is short and printable ASCII, otherwise
+// empty string. This prevents log and header injection by clients.
+func validRequestID(id string) string {
+	if len(id) > maxRequestIDLength {
+		return ""
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return ""
+		}
+	}
+
+	return id
+}
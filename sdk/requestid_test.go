@@ -0,0 +1,56 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestWithRequestID(t *testing.T) {
+	cases := []struct {
+		header   string
+		incoming string
+		id       string
+		echo     string
+	}{
+		{"", "client-id", "generated", ""},
+		{DefaultRequestIDHeader, "client-id", "client-id", "client-id"},
+		{DefaultRequestIDHeader, "", "generated", "generated"},
+		{DefaultRequestIDHeader, "bad\nid", "generated", "generated"},
+		{DefaultRequestIDHeader, strings.Repeat("a", 129), "generated", "generated"},
+		{"X-Correlation-ID", "client-id", "client-id", "client-id"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, _ := New(":80", service, WithRequestIDHeader(tc.header))
+			pxy.GetID = func() string { return "generated" }
+
+			r, _ := http.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.incoming)
+			}
+			rr := httptest.NewRecorder()
+
+			r = pxy.withRequestID(rr, r)
+			if id := RequestIDFromContext(r.Context()); id != tc.id {
+				t.Errorf("Unexpected request ID: got %q want %q", id, tc.id)
+			}
+
+			if tc.header != "" && rr.Header().Get(tc.header) != tc.echo {
+				t.Errorf("Unexpected echoed ID: got %q want %q", rr.Header().Get(tc.header), tc.echo)
+			}
+
+			pxy.GetID = func() string { return "again" }
+			if id := RequestIDFromContext(pxy.withRequestID(rr, r).Context()); id != tc.id {
+				t.Errorf("Request ID resolved twice: %v", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package sdk
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LineWriter is implemented by loggers accepting preformatted lines. Endpoint
+// request lines are written to Proxy.Requests with WriteLine when it is
+// implemented, without going through fmt.
+type LineWriter interface {
+	WriteLine(line []byte)
+}
+
+// LineLogger writes lines to an io.Writer in the format of the standard
+// logger with log.LstdFlags|log.LUTC. Lines written with WriteLine don't
+// allocate. It is the default Proxy.Requests logger.
+type LineLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+	now    func() time.Time
+}
+
+// NewLineLogger returns a LineLogger writing lines starting with prefix to w.
+func NewLineLogger(w io.Writer, prefix string) *LineLogger {
+	return &LineLogger{w: w, prefix: prefix, now: time.Now}
+}
+
+// Println formats v as fmt.Sprintln and writes it as a line.
+func (l *LineLogger) Println(v ...interface{}) {
+	l.WriteLine([]byte(fmt.Sprintln(v...)))
+}
+
+// Printf formats v as fmt.Sprintf and writes it as a line.
+func (l *LineLogger) Printf(format string, v ...interface{}) {
+	l.WriteLine([]byte(fmt.Sprintf(format, v...)))
+}
+
+// WriteLine writes line after the prefix and the UTC time, adding the final
+// newline when missing.
+func (l *LineLogger) WriteLine(line []byte) {
+	now := l.now().UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf[:0], l.prefix...)
+	l.buf = now.AppendFormat(l.buf, "2006/01/02 15:04:05 ")
+	l.buf = append(l.buf, line...)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		l.buf = append(l.buf, '\n')
+	}
+	l.w.Write(l.buf)
+}
+
+// WithRequestLogSampling logs only 1 in n successful requests. Requests
+// answered with status 400 or above are always logged. It applies to the
+// endpoint request lines and the access log.
+func WithRequestLogSampling(n int) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.logSampling = uint64(n)
+		return nil
+	}
+}
+
+// sampled reports whether the request answered with status is logged.
+func (pxy *Proxy) sampled(status int) bool {
+	if pxy.logSampling <= 1 || status >= http.StatusBadRequest {
+		return true
+	}
+	return atomic.AddUint64(&pxy.logSampled, 1)%pxy.logSampling == 1
+}
+
+var linePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// logEndpointRequest writes the request line of an endpoint request, as
+// logRequest with "%v:%v, status: %v, topic: %v, Id: %v" but without
+// formatting through fmt.
+func (pxy *Proxy) logEndpointRequest(r *http.Request, status int, topic, id string) {
+	if pxy.accessLogFn != nil || !pxy.sampled(status) {
+		return
+	}
+
+	bp := linePool.Get().(*[]byte)
+	b := append((*bp)[:0], r.Method...)
+	b = append(b, ':')
+	b = append(b, r.URL.Path...)
+	b = append(b, ", status: "...)
+	b = strconv.AppendInt(b, int64(status), 10)
+	b = append(b, ", topic: "...)
+	b = append(b, topic...)
+	b = append(b, ", Id: "...)
+	b = append(b, id...)
+
+	if cl, ok := pxy.Requests.(ContextLogger); ok {
+		cl.PrintfCtx(r.Context(), "%s", b)
+	} else if lw, ok := pxy.Requests.(LineWriter); ok {
+		lw.WriteLine(b)
+	} else {
+		pxy.Requests.Printf("%s", b)
+	}
+
+	*bp = b
+	linePool.Put(bp)
+}
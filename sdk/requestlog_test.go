@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestLineLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLineLogger(&buf, "[R]")
+	l.now = func() time.Time { return time.Date(2019, 3, 4, 5, 6, 7, 0, time.FixedZone("", 3600)) }
+
+	l.WriteLine([]byte("GET:/a, status: 200"))
+	l.Println("a", 1)
+	l.Printf("b %v", 2)
+
+	expected := "[R]2019/03/04 04:06:07 GET:/a, status: 200\n" +
+		"[R]2019/03/04 04:06:07 a 1\n" +
+		"[R]2019/03/04 04:06:07 b 2\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+}
+
+func TestLogEndpointRequest(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	r, _ := http.NewRequest("GET", "/a", nil)
+
+	cases := []struct {
+		sampling int
+		statuses []int
+		logged   int
+	}{
+		{0, []int{200, 200, 200}, 3},
+		{3, []int{200, 200, 200, 200, 200, 200}, 2},
+		{3, []int{200, 500, 404, 200}, 3},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithRequestLogSampling(tc.sampling))
+			l := &MockLogger{}
+			pxy.Requests = l
+
+			for _, status := range tc.statuses {
+				pxy.logEndpointRequest(r, status, "service.a", "id")
+			}
+
+			if len(l.storage) != tc.logged {
+				t.Fatalf("Unexpected lines: %v", l.storage)
+			}
+			if l.storage[0] != fmt.Sprintf("GET:/a, status: %v, topic: service.a, Id: id", tc.statuses[0]) {
+				t.Errorf("Unexpected line %q", l.storage[0])
+			}
+		})
+	}
+}
+
+func TestLogEndpointRequestAllocs(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Requests = NewLineLogger(ioutil.Discard, "[R]")
+	r, _ := http.NewRequest("GET", "/a", nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		pxy.logEndpointRequest(r, 200, "service.a", "id")
+	})
+	if allocs != 0 {
+		t.Errorf("Unexpected allocations: %v", allocs)
+	}
+}
@@ -0,0 +1,181 @@
+package sdk
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Router routes the requests of the proxy to the handlers of its endpoints.
+// Endpoint paths are given in the syntax of the router. Registering
+// conflicting routes may panic. The proxy uses one router for the endpoints
+// without host and one for each host.
+type Router interface {
+	// Handle registers h for requests with method and path.
+	Handle(method, path string, h httprouter.Handle)
+	// Lookup returns the handler and the path params of the route for
+	// method and path. The handler is nil when there is none, and the bool
+	// reports whether a route exists for the path with or without trailing
+	// slash.
+	Lookup(method, path string) (httprouter.Handle, httprouter.Params, bool)
+	// ServeHTTP serves r with the handler of its route, or with the not
+	// found or method not allowed handlers given to the router.
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+}
+
+// NewRouterFunc creates a Router serving unrouted requests with notFound, or
+// with methodNotAllowed when the path has routes for other methods.
+type NewRouterFunc func(notFound, methodNotAllowed http.Handler) Router
+
+// WithRouter routes the requests with the routers created by newRouter
+// instead of httprouter, e.g. NewServeMuxRouter or chirouter.New. The routes
+// registered by the previous options are moved to the new routers.
+func WithRouter(newRouter NewRouterFunc) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.newRouter = newRouter
+		pxy.router, pxy.hosts = pxy.makeRouter(), nil
+		for _, rt := range pxy.routes {
+			pxy.handleRoute(pxy.hostRouter(rt.host), rt)
+		}
+		return nil
+	}
+}
+
+// makeRouter creates an empty router answering unrouted requests with the
+// handlers set by finishRouters.
+func (pxy *Proxy) makeRouter() Router {
+	newRouter := pxy.newRouter
+	if newRouter == nil {
+		newRouter = NewHTTPRouter
+	}
+	return newRouter(http.HandlerFunc(pxy.serveNotFound), http.HandlerFunc(pxy.serveMethodNotAllowed))
+}
+
+func (pxy *Proxy) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	pxy.routesMu.RLock()
+	h := pxy.notFound
+	pxy.routesMu.RUnlock()
+
+	if h == nil {
+		h = http.NotFoundHandler()
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (pxy *Proxy) serveMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	pxy.routesMu.RLock()
+	h := pxy.methodNotAllowed
+	pxy.routesMu.RUnlock()
+
+	if h == nil {
+		w.Header().Set("Allow", pxy.allowedMethods(r))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// NewHTTPRouter returns a Router backed by httprouter, the default router.
+// Paths take :name params and a final *name catch-all, and requests with a
+// missing or extra trailing slash are redirected.
+func NewHTTPRouter(notFound, methodNotAllowed http.Handler) Router {
+	r := httprouter.New()
+	r.NotFound, r.MethodNotAllowed = notFound, methodNotAllowed
+	// OPTIONS is answered by the endpoint OPTIONS handlers.
+	r.HandleMethodNotAllowed, r.HandleOPTIONS = true, false
+	return r
+}
+
+// serveMuxRouter adapts http.ServeMux to Router.
+type serveMuxRouter struct {
+	mux                        *http.ServeMux
+	routes                     map[string]muxRoute
+	notFound, methodNotAllowed http.Handler
+}
+
+type muxRoute struct {
+	h        httprouter.Handle
+	segments []string
+}
+
+// NewServeMuxRouter returns a Router backed by http.ServeMux. Paths are
+// ServeMux patterns without method and host, e.g. /users/{id} or
+// /files/{path...}, whose wildcards are passed as the params of the handlers.
+// GET routes match HEAD requests too.
+func NewServeMuxRouter(notFound, methodNotAllowed http.Handler) Router {
+	return &serveMuxRouter{
+		mux:              http.NewServeMux(),
+		routes:           map[string]muxRoute{},
+		notFound:         notFound,
+		methodNotAllowed: methodNotAllowed,
+	}
+}
+
+func (m *serveMuxRouter) Handle(method, path string, h httprouter.Handle) {
+	pattern := method + " " + path
+	rt := muxRoute{h, strings.Split(strings.TrimPrefix(path, "/"), "/")}
+	m.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, rt.params(r.PathValue))
+	})
+	m.routes[pattern] = rt
+}
+
+func (m *serveMuxRouter) Lookup(method, path string) (httprouter.Handle, httprouter.Params, bool) {
+	r := &http.Request{Method: method, URL: &url.URL{Path: path}}
+	_, pattern := m.mux.Handler(r)
+	rt, ok := m.routes[pattern]
+	if !ok {
+		return nil, nil, false
+	}
+	return rt.h, rt.params(rt.match(path)), false
+}
+
+func (m *serveMuxRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := m.mux.Handler(r); pattern == "" {
+		if hasPath(m, r.URL.Path) {
+			m.methodNotAllowed.ServeHTTP(w, r)
+		} else {
+			m.notFound.ServeHTTP(w, r)
+		}
+		return
+	}
+	m.mux.ServeHTTP(w, r)
+}
+
+// params returns the values of the wildcards of the route.
+func (rt muxRoute) params(value func(name string) string) httprouter.Params {
+	var p httprouter.Params
+	for _, s := range rt.segments {
+		if name, ok := muxWildcard(s); ok {
+			p = append(p, httprouter.Param{Key: name, Value: value(name)})
+		}
+	}
+	return p
+}
+
+// match returns the wildcard values of path, which matches the route.
+func (rt muxRoute) match(path string) func(name string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	return func(name string) string {
+		for i, s := range rt.segments {
+			if n, ok := muxWildcard(s); !ok || n != name || i >= len(parts) {
+				continue
+			}
+			if strings.HasSuffix(s, "...}") {
+				return strings.Join(parts[i:], "/")
+			}
+			return parts[i]
+		}
+		return ""
+	}
+}
+
+// muxWildcard returns the name of the wildcard segment s.
+func muxWildcard(s string) (string, bool) {
+	if len(s) < 3 || s[0] != '{' || s[len(s)-1] != '}' || s == "{$}" {
+		return "", false
+	}
+	return strings.TrimSuffix(s[1:len(s)-1], "..."), true
+}
@@ -0,0 +1,106 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestServeMuxRouter(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"users", "files"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			req := mrpcproxy.Request{}
+			json.Unmarshal(data, &req)
+			body := topic + ":" + req.Params.Get("id") + req.Params.Get("path")
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(body)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithHealthChecks(HealthConfig{}), WithRouter(NewServeMuxRouter))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/users/{id}", Topic: "{{.id}}"},
+		Endpoint{Method: "GET", Path: "/files/{path...}", Topic: "files"},
+		Endpoint{Host: "api.example.com", Method: "PUT", Path: "/users/{id}", Topic: "users"},
+	)
+	pxy.finishRouters(pxy.router, pxy.hosts, pxy.hostRouter, pxy.Eps)
+
+	cases := []struct {
+		method, host, path string
+		code               int
+		allow              string
+		body               string
+	}{
+		{"GET", "", "/users/users", 200, "", "users:users"},
+		{"GET", "", "/files/a/b.txt", 200, "", "files:a/b.txt"},
+		{"HEAD", "", "/files/a", 200, "", "files:a"},
+		{"PUT", "api.example.com", "/users/1", 200, "", "users:1"},
+		{"GET", "api.example.com", "/users/users", 200, "", "users:users"},
+		{"GET", "", "/healthz", 200, "", `{"status":"ok"}`},
+		{"POST", "", "/users/1", 405, "GET, HEAD, OPTIONS", ""},
+		{"GET", "", "/missing", 404, "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.path, nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if allow := w.Header().Get("Allow"); allow != tc.allow {
+				t.Errorf("Unexpected Allow %q; expected %q", allow, tc.allow)
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != tc.body {
+				t.Errorf("Unexpected body %q; expected %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestServeMuxRouterLookup(t *testing.T) {
+	rt := NewServeMuxRouter(http.NotFoundHandler(), http.NotFoundHandler())
+	rt.Handle("GET", "/a/{id}/b/{rest...}", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {})
+
+	cases := []struct {
+		method, path string
+		found        bool
+		id, rest     string
+	}{
+		{"GET", "/a/1/b/c/d", true, "1", "c/d"},
+		{"HEAD", "/a/1/b/c", true, "1", "c"},
+		{"POST", "/a/1/b/c", false, "", ""},
+		{"GET", "/a/1", false, "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			h, p, _ := rt.Lookup(tc.method, tc.path)
+			if (h != nil) != tc.found {
+				t.Fatalf("Unexpected handler: got %v want %v", h != nil, tc.found)
+			}
+			if p.ByName("id") != tc.id || p.ByName("rest") != tc.rest {
+				t.Errorf("Unexpected params: %v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultSampleQueueSize   = 1024
+	defaultSampleMaxBodySize = 64 << 10
+)
+
+// Sample describes a request sampled by WithSampling. The credentials
+// headers are removed from Header and ResponseHeader.
+type Sample struct {
+	Time           time.Time
+	Method         string
+	Path           string
+	Route          string
+	Topic          string
+	Status         int
+	Latency        time.Duration
+	RequestBytes   int64
+	ResponseBytes  int64
+	RequestID      string
+	Header         http.Header
+	ResponseHeader http.Header
+	// The bodies are captured only with SampleConfig.Bodies, up to
+	// SampleConfig.MaxBodySize.
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// Sampler receives the sampled requests. Sample is called from a single
+// goroutine, off the request path.
+type Sampler interface {
+	Sample(s *Sample)
+}
+
+// SampleConfig configures the sampling of requests.
+type SampleConfig struct {
+	// Percent of the endpoint requests sampled, from 0 to 100.
+	Percent float64
+	// Bodies captures the request and response bodies.
+	Bodies bool
+	// MaxBodySize limits the captured bodies, 64KiB by default.
+	MaxBodySize int
+	// QueueSize is the number of samples waiting for the sampler, 1024 by
+	// default. Samples are dropped when the queue is full.
+	QueueSize int
+}
+
+// sampling queues the sampled requests for the sampler.
+type sampling struct {
+	cfg     SampleConfig
+	samples chan *Sample
+}
+
+// WithSampling passes the metadata of cfg.Percent of the endpoint requests
+// to s asynchronously, so that analytics do not add latency to the requests.
+// The sampler stops when the proxy shuts down.
+func WithSampling(s Sampler, cfg SampleConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.MaxBodySize <= 0 {
+			cfg.MaxBodySize = defaultSampleMaxBodySize
+		}
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = defaultSampleQueueSize
+		}
+
+		sm := &sampling{cfg: cfg, samples: make(chan *Sample, cfg.QueueSize)}
+		go func() {
+			for {
+				select {
+				case sample := <-sm.samples:
+					s.Sample(sample)
+				case <-pxy.ctx.Done():
+					return
+				}
+			}
+		}()
+
+		pxy.sampling = sm
+		return nil
+	}
+}
+
+// sample captures the sampled requests to ep.
+func (pxy *Proxy) sample(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	sm := pxy.sampling
+	if sm == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if rand.Float64()*100 >= sm.cfg.Percent {
+			h(w, r, p)
+			return
+		}
+
+		start := time.Now()
+		var reqBody *limitedBuffer
+		if sm.cfg.Bodies && r.Body != nil {
+			reqBody = &limitedBuffer{max: sm.cfg.MaxBodySize}
+			r.Body = teeReadCloser{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		sw := &sampleWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}}
+		if sm.cfg.Bodies {
+			sw.body = &limitedBuffer{max: sm.cfg.MaxBodySize}
+		}
+
+		h(sw, r, p)
+
+		sample := &Sample{
+			Time:           start,
+			Method:         r.Method,
+			Path:           r.URL.Path,
+			Route:          ep.Path,
+			Topic:          ep.Topic,
+			Status:         sw.status,
+			Latency:        time.Since(start),
+			RequestBytes:   r.ContentLength,
+			ResponseBytes:  sw.bytes,
+			RequestID:      RequestIDFromContext(r.Context()),
+			Header:         withoutCredentials(r.Header),
+			ResponseHeader: withoutCredentials(w.Header()),
+		}
+		if reqBody != nil {
+			sample.RequestBody = reqBody.Bytes()
+		}
+		if sw.body != nil {
+			sample.ResponseBody = sw.body.Bytes()
+		}
+
+		select {
+		case sm.samples <- sample:
+		default:
+		}
+	}
+}
+
+// withoutCredentials returns a copy of h without the headers that are always
+// redacted from recordings.
+func withoutCredentials(h http.Header) http.Header {
+	c := h.Clone()
+	for _, name := range defaultRedactHeaders {
+		c.Del(name)
+	}
+	return c
+}
+
+// sampleWriter captures the response of sampled requests.
+type sampleWriter struct {
+	statusWriter
+	body *limitedBuffer
+}
+
+func (w *sampleWriter) Write(b []byte) (int, error) {
+	if w.body != nil {
+		w.body.Write(b)
+	}
+	return w.statusWriter.Write(b)
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.Len(); n < len(p) {
+		b.Buffer.Write(p[:n])
+	} else {
+		b.Buffer.Write(p)
+	}
+	return len(p), nil
+}
+
+// teeReadCloser reads through the tee and closes the original body.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,134 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+type mockSampler struct {
+	mu      sync.Mutex
+	samples []*Sample
+}
+
+func (s *mockSampler) Sample(sample *Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+func (s *mockSampler) wait(n int) []*Sample {
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		if len(s.samples) >= n {
+			defer s.mu.Unlock()
+			return s.samples
+		}
+		s.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples
+}
+
+func TestSampling(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("items", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 201, Msg: []byte("created")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		cfg          SampleConfig
+		samples      int
+		reqBody      string
+		responseBody string
+	}{
+		{SampleConfig{Percent: 100}, 3, "", ""},
+		{SampleConfig{Percent: 100, Bodies: true}, 3, `{"name":"item"}`, "created"},
+		{SampleConfig{Percent: 100, Bodies: true, MaxBodySize: 4}, 3, `{"na`, "crea"},
+		{SampleConfig{Percent: 0}, 0, "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			s := &mockSampler{}
+			pxy, _ := New(":80", service, WithSampling(s, tc.cfg))
+			defer pxy.cancel()
+			pxy.Requests = &MockLogger{}
+			pxy.Handle(Endpoint{Method: "POST", Path: "/items/:kind", Topic: "items"})
+
+			for j := 0; j < 3; j++ {
+				r, _ := http.NewRequest("POST", "/items/books", strings.NewReader(`{"name":"item"}`))
+				r.Header.Set("Authorization", "Bearer token")
+				r.Header.Set("X-Client", "test")
+				w := httptest.NewRecorder()
+				pxy.ServeHTTP(w, r)
+				if w.Code != 201 || w.Body.String() != "created" {
+					t.Fatalf("Unexpected response: %v %q", w.Code, w.Body.String())
+				}
+			}
+
+			samples := s.wait(tc.samples)
+			if len(samples) != tc.samples {
+				t.Fatalf("Unexpected samples: got %v want %v", len(samples), tc.samples)
+			}
+			for _, sample := range samples {
+				if sample.Method != "POST" || sample.Path != "/items/books" || sample.Route != "/items/:kind" ||
+					sample.Topic != "items" || sample.Status != 201 || sample.ResponseBytes != 7 || sample.RequestBytes != 15 {
+					t.Errorf("Unexpected sample: %+v", sample)
+				}
+				if sample.Header.Get("Authorization") != "" || sample.Header.Get("X-Client") != "test" {
+					t.Errorf("Unexpected sample headers: %v", sample.Header)
+				}
+				if string(sample.RequestBody) != tc.reqBody || string(sample.ResponseBody) != tc.responseBody {
+					t.Errorf("Unexpected sample bodies: %q %q", sample.RequestBody, sample.ResponseBody)
+				}
+			}
+		})
+	}
+}
+
+func TestSamplingQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	s := &blockingSampler{block: block}
+	pxy, _ := New(":80", &mrpc.Service{}, WithSampling(s, SampleConfig{Percent: 100, QueueSize: 1}))
+	defer pxy.cancel()
+	defer close(block)
+	pxy.Requests = &MockLogger{}
+	pxy.HandleFunc(Endpoint{Method: "GET", Path: "/ping"}, func(w http.ResponseWriter, r *http.Request) {})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			r, _ := http.NewRequest("GET", "/ping", nil)
+			pxy.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Requests blocked by the sampler")
+	}
+}
+
+type blockingSampler struct {
+	block chan struct{}
+}
+
+func (s *blockingSampler) Sample(*Sample) { <-s.block }
@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// FieldError describes a failed validation of a request field.
+type FieldError struct {
+	Location string `json:"location"` // body or query
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+}
+
+// ValidationError is returned when the request doesn't match the endpoint
+// schemas.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fmt.Sprintf("%v %v: %v", fe.Location, fe.Field, fe.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// renderValidationError is used for validation errors when no ErrorRenderer is
+// configured.
+func renderValidationError(code int, err error, r *http.Request) ([]byte, http.Header) {
+	var verr ValidationError
+	errors.As(err, &verr)
+	body, _ := json.Marshal(struct {
+		Errors []FieldError `json:"errors"`
+	}{verr.Errors})
+	return body, http.Header{"Content-Type": {"application/json"}}
+}
+
+type endpointSchemas struct {
+	body  *gojsonschema.Schema
+	query *gojsonschema.Schema
+}
+
+// compileSchemas loads the endpoint schemas. It returns nil when the endpoint
+// has none.
+func compileSchemas(ep Endpoint) (*endpointSchemas, error) {
+	label := fmt.Sprintf("%v %v", ep.Method, ep.Path)
+	return compileSchemaSet(label, ep.BodySchema, ep.BodySchemaFile, ep.QuerySchema, ep.QuerySchemaFile)
+}
+
+// compileSchemaSet loads a body and a query schema. It returns nil when both
+// are missing.
+func compileSchemaSet(label string, bodySchema json.RawMessage, bodyFile string, querySchema json.RawMessage, queryFile string) (*endpointSchemas, error) {
+	body, err := loadSchema(bodySchema, bodyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%v: body schema: %v", label, err)
+	}
+
+	query, err := loadSchema(querySchema, queryFile)
+	if err != nil {
+		return nil, fmt.Errorf("%v: query schema: %v", label, err)
+	}
+
+	if body == nil && query == nil {
+		return nil, nil
+	}
+
+	return &endpointSchemas{body, query}, nil
+}
+
+func loadSchema(inline json.RawMessage, file string) (*gojsonschema.Schema, error) {
+	if len(inline) == 0 && file != "" {
+		var err error
+		inline, err = ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(inline) == 0 {
+		return nil, nil
+	}
+
+	return gojsonschema.NewSchema(gojsonschema.NewBytesLoader(inline))
+}
+
+// validate checks the request body and query against the schemas. Path
+// params and form fields aren't part of the query.
+func (s *endpointSchemas) validate(q url.Values, body []byte) error {
+	if s == nil {
+		return nil
+	}
+
+	var errs []FieldError
+
+	if s.query != nil {
+		query := map[string]interface{}{}
+		for k, vs := range q {
+			if len(vs) == 1 {
+				query[k] = vs[0]
+			} else {
+				query[k] = vs
+			}
+		}
+
+		fes, err := validateDocument(s.query, gojsonschema.NewGoLoader(query), "query")
+		if err != nil {
+			return err
+		}
+		errs = append(errs, fes...)
+	}
+
+	if s.body != nil {
+		if !json.Valid(body) {
+			errs = append(errs, FieldError{Location: "body", Field: "(root)", Message: "Invalid JSON"})
+		} else {
+			fes, err := validateDocument(s.body, gojsonschema.NewBytesLoader(body), "body")
+			if err != nil {
+				return err
+			}
+			errs = append(errs, fes...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return StatusError{http.StatusBadRequest, ValidationError{errs}}
+	}
+
+	return nil
+}
+
+func validateDocument(schema *gojsonschema.Schema, doc gojsonschema.JSONLoader, location string) ([]FieldError, error) {
+	res, err := schema.Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []FieldError
+	for _, re := range res.Errors() {
+		errs = append(errs, FieldError{Location: location, Field: re.Field(), Message: re.Description()})
+	}
+	return errs, nil
+}
@@ -0,0 +1,84 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("v", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("OK")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	f, _ := ioutil.TempFile("", "schema")
+	f.WriteString(`{"type": "object", "additionalProperties": false, "required": ["page"], "properties": {"page": {"type": "string", "pattern": "^[0-9]+$"}}}`)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	ep := Endpoint{
+		Topic:           "service.v",
+		Method:          "POST",
+		Path:            "/v",
+		BodySchema:      json.RawMessage(`{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`),
+		QuerySchemaFile: f.Name(),
+	}
+
+	cases := []struct {
+		url    string
+		params httprouter.Params
+		body   string
+		status int
+		resp   string
+	}{
+		{"/v?page=1", nil, `{"name": "a"}`, http.StatusOK, "OK"},
+		{"/v?page=1", httprouter.Params{{Key: "id", Value: "1"}}, `{"name": "a"}`, http.StatusOK, "OK"},
+		{"/v?page=1&extra=1", nil, `{"name": "a"}`, http.StatusBadRequest, `{"errors":[{"location":"query","field":"(root)","message":"Additional property extra is not allowed"}]}`},
+		{"/v?page=1", nil, `{}`, http.StatusBadRequest, `{"errors":[{"location":"body","field":"(root)","message":"name is required"}]}`},
+		{"/v?page=x", nil, `not json`, http.StatusBadRequest, `{"errors":[{"location":"query","field":"page","message":"Does not match pattern '^[0-9]+$'"},{"location":"body","field":"(root)","message":"Invalid JSON"}]}`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service)
+			pxy.Logger = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			h, err := pxy.getTopicHandler(ep)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := http.NewRequest("POST", tc.url, bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+			h(rr, r, tc.params)
+
+			if rr.Code != tc.status || rr.Body.String() != tc.resp {
+				t.Errorf("Unexpected response: got %v %v want %v %v", rr.Code, rr.Body.String(), tc.status, tc.resp)
+			}
+		})
+	}
+}
+
+func TestCompileSchemasError(t *testing.T) {
+	_, err := compileSchemas(Endpoint{Method: "GET", Path: "/a", BodySchemaFile: "missing.json"})
+	if err == nil {
+		t.Error("Missing schema file not reported")
+	}
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AcceptVersionHeader selects the schema version of versioned endpoints.
+const AcceptVersionHeader = "Accept-Version"
+
+var (
+	// ErrUnsupportedVersion is returned for requests asking for a schema
+	// version the endpoint doesn't declare.
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	// ErrDuplicateVersion is returned by Handle for endpoints declaring a
+	// schema version twice.
+	ErrDuplicateVersion = errors.New("duplicate schema version")
+)
+
+// SchemaVersion is a version of the request and response payload schemas of
+// an endpoint. See Endpoint.BodySchema and Endpoint.QuerySchema.
+type SchemaVersion struct {
+	Version         string          `json:"version"`
+	BodySchema      json.RawMessage `json:"bodySchema"`
+	BodySchemaFile  string          `json:"bodySchemaFile"`
+	QuerySchema     json.RawMessage `json:"querySchema"`
+	QuerySchemaFile string          `json:"querySchemaFile"`
+
+	// ResponseSchema documents the response payload. It isn't validated.
+	ResponseSchema json.RawMessage `json:"responseSchema"`
+}
+
+// SchemaVersion returns the declared schema version of the endpoint.
+func (ep Endpoint) SchemaVersion(version string) (SchemaVersion, bool) {
+	for _, v := range ep.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return SchemaVersion{}, false
+}
+
+type versionedSchemas struct {
+	schemas map[string]*endpointSchemas
+	latest  string
+}
+
+// compileVersions loads the schemas of the endpoint versions. It returns nil
+// when the endpoint isn't versioned.
+func compileVersions(ep Endpoint) (*versionedSchemas, error) {
+	if len(ep.Versions) == 0 {
+		return nil, nil
+	}
+
+	vs := &versionedSchemas{
+		schemas: make(map[string]*endpointSchemas, len(ep.Versions)),
+		latest:  ep.Versions[len(ep.Versions)-1].Version,
+	}
+	for _, v := range ep.Versions {
+		if _, ok := vs.schemas[v.Version]; ok {
+			return nil, fmt.Errorf("%v %v: %w: %v", ep.Method, ep.Path, ErrDuplicateVersion, v.Version)
+		}
+
+		label := fmt.Sprintf("%v %v version %v", ep.Method, ep.Path, v.Version)
+		schemas, err := compileSchemaSet(label, v.BodySchema, v.BodySchemaFile, v.QuerySchema, v.QuerySchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		vs.schemas[v.Version] = schemas
+	}
+
+	return vs, nil
+}
+
+// negotiate returns the requested version and its schemas. The last declared
+// version is used when none is requested.
+func (vs *versionedSchemas) negotiate(version string) (string, *endpointSchemas, error) {
+	if version == "" {
+		version = vs.latest
+	}
+
+	schemas, ok := vs.schemas[version]
+	if !ok {
+		return "", nil, ErrUnsupportedVersion
+	}
+	return version, schemas, nil
+}
@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestSchemaVersions(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("v", func(w mrpc.TopicWriter, data []byte) {
+		req := mrpcproxy.Request{}
+		json.Unmarshal(data, &req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(req.SchemaVersion)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	ep := Endpoint{
+		Topic:  "v",
+		Method: "POST",
+		Path:   "/v",
+		Versions: []SchemaVersion{
+			{Version: "1", BodySchema: json.RawMessage(`{"type": "object", "required": ["name"]}`)},
+			{Version: "2", BodySchema: json.RawMessage(`{"type": "object", "required": ["fullName"]}`)},
+		},
+	}
+
+	cases := []struct {
+		version string
+		body    string
+		code    int
+		msg     string
+	}{
+		{"", `{"fullName": "a"}`, 200, "2"},
+		{"2", `{"fullName": "a"}`, 200, "2"},
+		{"1", `{"name": "a"}`, 200, "1"},
+		{"1", `{"fullName": "a"}`, 400, ""},
+		{"", `{"name": "a"}`, 400, ""},
+		{"3", `{"name": "a"}`, 406, ""},
+	}
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	if err := pxy.Handle(ep); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/v", bytes.NewBufferString(tc.body))
+			if tc.version != "" {
+				r.Header.Set(AcceptVersionHeader, tc.version)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if tc.code == 200 && w.Body.String() != tc.msg {
+				t.Errorf("Unexpected version %q", w.Body.String())
+			}
+			if vary := w.Header().Get("Vary"); vary != AcceptVersionHeader {
+				t.Errorf("Unexpected Vary %q", vary)
+			}
+		})
+	}
+
+	if v, ok := ep.SchemaVersion("1"); !ok || v.Version != "1" {
+		t.Errorf("Schema version 1 not found")
+	}
+	if _, ok := ep.SchemaVersion("3"); ok {
+		t.Errorf("Unexpected schema version 3")
+	}
+}
+
+func TestDuplicateSchemaVersion(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	err := pxy.Handle(Endpoint{Topic: "v", Method: "GET", Path: "/v", Versions: []SchemaVersion{{Version: "1"}, {Version: "1"}}})
+	if !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package sdk
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// SecurityHeaders configures the security headers added to every response.
+type SecurityHeaders struct {
+	// HSTSMaxAge in seconds of the Strict-Transport-Security header. The
+	// header is not sent when zero.
+	HSTSMaxAge            int  `json:"hstsMaxAge"`
+	HSTSIncludeSubdomains bool `json:"hstsIncludeSubdomains"`
+	HSTSPreload           bool `json:"hstsPreload"`
+
+	// ContentTypeOptions defaults to nosniff.
+	ContentTypeOptions string `json:"contentTypeOptions"`
+	// FrameOptions defaults to DENY.
+	FrameOptions string `json:"frameOptions"`
+	// ReferrerPolicy defaults to strict-origin-when-cross-origin.
+	ReferrerPolicy string `json:"referrerPolicy"`
+	// ContentSecurityPolicy is not sent when empty.
+	ContentSecurityPolicy string `json:"contentSecurityPolicy"`
+}
+
+// headers returns the response headers of s.
+func (s SecurityHeaders) headers() map[string]string {
+	h := map[string]string{
+		"X-Content-Type-Options": orDefault(s.ContentTypeOptions, "nosniff"),
+		"X-Frame-Options":        orDefault(s.FrameOptions, "DENY"),
+		"Referrer-Policy":        orDefault(s.ReferrerPolicy, "strict-origin-when-cross-origin"),
+	}
+
+	if s.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(s.HSTSMaxAge)
+		if s.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if s.HSTSPreload {
+			hsts += "; preload"
+		}
+		h["Strict-Transport-Security"] = hsts
+	}
+
+	if s.ContentSecurityPolicy != "" {
+		h["Content-Security-Policy"] = s.ContentSecurityPolicy
+	}
+
+	return h
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// WithSecurityHeaders adds the security headers of s to every response,
+// including errors and responses of unrouted paths. Endpoint.SecurityHeaders
+// overrides them per endpoint. Headers returned by the service take
+// precedence.
+func WithSecurityHeaders(s SecurityHeaders) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.securityHeaders = s.headers()
+		return nil
+	}
+}
+
+// setSecurityHeaders sets the security headers with the overrides applied.
+// Overrides with empty value remove the header.
+func (pxy *Proxy) setSecurityHeaders(w http.ResponseWriter, overrides map[string]string) {
+	for header, value := range pxy.securityHeaders {
+		w.Header().Set(header, value)
+	}
+
+	for header, value := range overrides {
+		if value == "" {
+			w.Header().Del(header)
+			continue
+		}
+		w.Header().Set(header, value)
+	}
+}
+
+// endpointSecurityHeaders applies the security header overrides of ep.
+func (pxy *Proxy) endpointSecurityHeaders(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	if ep.SecurityHeaders == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		pxy.setSecurityHeaders(w, ep.SecurityHeaders)
+		h(w, r, p)
+	}
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	defaults := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+	}
+
+	cases := []struct {
+		cfg       SecurityHeaders
+		overrides map[string]string
+		path      string
+		expected  map[string]string
+	}{
+		{SecurityHeaders{}, nil, "/a", defaults},
+		{SecurityHeaders{}, nil, "/missing", defaults},
+		{
+			SecurityHeaders{
+				HSTSMaxAge: 31536000, HSTSIncludeSubdomains: true, HSTSPreload: true,
+				FrameOptions: "SAMEORIGIN", ContentSecurityPolicy: "default-src 'none'",
+			},
+			nil,
+			"/a",
+			map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Content-Security-Policy":   "default-src 'none'",
+			},
+		},
+		{
+			SecurityHeaders{HSTSMaxAge: 60},
+			map[string]string{"X-Frame-Options": "", "Content-Security-Policy": "frame-ancestors 'self'"},
+			"/a",
+			map[string]string{
+				"Strict-Transport-Security": "max-age=60",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Content-Security-Policy":   "frame-ancestors 'self'",
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, WithSecurityHeaders(tc.cfg))
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: "a", Method: "GET", Path: "/a", SecurityHeaders: tc.overrides})
+
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			headers := map[string]string{}
+			for _, h := range []string{"Strict-Transport-Security", "X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy", "Content-Security-Policy"} {
+				if v := w.Header().Get(h); v != "" {
+					headers[h] = v
+				}
+			}
+			if !reflect.DeepEqual(headers, tc.expected) {
+				t.Errorf("Unexpected headers %v", headers)
+			}
+		})
+	}
+}
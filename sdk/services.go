@@ -0,0 +1,59 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miracl/mrpc"
+)
+
+var (
+	// ErrUnknownService is returned on registration of an endpoint with a
+	// service not added with WithService.
+	ErrUnknownService = errors.New("unknown MRPC service")
+)
+
+// WithService adds an MRPC service, e.g. on another broker or with other
+// credentials, selected by name with Endpoint.Service. Like the main
+// service, it is served and stopped by its owner.
+func WithService(name string, s *mrpc.Service) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if s == nil {
+			return ErrNoService
+		}
+		if pxy.services == nil {
+			pxy.services = map[string]*mrpc.Service{}
+		}
+		pxy.services[name] = s
+		return nil
+	}
+}
+
+// endpointService returns the MRPC service of ep, the main service when
+// unset.
+func (pxy *Proxy) endpointService(ep Endpoint) (*mrpc.Service, error) {
+	if ep.Service == "" {
+		return pxy.MRPCService, nil
+	}
+
+	s, ok := pxy.services[ep.Service]
+	if !ok {
+		return nil, ErrUnknownService
+	}
+	return s, nil
+}
+
+type serviceKey struct{}
+
+func withService(ctx context.Context, s *mrpc.Service) context.Context {
+	return context.WithValue(ctx, serviceKey{}, s)
+}
+
+// mrpcService returns the MRPC service of the endpoint ctx belongs to, or
+// else the main service.
+func (pxy *Proxy) mrpcService(ctx context.Context) *mrpc.Service {
+	if s, ok := ctx.Value(serviceKey{}).(*mrpc.Service); ok {
+		return s
+	}
+	return pxy.MRPCService
+}
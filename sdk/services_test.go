@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestServices(t *testing.T) {
+	newService := func(body string) *mrpc.Service {
+		service, _ := mrpc.NewService(mem.New())
+		service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(body)})
+			w.Write(msg)
+		})
+		service.HandleFunc("raw", func(w mrpc.TopicWriter, data []byte) {
+			w.Write([]byte(body))
+		})
+		go service.Serve()
+		return service
+	}
+	main, other := newService("main"), newService("other")
+	defer main.Stop(nil)
+	defer other.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", main, WithService("other", other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.Handle(
+		Endpoint{Method: "GET", Path: "/main", Topic: "a"},
+		Endpoint{Method: "GET", Path: "/other", Topic: "a", Service: "other"},
+		Endpoint{Method: "GET", Path: "/raw", Topic: "raw", Service: "other", RawBody: true},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/main", "main"},
+		{"/other", "other"},
+		{"/raw", "other"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: %v %q; expected %q", w.Code, w.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func TestServiceErrors(t *testing.T) {
+	if _, err := New(":80", &mrpc.Service{}, WithService("other", nil)); err != (FuncOptsError{ErrNoService}) {
+		t.Errorf("Unexpected option error: %v", err)
+	}
+
+	pxy, _ := New(":80", &mrpc.Service{})
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/a", Topic: "a", Service: "missing"}); err != ErrUnknownService {
+		t.Errorf("Unexpected handle error: %v", err)
+	}
+}
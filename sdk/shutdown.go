@@ -0,0 +1,81 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// ShutdownStats reports the requests in flight when the shutdown started.
+type ShutdownStats struct {
+	// Drained requests completed before the shutdown deadline.
+	Drained int
+	// Aborted requests were cancelled when the deadline expired.
+	Aborted int
+}
+
+// OnShutdown registers f to be run by Shutdown after the requests are drained.
+func (pxy *Proxy) OnShutdown(f func()) {
+	pxy.shutdownHooks = append(pxy.shutdownHooks, f)
+}
+
+// InFlight returns the number of endpoint requests currently being handled.
+func (pxy *Proxy) InFlight() int {
+	return int(atomic.LoadInt64(&pxy.inFlight))
+}
+
+// Shutdown stops accepting new requests and waits for the in-flight requests
+// until ctx is done. Requests still running after that have their MRPC calls
+// cancelled. The registered OnShutdown hooks are run before returning.
+func (pxy *Proxy) Shutdown(ctx context.Context) (ShutdownStats, error) {
+	atomic.StoreInt32(&pxy.shuttingDown, 1)
+	inFlight := pxy.InFlight()
+
+	err := pxy.http.Shutdown(ctx)
+
+	stats := ShutdownStats{Aborted: pxy.InFlight()}
+	if stats.Aborted > inFlight {
+		inFlight = stats.Aborted
+	}
+	stats.Drained = inFlight - stats.Aborted
+	pxy.cancel()
+
+	pxy.Logger.Printf("shutdown: drained %v, aborted %v requests", stats.Drained, stats.Aborted)
+
+	for _, f := range pxy.shutdownHooks {
+		f()
+	}
+
+	return stats, err
+}
+
+// track counts the in-flight requests and rejects new ones during shutdown,
+// in maintenance mode and over MaxInFlight.
+func (pxy *Proxy) track(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if atomic.LoadInt32(&pxy.shuttingDown) == 1 {
+			pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusServiceUnavailable, RequestIDFromContext(r.Context()))
+			w.Header().Set("Connection", "close")
+			pxy.writeError(w, r, http.StatusServiceUnavailable, ErrShuttingDown)
+			return
+		}
+
+		if m := pxy.Maintenance(); m.Enabled {
+			pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusServiceUnavailable, RequestIDFromContext(r.Context()))
+			pxy.writeMaintenance(w, r, m)
+			return
+		}
+
+		if !pxy.admit() {
+			pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, http.StatusServiceUnavailable, RequestIDFromContext(r.Context()))
+			w.Header().Set("Retry-After", "1")
+			pxy.writeError(w, r, http.StatusServiceUnavailable, ErrOverloaded)
+			return
+		}
+		defer atomic.AddInt64(&pxy.inFlight, -1)
+
+		h(w, r, p)
+	}
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestShutdown(t *testing.T) {
+	cases := []struct {
+		timeout time.Duration
+		stats   ShutdownStats
+		err     error
+	}{
+		{time.Second, ShutdownStats{Drained: 1}, nil},
+		{10 * time.Millisecond, ShutdownStats{Aborted: 1}, context.DeadlineExceeded},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			port := *portFlag + 1
+			service, _ := mrpc.NewService(mem.New())
+			service.HandleFunc("slow", func(w mrpc.TopicWriter, data []byte) {
+				time.Sleep(100 * time.Millisecond)
+				msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+				w.Write(msg)
+			})
+
+			pxy, _ := New(fmt.Sprintf(":%v", port), service)
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: "service.slow", Method: "GET", Path: "/slow"})
+
+			hookRun := false
+			pxy.OnShutdown(func() { hookRun = true })
+
+			go pxy.Serve()
+			time.Sleep(100 * time.Millisecond)
+
+			go http.Get(fmt.Sprintf("http://127.0.0.1:%v/slow", port))
+			time.Sleep(20 * time.Millisecond)
+
+			if pxy.InFlight() != 1 {
+				t.Fatalf("Unexpected in-flight requests: %v", pxy.InFlight())
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			stats, err := pxy.Shutdown(ctx)
+
+			if err != tc.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if stats != tc.stats {
+				t.Errorf("Unexpected stats: got %+v want %+v", stats, tc.stats)
+			}
+
+			if !hookRun {
+				t.Error("Shutdown hook not run")
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// DefaultSignatureHeader is the request header carrying the HMAC
+	// signature by default.
+	DefaultSignatureHeader = "X-Signature"
+
+	defaultSignatureMaxBodySize = 1 << 20
+)
+
+// Signature algorithms.
+const (
+	SignatureSHA1   = "sha1"
+	SignatureSHA256 = "sha256"
+	SignatureSHA512 = "sha512"
+)
+
+// Signature encodings.
+const (
+	SignatureHex    = "hex"
+	SignatureBase64 = "base64"
+)
+
+var signatureHashes = map[string]func() hash.Hash{
+	SignatureSHA1:   sha1.New,
+	SignatureSHA256: sha256.New,
+	SignatureSHA512: sha512.New,
+}
+
+var (
+	// ErrNoSignature is returned when the request doesn't carry the signature
+	// header.
+	ErrNoSignature = errors.New("no signature")
+	// ErrInvalidSignature is returned when the signature doesn't match the
+	// request body.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrUnsupportedSignature is returned on configuration of an unknown
+	// signature algorithm or encoding.
+	ErrUnsupportedSignature = errors.New("unsupported signature algorithm or encoding")
+	// ErrNoSignatureSecret is returned when SignatureConfig has no secrets.
+	ErrNoSignatureSecret = errors.New("no signature secret configured")
+)
+
+// SecretProvider returns the secrets accepted for r. Returning several
+// secrets allows rotating them.
+type SecretProvider func(r *http.Request) ([][]byte, error)
+
+// StaticSecrets returns a SecretProvider accepting secrets for all requests.
+func StaticSecrets(secrets ...[]byte) SecretProvider {
+	return func(r *http.Request) ([][]byte, error) {
+		return secrets, nil
+	}
+}
+
+// SignatureConfig configures verification of HMAC signed request bodies, as
+// sent by webhook providers.
+type SignatureConfig struct {
+	// Header carrying the signature. Defaults to X-Signature.
+	Header string
+	// Algorithm of the HMAC, sha1, sha256 or sha512. Defaults to sha256.
+	Algorithm string
+	// Encoding of the signature, hex or base64. Defaults to hex.
+	Encoding string
+	// Prefix is stripped from the header value, e.g. "sha256=".
+	Prefix string
+	// Secrets provides the HMAC keys.
+	Secrets SecretProvider
+	// MaxBodySize is the largest body in bytes that is read for verification.
+	// Defaults to 1 MiB.
+	MaxBodySize int64
+}
+
+// WithSignatureVerification rejects requests without a valid HMAC signature
+// of their body with 401.
+func WithSignatureVerification(cfg SignatureConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		mw, err := pxy.SignatureVerification(cfg)
+		if err != nil {
+			return err
+		}
+
+		pxy.Use(mw)
+		return nil
+	}
+}
+
+// SignatureVerification returns a middleware verifying signatures as
+// WithSignatureVerification, for use on groups or single endpoints.
+func (pxy *Proxy) SignatureVerification(cfg SignatureConfig) (Middleware, error) {
+	if cfg.Header == "" {
+		cfg.Header = DefaultSignatureHeader
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = SignatureSHA256
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = SignatureHex
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = defaultSignatureMaxBodySize
+	}
+	if _, ok := signatureHashes[cfg.Algorithm]; !ok {
+		return nil, ErrUnsupportedSignature
+	}
+	if cfg.Encoding != SignatureHex && cfg.Encoding != SignatureBase64 {
+		return nil, ErrUnsupportedSignature
+	}
+	if cfg.Secrets == nil {
+		return nil, ErrNoSignatureSecret
+	}
+
+	return func(ep Endpoint, next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			status, err := cfg.verify(r)
+			if err != nil {
+				printlnCtx(r.Context(), pxy.Debugger, err)
+				pxy.logRequest(r, "%v:%v, status: %v, Id: %v", r.Method, r.URL.Path, status, RequestIDFromContext(r.Context()))
+				pxy.writeError(w, r, status, err)
+				return
+			}
+
+			next(w, r, p)
+		}
+	}, nil
+}
+
+// verify checks the signature of the body of r and restores the body for the
+// next handlers. It returns the status to answer with on failure.
+func (cfg SignatureConfig) verify(r *http.Request) (int, error) {
+	value := r.Header.Get(cfg.Header)
+	if value == "" {
+		return http.StatusUnauthorized, ErrNoSignature
+	}
+	if !strings.HasPrefix(value, cfg.Prefix) {
+		return http.StatusUnauthorized, ErrInvalidSignature
+	}
+
+	var sig []byte
+	var err error
+	if cfg.Encoding == SignatureBase64 {
+		sig, err = base64.StdEncoding.DecodeString(value[len(cfg.Prefix):])
+	} else {
+		sig, err = hex.DecodeString(value[len(cfg.Prefix):])
+	}
+	if err != nil {
+		return http.StatusUnauthorized, ErrInvalidSignature
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, cfg.MaxBodySize)); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return http.StatusRequestEntityTooLarge, err
+			}
+			return http.StatusBadRequest, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	secrets, err := cfg.Secrets(r)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	for _, secret := range secrets {
+		mac := hmac.New(signatureHashes[cfg.Algorithm], secret)
+		mac.Write(body)
+		if hmac.Equal(mac.Sum(nil), sig) {
+			return http.StatusOK, nil
+		}
+	}
+
+	return http.StatusUnauthorized, ErrInvalidSignature
+}
@@ -0,0 +1,153 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func hmacSum(h func() hash.Hash, secret, body string) []byte {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+func TestSignatureVerification(t *testing.T) {
+	body := `{"event":"paid"}`
+
+	cases := []struct {
+		cfg    SignatureConfig
+		header string
+		value  string
+		body   string
+		status int
+	}{
+		{
+			cfg:    SignatureConfig{Secrets: StaticSecrets([]byte("secret"))},
+			header: DefaultSignatureHeader,
+			value:  hex.EncodeToString(hmacSum(sha256.New, "secret", body)),
+			status: http.StatusOK,
+		},
+		{
+			cfg:    SignatureConfig{Secrets: StaticSecrets([]byte("secret"))},
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    SignatureConfig{Secrets: StaticSecrets([]byte("secret"))},
+			header: DefaultSignatureHeader,
+			value:  hex.EncodeToString(hmacSum(sha256.New, "other", body)),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    SignatureConfig{Secrets: StaticSecrets([]byte("old"), []byte("new"))},
+			header: DefaultSignatureHeader,
+			value:  hex.EncodeToString(hmacSum(sha256.New, "new", body)),
+			status: http.StatusOK,
+		},
+		{
+			cfg:    SignatureConfig{Header: "X-Hub-Signature", Prefix: "sha1=", Algorithm: SignatureSHA1, Secrets: StaticSecrets([]byte("secret"))},
+			header: "X-Hub-Signature",
+			value:  "sha1=" + hex.EncodeToString(hmacSum(sha1.New, "secret", body)),
+			status: http.StatusOK,
+		},
+		{
+			cfg:    SignatureConfig{Header: "X-Hub-Signature", Prefix: "sha1=", Algorithm: SignatureSHA1, Secrets: StaticSecrets([]byte("secret"))},
+			header: "X-Hub-Signature",
+			value:  hex.EncodeToString(hmacSum(sha1.New, "secret", body)),
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    SignatureConfig{Encoding: SignatureBase64, Secrets: StaticSecrets([]byte("secret"))},
+			header: DefaultSignatureHeader,
+			value:  base64.StdEncoding.EncodeToString(hmacSum(sha256.New, "secret", body)),
+			status: http.StatusOK,
+		},
+		{
+			cfg:    SignatureConfig{Secrets: StaticSecrets([]byte("secret"))},
+			header: DefaultSignatureHeader,
+			value:  "not hex",
+			status: http.StatusUnauthorized,
+		},
+		{
+			cfg:    SignatureConfig{MaxBodySize: 4, Secrets: StaticSecrets([]byte("secret"))},
+			header: DefaultSignatureHeader,
+			value:  hex.EncodeToString(hmacSum(sha256.New, "secret", body)),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg: SignatureConfig{Secrets: func(r *http.Request) ([][]byte, error) {
+				return nil, errors.New("secret store down")
+			}},
+			header: DefaultSignatureHeader,
+			value:  hex.EncodeToString(hmacSum(sha256.New, "secret", body)),
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			pxy, err := New(":80", service, WithSignatureVerification(tc.cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pxy.Debugger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+
+			var received string
+			h := pxy.wrap(Endpoint{}, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+				b, _ := ioutil.ReadAll(r.Body)
+				received = string(b)
+			})
+
+			req, _ := http.NewRequest("POST", "/hook", strings.NewReader(body))
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			rr := httptest.NewRecorder()
+			h(rr, req, nil)
+
+			if rr.Code != tc.status {
+				t.Errorf("Unexpected status: got %v want %v", rr.Code, tc.status)
+			}
+			if tc.status == http.StatusOK && received != body {
+				t.Errorf("Unexpected body %q", received)
+			}
+		})
+	}
+}
+
+func TestSignatureVerificationConfig(t *testing.T) {
+	cases := []struct {
+		cfg SignatureConfig
+		err error
+	}{
+		{SignatureConfig{}, ErrNoSignatureSecret},
+		{SignatureConfig{Algorithm: "md5", Secrets: StaticSecrets([]byte("a"))}, ErrUnsupportedSignature},
+		{SignatureConfig{Encoding: "base32", Secrets: StaticSecrets([]byte("a"))}, ErrUnsupportedSignature},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			_, err := New(":80", service, WithSignatureVerification(tc.cfg))
+			if err != (FuncOptsError{tc.err}) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
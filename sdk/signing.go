@@ -0,0 +1,18 @@
+package sdk
+
+import (
+	"github.com/miracl/mrpcproxy"
+)
+
+// WithRequestSigning sends the MRPC requests in a mrpcproxy.SignedMessage
+// signed with key, so services can verify with mrpcproxy.VerifyRequest that
+// they were published by the proxy.
+func WithRequestSigning(key mrpcproxy.SigningKey) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if key.Secret == nil && key.PrivateKey == nil {
+			return mrpcproxy.ErrNoSigningKey
+		}
+		pxy.signingKey = &key
+		return nil
+	}
+}
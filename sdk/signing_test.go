@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestRequestSigning(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		key  mrpcproxy.SigningKey
+		keys []mrpcproxy.VerificationKey
+		err  error
+	}{
+		{
+			mrpcproxy.SigningKey{ID: "k1", Secret: []byte("secret")},
+			[]mrpcproxy.VerificationKey{{ID: "k1", Secret: []byte("secret")}},
+			nil,
+		},
+		{
+			mrpcproxy.SigningKey{ID: "k2", Secret: []byte("secret")},
+			[]mrpcproxy.VerificationKey{{ID: "k1", Secret: []byte("old")}, {ID: "k2", Secret: []byte("secret")}},
+			nil,
+		},
+		{
+			mrpcproxy.SigningKey{ID: "k1", Secret: []byte("secret")},
+			[]mrpcproxy.VerificationKey{{ID: "k1", Secret: []byte("other")}},
+			mrpcproxy.ErrInvalidSignature,
+		},
+		{
+			mrpcproxy.SigningKey{PrivateKey: priv},
+			[]mrpcproxy.VerificationKey{{PublicKey: pub}},
+			nil,
+		},
+		{
+			mrpcproxy.SigningKey{PrivateKey: priv},
+			[]mrpcproxy.VerificationKey{{PublicKey: otherPub}},
+			mrpcproxy.ErrInvalidSignature,
+		},
+		{
+			mrpcproxy.SigningKey{PrivateKey: priv},
+			[]mrpcproxy.VerificationKey{{Secret: []byte("secret")}},
+			mrpcproxy.ErrUnknownKey,
+		},
+		{
+			mrpcproxy.SigningKey{ID: "k3", Secret: []byte("secret")},
+			[]mrpcproxy.VerificationKey{{ID: "k1", Secret: []byte("secret")}},
+			mrpcproxy.ErrUnknownKey,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			var verifyErr error
+			var topic string
+			service, _ := mrpc.NewService(mem.New())
+			service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+				var req *mrpcproxy.Request
+				if req, verifyErr = mrpcproxy.VerifyRequest(data, mrpcproxy.JSONEncoding, tc.keys...); verifyErr == nil {
+					topic = req.Topic
+				}
+				msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+				w.Write(msg)
+			})
+			go service.Serve()
+			defer service.Stop(nil)
+			time.Sleep(1 * time.Millisecond)
+
+			pxy, err := New(":80", service, WithRequestSigning(tc.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := pxy.Call(context.Background(), "a", pxy.newRequest("id", "a", "GET"), time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if verifyErr != tc.err {
+				t.Errorf("Unexpected verification error: got %v want %v", verifyErr, tc.err)
+			}
+			if tc.err == nil && topic != "a" {
+				t.Errorf("Unexpected topic %q", topic)
+			}
+		})
+	}
+}
+
+func TestRequestSigningNoKey(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	_, err := New(":80", service, WithRequestSigning(mrpcproxy.SigningKey{ID: "k1"}))
+	if err != (FuncOptsError{mrpcproxy.ErrNoSigningKey}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
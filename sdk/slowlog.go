@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBounds are the upper bounds of the latency histogram buckets.
+var DefaultLatencyBounds = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// LatencyHistogram counts the endpoint requests by latency.
+type LatencyHistogram struct {
+	// Bounds are the upper bounds of the buckets. Counts has an extra last
+	// bucket for the requests over the last bound.
+	Bounds []time.Duration `json:"bounds"`
+	Counts []int64         `json:"counts"`
+	// Slow is the number of requests over the slow request threshold.
+	Slow int64 `json:"slow"`
+}
+
+// slowLog logs the requests over threshold and counts all by latency.
+type slowLog struct {
+	threshold time.Duration
+	bounds    []time.Duration
+	counts    []int64
+	slow      int64
+}
+
+// WithSlowRequestLog logs the endpoint requests taking threshold or more to
+// Logger, with the time spent in each phase, see Timings. The latencies of
+// all requests are counted in the histogram returned by Latencies.
+func WithSlowRequestLog(threshold time.Duration) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.slowLog = &slowLog{
+			threshold: threshold,
+			bounds:    DefaultLatencyBounds,
+			counts:    make([]int64, len(DefaultLatencyBounds)+1),
+		}
+		return nil
+	}
+}
+
+// Latencies returns the latency histogram of the endpoint requests. It is
+// empty without WithSlowRequestLog.
+func (pxy *Proxy) Latencies() LatencyHistogram {
+	l := pxy.slowLog
+	if l == nil {
+		return LatencyHistogram{}
+	}
+
+	h := LatencyHistogram{
+		Bounds: l.bounds,
+		Counts: make([]int64, len(l.counts)),
+		Slow:   atomic.LoadInt64(&l.slow),
+	}
+	for i := range l.counts {
+		h.Counts[i] = atomic.LoadInt64(&l.counts[i])
+	}
+	return h
+}
+
+// logSlowRequest counts the latency of the request r to ep answered with
+// status and logs it when slow.
+func (pxy *Proxy) logSlowRequest(r *http.Request, ep Endpoint, status int, t Timings) {
+	l := pxy.slowLog
+	l.observe(t.Total)
+	if t.Total < l.threshold {
+		return
+	}
+
+	atomic.AddInt64(&l.slow, 1)
+	printfCtx(r.Context(), pxy.Logger, "slow request %v:%v, status: %v, topic: %v, Id: %v, total: %v, routing: %v, read: %v, mrpc: %v, transform: %v, write: %v",
+		r.Method, r.URL.Path, status, ep.Topic, RequestIDFromContext(r.Context()), t.Total,
+		t.Routing, t.Read, t.MRPC, t.Transform, t.Write)
+}
+
+// observe counts a request taking d.
+func (l *slowLog) observe(d time.Duration) {
+	i := 0
+	for i < len(l.bounds) && d > l.bounds[i] {
+		i++
+	}
+	atomic.AddInt64(&l.counts[i], 1)
+}
@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestSlowRequestLog(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("fast", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	service.HandleFunc("slow", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(30 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 201})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithSlowRequestLog(20*time.Millisecond))
+	pxy.GetID = func() string { return "uuid" }
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/fast", Topic: "fast"},
+		Endpoint{Method: "GET", Path: "/slow", Topic: "slow"},
+	)
+
+	slowLine := regexp.MustCompile(`^slow request GET:/slow, status: 201, topic: slow, Id: uuid, total: \S+, routing: \S+, read: \S+, mrpc: \d+(\.\d+)?ms, transform: \S+, write: \S+$`)
+
+	cases := []struct {
+		path string
+		slow bool
+	}{
+		{"/fast", false},
+		{"/slow", true},
+		{"/fast", false},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			l := &MockLogger{}
+			pxy.Logger = l
+
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			pxy.ServeHTTP(httptest.NewRecorder(), r)
+
+			var slow []string
+			for _, line := range l.storage {
+				if regexp.MustCompile(`^slow request`).MatchString(line) {
+					slow = append(slow, line)
+				}
+			}
+			if tc.slow != (len(slow) == 1) || tc.slow && !slowLine.MatchString(slow[0]) {
+				t.Errorf("Unexpected slow request log: %q", slow)
+			}
+		})
+	}
+
+	h := pxy.Latencies()
+	var total int64
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total != 3 || h.Slow != 1 || len(h.Counts) != len(h.Bounds)+1 {
+		t.Errorf("Unexpected histogram: %+v", h)
+	}
+	// Only the slow request is counted over 25ms.
+	var over int64
+	for _, n := range h.Counts[3:] {
+		over += n
+	}
+	if over != 1 {
+		t.Errorf("Unexpected histogram counts: %v", h.Counts)
+	}
+	if info := pxy.DebugInfo(); info.Latencies == nil || info.Latencies.Slow != 1 {
+		t.Errorf("Unexpected debug info latencies: %+v", info.Latencies)
+	}
+}
+
+func TestLatencyHistogramDisabled(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{})
+	if h := pxy.Latencies(); h.Counts != nil || pxy.DebugInfo().Latencies != nil {
+		t.Errorf("Unexpected histogram: %+v", h)
+	}
+}
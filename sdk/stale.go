@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/miracl/mrpcproxy"
+)
+
+// States of cached responses.
+const (
+	cacheFresh = iota
+	cacheRevalidate
+	cacheStale
+)
+
+// Suffixes of the keys marking the state of cached responses kept past their
+// ttl.
+const (
+	freshMarker      = "\nfresh"
+	revalidateMarker = "\nrevalidate"
+	staleMarker      = "\nstale"
+)
+
+var cacheMarker = &mrpcproxy.Response{}
+
+// WithStaleCache keeps cached responses past their ttl. For revalidate
+// after it, expired responses are served while being refreshed in the
+// background, and for ifError they are served when the MRPC request fails or
+// answers 5xx. The stale-while-revalidate and stale-if-error Cache-Control
+// directives of the responses override them.
+func WithStaleCache(revalidate, ifError time.Duration) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.staleRevalidate = revalidate
+		pxy.staleIfError = ifError
+		return nil
+	}
+}
+
+// staleWindows returns how long a response with headers h may be served
+// while revalidating and on errors after its ttl.
+func (pxy *Proxy) staleWindows(h http.Header) (revalidate, ifError time.Duration) {
+	revalidate, ifError = pxy.staleRevalidate, pxy.staleIfError
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		name, value, ok := strings.Cut(directive, "=")
+		s, err := strconv.Atoi(value)
+		if !ok || err != nil {
+			continue
+		}
+		switch name {
+		case "stale-while-revalidate":
+			revalidate = time.Duration(s) * time.Second
+		case "stale-if-error":
+			ifError = time.Duration(s) * time.Second
+		}
+	}
+	return revalidate, ifError
+}
+
+// setCacheMarkers marks the response cached under key for keep as fresh for
+// ttl and servable while revalidating for revalidate after it.
+func (pxy *Proxy) setCacheMarkers(ctx context.Context, key string, ttl, revalidate, keep time.Duration) {
+	pxy.cacheSet(ctx, key+staleMarker, cacheMarker, keep)
+	pxy.cacheSet(ctx, key+freshMarker, cacheMarker, ttl)
+	if revalidate > 0 {
+		pxy.cacheSet(ctx, key+revalidateMarker, cacheMarker, ttl+revalidate)
+	}
+}
+
+// cacheState returns the state of the response cached under key.
+func (pxy *Proxy) cacheState(ctx context.Context, key string) int {
+	if _, ok := pxy.cacheGet(ctx, key+staleMarker); !ok {
+		// Cached without stale windows.
+		return cacheFresh
+	}
+	if _, ok := pxy.cacheGet(ctx, key+freshMarker); ok {
+		return cacheFresh
+	}
+	if _, ok := pxy.cacheGet(ctx, key+revalidateMarker); ok {
+		return cacheRevalidate
+	}
+	return cacheStale
+}
+
+// revalidate refreshes the response cached under key in the background,
+// once at a time per key.
+func (pxy *Proxy) revalidate(r *http.Request, p httprouter.Params, ep Endpoint, key string) {
+	pxy.revalidatingMu.Lock()
+	if pxy.revalidating[key] {
+		pxy.revalidatingMu.Unlock()
+		return
+	}
+	if pxy.revalidating == nil {
+		pxy.revalidating = map[string]bool{}
+	}
+	pxy.revalidating[key] = true
+	pxy.revalidatingMu.Unlock()
+
+	// The refresh outlives the request but serves the same tenant.
+	ctx := r.Context()
+	r = r.Clone(context.WithValue(pxy.ctx, tenantKey{}, TenantFromContext(ctx)))
+	go func() {
+		defer func() {
+			pxy.revalidatingMu.Lock()
+			delete(pxy.revalidating, key)
+			pxy.revalidatingMu.Unlock()
+		}()
+
+		res, err := pxy.mrpcRequest(r, p, ep)
+		if err != nil {
+			printfCtx(ctx, pxy.Debugger, "revalidating %v: %v", key, err)
+			return
+		}
+		pxy.storeResponse(r, key, res)
+	}()
+}
@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestStaleCache(t *testing.T) {
+	var calls, failing int32
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("items", func(w mrpc.TopicWriter, data []byte) {
+		n := atomic.AddInt32(&calls, 1)
+		res := &mrpcproxy.Response{Code: 200, Msg: []byte(strconv.Itoa(int(n)))}
+		if atomic.LoadInt32(&failing) == 1 {
+			res = &mrpcproxy.Response{Code: 503}
+		}
+		msg, _ := json.Marshal(res)
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	var offset int64
+	start := time.Now()
+	cache := NewLRUCache(100)
+	cache.lru.now = func() time.Time { return start.Add(time.Duration(atomic.LoadInt64(&offset))) }
+
+	pxy, _ := New(":80", service, WithCache(cache, time.Minute), WithStaleCache(time.Minute, time.Hour))
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(Endpoint{Method: "GET", Path: "/items", Topic: "items"})
+
+	cases := []struct {
+		at      time.Duration
+		failing bool
+		status  int
+		body    string
+		calls   int32
+	}{
+		{0, false, 200, "1", 1},
+		{30 * time.Second, false, 200, "1", 1},
+		// Stale while revalidating in the background.
+		{90 * time.Second, false, 200, "1", 2},
+		{100 * time.Second, false, 200, "2", 2},
+		// Stale on errors after the revalidate window.
+		{5 * time.Minute, true, 200, "2", 3},
+		{2 * time.Hour, true, 503, "", 4},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			atomic.StoreInt64(&offset, int64(tc.at))
+			if tc.failing {
+				atomic.StoreInt32(&failing, 1)
+			}
+
+			r, _ := http.NewRequest("GET", "/items", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: got %v %q want %v %q", w.Code, w.Body.String(), tc.status, tc.body)
+			}
+
+			for j := 0; j < 100 && atomic.LoadInt32(&calls) < tc.calls; j++ {
+				time.Sleep(time.Millisecond)
+			}
+			// Let the background refresh store its response.
+			time.Sleep(5 * time.Millisecond)
+			if n := atomic.LoadInt32(&calls); n != tc.calls {
+				t.Errorf("Unexpected MRPC calls: got %v want %v", n, tc.calls)
+			}
+		})
+	}
+}
+
+func TestStaleWindows(t *testing.T) {
+	pxy := &Proxy{staleRevalidate: time.Second, staleIfError: time.Minute}
+
+	cases := []struct {
+		cc                  string
+		revalidate, ifError time.Duration
+	}{
+		{"", time.Second, time.Minute},
+		{"max-age=60, stale-while-revalidate=30", 30 * time.Second, time.Minute},
+		{"stale-if-error=600", time.Second, 10 * time.Minute},
+		{"stale-while-revalidate=x", time.Second, time.Minute},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			revalidate, ifError := pxy.staleWindows(http.Header{"Cache-Control": {tc.cc}})
+			if revalidate != tc.revalidate || ifError != tc.ifError {
+				t.Errorf("Unexpected windows: got %v %v want %v %v", revalidate, ifError, tc.revalidate, tc.ifError)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const spaIndex = "index.html"
+
+// ServeStatic serves the files in dir under the path prefix. The prefix must
+// not overlap with the endpoint paths.
+func (pxy *Proxy) ServeStatic(prefix, dir string) {
+	fs := http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.FileServer(http.Dir(dir)))
+	h := pxy.accessLogHandler(pxy.logStatic(fs), "")
+	handle := func(w http.ResponseWriter, r *http.Request, p httprouter.Params) { h.ServeHTTP(w, r) }
+	pxy.addRoute(route{"", "GET", path.Join(prefix, "/*filepath"), handle, false})
+	pxy.addRoute(route{"", "HEAD", path.Join(prefix, "/*filepath"), handle, false})
+}
+
+// ServeSPA serves a single page application from dir. GET requests not matched
+// by any endpoint are answered with the matching file in dir or with
+// index.html, so client side routes work on reload.
+func (pxy *Proxy) ServeSPA(dir string) {
+	pxy.spaDir = dir
+}
+
+// spaHandler serves GET requests with the SPA files and everything else with
+// the 404 handler.
+type spaHandler struct {
+	files    http.Handler
+	notFound http.Handler
+}
+
+func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	h.files.ServeHTTP(w, r)
+}
+
+// spaFiles serves the files in dir falling back to index.html.
+func spaFiles(dir string) http.Handler {
+	fs := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+			http.ServeFile(w, r, filepath.Join(dir, spaIndex))
+			return
+		}
+
+		fs.ServeHTTP(w, r)
+	})
+}
+
+func (pxy *Proxy) logStatic(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		pxy.logRequest(r, "%v:%v, status: %v", r.Method, r.URL.Path, sw.status)
+	})
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestServeStaticAndSPA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("index"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0644)
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	l := &MockLogger{}
+	pxy.Requests = l
+	pxy.ServeStatic("/assets/", dir)
+	pxy.ServeSPA(dir)
+	pxy.notFound = pxy.notFoundHandler()
+
+	cases := []struct {
+		method string
+		url    string
+		status int
+		body   string
+		log    string
+	}{
+		{"GET", "/assets/app.js", http.StatusOK, "app", "GET:/assets/app.js, status: 200"},
+		{"GET", "/assets/missing.js", http.StatusNotFound, "404 page not found\n", "GET:/assets/missing.js, status: 404"},
+		{"GET", "/app.js", http.StatusOK, "app", "GET:/app.js, status: 200"},
+		{"GET", "/users/1", http.StatusOK, "index", "GET:/users/1, status: 200"},
+		{"GET", "/", http.StatusOK, "index", "GET:/, status: 200"},
+		{"POST", "/users/1", http.StatusNotFound, "", "POST:/users/1, status: 404"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.url, nil)
+			rr := httptest.NewRecorder()
+			pxy.router.ServeHTTP(rr, r)
+
+			if rr.Code != tc.status {
+				t.Errorf("Unexpected status: got %v want %v", rr.Code, tc.status)
+			}
+
+			if rr.Body.String() != tc.body {
+				t.Errorf("Unexpected body: got %q want %q", rr.Body.String(), tc.body)
+			}
+
+			if l.storage[len(l.storage)-1] != tc.log {
+				t.Errorf("Unexpected log: got %v want %v", l.storage[len(l.storage)-1], tc.log)
+			}
+		})
+	}
+}
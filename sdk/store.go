@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+var (
+	// ErrNotCounter is returned by Incr for keys holding other values.
+	ErrNotCounter = errors.New("value is not a counter")
+)
+
+// Store is a key-value store with expiring entries shared by proxy
+// instances, e.g. RedisStore. NewStoreCache and NewStoreIdempotencyStore
+// adapt it for the cache and the idempotency keys, and its counters back
+// limits enforced across instances.
+type Store interface {
+	// Get returns the value of key and whether it exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr increments the counter at key and returns its new value. Missing
+	// counters start at 0 and expire after ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// MemoryStore is an in-memory Store evicting the least recently used
+// entries. It isn't shared by proxy instances.
+type MemoryStore struct {
+	lru *lru
+}
+
+// NewMemoryStore creates a store holding at most size entries.
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{newLRU(size)}
+}
+
+// Get returns the value of key if it hasn't expired.
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, ok := s.lru.get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if n, ok := v.(int64); ok {
+		return []byte(strconv.FormatInt(n, 10)), true, nil
+	}
+	return v.([]byte), true, nil
+}
+
+// Set stores a copy of value under key for ttl.
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.lru.set(key, append([]byte(nil), value...), ttl)
+	return nil
+}
+
+// Incr increments the counter at key.
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return s.lru.incr(key, ttl)
+}
+
+// StoreCache is a Cache keeping the responses in a Store. Store errors are
+// treated as cache misses.
+type StoreCache struct {
+	store Store
+}
+
+// NewStoreCache creates a cache keeping the responses in s.
+func NewStoreCache(s Store) *StoreCache {
+	return &StoreCache{s}
+}
+
+// Get returns the cached response for key.
+func (c *StoreCache) Get(key string) (*mrpcproxy.Response, bool) {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext returns the cached response for key.
+func (c *StoreCache) GetContext(ctx context.Context, key string) (*mrpcproxy.Response, bool) {
+	res := &mrpcproxy.Response{}
+	if !getJSON(ctx, c.store, "cache:"+key, res) {
+		return nil, false
+	}
+	return res, true
+}
+
+// Set caches res under key for ttl.
+func (c *StoreCache) Set(key string, res *mrpcproxy.Response, ttl time.Duration) {
+	c.SetContext(context.Background(), key, res, ttl)
+}
+
+// SetContext caches res under key for ttl.
+func (c *StoreCache) SetContext(ctx context.Context, key string, res *mrpcproxy.Response, ttl time.Duration) {
+	setJSON(ctx, c.store, "cache:"+key, res, ttl)
+}
+
+// StoreIdempotencyStore is an IdempotencyStore keeping the records in a
+// Store. Store errors are treated as missing records.
+type StoreIdempotencyStore struct {
+	store Store
+}
+
+// NewStoreIdempotencyStore creates an idempotency store keeping the records
+// in s.
+func NewStoreIdempotencyStore(s Store) *StoreIdempotencyStore {
+	return &StoreIdempotencyStore{s}
+}
+
+// Get returns the record of key.
+func (s *StoreIdempotencyStore) Get(key string) (*IdempotencyRecord, bool) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the record of key.
+func (s *StoreIdempotencyStore) GetContext(ctx context.Context, key string) (*IdempotencyRecord, bool) {
+	rec := &IdempotencyRecord{}
+	if !getJSON(ctx, s.store, "idempotency:"+key, rec) {
+		return nil, false
+	}
+	return rec, true
+}
+
+// Set stores rec under key for ttl.
+func (s *StoreIdempotencyStore) Set(key string, rec *IdempotencyRecord, ttl time.Duration) {
+	s.SetContext(context.Background(), key, rec, ttl)
+}
+
+// SetContext stores rec under key for ttl.
+func (s *StoreIdempotencyStore) SetContext(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) {
+	setJSON(ctx, s.store, "idempotency:"+key, rec, ttl)
+}
+
+func getJSON(ctx context.Context, s Store, key string, v interface{}) bool {
+	b, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func setJSON(ctx context.Context, s Store, key string, v interface{}, ttl time.Duration) {
+	if b, err := json.Marshal(v); err == nil {
+		s.Set(ctx, key, b, ttl)
+	}
+}
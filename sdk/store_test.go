@@ -0,0 +1,105 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(10)
+	ctx := context.Background()
+	now := time.Now()
+	s.lru.now = func() time.Time { return now }
+
+	value := []byte("a")
+	s.Set(ctx, "a", value, time.Second)
+	value[0] = 'b'
+	if v, ok, _ := s.Get(ctx, "a"); !ok || string(v) != "a" {
+		t.Errorf("Unexpected value %q %v", v, ok)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if n, err := s.Incr(ctx, "n", time.Second); err != nil || n != int64(i) {
+			t.Errorf("Unexpected counter %v %v", n, err)
+		}
+	}
+	if v, ok, _ := s.Get(ctx, "n"); !ok || string(v) != "2" {
+		t.Errorf("Unexpected counter value %q", v)
+	}
+	if _, err := s.Incr(ctx, "a", time.Second); err != ErrNotCounter {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expired value returned")
+	}
+	if n, _ := s.Incr(ctx, "n", time.Second); n != 1 {
+		t.Errorf("Expired counter not reset: %v", n)
+	}
+}
+
+func TestStoreAdapters(t *testing.T) {
+	store := NewMemoryStore(10)
+
+	c := NewStoreCache(store)
+	c.Set("k", &mrpcproxy.Response{Code: 200, Msg: []byte("a"), Headers: http.Header{"X-A": {"1"}}}, time.Minute)
+	if res, ok := c.Get("k"); !ok || string(res.Msg) != "a" || res.Headers.Get("X-A") != "1" {
+		t.Errorf("Unexpected cached response %+v", res)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Unexpected cache hit")
+	}
+
+	s := NewStoreIdempotencyStore(store)
+	s.Set("k", &IdempotencyRecord{"fp", &mrpcproxy.Response{Code: 201}}, time.Minute)
+	if rec, ok := s.Get("k"); !ok || rec.Fingerprint != "fp" || rec.Response.Code != 201 {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("Unexpected record")
+	}
+}
+
+// ctxStore records the contexts of its calls.
+type ctxStore struct {
+	Store
+	ctxs []context.Context
+}
+
+func (s *ctxStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.ctxs = append(s.ctxs, ctx)
+	return s.Store.Get(ctx, key)
+}
+
+func (s *ctxStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.ctxs = append(s.ctxs, ctx)
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func TestStoreAdaptersContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, 1)
+	store := &ctxStore{Store: NewMemoryStore(10)}
+
+	c := NewStoreCache(store)
+	c.SetContext(ctx, "k", &mrpcproxy.Response{Code: 200}, time.Minute)
+	c.GetContext(ctx, "k")
+
+	s := NewStoreIdempotencyStore(store)
+	s.SetContext(ctx, "k", &IdempotencyRecord{"fp", &mrpcproxy.Response{Code: 201}}, time.Minute)
+	s.GetContext(ctx, "k")
+
+	if len(store.ctxs) != 4 {
+		t.Fatalf("Unexpected calls %v", len(store.ctxs))
+	}
+	for i, got := range store.ctxs {
+		if got.Value(key{}) != 1 {
+			t.Errorf("Call %v without the context", i)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+var (
+	// ErrNoBodyFetcher is returned for responses referencing their body when
+	// no BodyFetcher is configured.
+	ErrNoBodyFetcher = errors.New("no body fetcher configured")
+	// ErrUnsupportedRef is returned by HTTPFetcher for references that
+	// aren't http or https URLs.
+	ErrUnsupportedRef = errors.New("unsupported body reference")
+)
+
+// FetchedBody is a response body opened by a BodyFetcher.
+type FetchedBody struct {
+	io.ReadCloser
+	// Size of the body in bytes, -1 when unknown.
+	Size int64
+	// ContentType is sent when the MRPC response doesn't set one.
+	ContentType string
+}
+
+// BodyFetcher opens the bodies referenced by MRPC responses with BodyRef.
+// They're streamed to the client instead of going through the bus.
+type BodyFetcher interface {
+	Fetch(ctx context.Context, ref string) (*FetchedBody, error)
+}
+
+// WithBodyFetcher streams the bodies referenced by MRPC responses from f.
+func WithBodyFetcher(f BodyFetcher) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.fetcher = f
+		return nil
+	}
+}
+
+// HTTPFetcher fetches bodies referenced by http and https URLs.
+type HTTPFetcher struct {
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Fetch gets the URL ref.
+func (f HTTPFetcher) Fetch(ctx context.Context, ref string) (*FetchedBody, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrUnsupportedRef
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("fetching body: %v", res.Status)
+	}
+
+	return &FetchedBody{res.Body, res.ContentLength, res.Header.Get("Content-Type")}, nil
+}
+
+// ObjectGetter is implemented by object storage clients able to download
+// objects.
+type ObjectGetter interface {
+	GetObject(ctx context.Context, bucket, key string) (r io.ReadCloser, size int64, err error)
+}
+
+// ObjectFetcher fetches bodies referenced by object keys in a bucket.
+type ObjectFetcher struct {
+	Client ObjectGetter
+	Bucket string
+}
+
+// Fetch downloads the object at key ref.
+func (f ObjectFetcher) Fetch(ctx context.Context, ref string) (*FetchedBody, error) {
+	r, size, err := f.Client.GetObject(ctx, f.Bucket, ref)
+	if err != nil {
+		return nil, err
+	}
+	return &FetchedBody{ReadCloser: r, Size: size}, nil
+}
+
+// fetchBody opens the body referenced by ref.
+func (pxy *Proxy) fetchBody(r *http.Request, ref string) (*FetchedBody, error) {
+	if pxy.fetcher == nil {
+		return nil, ErrNoBodyFetcher
+	}
+	return pxy.fetcher.Fetch(r.Context(), ref)
+}
+
+// streamBody writes the header and copies the fetched body to w.
+func (pxy *Proxy) streamBody(w http.ResponseWriter, r *http.Request, code int, body *FetchedBody) {
+	if w.Header().Get("Content-Type") == "" && body.ContentType != "" {
+		w.Header().Set("Content-Type", body.ContentType)
+	}
+	if body.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(body.Size, 10))
+	}
+
+	w.WriteHeader(code)
+	if _, err := io.Copy(w, body); err != nil {
+		printfCtx(r.Context(), pxy.Logger, "streaming body to http.ResponseWriter failed: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+type mockObjectGetter map[string]string
+
+func (g mockObjectGetter) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	obj, ok := g[bucket+"/"+key]
+	if !ok {
+		return nil, 0, errors.New("no such key")
+	}
+	return ioutil.NopCloser(strings.NewReader(obj)), int64(len(obj)), nil
+}
+
+func TestStreamBody(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		io.WriteString(w, "pdf content")
+	}))
+	defer origin.Close()
+
+	cases := []struct {
+		fetcher     BodyFetcher
+		res         mrpcproxy.Response
+		code        int
+		body        string
+		contentType string
+	}{
+		{HTTPFetcher{}, mrpcproxy.Response{Code: 200, BodyRef: origin.URL + "/file"}, 200, "pdf content", "application/pdf"},
+		{HTTPFetcher{}, mrpcproxy.Response{Code: 200, BodyRef: origin.URL + "/file", Headers: http.Header{"Content-Type": {"text/plain"}}}, 200, "pdf content", "text/plain"},
+		{HTTPFetcher{}, mrpcproxy.Response{Code: 200, BodyRef: origin.URL + "/missing"}, 502, "", ""},
+		{HTTPFetcher{}, mrpcproxy.Response{Code: 200, BodyRef: "file:///etc/passwd"}, 502, "", ""},
+		{ObjectFetcher{mockObjectGetter{"b/k": "object"}, "b"}, mrpcproxy.Response{Code: 201, BodyRef: "k"}, 201, "object", ""},
+		{ObjectFetcher{mockObjectGetter{}, "b"}, mrpcproxy.Response{Code: 200, BodyRef: "k"}, 502, "", ""},
+		{nil, mrpcproxy.Response{Code: 200, BodyRef: "k"}, 502, "", ""},
+		{nil, mrpcproxy.Response{Code: 200, Msg: []byte("inline")}, 200, "inline", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			service.HandleFunc("file", func(w mrpc.TopicWriter, data []byte) {
+				msg, _ := json.Marshal(&tc.res)
+				w.Write(msg)
+			})
+			go service.Serve()
+			defer service.Stop(nil)
+			time.Sleep(1 * time.Millisecond)
+
+			pxy, _ := New(":80", service, WithBodyFetcher(tc.fetcher))
+			pxy.Debugger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			pxy.Handle(Endpoint{Topic: "file", Method: "GET", Path: "/file"})
+
+			r, _ := http.NewRequest("GET", "/file", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Fatalf("Unexpected code: got %v want %v", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("Unexpected body %q", w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("Unexpected Content-Type %q", ct)
+			}
+			if tc.code < 300 && w.Header().Get("Content-Length") != fmt.Sprint(len(tc.body)) && tc.res.BodyRef != "" {
+				t.Errorf("Unexpected Content-Length %q", w.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
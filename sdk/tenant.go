@@ -0,0 +1,277 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrNoTenant is returned for requests without a tenant when the tenant
+	// is required.
+	ErrNoTenant = errors.New("tenant required")
+	// ErrTenantRateLimit is returned for requests over the rate limit of
+	// their tenant.
+	ErrTenantRateLimit = errors.New("tenant rate limit exceeded")
+	// ErrNoTenantResolver is returned by WithTenancy without a resolver.
+	ErrNoTenantResolver = errors.New("no tenant resolver")
+	// ErrInvalidTopicTemplate is returned by WithTenancy for topic templates
+	// without {topic}.
+	ErrInvalidTopicTemplate = errors.New("topic template without {topic}")
+	// ErrInvalidTenant is returned for tenants that cannot appear in topics.
+	ErrInvalidTenant = errors.New("invalid tenant")
+)
+
+const maxTopicSegmentLength = 64
+
+// TenantResolver returns the tenant of r, or "" when it has none.
+type TenantResolver func(r *http.Request) string
+
+// TenantFromHeader resolves the tenant from the request header.
+func TenantFromHeader(header string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// TenantFromSubdomain resolves the tenant from the subdomain of the request
+// host under domain, e.g. acme for acme.example.com under example.com.
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.TrimPrefix(domain, "."))
+	return func(r *http.Request) string {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return ""
+		}
+		tenant := strings.TrimSuffix(host, suffix)
+		if strings.Contains(tenant, ".") {
+			return ""
+		}
+		return tenant
+	}
+}
+
+// TenantFromPath resolves the tenant from the path segment at index, e.g.
+// acme for /tenants/acme/orders at index 1.
+func TenantFromPath(index int) TenantResolver {
+	return func(r *http.Request) string {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if index < 0 || index >= len(segments) {
+			return ""
+		}
+		return segments[index]
+	}
+}
+
+// TenantLimit is a token bucket rate limit of the requests of a tenant.
+type TenantLimit struct {
+	// Rate of the requests per second. Tenants are not limited when it is 0.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests accepted at once, 1 by default.
+	Burst int `json:"burst"`
+}
+
+// TenancyConfig configures the multi-tenant mode.
+type TenancyConfig struct {
+	// Resolver returns the tenant of the requests.
+	Resolver TenantResolver
+	// Required rejects the requests without a tenant with 400.
+	Required bool
+	// Limit applies to each tenant without one in Limits.
+	Limit TenantLimit
+	// Limits are the limits of specific tenants.
+	Limits map[string]TenantLimit
+	// Store keeps the request counters of the tenants, shared by all proxy
+	// instances for limits enforced across them. The limits are then
+	// enforced over fixed windows of Burst/Rate seconds. The tenants are
+	// limited by each instance when nil.
+	Store Store
+	// TopicTemplate routes the requests of each tenant to its own topics,
+	// e.g. tenant-{tenant}.{topic} sends the requests of acme to the endpoint
+	// topic service.a over tenant-acme.service.a. Tenants are then limited to
+	// letters, digits, - and _. Request.Topic remains the endpoint topic.
+	TopicTemplate string
+}
+
+// tenancy resolves and rate limits the tenants of the endpoint requests.
+type tenancy struct {
+	cfg TenancyConfig
+
+	// Serializes the updates of the buckets.
+	mu sync.Mutex
+	// Token buckets by tenant, without Store.
+	buckets *lru
+}
+
+// tokenBucket is the rate limit state of a tenant.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// maxTenantBuckets bounds the tracked tenants. The least recently used
+// buckets are dropped past it, and the buckets expire once refilled since
+// they are restored at the first request.
+const maxTenantBuckets = 10000
+
+// WithTenancy enables the multi-tenant mode. The tenant of the endpoint
+// requests is resolved with cfg.Resolver, rate limited and sent to the
+// services as Request.Tenant. Requests over the limit are answered 429.
+func WithTenancy(cfg TenancyConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Resolver == nil {
+			return ErrNoTenantResolver
+		}
+		if cfg.TopicTemplate != "" && !strings.Contains(cfg.TopicTemplate, "{topic}") {
+			return ErrInvalidTopicTemplate
+		}
+		pxy.tenancy = &tenancy{cfg: cfg, buckets: newLRU(maxTenantBuckets)}
+		return nil
+	}
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant of the request ctx belongs to.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenants resolves the tenant of the requests to ep and enforces its limit.
+func (pxy *Proxy) tenants(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	t := pxy.tenancy
+	if t == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		tenant := t.cfg.Resolver(r)
+		if tenant == "" {
+			if t.cfg.Required {
+				pxy.logEndpointRequest(r, http.StatusBadRequest, ep.Topic, RequestIDFromContext(r.Context()))
+				pxy.writeError(w, r, http.StatusBadRequest, ErrNoTenant)
+				return
+			}
+			h(w, r, p)
+			return
+		}
+
+		if t.cfg.TopicTemplate != "" && !validTopicSegment(tenant) {
+			pxy.logEndpointRequest(r, http.StatusBadRequest, ep.Topic, RequestIDFromContext(r.Context()))
+			pxy.writeError(w, r, http.StatusBadRequest, ErrInvalidTenant)
+			return
+		}
+
+		wait, ok, err := t.allowRequest(r.Context(), tenant, time.Now())
+		if err != nil {
+			printfCtx(r.Context(), pxy.Debugger, "rate limit of tenant %v: %v", tenant, err)
+		}
+		if !ok {
+			pxy.logEndpointRequest(r, http.StatusTooManyRequests, ep.Topic, RequestIDFromContext(r.Context()))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			pxy.writeError(w, r, http.StatusTooManyRequests, ErrTenantRateLimit)
+			return
+		}
+
+		h(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)), p)
+	}
+}
+
+// validTopicSegment reports whether s, e.g. a tenant, is safe to template
+// into topics.
+func validTopicSegment(s string) bool {
+	if len(s) > maxTopicSegmentLength {
+		return false
+	}
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// tenantTopic returns the topic serving the requests of tenant to topic.
+func (pxy *Proxy) tenantTopic(topic, tenant string) string {
+	t := pxy.tenancy
+	if t == nil || t.cfg.TopicTemplate == "" || tenant == "" {
+		return topic
+	}
+	return strings.NewReplacer("{tenant}", tenant, "{topic}", topic).Replace(t.cfg.TopicTemplate)
+}
+
+// allowRequest reports whether a request of tenant at now is within its
+// limit, counted in the Store when set. It returns how long to wait
+// otherwise. Requests are allowed when the Store fails.
+func (t *tenancy) allowRequest(ctx context.Context, tenant string, now time.Time) (time.Duration, bool, error) {
+	if t.cfg.Store == nil {
+		wait, ok := t.allow(tenant, now)
+		return wait, ok, nil
+	}
+
+	limit := t.limit(tenant)
+	if limit.Rate <= 0 {
+		return 0, true, nil
+	}
+	burst := math.Max(1, float64(limit.Burst))
+
+	window := time.Duration(burst / limit.Rate * float64(time.Second))
+	start := now.Truncate(window)
+	wait := start.Add(window).Sub(now)
+	n, err := t.cfg.Store.Incr(ctx, "tenant:"+tenant+":"+strconv.FormatInt(start.UnixNano(), 10), wait)
+	if err != nil {
+		return 0, true, err
+	}
+	return wait, float64(n) <= burst, nil
+}
+
+// allow takes a token of tenant at now. It returns how long to wait for the
+// next token when there is none.
+func (t *tenancy) allow(tenant string, now time.Time) (time.Duration, bool) {
+	limit := t.limit(tenant)
+	if limit.Rate <= 0 {
+		return 0, true
+	}
+	burst := math.Max(1, float64(limit.Burst))
+	refill := time.Duration(burst / limit.Rate * float64(time.Second))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.buckets.get(tenant)
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+	}
+	bucket := b.(*tokenBucket)
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		t.buckets.set(tenant, bucket, refill)
+		return time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second)), false
+	}
+	bucket.tokens--
+	t.buckets.set(tenant, bucket, refill)
+	return 0, true
+}
+
+// limit returns the limit of tenant.
+func (t *tenancy) limit(tenant string) TenantLimit {
+	if limit, ok := t.cfg.Limits[tenant]; ok {
+		return limit
+	}
+	return t.cfg.Limit
+}
@@ -0,0 +1,256 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	cases := []struct {
+		resolver TenantResolver
+		url      string
+		header   string
+		tenant   string
+	}{
+		{TenantFromHeader("X-Tenant"), "http://example.com/", "acme", "acme"},
+		{TenantFromHeader("X-Tenant"), "http://example.com/", "", ""},
+		{TenantFromSubdomain("example.com"), "http://acme.example.com:8080/", "", "acme"},
+		{TenantFromSubdomain("example.com"), "http://ACME.Example.com/", "", "acme"},
+		{TenantFromSubdomain("example.com"), "http://example.com/", "", ""},
+		{TenantFromSubdomain("example.com"), "http://a.b.example.com/", "", ""},
+		{TenantFromSubdomain("example.com"), "http://acme.example.org/", "", ""},
+		{TenantFromPath(1), "http://example.com/tenants/acme/orders", "", "acme"},
+		{TenantFromPath(3), "http://example.com/tenants/acme", "", ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.url, nil)
+			if tc.header != "" {
+				r.Header.Set("X-Tenant", tc.header)
+			}
+			if tenant := tc.resolver(r); tenant != tc.tenant {
+				t.Errorf("Unexpected tenant: got %q want %q", tenant, tc.tenant)
+			}
+		})
+	}
+}
+
+func TestTenancy(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("orders", func(w mrpc.TopicWriter, data []byte) {
+		req := &mrpcproxy.Request{}
+		json.Unmarshal(data, req)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(req.Tenant)})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service, WithTenancy(TenancyConfig{
+		Resolver: TenantFromHeader("X-Tenant"),
+		Required: true,
+		Limit:    TenantLimit{Rate: 0.1, Burst: 2},
+		Limits:   map[string]TenantLimit{"big": {}},
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pxy.Requests = &MockLogger{}
+	pxy.Handle(Endpoint{Method: "GET", Path: "/orders", Topic: "orders"})
+
+	cases := []struct {
+		tenant string
+		status int
+		body   string
+	}{
+		{"", 400, ""},
+		{"acme", 200, "acme"},
+		{"acme", 200, "acme"},
+		{"acme", 429, ""},
+		{"other", 200, "other"},
+		{"big", 200, "big"},
+		{"big", 200, "big"},
+		{"big", 200, "big"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/orders", nil)
+			if tc.tenant != "" {
+				r.Header.Set("X-Tenant", tc.tenant)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.status == 200 && w.Body.String() != tc.body {
+				t.Errorf("Unexpected tenant sent: got %q want %q", w.Body.String(), tc.body)
+			}
+			if tc.status == 429 && w.Header().Get("Retry-After") != "10" {
+				t.Errorf("Unexpected Retry-After: %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestTenantRateLimit(t *testing.T) {
+	tn := &tenancy{cfg: TenancyConfig{Limit: TenantLimit{Rate: 2, Burst: 2}}, buckets: newLRU(maxTenantBuckets)}
+	now := time.Now()
+
+	cases := []struct {
+		at      time.Duration
+		allowed bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false},
+		{500 * time.Millisecond, true},
+		{500 * time.Millisecond, false},
+		{10 * time.Second, true},
+		{10 * time.Second, true},
+		{10 * time.Second, false},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			if _, ok := tn.allow("acme", now.Add(tc.at)); ok != tc.allowed {
+				t.Errorf("Unexpected allow: got %v want %v", ok, tc.allowed)
+			}
+		})
+	}
+
+	tn.buckets.now = func() time.Time { return now.Add(time.Minute) }
+	if _, ok := tn.buckets.get("acme"); ok {
+		t.Error("Refilled bucket kept")
+	}
+}
+
+func TestTenantBucketsBounded(t *testing.T) {
+	tn := &tenancy{cfg: TenancyConfig{Limit: TenantLimit{Rate: 1}}, buckets: newLRU(2)}
+	now := time.Now()
+	for _, tenant := range []string{"a", "b", "c", "d"} {
+		tn.allow(tenant, now)
+	}
+	if len(tn.buckets.entries) != 2 {
+		t.Errorf("Unexpected buckets: %v", len(tn.buckets.entries))
+	}
+}
+
+func TestTenantSharedRateLimit(t *testing.T) {
+	store := NewMemoryStore(100)
+	instances := []*tenancy{
+		{cfg: TenancyConfig{Limit: TenantLimit{Rate: 2, Burst: 2}, Store: store}},
+		{cfg: TenancyConfig{Limit: TenantLimit{Rate: 2, Burst: 2}, Store: store}},
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		instance int
+		at       time.Duration
+		allowed  bool
+		wait     time.Duration
+	}{
+		{0, 0, true, time.Second},
+		{1, 500 * time.Millisecond, true, 500 * time.Millisecond},
+		{0, 600 * time.Millisecond, false, 400 * time.Millisecond},
+		{1, time.Second, true, time.Second},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			wait, ok, err := instances[tc.instance].allowRequest(context.Background(), "acme", now.Add(tc.at))
+			if err != nil || ok != tc.allowed || wait != tc.wait {
+				t.Errorf("Unexpected allow: got %v %v %v want %v %v", ok, wait, err, tc.allowed, tc.wait)
+			}
+		})
+	}
+}
+
+func TestWithTenancyNoResolver(t *testing.T) {
+	if _, err := New(":80", &mrpc.Service{}, WithTenancy(TenancyConfig{})); err != (FuncOptsError{ErrNoTenantResolver}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTenantTopics(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"orders", "orders.tenant-acme", "orders-v2.tenant-acme", "failing.tenant-acme"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			res := &mrpcproxy.Response{Code: 200, Msg: []byte(topic)}
+			if topic == "failing.tenant-acme" {
+				res = &mrpcproxy.Response{Code: 500}
+			}
+			msg, _ := json.Marshal(res)
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service, WithTenancy(TenancyConfig{
+		Resolver:      TenantFromHeader("X-Tenant"),
+		TopicTemplate: "{topic}.tenant-{tenant}",
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pxy.Requests = &MockLogger{}
+	pxy.Logger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/orders", Topic: "orders"},
+		Endpoint{Method: "GET", Path: "/v2/orders", Topic: "failing", Fallbacks: []string{"orders-v2"}},
+	)
+
+	cases := []struct {
+		path   string
+		tenant string
+		status int
+		topic  string
+	}{
+		{"/orders", "", 200, "orders"},
+		{"/orders", "acme", 200, "orders.tenant-acme"},
+		{"/orders", "acme.orders", 400, ""},
+		{"/orders", "*", 400, ""},
+		{"/v2/orders", "acme", 200, "orders-v2.tenant-acme"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.tenant != "" {
+				r.Header.Set("X-Tenant", tc.tenant)
+			}
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if tc.status == 200 && w.Body.String() != tc.topic {
+				t.Errorf("Unexpected topic: got %q want %q", w.Body.String(), tc.topic)
+			}
+		})
+	}
+
+	if _, err := New(":80", service, WithTenancy(TenancyConfig{
+		Resolver:      TenantFromHeader("X-Tenant"),
+		TopicTemplate: "tenant-{tenant}",
+	})); err != (FuncOptsError{ErrInvalidTopicTemplate}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+const (
+	defaultThrottleThreshold   = 0.5
+	defaultThrottleWindow      = 10 * time.Second
+	defaultThrottleMinRequests = 20
+	defaultThrottleBurst       = 10
+)
+
+// maxThrottleTopics bounds the tracked topics, which may be resolved from the
+// requests. The least recently used topics are dropped past it, and the
+// topics expire after two idle windows since their counters are reset then.
+const maxThrottleTopics = 10000
+
+var (
+	// ErrThrottled is returned for requests shed because their topic is
+	// failing.
+	ErrThrottled = errors.New("topic throttled")
+	// ErrInvalidThrottleThreshold is returned by WithThrottling for a
+	// threshold outside (0, 1].
+	ErrInvalidThrottleThreshold = errors.New("throttle threshold must be in (0, 1]")
+)
+
+// ThrottleConfig configures the adaptive throttling of the MRPC topics.
+type ThrottleConfig struct {
+	// Threshold is the ratio of failed calls of a topic over which its
+	// requests are throttled, 0.5 by default. Calls failing, timing out or
+	// answered with a server error are failed.
+	Threshold float64
+	// Window over which the failure ratio is measured, 10 seconds by
+	// default.
+	Window time.Duration
+	// MinRequests in the window before a topic is throttled, 20 by default.
+	MinRequests int64
+	// Burst is the capacity of the token bucket of the throttled topics, 10
+	// by default.
+	Burst float64
+}
+
+type throttle struct {
+	cfg    ThrottleConfig
+	now    func() time.Time
+	random func() float64
+
+	// Serializes the updates of the topics.
+	mu     sync.Mutex
+	topics *lru
+}
+
+// topicThrottle counts the calls of a topic in the current and the previous
+// window.
+type topicThrottle struct {
+	start                      time.Time
+	requests, failures         int64
+	prevRequests, prevFailures int64
+
+	tokens float64
+	filled time.Time
+	shed   int64
+}
+
+// WithThrottling sheds the MRPC calls of the topics failing more than the
+// threshold with 503 before they are published. The calls of a throttled
+// topic take a token from a bucket refilled at the rate of its successful
+// calls, so that the load follows what the backend still serves. Without a
+// token the calls are shed with the failure ratio as probability. Shed calls
+// fail over to the endpoint fallbacks and are counted in Throttled.
+func WithThrottling(cfg ThrottleConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.Threshold == 0 {
+			cfg.Threshold = defaultThrottleThreshold
+		}
+		if cfg.Threshold < 0 || cfg.Threshold > 1 {
+			return ErrInvalidThrottleThreshold
+		}
+		if cfg.Window == 0 {
+			cfg.Window = defaultThrottleWindow
+		}
+		if cfg.MinRequests == 0 {
+			cfg.MinRequests = defaultThrottleMinRequests
+		}
+		if cfg.Burst == 0 {
+			cfg.Burst = defaultThrottleBurst
+		}
+
+		pxy.throttle = &throttle{cfg: cfg, now: time.Now, random: rand.Float64, topics: newLRU(maxThrottleTopics)}
+		return nil
+	}
+}
+
+// Throttled returns the number of calls shed by the throttling, by topic.
+// Only the recently used topics are counted.
+func (pxy *Proxy) Throttled() map[string]int64 {
+	t := pxy.throttle
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	shed := map[string]int64{}
+	t.topics.each(func(topic string, v interface{}) {
+		if tt := v.(*topicThrottle); tt.shed > 0 {
+			shed[topic] = tt.shed
+		}
+	})
+	return shed
+}
+
+// topic returns the counters of topic, rotating its windows. t.mu must be
+// held.
+func (t *throttle) topic(topic string, now time.Time) *topicThrottle {
+	var tt *topicThrottle
+	if v, ok := t.topics.get(topic); ok {
+		tt = v.(*topicThrottle)
+	} else {
+		tt = &topicThrottle{start: now, tokens: t.cfg.Burst, filled: now}
+	}
+	t.topics.set(topic, tt, 2*t.cfg.Window)
+
+	if elapsed := now.Sub(tt.start); elapsed >= 2*t.cfg.Window {
+		tt.start = now
+		tt.requests, tt.failures, tt.prevRequests, tt.prevFailures = 0, 0, 0, 0
+	} else if elapsed >= t.cfg.Window {
+		tt.start = tt.start.Add(t.cfg.Window)
+		tt.prevRequests, tt.prevFailures = tt.requests, tt.failures
+		tt.requests, tt.failures = 0, 0
+	}
+	return tt
+}
+
+// allow reports whether a call to topic may be published.
+func (t *throttle) allow(topic string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	tt := t.topic(topic, now)
+
+	requests := tt.requests + tt.prevRequests
+	failures := tt.failures + tt.prevFailures
+	if requests < t.cfg.MinRequests || float64(failures) <= t.cfg.Threshold*float64(requests) {
+		tt.tokens, tt.filled = t.cfg.Burst, now
+		return true
+	}
+
+	// The bucket is refilled at the rate of the successful calls.
+	ratio := float64(failures) / float64(requests)
+	window := now.Sub(tt.start) + t.cfg.Window
+	rate := float64(requests-failures) / window.Seconds()
+	tt.tokens += rate * now.Sub(tt.filled).Seconds()
+	if tt.tokens > t.cfg.Burst {
+		tt.tokens = t.cfg.Burst
+	}
+	tt.filled = now
+
+	if tt.tokens >= 1 {
+		tt.tokens--
+		return true
+	}
+	if t.random() >= ratio {
+		return true
+	}
+
+	tt.shed++
+	return false
+}
+
+// record counts the outcome of a call to topic. Calls cancelled by the
+// client are not counted.
+func (t *throttle) record(topic string, res *mrpcproxy.Response, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tt := t.topic(topic, t.now())
+	tt.requests++
+	if err != nil || res.Timeout || res.Code >= http.StatusInternalServerError {
+		tt.failures++
+	}
+}
@@ -0,0 +1,144 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestThrottling(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 500})
+		w.Write(msg)
+	})
+	service.HandleFunc("b", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("b")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, err := New(":80", service, WithThrottling(ThrottleConfig{Window: time.Minute, MinRequests: 4, Burst: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	now := time.Now()
+	pxy.throttle.now = func() time.Time { return now }
+	pxy.throttle.random = func() float64 { return 0.5 }
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/a", Topic: "a"},
+		Endpoint{Method: "GET", Path: "/fallback", Topic: "a", Fallbacks: []string{"b"}},
+	)
+
+	serve := func(path string) int {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The first calls and the token of the bucket reach the service.
+	for i := 0; i < 5; i++ {
+		if code := serve("/a"); code != 500 {
+			t.Fatalf("Unexpected status of call %v: %v", i, code)
+		}
+	}
+	if code := serve("/a"); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected throttled status: %v", code)
+	}
+	if code := serve("/fallback"); code != 200 {
+		t.Errorf("Unexpected fallback status: %v", code)
+	}
+	if shed := pxy.Throttled(); !reflect.DeepEqual(shed, map[string]int64{"a": 2}) {
+		t.Errorf("Unexpected throttled calls: %v", shed)
+	}
+
+	// The failures age out of the window.
+	now = now.Add(2 * time.Minute)
+	if code := serve("/a"); code != 500 {
+		t.Errorf("Unexpected status after the window: %v", code)
+	}
+}
+
+func TestThrottleAllow(t *testing.T) {
+	cases := []struct {
+		requests, failures int64
+		random             float64
+		elapsed            time.Duration
+		allowed            bool
+	}{
+		{10, 5, 0, 0, true},
+		{10, 6, 0.7, 0, true},
+		{10, 6, 0.5, 0, false},
+		{3, 3, 0, 0, true},
+		// 4 successes in 45 seconds refill a token in about 11 seconds.
+		{10, 6, 0.5, 15 * time.Second, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			now := time.Now()
+			th := &throttle{
+				cfg:    ThrottleConfig{Threshold: 0.5, Window: 30 * time.Second, MinRequests: 4, Burst: 1},
+				now:    func() time.Time { return now },
+				random: func() float64 { return tc.random },
+				topics: newLRU(maxThrottleTopics),
+			}
+			tt := th.topic("a", now)
+			tt.requests, tt.failures, tt.tokens = tc.requests, tc.failures, 0
+
+			now = now.Add(tc.elapsed)
+			if allowed := th.allow("a"); allowed != tc.allowed {
+				t.Errorf("Unexpected allow: got %v want %v", allowed, tc.allowed)
+			}
+		})
+	}
+}
+
+func TestThrottleConfigErrors(t *testing.T) {
+	for _, threshold := range []float64{-0.1, 1.5} {
+		if _, err := New(":80", &mrpc.Service{}, WithThrottling(ThrottleConfig{Threshold: threshold})); err != (FuncOptsError{ErrInvalidThrottleThreshold}) {
+			t.Errorf("Unexpected error for %v: %v", threshold, err)
+		}
+	}
+}
+
+func TestThrottleTopicsBound(t *testing.T) {
+	now := time.Now()
+	th := &throttle{
+		cfg:    ThrottleConfig{Threshold: 0.5, Window: time.Minute, MinRequests: 4, Burst: 1},
+		now:    func() time.Time { return now },
+		random: func() float64 { return 0 },
+		topics: newLRU(2),
+	}
+	th.topics.now = th.now
+
+	for _, topic := range []string{"a", "b", "c"} {
+		th.record(topic, &mrpcproxy.Response{Code: 200}, nil)
+	}
+	if _, ok := th.topics.get("a"); ok {
+		t.Error("Least recently used topic not dropped")
+	}
+	if n := th.topics.list.Len(); n != 2 {
+		t.Errorf("Unexpected topics %v", n)
+	}
+
+	// Idle topics expire after two windows.
+	now = now.Add(2*time.Minute + time.Second)
+	if _, ok := th.topics.get("c"); ok {
+		t.Error("Idle topic not expired")
+	}
+}
@@ -0,0 +1,140 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+const (
+	// defaultMaxHeaderTimeout caps TimeoutHeader overrides when MaxTimeout
+	// isn't set.
+	defaultMaxHeaderTimeout = 30 * time.Second
+
+	defaultTimeoutStatus = http.StatusGatewayTimeout
+)
+
+// TimeoutInfo describes a request the service didn't answer in time. It is
+// the data of the timeout body templates.
+type TimeoutInfo struct {
+	RequestID string
+	Topic     string
+	Timeout   time.Duration
+}
+
+// WithTimeoutResponse answers the requests the services don't answer in time
+// with status and the JSON body executed from the text/template body with a
+// TimeoutInfo, e.g. {"error":"timeout","requestId":"{{.RequestID}}"}. The
+// body is left empty when body is "". Endpoint.TimeoutStatus and
+// Endpoint.TimeoutBody override them.
+func WithTimeoutResponse(status int, body string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		tmpl, err := parseTimeoutBody(body)
+		if err != nil {
+			return err
+		}
+		pxy.timeoutStatus = status
+		pxy.timeoutBody = tmpl
+		return nil
+	}
+}
+
+// WithLegacyTimeoutStatus answers the requests the services don't answer in
+// time with 408, as the proxy did before 504 became the default.
+func WithLegacyTimeoutStatus() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.timeoutStatus = http.StatusRequestTimeout
+		return nil
+	}
+}
+
+// parseTimeoutBody parses a timeout body template, nil when body is empty.
+func parseTimeoutBody(body string) (*template.Template, error) {
+	if body == "" {
+		return nil, nil
+	}
+	return template.New("timeout").Option("missingkey=error").Parse(body)
+}
+
+// timeoutResponse returns the response to requests timing out, with the
+// status of the proxy.
+func (pxy *Proxy) timeoutResponse(id string) *mrpcproxy.Response {
+	status := pxy.timeoutStatus
+	if status == 0 {
+		status = defaultTimeoutStatus
+	}
+	return &mrpcproxy.Response{RequestID: id, Code: status, Timeout: true}
+}
+
+// renderTimeout sets the status and body of ep to res timing out after
+// timeout.
+func (pxy *Proxy) renderTimeout(res *mrpcproxy.Response, ep Endpoint, timeout time.Duration) error {
+	if ep.TimeoutStatus != 0 {
+		res.Code = ep.TimeoutStatus
+	}
+	tmpl := ep.timeoutBody
+	if tmpl == nil {
+		tmpl = pxy.timeoutBody
+	}
+	if tmpl == nil {
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, TimeoutInfo{res.RequestID, ep.Topic, timeout}); err != nil {
+		return err
+	}
+	res.Msg = body.Bytes()
+	if res.Headers == nil {
+		res.Headers = http.Header{}
+	}
+	res.Headers.Set("Content-Type", "application/json")
+	return nil
+}
+
+type timeoutKey struct{}
+
+// RequestTimeoutContext returns a context overriding the MRPC timeout of the
+// request it is attached to. Middleware can use it to set per request
+// timeouts.
+func RequestTimeoutContext(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// timeout returns the MRPC timeout for r. The most specific setting wins: the
+// context override, the TimeoutHeader of requests from trusted proxies, the
+// endpoint KeepAlive and finally the proxy Timeout. The result is capped at
+// MaxTimeout, and header overrides at 30 seconds when MaxTimeout isn't set.
+func (pxy *Proxy) timeout(r *http.Request, ep Endpoint) time.Duration {
+	pxy.configMu.RLock()
+	defer pxy.configMu.RUnlock()
+
+	timeout := pxy.Timeout
+	if ep.KeepAlive > 0 {
+		timeout = time.Duration(ep.KeepAlive) * time.Millisecond
+	}
+
+	if pxy.TimeoutHeader != "" && pxy.fromTrustedProxy(r) {
+		if ms, err := strconv.Atoi(r.Header.Get(pxy.TimeoutHeader)); err == nil && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+			if pxy.MaxTimeout <= 0 && timeout > defaultMaxHeaderTimeout {
+				timeout = defaultMaxHeaderTimeout
+			}
+		}
+	}
+
+	if d, ok := r.Context().Value(timeoutKey{}).(time.Duration); ok && d > 0 {
+		timeout = d
+	}
+
+	if pxy.MaxTimeout > 0 && timeout > pxy.MaxTimeout {
+		timeout = pxy.MaxTimeout
+	}
+
+	return timeout
+}
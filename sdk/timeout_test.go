@@ -0,0 +1,120 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestTimeout(t *testing.T) {
+	cases := []struct {
+		header     string
+		trusted    bool
+		maxTimeout time.Duration
+		keepAlive  int
+		reqHeader  string
+		ctx        time.Duration
+		timeout    time.Duration
+	}{
+		{timeout: time.Second},
+		{keepAlive: 200, timeout: 200 * time.Millisecond},
+		{keepAlive: 200, reqHeader: "300", timeout: 200 * time.Millisecond},
+		{header: "X-Timeout", trusted: true, keepAlive: 200, reqHeader: "300", timeout: 300 * time.Millisecond},
+		{header: "X-Timeout", keepAlive: 200, reqHeader: "300", timeout: 200 * time.Millisecond},
+		{header: "X-Timeout", trusted: true, reqHeader: "invalid", timeout: time.Second},
+		{header: "X-Timeout", trusted: true, reqHeader: "300", ctx: 400 * time.Millisecond, timeout: 400 * time.Millisecond},
+		{header: "X-Timeout", trusted: true, reqHeader: "5000", maxTimeout: 2 * time.Second, timeout: 2 * time.Second},
+		{header: "X-Timeout", trusted: true, reqHeader: "60000", timeout: 30 * time.Second},
+		{keepAlive: 5000, maxTimeout: 2 * time.Second, timeout: 2 * time.Second},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			service, _ := mrpc.NewService(mem.New())
+			var opts []func(*Proxy) error
+			if tc.trusted {
+				opts = append(opts, WithTrustedProxies(TrustedProxies{CIDRs: []string{"192.0.2.1"}}))
+			}
+			pxy, _ := New(":80", service, opts...)
+			pxy.TimeoutHeader = tc.header
+			pxy.MaxTimeout = tc.maxTimeout
+
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("X-Timeout", tc.reqHeader)
+			if tc.ctx > 0 {
+				r = r.WithContext(RequestTimeoutContext(r.Context(), tc.ctx))
+			}
+
+			if timeout := pxy.timeout(r, Endpoint{KeepAlive: tc.keepAlive}); timeout != tc.timeout {
+				t.Errorf("Unexpected timeout: got %v want %v", timeout, tc.timeout)
+			}
+		})
+	}
+}
+
+func TestTimeoutResponse(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	cases := []struct {
+		opts   []func(*Proxy) error
+		ep     Endpoint
+		status int
+		body   string
+	}{
+		{nil, Endpoint{}, http.StatusGatewayTimeout, ""},
+		{[]func(*Proxy) error{WithLegacyTimeoutStatus()}, Endpoint{}, http.StatusRequestTimeout, ""},
+		{
+			[]func(*Proxy) error{WithTimeoutResponse(http.StatusServiceUnavailable, `{"id":"{{.RequestID}}","topic":"{{.Topic}}","timeout":"{{.Timeout}}"}`)},
+			Endpoint{},
+			http.StatusServiceUnavailable, `{"id":"uuid","topic":"slow","timeout":"10ms"}`,
+		},
+		{
+			[]func(*Proxy) error{WithTimeoutResponse(http.StatusServiceUnavailable, `{"error":"proxy"}`)},
+			Endpoint{TimeoutStatus: http.StatusGatewayTimeout, TimeoutBody: `{"error":"endpoint"}`},
+			http.StatusGatewayTimeout, `{"error":"endpoint"}`,
+		},
+		{[]func(*Proxy) error{WithLegacyTimeoutStatus()}, Endpoint{TimeoutStatus: 599}, 599, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, err := New(":80", service, tc.opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			pxy.GetID = func() string { return "uuid" }
+			pxy.Timeout = 10 * time.Millisecond
+			pxy.Logger = &MockLogger{}
+			pxy.Requests = &MockLogger{}
+			ep := tc.ep
+			ep.Method, ep.Path, ep.Topic = "GET", "/slow", "slow"
+			if err := pxy.Handle(ep); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			r, _ := http.NewRequest("GET", "/slow", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: got %v %q want %v %q", w.Code, w.Body.String(), tc.status, tc.body)
+			}
+			if tc.body != "" && w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Unexpected Content-Type: %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	if _, err := New(":80", service, WithTimeoutResponse(504, "{{")); err == nil {
+		t.Error("Invalid template accepted")
+	}
+}
@@ -0,0 +1,166 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Phases of the timed requests.
+const (
+	phaseRouting = iota
+	phaseRead
+	phaseMRPC
+	phaseTransform
+	phaseWrite
+	numPhases
+)
+
+// phaseNames are the Server-Timing metric names of the phases.
+var phaseNames = [numPhases]string{"routing", "read", "mrpc", "transform", "write"}
+
+// Timings are the time spent by a request in each phase.
+type Timings struct {
+	// Routing is the time until the endpoint handler ran.
+	Routing time.Duration
+	// Read is the time reading the request body.
+	Read time.Duration
+	// MRPC is the total time waiting for MRPC responses.
+	MRPC time.Duration
+	// Transform is the time in RequestTransformer and ResponseTransformer.
+	Transform time.Duration
+	// Write is the time writing the response body.
+	Write time.Duration
+	// Total is the time since the request was received.
+	Total time.Duration
+}
+
+// requestTimings accumulates the time spent by a request in each phase.
+type requestTimings struct {
+	start  time.Time
+	phases [numPhases]int64
+}
+
+type timingsKey struct{}
+
+// WithServerTiming sends the time spent by the endpoint requests routing,
+// reading the body, waiting for MRPC responses and in the transformers as a
+// Server-Timing header. The header is set before Handler runs, so the write
+// time isn't included.
+func WithServerTiming() func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.serverTiming = true
+		return nil
+	}
+}
+
+// TimingsFromContext returns the timings so far of the request ctx belongs
+// to, e.g. for Handler. The requests are timed only with WithServerTiming or
+// WithSlowRequestLog.
+func TimingsFromContext(ctx context.Context) (Timings, bool) {
+	t, ok := ctx.Value(timingsKey{}).(*requestTimings)
+	if !ok {
+		return Timings{}, false
+	}
+	return t.timings(), true
+}
+
+// timed reports whether the requests are timed.
+func (pxy *Proxy) timed() bool {
+	return pxy.slowLog != nil || pxy.serverTiming
+}
+
+// startTimings returns r timed from now when the requests are timed.
+func (pxy *Proxy) startTimings(r *http.Request) *http.Request {
+	if !pxy.timed() {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), timingsKey{}, &requestTimings{start: time.Now()}))
+}
+
+// addTiming adds d to the phase of the request ctx belongs to.
+func addTiming(ctx context.Context, phase int, d time.Duration) {
+	if t, ok := ctx.Value(timingsKey{}).(*requestTimings); ok {
+		atomic.AddInt64(&t.phases[phase], int64(d))
+	}
+}
+
+func (t *requestTimings) phase(phase int) time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.phases[phase]))
+}
+
+func (t *requestTimings) timings() Timings {
+	return Timings{
+		Routing:   t.phase(phaseRouting),
+		Read:      t.phase(phaseRead),
+		MRPC:      t.phase(phaseMRPC),
+		Transform: t.phase(phaseTransform),
+		Write:     t.phase(phaseWrite),
+		Total:     time.Since(t.start),
+	}
+}
+
+// setServerTiming sets the Server-Timing header of the response to r.
+func (pxy *Proxy) setServerTiming(w http.ResponseWriter, r *http.Request) {
+	if !pxy.serverTiming {
+		return
+	}
+	t, ok := r.Context().Value(timingsKey{}).(*requestTimings)
+	if !ok {
+		return
+	}
+
+	metrics := make([]string, 0, phaseWrite+1)
+	for phase := 0; phase < phaseWrite; phase++ {
+		metrics = append(metrics, serverTimingMetric(phaseNames[phase], t.phase(phase)))
+	}
+	metrics = append(metrics, serverTimingMetric("total", time.Since(t.start)))
+	w.Header().Set("Server-Timing", strings.Join(metrics, ", "))
+}
+
+func serverTimingMetric(name string, d time.Duration) string {
+	return fmt.Sprintf("%v;dur=%.3f", name, float64(d)/float64(time.Millisecond))
+}
+
+// timeRequests times the requests to ep, logs the slow ones and counts
+// their latency.
+func (pxy *Proxy) timeRequests(ep Endpoint, h httprouter.Handle) httprouter.Handle {
+	if !pxy.timed() {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		t, ok := r.Context().Value(timingsKey{}).(*requestTimings)
+		if !ok {
+			// Served without ServeHTTP, e.g. by EndpointHandler.
+			r = pxy.startTimings(r)
+			t = r.Context().Value(timingsKey{}).(*requestTimings)
+		}
+		addTiming(r.Context(), phaseRouting, time.Since(t.start))
+
+		tw := &timingWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}, timings: t}
+		h(tw, r, p)
+
+		if pxy.slowLog != nil {
+			pxy.logSlowRequest(r, ep, tw.status, t.timings())
+		}
+	}
+}
+
+// timingWriter times the writes of the response.
+type timingWriter struct {
+	statusWriter
+	timings *requestTimings
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := w.statusWriter.Write(b)
+	atomic.AddInt64(&w.timings.phases[phaseWrite], int64(time.Since(start)))
+	return n, err
+}
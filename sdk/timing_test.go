@@ -0,0 +1,69 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestServerTiming(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("timed", func(w mrpc.TopicWriter, data []byte) {
+		time.Sleep(2 * time.Millisecond)
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	header := regexp.MustCompile(`^routing;dur=\d+\.\d{3}, read;dur=\d+\.\d{3}, mrpc;dur=\d+\.\d{3}, transform;dur=\d+\.\d{3}, total;dur=\d+\.\d{3}$`)
+
+	cases := []struct {
+		opts  []func(*Proxy) error
+		timed bool
+		sent  bool
+	}{
+		{nil, false, false},
+		{[]func(*Proxy) error{WithServerTiming()}, true, true},
+		{[]func(*Proxy) error{WithSlowRequestLog(time.Hour)}, true, false},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", service, tc.opts...)
+			pxy.Requests = &MockLogger{}
+
+			var timings Timings
+			var timed bool
+			pxy.Handler = func(w http.ResponseWriter, r *http.Request, res *mrpcproxy.Response) {
+				timings, timed = TimingsFromContext(r.Context())
+				w.WriteHeader(res.Code)
+			}
+			pxy.Handle(Endpoint{Method: "POST", Path: "/timed", Topic: "timed"})
+
+			r, _ := http.NewRequest("POST", "/timed", nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 {
+				t.Fatalf("Unexpected status: %v", w.Code)
+			}
+			if timed != tc.timed || tc.timed && (timings.MRPC < 2*time.Millisecond || timings.Total < timings.MRPC) {
+				t.Errorf("Unexpected timings: %+v timed %v", timings, timed)
+			}
+			st := w.Header().Get("Server-Timing")
+			if tc.sent != (st != "") || tc.sent && !header.MatchString(st) {
+				t.Errorf("Unexpected Server-Timing header: %q", st)
+			}
+		})
+	}
+}
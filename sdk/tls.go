@@ -0,0 +1,90 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/miracl/mrpcproxy"
+)
+
+var (
+	// ErrNoClientCAs is returned when client certificate authentication is
+	// enabled without CA certificates.
+	ErrNoClientCAs = errors.New("no client CA certificates")
+)
+
+// WithTLS makes Serve listen for HTTPS with the given certificate and key files.
+func WithTLS(certFile, keyFile string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.certFile = certFile
+		pxy.keyFile = keyFile
+		return nil
+	}
+}
+
+// WithClientCertAuth requires clients to present a certificate signed by one of
+// the CAs in the pool. The verified certificate identity is forwarded in
+// mrpcproxy.Request.ClientCert.
+//
+// It must be combined with WithTLS.
+func WithClientCertAuth(cas *x509.CertPool) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cas == nil {
+			return ErrNoClientCAs
+		}
+
+		if pxy.http.TLSConfig == nil {
+			pxy.http.TLSConfig = &tls.Config{}
+		}
+		pxy.http.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		pxy.http.TLSConfig.ClientCAs = cas
+		return nil
+	}
+}
+
+// LoadCertPool reads PEM encoded certificates from files into a new pool.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", f)
+		}
+	}
+
+	return pool, nil
+}
+
+// clientCert returns the identity of the verified client certificate of r or
+// nil when there is none.
+func clientCert(r *http.Request) *mrpcproxy.ClientCert {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	cc := &mrpcproxy.ClientCert{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+	for _, u := range cert.URIs {
+		cc.URIs = append(cc.URIs, u.String())
+	}
+
+	return cc
+}
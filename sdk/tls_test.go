@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestWithClientCertAuth(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	cas := x509.NewCertPool()
+	pxy, err := New(":443", service, WithTLS("cert.pem", "key.pem"), WithClientCertAuth(cas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pxy.certFile != "cert.pem" || pxy.keyFile != "key.pem" {
+		t.Errorf("Unexpected TLS files: %v %v", pxy.certFile, pxy.keyFile)
+	}
+
+	if pxy.http.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert || pxy.http.TLSConfig.ClientCAs != cas {
+		t.Errorf("Client certificates not required")
+	}
+
+	if _, err := New(":443", service, WithClientCertAuth(nil)); err != (FuncOptsError{ErrNoClientCAs}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestClientCert(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	u, _ := url.Parse("spiffe://example/client")
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "client"},
+		DNSNames:       []string{"client.example"},
+		EmailAddresses: []string{"client@example"},
+		URIs:           []*url.URL{u},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	cert, _ := x509.ParseCertificate(der)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	if cc := clientCert(r); cc != nil {
+		t.Errorf("Unexpected client cert on plain request: %v", cc)
+	}
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	cc := clientCert(r)
+	if cc == nil {
+		t.Fatal("Client cert not extracted")
+	}
+
+	if cc.Subject != "CN=client" || cc.Issuer != "CN=client" || cc.SerialNumber != "42" || len(cc.Fingerprint) != 64 {
+		t.Errorf("Unexpected client cert: %+v", cc)
+	}
+
+	if !reflect.DeepEqual(cc.DNSNames, tmpl.DNSNames) ||
+		!reflect.DeepEqual(cc.EmailAddresses, tmpl.EmailAddresses) ||
+		!reflect.DeepEqual(cc.URIs, []string{"spiffe://example/client"}) {
+		t.Errorf("Unexpected client cert SANs: %+v", cc)
+	}
+}
@@ -0,0 +1,63 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrTopicFieldMissing is returned for requests whose JSON body lacks a
+	// topic field of the endpoint.
+	ErrTopicFieldMissing = errors.New("topic field missing")
+	// ErrInvalidTopicField is returned for requests whose body isn't a JSON
+	// object or whose topic field isn't a string or a number of up to 64
+	// letters, digits, - and _.
+	ErrInvalidTopicField = errors.New("invalid topic field")
+)
+
+// topicFieldParams returns the values of the fields of the JSON body of r as
+// params of the topic templates, keeping the body for the MRPC request.
+func topicFieldParams(r *http.Request, fields []string) (httprouter.Params, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, StatusError{http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidTopicField, err)}
+	}
+
+	params := make(httprouter.Params, len(fields))
+	for i, f := range fields {
+		v, ok := doc[f]
+		if !ok || v == nil {
+			return nil, StatusError{http.StatusBadRequest, fmt.Errorf("%w: %v", ErrTopicFieldMissing, f)}
+		}
+
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case json.Number:
+			s = v.String()
+		}
+		if s == "" || !validTopicSegment(s) {
+			return nil, StatusError{http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidTopicField, f)}
+		}
+		params[i] = httprouter.Param{Key: f, Value: s}
+	}
+	return params, nil
+}
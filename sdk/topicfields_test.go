@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestTopicFields(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"refund", "charge", "v2", "acme-refund"} {
+		topic := topic
+		service.HandleFunc("payments."+topic, func(w mrpc.TopicWriter, data []byte) {
+			req := &mrpcproxy.Request{}
+			json.Unmarshal(data, req)
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic + "|" + string(req.Msg))})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "POST", Path: "/payments", Topic: "payments.{{.type}}", TopicFields: []string{"type"}},
+		Endpoint{Method: "POST", Path: "/tenants/:tenant/payments", Topic: "payments.{{.tenant}}-{{.type}}", TopicFields: []string{"type", "tenant"}},
+	)
+
+	cases := []struct {
+		path   string
+		body   string
+		status int
+		res    string
+	}{
+		{"/payments", `{"type": "refund", "amount": 1}`, 200, `refund|{"type": "refund", "amount": 1}`},
+		{"/payments", `{"type": "charge"}`, 200, `charge|{"type": "charge"}`},
+		{"/payments", `{"type": "v2"}`, 200, `v2|{"type": "v2"}`},
+		{"/payments", `{"amount": 1}`, 400, ""},
+		{"/payments", `{"type": null}`, 400, ""},
+		{"/payments", `{"type": "refund.admin"}`, 400, ""},
+		{"/payments", `{"type": ""}`, 400, ""},
+		{"/payments", `{"type": {"a": 1}}`, 400, ""},
+		{"/payments", `["refund"]`, 400, ""},
+		{"/payments", ``, 400, ""},
+		{"/tenants/acme/payments", `{"type": "refund", "tenant": "other"}`, 200, `acme-refund|{"type": "refund", "tenant": "other"}`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status || tc.status == 200 && w.Body.String() != tc.res {
+				t.Errorf("Unexpected response: got %v %q want %v %q", w.Code, w.Body.String(), tc.status, tc.res)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package sdk
+
+// WithTopicPrefix prepends prefix, e.g. "staging.", to the topics of the
+// endpoints, so that environments can share a broker. Endpoints opt out
+// with NoTopicPrefix.
+func WithTopicPrefix(prefix string) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		pxy.topicPrefix = prefix
+		return nil
+	}
+}
+
+// prefixTopics returns ep with the topic prefix applied to all its topics.
+func (pxy *Proxy) prefixTopics(ep Endpoint) Endpoint {
+	prefix := pxy.topicPrefix
+	if prefix == "" || ep.NoTopicPrefix {
+		return ep
+	}
+
+	if ep.Topic != "" {
+		ep.Topic = prefix + ep.Topic
+	}
+	ep.Topics = prefixAll(prefix, ep.Topics)
+	ep.Fallbacks = prefixAll(prefix, ep.Fallbacks)
+	if ep.Shadow != "" {
+		ep.Shadow = prefix + ep.Shadow
+	}
+	if ep.Canary != nil {
+		canary := *ep.Canary
+		canary.Topic = prefix + canary.Topic
+		ep.Canary = &canary
+	}
+	if len(ep.APIVersions) > 0 {
+		versions := make([]APIVersion, len(ep.APIVersions))
+		for i, v := range ep.APIVersions {
+			v.Topic = prefix + v.Topic
+			versions[i] = v
+		}
+		ep.APIVersions = versions
+	}
+	return ep
+}
+
+func prefixAll(prefix string, topics []string) []string {
+	if topics == nil {
+		return nil
+	}
+
+	prefixed := make([]string, len(topics))
+	for i, topic := range topics {
+		prefixed[i] = prefix + topic
+	}
+	return prefixed
+}
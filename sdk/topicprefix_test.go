@@ -0,0 +1,90 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestTopicPrefix(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"a", "staging.a", "staging.b"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service, WithTopicPrefix("staging."))
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	if err := pxy.Handle(
+		Endpoint{Method: "GET", Path: "/a", Topic: "a"},
+		Endpoint{Method: "GET", Path: "/shared", Topic: "a", NoTopicPrefix: true},
+		Endpoint{Method: "GET", Path: "/fallback", Topic: "missing", Fallbacks: []string{"b"}, KeepAlive: 10},
+		Endpoint{Method: "GET", Path: "/t/:name", Topic: "{{.name}}"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/a", "staging.a"},
+		{"/shared", "a"},
+		{"/fallback", "staging.b"},
+		{"/t/b", "staging.b"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != 200 || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: %v %q; expected %q", w.Code, w.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func TestPrefixTopics(t *testing.T) {
+	pxy := &Proxy{topicPrefix: "p."}
+	ep := Endpoint{
+		Topic:       "a",
+		Topics:      []string{"b", "c"},
+		Shadow:      "d",
+		Canary:      &Canary{Topic: "e"},
+		APIVersions: []APIVersion{{Version: "v1", Topic: "f"}},
+	}
+
+	prefixed := pxy.prefixTopics(ep)
+	expected := Endpoint{
+		Topic:       "p.a",
+		Topics:      []string{"p.b", "p.c"},
+		Shadow:      "p.d",
+		Canary:      &Canary{Topic: "p.e"},
+		APIVersions: []APIVersion{{Version: "v1", Topic: "p.f"}},
+	}
+	if !reflect.DeepEqual(prefixed, expected) {
+		t.Errorf("Unexpected endpoint %+v; expected %+v", prefixed, expected)
+	}
+	if ep.Canary.Topic != "e" || ep.APIVersions[0].Topic != "f" {
+		t.Errorf("Endpoint modified: %+v", ep)
+	}
+}
@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+)
+
+var (
+	// ErrInvalidTopicParam is returned for requests whose params substituted
+	// into a {name} topic placeholder are empty or contain topic separators
+	// or wildcards.
+	ErrInvalidTopicParam = errors.New("invalid topic param")
+)
+
+var topicFuncs = template.FuncMap{"topicParam": topicParam}
+
+// parseTopic parses a topic template. Besides the template syntax, {name}
+// placeholders are substituted with the param name, e.g. users.{id}.profile
+// routes the requests to the topic of each user.
+func parseTopic(topic string) (*template.Template, error) {
+	return template.New("topic").Funcs(topicFuncs).Parse(expandPlaceholders(topic))
+}
+
+// expandPlaceholders rewrites the {name} placeholders of topic as template
+// actions, leaving the {{ }} actions as they are.
+func expandPlaceholders(topic string) string {
+	var b strings.Builder
+	for i := 0; i < len(topic); i++ {
+		if topic[i] == '{' {
+			if strings.HasPrefix(topic[i:], "{{") {
+				end := strings.Index(topic[i:], "}}")
+				if end < 0 {
+					b.WriteString(topic[i:])
+					break
+				}
+				b.WriteString(topic[i : i+end+2])
+				i += end + 1
+				continue
+			}
+			if end := strings.IndexByte(topic[i:], '}'); end > 1 && isParamName(topic[i+1:i+end]) {
+				b.WriteString(`{{topicParam . "` + topic[i+1:i+end] + `"}}`)
+				i += end
+				continue
+			}
+		}
+		b.WriteByte(topic[i])
+	}
+	return b.String()
+}
+
+func isParamName(s string) bool {
+	for _, c := range s {
+		if c != '_' && c != '-' && (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// topicParam returns the param name, which must be a single topic token.
+func topicParam(params map[string]string, name string) (string, error) {
+	v := params[name]
+	if v == "" || strings.ContainsAny(v, ".*> \t\r\n") {
+		return "", ErrInvalidTopicParam
+	}
+	return v, nil
+}
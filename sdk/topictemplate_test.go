@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	cases := map[string]string{
+		"users.{id}.profile": `users.{{topicParam . "id"}}.profile`,
+		"{tenant}.{user_id}": `{{topicParam . "tenant"}}.{{topicParam . "user_id"}}`,
+		"users.{{.id}}":      "users.{{.id}}",
+		"{{.a}}.{b}":         `{{.a}}.{{topicParam . "b"}}`,
+		"users.{}":           "users.{}",
+		"users.{a b}":        "users.{a b}",
+		"users.{{.id":        "users.{{.id",
+		"users.{id":          "users.{id",
+		"plain":              "plain",
+	}
+
+	for topic, expected := range cases {
+		if got := expandPlaceholders(topic); got != expected {
+			t.Errorf("Unexpected template of %q: got %q want %q", topic, got, expected)
+		}
+	}
+}
+
+func TestTopicPlaceholders(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	for _, topic := range []string{"users.1.profile", "users.2.posts.3"} {
+		topic := topic
+		service.HandleFunc(topic, func(w mrpc.TopicWriter, data []byte) {
+			msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte(topic)})
+			w.Write(msg)
+		})
+	}
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	pxy, _ := New(":80", service)
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Handle(
+		Endpoint{Method: "GET", Path: "/users/:id", Topic: "users.{id}.profile"},
+		Endpoint{Method: "GET", Path: "/users/:id/posts/:post", Topic: "users.{id}.posts.{post}"},
+	)
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/users/1", 200, "users.1.profile"},
+		{"/users/2/posts/3", 200, "users.2.posts.3"},
+		{"/users/1.admin", http.StatusBadRequest, ""},
+		{"/users/*", http.StatusBadRequest, ""},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest("GET", tc.path, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status || w.Body.String() != tc.body {
+				t.Errorf("Unexpected response: %v %q; expected %v %q", w.Code, w.Body.String(), tc.status, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTransportInterval = 5 * time.Second
+)
+
+var (
+	// ErrTransportDown is returned for MRPC calls while the transport watcher
+	// reports the transport disconnected.
+	ErrTransportDown = errors.New("MRPC transport is down")
+	// ErrNoTransportCheck is returned by WithTransportWatcher without a probe
+	// topic or a check.
+	ErrNoTransportCheck = errors.New("transport watcher requires a probe topic or a check")
+)
+
+// TransportConfig configures the watcher of the MRPC transport.
+type TransportConfig struct {
+	// ProbeTopic is requested on every check. Any reply within ProbeTimeout
+	// means the transport is connected.
+	ProbeTopic   string
+	ProbeTimeout time.Duration
+	// Check reports the connectivity of the transport, e.g. from the state
+	// of the transport client. It is used instead of the probe when set.
+	Check func(ctx context.Context) error
+	// Interval between the checks, 5 seconds by default.
+	Interval time.Duration
+
+	// OnChange is called with the new status when the transport disconnects
+	// or reconnects.
+	OnChange func(TransportStatus)
+}
+
+// TransportStatus is the state of the MRPC transport seen by the watcher.
+type TransportStatus struct {
+	Connected bool `json:"connected"`
+	// Since is when the transport last connected or disconnected.
+	Since time.Time `json:"since"`
+	// Error of the last check while disconnected.
+	Error string `json:"error,omitempty"`
+}
+
+type transportWatcher struct {
+	cfg TransportConfig
+
+	mu     sync.RWMutex
+	status TransportStatus
+}
+
+// WithTransportWatcher checks the MRPC transport every interval. While it is
+// disconnected the MRPC calls fail with 503 instead of waiting for their
+// timeout, and the readiness check fails. The transport is assumed connected
+// until the first check fails.
+func WithTransportWatcher(cfg TransportConfig) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		if cfg.ProbeTopic == "" && cfg.Check == nil {
+			return ErrNoTransportCheck
+		}
+		if cfg.ProbeTimeout == 0 {
+			cfg.ProbeTimeout = defaultProbeTimeout
+		}
+		if cfg.Interval == 0 {
+			cfg.Interval = defaultTransportInterval
+		}
+		if cfg.Check == nil {
+			topic, timeout := cfg.ProbeTopic, cfg.ProbeTimeout
+			cfg.Check = func(ctx context.Context) error {
+				return pxy.probe(ctx, topic, timeout)
+			}
+		}
+
+		tw := &transportWatcher{cfg: cfg, status: TransportStatus{Connected: true, Since: time.Now()}}
+		go pxy.watchTransport(tw)
+
+		pxy.transport = tw
+		return nil
+	}
+}
+
+// TransportStatus returns the state of the MRPC transport. The transport is
+// reported connected without WithTransportWatcher.
+func (pxy *Proxy) TransportStatus() TransportStatus {
+	tw := pxy.transport
+	if tw == nil {
+		return TransportStatus{Connected: true}
+	}
+
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	return tw.status
+}
+
+// transportDown reports whether the watcher saw the transport disconnect.
+func (pxy *Proxy) transportDown() bool {
+	return pxy.transport != nil && !pxy.TransportStatus().Connected
+}
+
+// watchTransport checks the transport until the proxy shuts down.
+func (pxy *Proxy) watchTransport(tw *transportWatcher) {
+	t := time.NewTicker(tw.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		pxy.checkTransport(tw)
+
+		select {
+		case <-t.C:
+		case <-pxy.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkTransport runs the check and reports the status changes.
+func (pxy *Proxy) checkTransport(tw *transportWatcher) {
+	err := tw.cfg.Check(pxy.ctx)
+	if err != nil && pxy.ctx.Err() != nil {
+		return
+	}
+
+	tw.mu.Lock()
+	if (err == nil) == tw.status.Connected {
+		if err != nil {
+			tw.status.Error = err.Error()
+		}
+		tw.mu.Unlock()
+		return
+	}
+	tw.status = TransportStatus{Connected: err == nil, Since: time.Now()}
+	if err != nil {
+		tw.status.Error = err.Error()
+	}
+	status := tw.status
+	tw.mu.Unlock()
+
+	if status.Connected {
+		pxy.Logger.Printf("MRPC transport reconnected")
+	} else {
+		pxy.Logger.Printf("MRPC transport disconnected: %v", status.Error)
+	}
+	if tw.cfg.OnChange != nil {
+		tw.cfg.OnChange(status)
+	}
+}
+
+// probe requests topic and waits for any reply up to timeout.
+func (pxy *Proxy) probe(ctx context.Context, topic string, timeout time.Duration) error {
+	ping, err := json.Marshal(pxy.NewRequest(topic, "PING"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, err = pxy.MRPCService.Request(ctx, topic, ping)
+	return err
+}
@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+	"github.com/miracl/mrpcproxy"
+)
+
+func TestTransportWatcher(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	service.HandleFunc("a", func(w mrpc.TopicWriter, data []byte) {
+		msg, _ := json.Marshal(&mrpcproxy.Response{Code: 200, Msg: []byte("a")})
+		w.Write(msg)
+	})
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	var down int32
+	changes := make(chan TransportStatus, 10)
+	pxy, err := New(":80", service, WithTransportWatcher(TransportConfig{
+		Check: func(ctx context.Context) error {
+			if atomic.LoadInt32(&down) == 1 {
+				return errors.New("disconnected")
+			}
+			return nil
+		},
+		Interval: time.Millisecond,
+		OnChange: func(s TransportStatus) { changes <- s },
+	}), WithHealthChecks(HealthConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pxy.Stop(context.Background())
+	pxy.Logger = &MockLogger{}
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	pxy.Timeout = time.Minute
+	pxy.Handle(Endpoint{Method: "GET", Path: "/a", Topic: "a"})
+
+	serve := func(path string) int {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		pxy.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("/a"); code != 200 {
+		t.Fatalf("Unexpected status: %v", code)
+	}
+
+	atomic.StoreInt32(&down, 1)
+	select {
+	case s := <-changes:
+		if s.Connected || s.Error != "disconnected" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect not reported")
+	}
+	if s := pxy.TransportStatus(); s.Connected {
+		t.Errorf("Unexpected transport status: %+v", s)
+	}
+	if code := serve("/a"); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status while down: %v", code)
+	}
+	if code := serve("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected readiness while down: %v", code)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	select {
+	case s := <-changes:
+		if !s.Connected || s.Error != "" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Reconnect not reported")
+	}
+	if code := serve("/a"); code != 200 {
+		t.Errorf("Unexpected status after reconnect: %v", code)
+	}
+}
+
+func TestTransportWatcherProbe(t *testing.T) {
+	service, _ := mrpc.NewService(mem.New())
+	go service.Serve()
+	defer service.Stop(nil)
+	time.Sleep(1 * time.Millisecond)
+
+	changes := make(chan TransportStatus, 1)
+	pxy, err := New(":80", service, WithTransportWatcher(TransportConfig{
+		ProbeTopic:   "missing",
+		ProbeTimeout: time.Millisecond,
+		Interval:     time.Hour,
+		OnChange:     func(s TransportStatus) { changes <- s },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pxy.Stop(context.Background())
+
+	select {
+	case s := <-changes:
+		if s.Connected {
+			t.Errorf("Unexpected status: %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect not reported")
+	}
+}
+
+func TestTransportWatcherConfig(t *testing.T) {
+	if _, err := New(":80", &mrpc.Service{}, WithTransportWatcher(TransportConfig{})); err != (FuncOptsError{ErrNoTransportCheck}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	pxy, _ := New(":80", &mrpc.Service{})
+	if s := pxy.TransportStatus(); !s.Connected {
+		t.Errorf("Unexpected status without watcher: %+v", s)
+	}
+}
@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrInvalidUpstream is returned when the upstream of an endpoint is not
+	// an absolute URL.
+	ErrInvalidUpstream = errors.New("upstream must be an absolute URL")
+)
+
+// upstreamHandler forwards the requests of ep to the HTTP service at
+// ep.Upstream. The request path is appended to the upstream path and the
+// upstream has the MRPC timeout of the endpoint to answer.
+func (pxy *Proxy) upstreamHandler(ep Endpoint) (httprouter.Handle, error) {
+	u, err := url.Parse(ep.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, ErrInvalidUpstream
+	}
+	if err := ep.ResponseHeaders.validate(); err != nil {
+		return nil, err
+	}
+
+	rp := httputil.NewSingleHostReverseProxy(u)
+	rp.ModifyResponse = func(res *http.Response) error {
+		ep.ResponseHeaders.apply(res.Header)
+		return nil
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		printlnCtx(r.Context(), pxy.Debugger, err)
+		code := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+		pxy.writeError(w, r, code, err)
+	}
+	h := pxy.logStatic(rp)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx, cancel := context.WithTimeout(r.Context(), pxy.timeout(r, ep))
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}, nil
+}
@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.URL.RawQuery))
+	}))
+	defer upstream.Close()
+
+	service, _ := mrpc.NewService(mem.New())
+	pxy, _ := New(":80", service)
+	pxy.Requests = &MockLogger{}
+	pxy.Debugger = &MockLogger{}
+	err := pxy.Handle(
+		Endpoint{Method: "POST", Path: "/users/:id", Upstream: upstream.URL + "/v1"},
+		Endpoint{Method: "GET", Path: "/slow", Upstream: upstream.URL + "/v1", KeepAlive: 10},
+		Endpoint{Method: "GET", Path: "/down", Upstream: "http://127.0.0.1:1"},
+		Endpoint{Method: "GET", Path: "/hidden", Upstream: upstream.URL, ResponseHeaders: &HeaderPolicy{
+			Set: map[string]string{"X-Path": "hidden"},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, url string
+		status      int
+		path        string
+		body        string
+	}{
+		{"POST", "/users/1?a=b", http.StatusCreated, "/v1/users/1", "POST a=b"},
+		{"GET", "/slow", http.StatusGatewayTimeout, "", ""},
+		{"GET", "/down", http.StatusBadGateway, "", ""},
+		{"GET", "/hidden", http.StatusCreated, "hidden", "GET "},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, tc.url, nil)
+			w := httptest.NewRecorder()
+			pxy.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Unexpected status: got %v want %v", w.Code, tc.status)
+			}
+			if path := w.Header().Get("X-Path"); path != tc.path {
+				t.Errorf("Unexpected upstream path: got %q want %q", path, tc.path)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("Unexpected body: got %q want %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/x", Upstream: "/relative"}); err != ErrInvalidUpstream {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
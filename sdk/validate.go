@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// ErrNoMethod is returned by Handle for endpoints without method.
+	ErrNoMethod = errors.New("no method")
+	// ErrInvalidPath is returned by Handle for endpoint paths not starting
+	// with a slash.
+	ErrInvalidPath = errors.New("path must start with /")
+	// ErrNoTopic is returned by Handle for endpoints without topic, topics,
+	// API versions or upstream.
+	ErrNoTopic = errors.New("no topic")
+	// ErrDuplicateEndpoint is returned by Handle for endpoints with the
+	// method, host and path of another endpoint.
+	ErrDuplicateEndpoint = errors.New("duplicate endpoint")
+	// ErrRouteConflict is returned by Handle for endpoints whose path
+	// conflicts with the routes of the router, e.g. with different param
+	// names in the same segment.
+	ErrRouteConflict = errors.New("route conflict")
+)
+
+// EndpointError is returned by Handle for an invalid endpoint.
+type EndpointError struct {
+	Method string
+	Host   string
+	Path   string
+	Err    error
+}
+
+func (e EndpointError) Error() string {
+	return fmt.Sprintf("endpoint %v %v%v: %v", e.Method, e.Host, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e EndpointError) Unwrap() error {
+	return e.Err
+}
+
+func endpointError(ep Endpoint, err error) EndpointError {
+	return EndpointError{ep.Method, ep.Host, ep.Path, err}
+}
+
+// MustHandle is like Handle but panics when an endpoint is invalid, for
+// endpoints fixed at build time.
+func (pxy *Proxy) MustHandle(eps ...Endpoint) {
+	if err := pxy.Handle(eps...); err != nil {
+		panic(err)
+	}
+}
+
+// validateEndpoint checks ep on its own.
+func (pxy *Proxy) validateEndpoint(ep Endpoint) error {
+	switch {
+	case ep.Method == "":
+		return endpointError(ep, ErrNoMethod)
+	case !strings.HasPrefix(ep.Path, "/"):
+		return endpointError(ep, ErrInvalidPath)
+	case ep.Topic == "" && len(ep.Topics) == 0 && len(ep.APIVersions) == 0 && ep.Upstream == "":
+		return endpointError(ep, ErrNoTopic)
+	}
+
+	if _, _, err := compilePath(pxy.router, ep.Path); err != nil {
+		return endpointError(ep, err)
+	}
+	return nil
+}
+
+// validateEndpoints checks eps before they are added, so that the proxy
+// fails at startup rather than on requests. The routes are tried on scratch
+// routers, which panic on conflicts.
+func (pxy *Proxy) validateEndpoints(eps []Endpoint) (err error) {
+	seen := map[string]bool{}
+	key := func(ep Endpoint) string {
+		return ep.Method + " " + strings.ToLower(ep.Host) + ep.Path
+	}
+	for _, ep := range pxy.Eps {
+		seen[key(ep)] = true
+	}
+	for _, ep := range eps {
+		if err := pxy.validateEndpoint(ep); err != nil {
+			return err
+		}
+		if seen[key(ep)] {
+			return endpointError(ep, ErrDuplicateEndpoint)
+		}
+		seen[key(ep)] = true
+	}
+
+	var current Endpoint
+	defer func() {
+		if r := recover(); r != nil {
+			err = endpointError(current, fmt.Errorf("%w: %v", ErrRouteConflict, r))
+		}
+	}()
+
+	routers := map[string]Router{}
+	router := func(host string) Router {
+		host = strings.ToLower(host)
+		r, ok := routers[host]
+		if !ok {
+			r = pxy.makeRouter()
+			routers[host] = r
+		}
+		return r
+	}
+	noop := func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+
+	for _, rt := range pxy.routes {
+		pxy.handleRoute(router(rt.host), route{rt.host, rt.method, rt.path, noop, rt.endpoint})
+	}
+	for _, current = range eps {
+		pxy.handleRoute(router(current.Host), route{current.Host, current.Method, current.Path, noop, true})
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miracl/mrpc"
+)
+
+func TestHandleValidation(t *testing.T) {
+	cases := []struct {
+		eps []Endpoint
+		err error
+	}{
+		{[]Endpoint{{Path: "/a", Topic: "a"}}, ErrNoMethod},
+		{[]Endpoint{{Method: "GET", Path: "a", Topic: "a"}}, ErrInvalidPath},
+		{[]Endpoint{{Method: "GET", Path: "/a"}}, ErrNoTopic},
+		{[]Endpoint{{Method: "GET", Path: "/existing", Topic: "a"}}, ErrDuplicateEndpoint},
+		{[]Endpoint{{Method: "GET", Path: "/b", Topic: "a"}, {Method: "GET", Path: "/b", Topic: "b"}}, ErrDuplicateEndpoint},
+		{[]Endpoint{{Method: "GET", Path: "/users/:name", Topic: "a"}}, ErrRouteConflict},
+		{[]Endpoint{{Method: "GET", Path: "/users/*path", Topic: "a"}}, ErrRouteConflict},
+		{[]Endpoint{{Method: "GET", Path: "/healthz", Topic: "a"}}, ErrRouteConflict},
+		{[]Endpoint{{Method: "GET", Host: "api.example.com", Path: "/existing", Topic: "a"}}, nil},
+		{[]Endpoint{{Method: "POST", Path: "/users/:id", Topic: "a"}}, nil},
+		{[]Endpoint{{Method: "GET", Path: "/proxied", Upstream: "http://localhost"}}, nil},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			pxy, _ := New(":80", &mrpc.Service{}, WithHealthChecks(HealthConfig{}))
+			pxy.MustHandle(
+				Endpoint{Method: "GET", Path: "/existing", Topic: "a"},
+				Endpoint{Method: "GET", Path: "/users/:id", Topic: "a"},
+			)
+
+			err := pxy.Handle(tc.eps...)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Unexpected error: got %v want %v", err, tc.err)
+			}
+			if err == nil {
+				return
+			}
+
+			var epErr EndpointError
+			if !errors.As(err, &epErr) {
+				t.Errorf("Unexpected error type: %T", err)
+			}
+			if len(pxy.Eps) != 2 {
+				t.Errorf("Invalid endpoints added: %v", pxy.Eps)
+			}
+		})
+	}
+}
+
+func TestMustHandle(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("MustHandle didn't panic")
+		}
+	}()
+	pxy.MustHandle(Endpoint{Method: "GET", Path: "/a"})
+}
+
+func TestHandleHandlerError(t *testing.T) {
+	pxy, _ := New(":80", &mrpc.Service{})
+	routes := len(pxy.routes)
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/a", Topic: "a"}, Endpoint{Method: "GET", Path: "/b", Topic: "{{.x"}); err == nil {
+		t.Fatal("Invalid topic template accepted")
+	}
+	if len(pxy.Eps) != 0 || len(pxy.routes) != routes {
+		t.Errorf("Endpoints added: %v", pxy.Eps)
+	}
+
+	if err := pxy.Handle(Endpoint{Method: "GET", Path: "/b", Topic: "b"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/miracl/mrpc"
+)
+
+const (
+	defaultWebhookAttempts    = 5
+	defaultWebhookBackoff     = time.Second
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookContentType = "application/json"
+
+	// WebhookTopicHeader carries the MRPC topic of webhook deliveries.
+	WebhookTopicHeader = "X-Webhook-Topic"
+)
+
+var (
+	// ErrNoWebhookURL is returned when a webhook has no callback URL.
+	ErrNoWebhookURL = errors.New("webhook has no callback URL")
+)
+
+// Webhook delivers the messages published on an MRPC topic as HTTP POSTs to
+// callback URLs.
+type Webhook struct {
+	// Topic the proxy subscribes to.
+	Topic string
+	// URLs the messages are posted to.
+	URLs []string
+	// Secret signs the body with HMAC-SHA256, sent hex encoded in the
+	// signature header with the "sha256=" prefix. Unsigned when empty.
+	Secret []byte
+	// Header carrying the signature. Defaults to X-Signature.
+	Header string
+	// ContentType of the body. Defaults to application/json.
+	ContentType string
+	// MaxAttempts of each delivery. Defaults to 5.
+	MaxAttempts int
+	// Backoff before the first retry, doubled for each next one. Defaults to
+	// a second.
+	Backoff time.Duration
+	// Timeout of each attempt. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Client sending the callbacks. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// WithWebhooks subscribes to the topics of the webhooks and posts their
+// messages to the callback URLs. Deliveries answered with 5xx, 408 or 429 or
+// failing are retried with exponential backoff, and abandoned on shutdown.
+func WithWebhooks(hooks ...Webhook) func(*Proxy) error {
+	return func(pxy *Proxy) error {
+		for _, wh := range hooks {
+			if len(wh.URLs) == 0 {
+				return ErrNoWebhookURL
+			}
+			if wh.Header == "" {
+				wh.Header = DefaultSignatureHeader
+			}
+			if wh.ContentType == "" {
+				wh.ContentType = defaultWebhookContentType
+			}
+			if wh.MaxAttempts <= 0 {
+				wh.MaxAttempts = defaultWebhookAttempts
+			}
+			if wh.Backoff <= 0 {
+				wh.Backoff = defaultWebhookBackoff
+			}
+			if wh.Timeout <= 0 {
+				wh.Timeout = defaultWebhookTimeout
+			}
+			if wh.Client == nil {
+				wh.Client = http.DefaultClient
+			}
+
+			wh := wh
+			err := pxy.MRPCService.HandleFunc(wh.Topic, func(w mrpc.TopicWriter, data []byte) {
+				for _, u := range wh.URLs {
+					go pxy.deliverWebhook(wh, u, data)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// deliverWebhook posts data to url until it is accepted, the attempts run
+// out or the proxy shuts down.
+func (pxy *Proxy) deliverWebhook(wh Webhook, url string, data []byte) {
+	backoff := wh.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := wh.post(pxy.ctx, url, data)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == wh.MaxAttempts {
+			pxy.Logger.Printf("webhook %v: %v: giving up after %v attempts: %v", wh.Topic, url, attempt, err)
+			return
+		}
+		pxy.Debugger.Printf("webhook %v: %v: attempt %v: %v", wh.Topic, url, attempt, err)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-pxy.ctx.Done():
+			return
+		}
+	}
+}
+
+// post sends one attempt, reporting whether a failure is worth retrying.
+func (wh Webhook) post(ctx context.Context, url string, data []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, wh.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", wh.ContentType)
+	req.Header.Set(WebhookTopicHeader, wh.Topic)
+	if len(wh.Secret) > 0 {
+		mac := hmac.New(sha256.New, wh.Secret)
+		mac.Write(data)
+		req.Header.Set(wh.Header, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := wh.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode >= 500, res.StatusCode == http.StatusRequestTimeout, res.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("status %v", res.StatusCode)
+	default:
+		return false, fmt.Errorf("status %v", res.StatusCode)
+	}
+}
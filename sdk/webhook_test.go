@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miracl/mrpc"
+	"github.com/miracl/mrpc/transport/mem"
+)
+
+func TestWebhooks(t *testing.T) {
+	cases := []struct {
+		statuses []int
+		attempts int32
+	}{
+		{[]int{200}, 1},
+		{[]int{500, 429, 204}, 3},
+		{[]int{400}, 1},
+		{[]int{503, 503, 503, 503}, 3},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("Case%v", i), func(t *testing.T) {
+			var attempts int32
+			done := make(chan struct{}, len(tc.statuses))
+			callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				body, _ := ioutil.ReadAll(r.Body)
+				cfg := SignatureConfig{Prefix: "sha256=", Algorithm: SignatureSHA256, Encoding: SignatureHex, Header: DefaultSignatureHeader, Secrets: StaticSecrets([]byte("secret")), MaxBodySize: 1 << 10}
+				r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+				if _, err := cfg.verify(r); err != nil {
+					t.Errorf("Unexpected signature error: %v", err)
+				}
+				if string(body) != `{"event":"created"}` || r.Header.Get(WebhookTopicHeader) != "events" {
+					t.Errorf("Unexpected delivery: %s %v", body, r.Header)
+				}
+				w.WriteHeader(tc.statuses[n-1])
+				done <- struct{}{}
+			}))
+			defer callback.Close()
+
+			service, _ := mrpc.NewService(mem.New())
+			go service.Serve()
+			defer service.Stop(nil)
+
+			pxy, err := New(":80", service, WithWebhooks(Webhook{
+				Topic:       "events",
+				URLs:        []string{callback.URL},
+				Secret:      []byte("secret"),
+				MaxAttempts: 3,
+				Backoff:     time.Millisecond,
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pxy.Logger = &MockLogger{}
+			pxy.Debugger = &MockLogger{}
+			time.Sleep(1 * time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			service.Request(ctx, "events", []byte(`{"event":"created"}`))
+			cancel()
+
+			for j := int32(0); j < tc.attempts; j++ {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatalf("Missing attempt %v", j+1)
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if n := atomic.LoadInt32(&attempts); n != tc.attempts {
+				t.Errorf("Unexpected attempts: got %v want %v", n, tc.attempts)
+			}
+		})
+	}
+
+	service, _ := mrpc.NewService(mem.New())
+	if _, err := New(":80", service, WithWebhooks(Webhook{Topic: "events"})); err != (FuncOptsError{ErrNoWebhookURL}) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package mrpcproxy
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+// Signature algorithms of signed messages.
+const (
+	SignatureHS256 = "HS256"
+	SignatureEdDSA = "EdDSA"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signed message doesn't verify.
+	ErrInvalidSignature = errors.New("invalid message signature")
+	// ErrUnknownKey is returned when no key matches the key ID of an
+	// encrypted message, or the key ID and algorithm of a signed message.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrNoSigningKey is returned when a SigningKey has neither a secret nor a
+	// private key.
+	ErrNoSigningKey = errors.New("no signing key")
+)
+
+// SignedMessage is the envelope of payloads signed by the proxy. Services
+// verify it with Verify before decoding the payload.
+type SignedMessage struct {
+	KeyID     string `json:",omitempty"`
+	Algorithm string
+	Payload   []byte
+	Signature []byte
+}
+
+// SigningKey signs messages with HMAC-SHA256 when Secret is set, otherwise
+// with the ed25519 PrivateKey.
+type SigningKey struct {
+	// ID is sent with the messages so services can pick the key among the
+	// rotated ones.
+	ID         string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+}
+
+// VerificationKey verifies messages signed by the SigningKey with the same ID:
+// HMAC-SHA256 messages with Secret and ed25519 messages with PublicKey.
+type VerificationKey struct {
+	ID        string
+	Secret    []byte
+	PublicKey ed25519.PublicKey
+}
+
+// Sign returns the SignedMessage of payload.
+func Sign(payload []byte, key SigningKey) ([]byte, error) {
+	msg := SignedMessage{KeyID: key.ID, Payload: payload}
+	switch {
+	case key.Secret != nil:
+		msg.Algorithm = SignatureHS256
+		msg.Signature = hmacSHA256(key.Secret, payload)
+	case key.PrivateKey != nil:
+		msg.Algorithm = SignatureEdDSA
+		msg.Signature = ed25519.Sign(key.PrivateKey, payload)
+	default:
+		return nil, ErrNoSigningKey
+	}
+
+	return json.Marshal(msg)
+}
+
+// Verify returns the payload of the SignedMessage data if its signature
+// verifies with one of keys.
+func Verify(data []byte, keys ...VerificationKey) ([]byte, error) {
+	msg := SignedMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, key := range keys {
+		if key.ID != msg.KeyID {
+			continue
+		}
+
+		switch {
+		case msg.Algorithm == SignatureHS256 && key.Secret != nil:
+			found = true
+			if hmac.Equal(hmacSHA256(key.Secret, msg.Payload), msg.Signature) {
+				return msg.Payload, nil
+			}
+		case msg.Algorithm == SignatureEdDSA && key.PublicKey != nil:
+			found = true
+			if ed25519.Verify(key.PublicKey, msg.Payload, msg.Signature) {
+				return msg.Payload, nil
+			}
+		}
+	}
+
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return nil, ErrInvalidSignature
+}
+
+// VerifyRequest verifies the SignedMessage data and decodes its Request with
+// enc.
+func VerifyRequest(data []byte, enc Encoding, keys ...VerificationKey) (*Request, error) {
+	payload, err := Verify(data, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{}
+	if err := enc.UnmarshalRequest(payload, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func hmacSHA256(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}